@@ -15,8 +15,18 @@ import (
 	"shreshtasmg.in/jupyter/internal/config"
 	"shreshtasmg.in/jupyter/internal/contactus"
 	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/download"
 	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/history"
 	"shreshtasmg.in/jupyter/internal/httpserver"
+	"shreshtasmg.in/jupyter/internal/reconcile"
+	"shreshtasmg.in/jupyter/internal/retention"
+	"shreshtasmg.in/jupyter/internal/s3event"
+	"shreshtasmg.in/jupyter/internal/secrets"
+	"shreshtasmg.in/jupyter/internal/sharelink"
+	"shreshtasmg.in/jupyter/internal/storagemigration"
+	"shreshtasmg.in/jupyter/internal/tus"
 	"shreshtasmg.in/jupyter/internal/uploader"
 )
 
@@ -25,19 +35,81 @@ func main() {
 	config.LoadEnv()
 	cfg := config.Load()
 
+	keyring, err := secrets.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load credential keys: %v", err)
+	}
+	keyHasher, err := company.KeyHasherFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load API key pepper: %v", err)
+	}
+	tokenSigner, err := download.SignerFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load download token key: %v", err)
+	}
+
 	db := database.New(cfg.DSN)
+	uploaderRepo := uploader.NewRepository(db)
+	if err := uploaderRepo.PrepareUniqueNames(); err != nil {
+		log.Fatalf("failed to prepare storage pool names: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&company.Company{},
+		&company.APIKey{},
+		&uploader.UploaderConfig{},
+		&filemeta.FileMeta{},
+		&filemeta.Tag{},
+		&filemeta.File{},
+		&config.AdminClient{},
+		&contactus.ContactUs{},
+		&s3event.ProcessedEvent{},
+		&s3event.ObjectSequencer{},
+		&retention.Rule{},
+		&sharelink.ShareLink{},
+		&sharelink.Access{},
+		&storagemigration.Job{},
+		&tus.Upload{},
+		&folder.Folder{},
+		&folder.Rename{},
+	); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
 
 	companyRepo := company.NewRepository(db)
-	uploaderRepo := uploader.NewRepository(db)
+	if err := uploaderRepo.AdoptLegacyConfigs(); err != nil {
+		log.Fatalf("failed to adopt legacy storage configs: %v", err)
+	}
 	fileMetaRepo := filemeta.NewRepository(db)
 	configRepo := config.NewRepository(db)
 	contactusRepo := contactus.NewRepository(db)
-	s3Service := uploader.NewS3Service()
-	uploaderConfigHandler := uploader.NewHandler(uploaderRepo, companyRepo, s3Service, fileMetaRepo, configRepo)
+	retentionRepo := retention.NewRepository(db)
+	folderRepo := folder.NewRepository(db)
+	s3Service := uploader.NewS3Service(cfg, keyring)
+	folderRenamer := uploader.NewFolderRenamer(folderRepo, companyRepo, fileMetaRepo, retentionRepo, s3Service)
+	if err := folderRenamer.ResumeUnfinished(); err != nil {
+		log.Printf("failed to resume folder renames: %v", err)
+	}
+	uploaderConfigHandler := uploader.NewHandler(uploaderRepo, companyRepo, s3Service, fileMetaRepo, configRepo, retentionRepo, folderRepo, folderRenamer, keyHasher)
 	configHandler := config.NewHandler(configRepo)
 	contactusHandler := contactus.NewHandler(contactusRepo)
+	s3EventProcessor := s3event.NewProcessor(s3event.NewRepository(db), companyRepo)
+	s3EventHandler := s3event.NewHandler(s3EventProcessor)
+	retentionHandler := retention.NewHandler(retentionRepo, companyRepo, s3Service)
+	shareLinkHandler := sharelink.NewHandler(sharelink.NewRepository(db), companyRepo, fileMetaRepo, folderRepo, s3Service, cfg.SubscriptionGracePeriod)
+	migrationRepo := storagemigration.NewRepository(db)
+	migrationRunner := storagemigration.NewRunner(migrationRepo, companyRepo, s3Service)
+	if err := migrationRunner.ResumeSwitched(); err != nil {
+		log.Printf("failed to resume storage migrations: %v", err)
+	}
+	migrationHandler := storagemigration.NewHandler(migrationRepo, companyRepo, uploaderRepo, migrationRunner)
+	historyHandler := history.NewHandler(companyRepo, fileMetaRepo)
+	tusHandler := tus.NewHandler(tus.NewRepository(db), companyRepo, fileMetaRepo, folderRepo, s3Service)
+	downloadHandler := download.NewHandler(companyRepo, fileMetaRepo, folderRepo, s3Service, tokenSigner, cfg.SubscriptionGracePeriod)
+	companyHandler := company.NewHandler(companyRepo, fileMetaRepo, keyHasher, cfg.SubscriptionGracePeriod)
+	reconcileHandler := reconcile.NewHandler(reconcile.NewReconciler(companyRepo, fileMetaRepo, s3Service), companyRepo)
 
-	router := httpserver.NewRouter(cfg, uploaderConfigHandler, contactusHandler, configHandler)
+	router := httpserver.NewRouter(cfg, configRepo, companyRepo, keyHasher, uploaderConfigHandler, contactusHandler, configHandler,
+		s3EventHandler, reconcileHandler, retentionHandler, shareLinkHandler, migrationHandler, tusHandler, historyHandler, downloadHandler, companyHandler)
 
 	log.Printf("starting HTTP server on %s", cfg.Addr)
 	if err := http.ListenAndServe(cfg.Addr, router); err != nil {
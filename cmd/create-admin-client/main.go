@@ -0,0 +1,44 @@
+package main
+
+// create-admin-client creates an admin client directly in the database. The
+// API only lets existing admin clients create new ones, so use it for the
+// first client of a deployment and to recover lost access.
+import (
+	"flag"
+	"log"
+
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+func main() {
+	clientID := flag.String("client-id", "", "client id of the new admin client (required)")
+	clientSecret := flag.String("client-secret", "", "client secret of the new admin client (default: random)")
+	flag.Parse()
+
+	if *clientID == "" {
+		log.Fatal("-client-id is required")
+	}
+	if *clientSecret == "" {
+		secret, err := utils.GenerateToken(32)
+		if err != nil {
+			log.Fatalf("failed to generate a client secret: %v", err)
+		}
+		*clientSecret = secret
+	}
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	db := database.New(cfg.DSN)
+	if err := db.AutoMigrate(&config.AdminClient{}); err != nil {
+		log.Fatalf("failed to migrate admin clients: %v", err)
+	}
+
+	adminClient := &config.AdminClient{ClientID: *clientID, ClientSecret: *clientSecret}
+	if err := config.NewRepository(db).Create(adminClient); err != nil {
+		log.Fatalf("failed to create admin client: %v", err)
+	}
+	log.Printf("created admin client %s with secret %s", adminClient.ClientID, adminClient.ClientSecret)
+}
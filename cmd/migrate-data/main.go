@@ -0,0 +1,66 @@
+package main
+
+// migrate-data runs the one-off data migrations of upgrades. The API
+// migrates the schema on start; the README lists when to run each step.
+// Every step can be run again safely.
+import (
+	"flag"
+	"log"
+
+	"gorm.io/gorm"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+// step is a data migration; it returns the number of rows it changed.
+type step struct {
+	name string
+	run  func(db *gorm.DB, cfg *config.Config) (int64, error)
+}
+
+var steps = []step{
+	{"loc-tags", func(db *gorm.DB, cfg *config.Config) (int64, error) {
+		return filemeta.NewRepository(db).BackfillLocTags()
+	}},
+	{"completed-at", func(db *gorm.DB, cfg *config.Config) (int64, error) {
+		return filemeta.NewRepository(db).BackfillCompletedAt()
+	}},
+	{"api-keys", func(db *gorm.DB, cfg *config.Config) (int64, error) {
+		hasher, err := company.KeyHasherFromConfig(cfg)
+		if err != nil {
+			return 0, err
+		}
+		return company.NewRepository(db).HashLegacyAPIKeys(hasher)
+	}},
+	{"clear-company-api-keys", func(db *gorm.DB, cfg *config.Config) (int64, error) {
+		return company.NewRepository(db).ClearLegacyAPIKeys()
+	}},
+}
+
+func main() {
+	only := flag.String("step", "", "run only the named step (default: all)")
+	flag.Parse()
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	db := database.New(cfg.DSN)
+
+	ran := false
+	for _, s := range steps {
+		if *only != "" && s.name != *only {
+			continue
+		}
+		ran = true
+		count, err := s.run(db, cfg)
+		if err != nil {
+			log.Fatalf("%s failed: %v", s.name, err)
+		}
+		log.Printf("%s: %d rows", s.name, count)
+	}
+	if !ran {
+		log.Fatalf("unknown step %q", *only)
+	}
+}
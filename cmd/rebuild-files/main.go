@@ -0,0 +1,53 @@
+package main
+
+// rebuild-files recomputes the files table (the current state of every
+// company's files) by replaying files_meta. Run it once after upgrading and
+// whenever the table is suspected to have drifted from the log.
+import (
+	"flag"
+	"log"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+func main() {
+	slug := flag.String("company", "", "company slug to rebuild (default: all companies)")
+	flag.Parse()
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	db := database.New(cfg.DSN)
+
+	companyRepo := company.NewRepository(db)
+	fileMetaRepo := filemeta.NewRepository(db)
+
+	var companies []company.Company
+	if *slug != "" {
+		companyRec, err := companyRepo.GetBySlug(*slug)
+		if err != nil {
+			log.Fatalf("failed to look up company: %v", err)
+		}
+		if companyRec == nil {
+			log.Fatalf("company %q not found", *slug)
+		}
+		companies = []company.Company{*companyRec}
+	} else {
+		var err error
+		companies, err = companyRepo.ListAll()
+		if err != nil {
+			log.Fatalf("failed to list companies: %v", err)
+		}
+	}
+
+	for _, companyRec := range companies {
+		count, err := fileMetaRepo.RebuildFiles(companyRec.ID, companyRec.CompanySlug)
+		if err != nil {
+			log.Fatalf("failed to rebuild files of %s: %v", companyRec.CompanySlug, err)
+		}
+		log.Printf("%s: %d files", companyRec.CompanySlug, count)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+// reconcile compares each company's bucket prefix with files_meta and prints
+// the diff as JSON. Pass -apply to correct the log and used_quota.
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/reconcile"
+	"shreshtasmg.in/jupyter/internal/secrets"
+	"shreshtasmg.in/jupyter/internal/uploader"
+)
+
+func main() {
+	slug := flag.String("company", "", "company slug to reconcile (default: all companies)")
+	apply := flag.Bool("apply", false, "apply corrections instead of a dry-run")
+	flag.Parse()
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	keyring, err := secrets.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load credential keys: %v", err)
+	}
+
+	db := database.New(cfg.DSN)
+
+	companyRepo := company.NewRepository(db)
+	reconciler := reconcile.NewReconciler(companyRepo, filemeta.NewRepository(db), uploader.NewS3Service(cfg, keyring))
+	ctx := context.Background()
+
+	var reports []reconcile.Report
+	if *slug != "" {
+		companyRec, err := companyRepo.GetBySlug(*slug)
+		if err != nil {
+			log.Fatalf("failed to look up company: %v", err)
+		}
+		if companyRec == nil {
+			log.Fatalf("company %q not found", *slug)
+		}
+		report, err := reconciler.Run(ctx, companyRec, *apply)
+		if err != nil {
+			log.Fatalf("failed to reconcile %s: %v", *slug, err)
+		}
+		reports = []reconcile.Report{*report}
+	} else {
+		var err error
+		reports, err = reconciler.RunAll(ctx, *apply)
+		if err != nil {
+			log.Fatalf("failed to reconcile: %v", err)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(reports)
+}
@@ -0,0 +1,99 @@
+package main
+
+// reencrypt-credentials encrypts plaintext cloud credentials and re-encrypts
+// values sealed with an older master key using the current key version. Run
+// it after adding a new key to CREDENTIALS_MASTER_KEYS; the old key can be
+// removed once it reports nothing left to update.
+import (
+	"flag"
+	"log"
+
+	"gorm.io/gorm"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/secrets"
+)
+
+// credentialColumns lists every column holding a stored cloud credential.
+var credentialColumns = []struct {
+	table   string
+	columns []string
+}{
+	{table: "uploader_config", columns: []string{"aws_access_key", "aws_secret_key", "previous_access_key", "previous_secret_key"}},
+	{table: "companies", columns: []string{"aws_access_key", "aws_secret_key"}},
+	{table: "storage_migrations", columns: []string{"source_access_key", "source_secret_key", "target_access_key", "target_secret_key"}},
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	flag.Parse()
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	keyring, err := secrets.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load credential keys: %v", err)
+	}
+	if !keyring.Enabled() {
+		log.Fatal("no credential master key configured")
+	}
+
+	db := database.New(cfg.DSN)
+
+	total := 0
+	for _, spec := range credentialColumns {
+		updated, err := resealTable(db, keyring, spec.table, spec.columns, *dryRun)
+		if err != nil {
+			log.Fatalf("failed to re-encrypt %s: %v", spec.table, err)
+		}
+		log.Printf("%s: %d rows updated", spec.table, updated)
+		total += updated
+	}
+	log.Printf("done: %d rows updated with key version %d (dry-run=%t)", total, keyring.CurrentVersion(), *dryRun)
+}
+
+func resealTable(db *gorm.DB, keyring *secrets.Keyring, table string, columns []string, dryRun bool) (int, error) {
+	var rows []map[string]interface{}
+	if err := db.Table(table).Select(append([]string{"id"}, columns...)).Find(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, row := range rows {
+		changes := make(map[string]interface{})
+		for _, column := range columns {
+			value, ok := stringValue(row[column])
+			if !ok || value == "" {
+				continue
+			}
+			sealed, changed, err := keyring.Reseal(value)
+			if err != nil {
+				return updated, err
+			}
+			if changed {
+				changes[column] = sealed
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if !dryRun {
+			if err := db.Table(table).Where("id = ?", row["id"]).Updates(changes).Error; err != nil {
+				return updated, err
+			}
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+func stringValue(v interface{}) (string, bool) {
+	switch value := v.(type) {
+	case string:
+		return value, true
+	case []byte:
+		return string(value), true
+	}
+	return "", false
+}
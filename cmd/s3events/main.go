@@ -0,0 +1,66 @@
+package main
+
+// s3events replays S3 event notifications saved to a file, e.g. exported
+// from a dead-letter queue. The file may hold one notification or a stream
+// of them (one JSON document per line).
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/s3event"
+)
+
+func main() {
+	file := flag.String("file", "", "path to a file with S3 event notifications")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	db := database.New(cfg.DSN)
+
+	processor := s3event.NewProcessor(s3event.NewRepository(db), company.NewRepository(db))
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	var results []s3event.RecordResult
+	dec := json.NewDecoder(f)
+	for {
+		var n s3event.Notification
+		if err := dec.Decode(&n); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			log.Fatalf("invalid notification: %v", err)
+		}
+		records, err := s3event.Unwrap(n)
+		if err != nil {
+			log.Fatalf("invalid notification: %v", err)
+		}
+		results = append(results, processor.Process(records)...)
+	}
+
+	for _, res := range results {
+		log.Printf("%s %s/%s seq=%s: %s %s", res.EventName, res.Bucket, res.Key, res.Sequencer, res.Action, res.Reason)
+	}
+	summary := s3event.Summarize(results)
+	log.Printf("received=%d applied=%d failed=%d", summary.Received, summary.Applied, summary.Failed)
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
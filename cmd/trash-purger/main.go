@@ -0,0 +1,56 @@
+package main
+
+// trash-purger periodically deletes folders that have been in the trash for
+// 30 days.
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/retention"
+	"shreshtasmg.in/jupyter/internal/secrets"
+	"shreshtasmg.in/jupyter/internal/uploader"
+)
+
+func main() {
+	interval := flag.Duration("interval", time.Hour, "time between purges")
+	once := flag.Bool("once", false, "run a single purge and exit")
+	flag.Parse()
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	keyring, err := secrets.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load credential keys: %v", err)
+	}
+
+	db := database.New(cfg.DSN)
+
+	purger := uploader.NewTrashPurger(
+		folder.NewRepository(db),
+		company.NewRepository(db),
+		filemeta.NewRepository(db),
+		retention.NewRepository(db),
+		uploader.NewS3Service(cfg, keyring),
+	)
+
+	for {
+		result, err := purger.Purge(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("trash purge failed: %v", err)
+		} else {
+			log.Printf("trash purge done: folders=%d deleted=%d bytes=%d kept=%d failed=%d", result.Purged, result.DeletedCount, result.DeletedBytes, result.Kept, result.Failed)
+		}
+		if *once {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
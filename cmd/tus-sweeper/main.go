@@ -0,0 +1,53 @@
+package main
+
+// tus-sweeper periodically aborts expired resumable uploads.
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/database"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/secrets"
+	"shreshtasmg.in/jupyter/internal/tus"
+	"shreshtasmg.in/jupyter/internal/uploader"
+)
+
+func main() {
+	interval := flag.Duration("interval", time.Hour, "time between sweeps")
+	once := flag.Bool("once", false, "run a single sweep and exit")
+	flag.Parse()
+
+	config.LoadEnv()
+	cfg := config.Load()
+
+	keyring, err := secrets.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("failed to load credential keys: %v", err)
+	}
+
+	db := database.New(cfg.DSN)
+
+	sweeper := tus.NewSweeper(
+		tus.NewRepository(db),
+		company.NewRepository(db),
+		filemeta.NewRepository(db),
+		uploader.NewS3Service(cfg, keyring),
+	)
+
+	for {
+		result, err := sweeper.Sweep(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("tus sweep failed: %v", err)
+		} else {
+			log.Printf("tus sweep done: aborted=%d removed=%d failed=%d", result.Aborted, result.Removed, result.Failed)
+		}
+		if *once {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/company/register": {
             "post": {
-                "description": "Register a new company and generate an API key with dates of format DD-MM-YYYY",
+                "description": "Register a new company and generate an API key with dates of format DD-MM-YYYY.\nisolation_mode=dedicated_bucket provisions a bucket for the company, shared_prefix claims the company slug in the shared bucket.\nThe company is placed in the named pool, else the least used active pool of region, else the least used active pool.\nDedicated buckets get an IAM user limited to the bucket, whose keys the company uses instead of the pool's.",
                 "consumes": [
                     "application/json"
                 ],
@@ -29,6 +29,20 @@ const docTemplate = `{
                 ],
                 "summary": "Register a company",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
                     {
                         "description": "Register Company Request",
                         "name": "request",
@@ -52,6 +66,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "409": {
+                        "description": "storage prefix already in use",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -61,6 +81,72 @@ const docTemplate = `{
                 }
             }
         },
+        "/config/storage": {
+            "post": {
+                "description": "Adds a named storage pool (bucket, region, credentials, capacity and default company quota). is_active=1 opens it for new companies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Create a storage pool",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Storage pool",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.CreateUploaderConfigRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.CreateUploaderConfigResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown client",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/contactus": {
             "post": {
                 "description": "Creates a new contact_us entry",
@@ -107,109 +193,167 @@ const docTemplate = `{
                 }
             }
         },
-        "/uploader/files": {
+        "/download/{token}": {
             "get": {
-                "description": "Uses X-API-Key to identify company and returns its files_meta records",
+                "description": "Same as GET /uploader/files/{id}/download, authorized by the token instead of the API key.",
                 "produces": [
-                    "application/json"
+                    "application/octet-stream"
                 ],
                 "tags": [
-                    "uploader"
+                    "download"
                 ],
-                "summary": "List files for the calling company",
+                "summary": "Download a file with a download token",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Company API key",
-                        "name": "X-API-Key",
-                        "in": "header",
+                        "description": "Download token",
+                        "name": "token",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Max number of items (default 50)",
-                        "name": "limit",
+                        "type": "boolean",
+                        "description": "Content-Disposition inline",
+                        "name": "inline",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Offset for pagination (default 0)",
-                        "name": "offset",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/uploader.ListCompanyFilesResponse"
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
                         }
                     },
+                    "304": {
+                        "description": "Not Modified"
+                    },
                     "401": {
-                        "description": "unauthorized",
+                        "description": "invalid or expired download token",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "internal error",
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found or in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "archived, restore required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "requested range not satisfiable",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Validates API key, generates a presigned S3 upload URL using company AWS config, and stores files_meta",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/share/{token}": {
+            "get": {
+                "description": "Redirects to a short-lived presigned download URL. Folder links list their files unless a file is selected. The password can be sent as X-Share-Password header or as a \"password\" form field (POST).",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "uploader"
+                    "share"
                 ],
-                "summary": "Generate S3 presigned upload URL and create file meta",
+                "summary": "Open a share link",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Company API key",
-                        "name": "X-API-Key",
-                        "in": "header",
+                        "description": "Share token",
+                        "name": "token",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "description": "File upload request",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/uploader.GenerateUploadURLRequest"
-                        }
+                        "type": "string",
+                        "description": "File name inside a shared folder",
+                        "name": "file",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Share link password",
+                        "name": "X-Share-Password",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/uploader.GenerateUploadURLResponse"
+                            "$ref": "#/definitions/sharelink.SharedFolderResponse"
                         }
                     },
-                    "400": {
-                        "description": "invalid request",
+                    "302": {
+                        "description": "Found"
+                    },
+                    "401": {
+                        "description": "password required",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "401": {
-                        "description": "unauthorized",
+                    "403": {
+                        "description": "company suspended or outside its subscription",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "500": {
-                        "description": "internal error",
+                    "404": {
+                        "description": "share link not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "archived, restore required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "410": {
+                        "description": "share link expired or used up, or its file no longer exists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "too many wrong passwords",
                         "schema": {
                             "type": "string"
                         }
@@ -217,52 +361,106 @@ const docTemplate = `{
                 }
             }
         },
-        "/uploader/files/delete": {
-            "post": {
-                "description": "Deletes a file from S3 for the authenticated company and records a files_meta entry with file_txn_type=2",
-                "consumes": [
-                    "application/json"
-                ],
+        "/storage/companies": {
+            "get": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "uploader"
+                    "company"
                 ],
-                "summary": "Delete a single file by key",
+                "summary": "List companies",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Company API key",
-                        "name": "X-API-Key",
+                        "description": "Admin client id",
+                        "name": "client_id",
                         "in": "header",
                         "required": true
                     },
                     {
-                        "description": "Delete file request",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/uploader.DeleteFileRequest"
-                        }
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only companies whose name or slug contains q",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of items (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset for pagination (default 0)",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/uploader.DeleteFileResponse"
+                            "$ref": "#/definitions/company.ListCompaniesResponse"
                         }
                     },
-                    "400": {
-                        "description": "invalid request",
+                    "500": {
+                        "description": "internal error",
                         "schema": {
                             "type": "string"
                         }
+                    }
+                }
+            }
+        },
+        "/storage/companies/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Get a company with its usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
                     },
-                    "401": {
-                        "description": "unauthorized",
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.CompanyDetailResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
                         "schema": {
                             "type": "string"
                         }
@@ -274,11 +472,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/uploader/folders/delete": {
-            "post": {
-                "description": "Deletes all objects under folder_prefix for the authenticated company and records a files_meta entry with file_txn_type=3",
+            },
+            "patch": {
+                "description": "Changes the name, subscription dates (DD-MM-YYYY) and total quota of a company; omitted fields are kept.\nThe slug, and with it the storage prefix, does not change with the name.",
                 "consumes": [
                     "application/json"
                 ],
@@ -286,24 +482,38 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "uploader"
+                    "company"
                 ],
-                "summary": "Delete all files under a folder (prefix)",
+                "summary": "Update a company",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Company API key",
-                        "name": "X-API-Key",
+                        "description": "Admin client id",
+                        "name": "client_id",
                         "in": "header",
                         "required": true
                     },
                     {
-                        "description": "Delete folder request",
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/uploader.DeleteFolderRequest"
+                            "$ref": "#/definitions/company.UpdateCompanyRequest"
                         }
                     }
                 ],
@@ -311,7 +521,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/uploader.DeleteFolderResponse"
+                            "$ref": "#/definitions/company.CompanyDetailResponse"
                         }
                     },
                     "400": {
@@ -320,8 +530,8 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "401": {
-                        "description": "unauthorized",
+                    "404": {
+                        "description": "company not found",
                         "schema": {
                             "type": "string"
                         }
@@ -334,189 +544,6311 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/storage/companies/{id}/api-keys": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "List the API keys of a company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.ListAPIKeysResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "For companies that lost their keys. The key is returned once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Issue an API key for a company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Label and optional expiry",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/company.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/company.CreatedAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "too many active API keys",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/companies/{id}/api-keys/{keyID}/revoke": {
+            "post": {
+                "description": "Unlike the company's own revoke, this may revoke its last active key.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Revoke an API key of a company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "keyID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.APIKeyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "company or API key not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "API key is already revoked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/companies/{id}/reactivate": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Reactivate a suspended company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.CompanyResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "company is not suspended",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/companies/{id}/renew": {
+            "post": {
+                "description": "extend_days moves the end date by that many days, counted from today if the subscription has already ended.\nOtherwise end_date (DD-MM-YYYY) replaces the end date and start_date, if given, the start date.\nA renewed company leaves its grace period or expiry at once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Renew the subscription of a company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New window",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/company.RenewSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.CompanyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/companies/{id}/suspend": {
+            "post": {
+                "description": "The company's API key is rejected with 403 on every uploader endpoint until it is reactivated. Stored files are kept.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Suspend a company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/company.SuspendCompanyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.CompanyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "company is already suspended",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/events": {
+            "post": {
+                "description": "Applies ObjectCreated/ObjectRemoved notifications (raw S3 or SNS wrapped) to files_meta and quotas. Redelivered events are ignored by sequencer.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Ingest S3 bucket event notifications",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "S3 event notification",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/s3event.Notification"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/s3event.IngestEventsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown client",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/history/diff": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "Compare a company folder between two points in time (admin)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company slug",
+                        "name": "company_slug",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start (RFC3339)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End (RFC3339)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder (default: all files)",
+                        "name": "prefix",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/history.DiffResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/history/snapshot": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "List a company folder as it was at a point in time (admin)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company slug",
+                        "name": "company_slug",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Point in time (RFC3339)",
+                        "name": "at",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder (default: all files)",
+                        "name": "prefix",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/history.SnapshotResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/migrations": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "List storage migrations of a company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company slug",
+                        "name": "company_slug",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/storagemigration.ListMigrationsResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Copies every object under the company slug to the target, verifies ETags/sizes and switches the company's storage pointer.\nUploads issued before the switch may still reach the source until drain_until; the job then copies them and optionally deletes the source.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Start moving a company's storage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Migration",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/storagemigration.CreateMigrationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/storagemigration.MigrationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "a migration is already in progress",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/migrations/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Storage migration status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Migration ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/storagemigration.MigrationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "migration not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/migrations/{id}/resume": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Resume an interrupted or failed storage migration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Migration ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/storagemigration.MigrationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "migration not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "migration is running or completed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/pools": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "List storage pools with usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.ListPoolsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a named storage pool (bucket, region, credentials, capacity and default company quota). is_active=1 opens it for new companies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Create a storage pool",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Storage pool",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.CreateUploaderConfigRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.CreateUploaderConfigResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "unknown client",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/pools/{id}/activate": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Open a storage pool for new companies",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pool ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.PoolResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "storage pool not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "invalid transition",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/pools/{id}/drain": {
+            "post": {
+                "description": "Existing companies keep using the pool",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Stop placing new companies in a storage pool",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pool ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.PoolResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "storage pool not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "invalid transition",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/pools/{id}/retire": {
+            "post": {
+                "description": "Only pools without companies can be retired, migrate them away first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Retire an empty storage pool",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pool ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.PoolResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "storage pool not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "pool still has companies",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/pools/{id}/rotate-credentials": {
+            "post": {
+                "description": "Tests the new key against the pool's bucket, then switches the pool and every company placed in it to the new key.\nThe old key must stay valid at the provider until old_key_valid_until.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Rotate a storage pool's access key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pool ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New credentials",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.RotateCredentialsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.RotateCredentialsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request or credentials",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "storage pool not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "previous rotation still in overlap",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/pools/{id}/upload-url-expiry": {
+            "put": {
+                "description": "Applies to every company placed in the pool, on top of their own caps.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Cap the lifetime of upload URLs in a storage pool",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pool ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Cap in seconds (60 to 3600), null to remove",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.UploadURLExpiryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.PoolResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "storage pool not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/reconcile": {
+            "post": {
+                "description": "Lists every object under the company slug and reports orphans, missing objects and size mismatches. With apply=true the log is corrected and used_quota is recomputed from actual bytes.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "storage"
+                ],
+                "summary": "Reconcile bucket contents with files_meta and used_quota",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Reconcile request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/reconcile.ReconcileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/reconcile.ReconcileResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/retention-rules": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "List retention rules of a company (admin)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Company slug",
+                        "name": "company_slug",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/retention.ListRulesResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Attach a retention rule to a company folder (admin)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Retention rule with company_slug",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/retention.CreateRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/retention.RuleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "company not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/storage/retention-rules/{id}": {
+            "delete": {
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Remove any retention rule (admin)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin client id",
+                        "name": "client_id",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Admin client secret",
+                        "name": "client_secret",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "rule not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/api-keys": {
+            "get": {
+                "description": "Keys are identified by their prefix; the keys themselves are only shown when issued.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "List the API keys of the calling company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.ListAPIKeysResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "The key is returned once. A company holds at most 20 active keys.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Issue an additional API key for the calling company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Label and optional expiry",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/company.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/company.CreatedAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "too many active API keys",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/api-keys/rotate": {
+            "post": {
+                "description": "Issues a new key and lets the key the request is made with expire after overlap_minutes (default 1440, at most 43200; 0 expires it at once), so clients can switch without downtime.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Rotate the calling API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key to rotate",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Rotation options",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/company.RotateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/company.RotateAPIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "too many active API keys",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/api-keys/{keyID}/revoke": {
+            "post": {
+                "description": "Takes effect at once. The last active key cannot be revoked; issue another one first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "company"
+                ],
+                "summary": "Revoke an API key of the calling company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key ID",
+                        "name": "keyID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/company.APIKeyResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "already revoked or last active key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files": {
+            "get": {
+                "description": "Uses X-API-Key to identify company and returns its current files ordered by key, without the files of folders in the trash",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "List files for the calling company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only files in this folder",
+                        "name": "loc_tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include sub folders of loc_tag",
+                        "name": "recursive",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max number of items (default 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset for pagination (default 0)",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.ListCompanyFilesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid loc_tag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Validates API key, generates a presigned S3 upload URL using company AWS config, and stores files_meta\nThe upload must send the returned headers: pinned content_type, cache_control and content_disposition, the storage class and, for locked uploads (lock_mode and retain_until, or a folder lock policy), the lock headers with Content-MD5.\nThe URL lives expires_in seconds (default 900), shortened to the caps of the company and its storage pool.\nloc_tag is a folder path of at most 16 segments and 255 bytes; segments hold letters, digits, spaces and -_.()+,@\u0026'!~= and cannot be \".\" or \"..\". Paths are compared without regard to case: an existing folder keeps its spelling.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Generate S3 presigned upload URL and create file meta",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "File upload request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.GenerateUploadURLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.GenerateUploadURLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "folder in trash or being renamed, or file under object lock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/batch": {
+            "post": {
+                "description": "Reserves quota for the total size of all valid items at once, then presigns every item.\nEach item reports its own result; quota of failed items is refunded.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Generate presigned upload URLs for many files",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Files to upload",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.BatchUploadURLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.BatchUploadURLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "quota_exceeded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/delete": {
+            "post": {
+                "description": "Deletes a file from S3 for the authenticated company, records a files_meta entry with file_txn_type=2 and the file's size, and refunds that size from the used quota.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Delete a single file by key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Delete file request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.DeleteFileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.DeleteFileResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "retention_active, object_locked or folder being renamed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/search": {
+            "get": {
+                "description": "Searches the current files (deleted and replaced uploads and files in the trash are excluded). Pass next_cursor back as cursor with the same filters to get the next page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Search the calling company's files",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File name contains",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "File name starts with",
+                        "name": "name_prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder",
+                        "name": "loc_tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include sub folders of loc_tag",
+                        "name": "recursive",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "File transaction type",
+                        "name": "txn_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum size in bytes",
+                        "name": "min_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum size in bytes",
+                        "name": "max_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated tags, all must match",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "created_at (default), file_name or file_size",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.SearchFilesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/{id}": {
+            "get": {
+                "description": "Returns the current metadata of the file uploaded as id, with live stats of the stored object.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Get a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FileDetailResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Changes the display name, description, custom metadata or file_txn_meta of a file. The object key is unchanged.\nEvery change is recorded as a files_meta transaction with file_txn_type=-1.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Update a file's metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Metadata changes",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.UpdateFileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FileDetailResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/{id}/download": {
+            "get": {
+                "description": "Streams the file. Range, If-Range, If-None-Match and If-Modified-Since are honoured; ETag, Last-Modified, Content-Type and Content-Length are passed through.\nSet inline=true to display the file in the browser instead of saving it.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Download a file through the API",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Content-Disposition inline",
+                        "name": "inline",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found or in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "archived, restore required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "requested range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/{id}/download-token": {
+            "post": {
+                "description": "Returns a signed token for GET /download/{token}, for clients that cannot send the API key (e.g. video players).\nTokens cannot be revoked and live at most an hour.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "Create a download token for a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Token lifetime",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/download.CreateDownloadTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/download.DownloadTokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found or in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/{id}/legal-hold": {
+            "put": {
+                "description": "A file under legal hold cannot be deleted or moved until the hold is released, whatever its retention.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Place a legal hold on a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FileDetailResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "object lock not enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Release the legal hold of a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FileDetailResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "object lock not enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/files/{id}/restore": {
+            "get": {
+                "description": "Reports the current storage class of the file and whether it must be restored before it can be downloaded.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Get the restore status of a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.RestoreStatusResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Requests a temporary readable copy of a file in GLACIER or DEEP_ARCHIVE, kept for days once the restore finished.\nRestores take minutes (Expedited) to 48 hours (DEEP_ARCHIVE Bulk); poll the restore status. Repeating a finished restore extends it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Restore an archived file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "File ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Restore options",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.RestoreFileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.RestoreStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "file is not archived",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "file was deleted or replaced",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders": {
+            "get": {
+                "description": "Folders are the folder resources and the folders derived from the loc_tag of the current files, without the ones in the trash",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "List the sub folders of a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Parent folder (default: top level)",
+                        "name": "parent",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.ListFoldersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid parent",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a folder resource and its placeholder object, so the folder exists while it is empty.\nCreating a folder at a path that already holds files adopts it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Create a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Folder",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.CreateFolderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "folder already exists, parent in trash or being renamed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/children": {
+            "get": {
+                "description": "Returns the direct sub folders (folder resources and folders implied by file loc_tags) and the files of a folder.\nFolders in the trash are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "List the contents of a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder path (default: top level)",
+                        "name": "path",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.ListFolderChildrenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder is in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/delete": {
+            "post": {
+                "description": "Deletes all objects under folder_prefix for the authenticated company, bypassing the trash, and records a files_meta entry with file_txn_type=3.\nFolder resources under the prefix are deleted too and the deleted bytes are refunded from the used quota.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Delete all files under a folder (prefix)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Delete folder request",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.DeleteFolderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.DeleteFolderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "retention_active, object_locked or folder being renamed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/renames/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Get the progress of a folder rename",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rename ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderRenameResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "rename not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/renames/{id}/resume": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Resume an interrupted or failed folder rename",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rename ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderRenameResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "rename not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "rename is running or completed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/trash": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "List the folders in the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.ListTrashedFoldersResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Get a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Moves the folder to the trash; its files are kept and still count against the quota until it is deleted permanently, by permanent=true or 30 days later by the trash purger.\nFiles in the trash are left out of listings, search, downloads and share links, and cannot be uploaded to.\npermanent=true deletes every object under the folder and its folder resources, also for a folder already in the trash.\nA folder holding files or sub folders is only deleted with recursive=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Delete a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also delete the folder contents",
+                        "name": "recursive",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Delete instead of moving to the trash",
+                        "name": "permanent",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.DeleteFolderObjectResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "folder not empty, already in trash, being renamed, retention_active or object_locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Sets the description and owner of a folder. Omitted fields are kept, empty strings clear them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Describe a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Folder metadata",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.UpdateFolderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/{id}/object-lock": {
+            "put": {
+                "description": "Uploads to the folder and its sub folders are locked for retention_days from the upload, unless they request a longer lock.\nThe company bucket must have Object Lock enabled. A COMPLIANCE policy can only be extended.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Set the Object Lock policy of a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Policy",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderLockPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "compliance policy cannot be weakened",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Objects already locked stay locked. A COMPLIANCE policy cannot be removed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Remove the Object Lock policy of a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "compliance policy cannot be removed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/{id}/rename": {
+            "post": {
+                "description": "Starts a rename that copies every file and sub folder to the new path, records the copies as uploads and the old path as a folder delete, then deletes the old objects; used quota is unchanged.\nUntil it completes, uploads, deletes and renames in the old and new folders are refused with 409. Poll GET /uploader/folders/renames/{id}; a failed rename reports its error and is resumed with POST /uploader/folders/renames/{id}/resume.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Rename or move a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New path",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.RenameFolderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderRenameResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "path in use, folder in trash or being renamed, retention_active, object_locked or archived file",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/folders/{id}/restore": {
+            "post": {
+                "description": "Restores the folder with the sub folders that were trashed along with it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "folders"
+                ],
+                "summary": "Restore a folder from the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.FolderResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "folder not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "folder not in trash, parent in trash or being renamed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/history/diff": {
+            "get": {
+                "description": "Files added, removed or replaced under prefix between from and to",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "Compare a folder between two points in time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start (RFC3339)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End (RFC3339)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder (default: all files)",
+                        "name": "prefix",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/history.DiffResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/history/snapshot": {
+            "get": {
+                "description": "Replays the transaction log up to at and returns the files that existed under prefix at that moment.\nUploads count from when they completed, so a resumable upload still running at that moment is not listed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "List a folder as it was at a point in time",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Point in time (RFC3339)",
+                        "name": "at",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Folder (default: all files)",
+                        "name": "prefix",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/history.SnapshotResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/retention-rules": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "List retention rules of the calling company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/retention.ListRulesResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "expire deletes files under prefix after N days, min_retention blocks deletes for N days,\ntransition moves files under prefix to storage_class after N days through the bucket lifecycle",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Attach a retention rule to a folder",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Retention rule",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/retention.CreateRuleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/retention.RuleResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "failed to update bucket lifecycle",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/retention-rules/{id}": {
+            "delete": {
+                "description": "Companies cannot remove rules created by an admin",
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Remove a retention rule",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Rule ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "rule is managed by an admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "rule not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/settings/upload-url-expiry": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Get the upload URL lifetime settings of the calling company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.UploadURLExpiryResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Requests for longer URLs are shortened to the cap. The cap of the company's storage pool still applies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploader"
+                ],
+                "summary": "Cap the lifetime of the calling company's upload URLs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Cap in seconds (60 to 3600), null to remove",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/uploader.UploadURLExpiryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/uploader.UploadURLExpiryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/share-links": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "List share links of the calling company",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/sharelink.ListShareLinksResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Shares a file (file_key) or folder (folder_prefix) through an opaque token with optional expiry, password and download limit",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "Create a public share link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Share link",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/sharelink.CreateShareLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/sharelink.ShareLinkResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "company suspended or outside its subscription",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "file not found or in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "internal error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/share-links/{id}/accesses": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "share"
+                ],
+                "summary": "List access log of a share link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Share link ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/sharelink.ListAccessesResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "share link not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/share-links/{id}/revoke": {
+            "post": {
+                "tags": [
+                    "share"
+                ],
+                "summary": "Revoke a share link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Share link ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "share link not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploader/tus": {
+            "post": {
+                "description": "Upload-Metadata must carry filename and loc_tag, tags (comma separated) is optional. The file is recorded as pending.\nUpload-Length is charged to quota at once and refunded if the upload is terminated or expires; uploads expire 24 hours after creation (Upload-Expires).\nlock_mode and retain_until (RFC3339) lock the object; folder lock policies apply without them.\nstorage_class stores the object in STANDARD (default), STANDARD_IA, GLACIER_IR or DEEP_ARCHIVE.",
+                "tags": [
+                    "tus"
+                ],
+                "summary": "Start a resumable upload (tus creation)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Total size in bytes",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "filename \u003cbase64\u003e,loc_tag \u003cbase64\u003e",
+                        "name": "Upload-Metadata",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Upload URL"
+                            },
+                            "Upload-Expires": {
+                                "type": "string",
+                                "description": "When the upload expires"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "quota_exceeded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "folder in trash or being renamed, or file under object lock",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "upload too large",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "options": {
+                "description": "Returns Tus-Version, Tus-Extension, Tus-Max-Size and Tus-Checksum-Algorithm headers",
+                "tags": [
+                    "tus"
+                ],
+                "summary": "Describe the tus server",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/uploader/tus/{id}": {
+            "delete": {
+                "description": "Aborts an unfinished upload and refunds its quota; for a finished upload only the session is removed, the file stays.",
+                "tags": [
+                    "tus"
+                ],
+                "summary": "Cancel a resumable upload (tus termination)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "upload not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "upload is being completed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "423": {
+                        "description": "upload is locked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "tags": [
+                    "tus"
+                ],
+                "summary": "Get the offset of a resumable upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "headers": {
+                            "Upload-Expires": {
+                                "type": "string",
+                                "description": "When the upload expires, while it is unfinished"
+                            },
+                            "Upload-Length": {
+                                "type": "integer",
+                                "description": "Total size"
+                            },
+                            "Upload-Offset": {
+                                "type": "integer",
+                                "description": "Bytes received"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "upload not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "upload expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Upload-Offset must equal the current offset. An optional Upload-Checksum (\"sha1|md5|sha256 \u003cbase64\u003e\") is verified before anything is stored.",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "tags": [
+                    "tus"
+                ],
+                "summary": "Append bytes to a resumable upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Company API key",
+                        "name": "X-API-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Current offset",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Checksum of the chunk",
+                        "name": "Upload-Checksum",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "Upload-Offset": {
+                                "type": "integer",
+                                "description": "New offset"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "quota_exceeded",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "upload not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "offset mismatch or upload being completed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "upload expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "unsupported content type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "423": {
+                        "description": "upload is locked",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "460": {
+                        "description": "checksum mismatch",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "company.APIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "active, expired or revoked",
+                    "type": "string"
+                }
+            }
+        },
+        "company.CompanyDetailResponse": {
+            "type": "object",
+            "properties": {
+                "aws_bucket_name": {
+                    "type": "string"
+                },
+                "company_name": {
+                    "type": "string"
+                },
+                "company_slug": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                },
+                "grace_until": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "isolation_mode": {
+                    "type": "string"
+                },
+                "max_upload_url_expiry": {
+                    "description": "seconds",
+                    "type": "integer"
+                },
+                "pool_id": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "active or suspended",
+                    "type": "string"
+                },
+                "subscription": {
+                    "description": "Subscription is active, grace (read-only), expired or not_started.",
+                    "type": "string"
+                },
+                "suspended_at": {
+                    "type": "string"
+                },
+                "suspension_reason": {
+                    "type": "string"
+                },
+                "total_usage_quota": {
+                    "type": "integer"
+                },
+                "usage": {
+                    "$ref": "#/definitions/company.CompanyUsage"
+                },
+                "used_quota": {
+                    "type": "integer"
+                }
+            }
+        },
+        "company.CompanyResponse": {
+            "type": "object",
+            "properties": {
+                "aws_bucket_name": {
+                    "type": "string"
+                },
+                "company_name": {
+                    "type": "string"
+                },
+                "company_slug": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                },
+                "grace_until": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "isolation_mode": {
+                    "type": "string"
+                },
+                "max_upload_url_expiry": {
+                    "description": "seconds",
+                    "type": "integer"
+                },
+                "pool_id": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "active or suspended",
+                    "type": "string"
+                },
+                "subscription": {
+                    "description": "Subscription is active, grace (read-only), expired or not_started.",
+                    "type": "string"
+                },
+                "suspended_at": {
+                    "type": "string"
+                },
+                "suspension_reason": {
+                    "type": "string"
+                },
+                "total_usage_quota": {
+                    "type": "integer"
+                },
+                "used_quota": {
+                    "type": "integer"
+                }
+            }
+        },
+        "company.CompanyUsage": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "integer"
+                },
+                "remaining_quota": {
+                    "type": "integer"
+                },
+                "stored_bytes": {
+                    "type": "integer"
+                },
+                "total_usage_quota": {
+                    "type": "integer"
+                },
+                "used_quota": {
+                    "type": "integer"
+                }
+            }
+        },
+        "company.CreateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "RFC3339",
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                }
+            }
+        },
+        "company.CreatedAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "label": {
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "revoked_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "active, expired or revoked",
+                    "type": "string"
+                }
+            }
+        },
+        "company.ListAPIKeysResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/company.APIKeyResponse"
+                    }
+                }
+            }
+        },
+        "company.ListCompaniesResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/company.CompanyResponse"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "company.RenewSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                },
+                "extend_days": {
+                    "type": "integer"
+                },
+                "start_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                }
+            }
+        },
+        "company.RotateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "label": {
+                    "description": "Label of the new key, default the label of the calling key.",
+                    "type": "string"
+                },
+                "overlap_minutes": {
+                    "description": "OverlapMinutes is how long the calling key stays valid, default 1440;\n0 expires it at once.",
+                    "type": "integer"
+                }
+            }
+        },
+        "company.RotateAPIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "$ref": "#/definitions/company.CreatedAPIKeyResponse"
+                },
+                "previous": {
+                    "$ref": "#/definitions/company.APIKeyResponse"
+                }
+            }
+        },
+        "company.SuspendCompanyRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "company.UpdateCompanyRequest": {
+            "type": "object",
+            "properties": {
+                "company_name": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                },
+                "start_date": {
+                    "description": "DD-MM-YYYY",
+                    "type": "string"
+                },
+                "total_usage_quota": {
+                    "type": "integer"
+                }
+            }
+        },
         "contactus.CreateContactUsRequest": {
             "type": "object",
             "properties": {
-                "contact_email": {
+                "contact_email": {
+                    "type": "string"
+                },
+                "contact_number": {
+                    "type": "string"
+                },
+                "full_name": {
+                    "type": "string"
+                },
+                "project_details": {
+                    "type": "string"
+                },
+                "project_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "contactus.CreateContactUsResponse": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "msg": {
+                    "type": "string"
+                }
+            }
+        },
+        "download.CreateDownloadTokenRequest": {
+            "type": "object",
+            "properties": {
+                "expires_in": {
+                    "description": "seconds, default 300, at most 3600",
+                    "type": "integer"
+                }
+            }
+        },
+        "download.DownloadTokenResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "path": {
+                    "description": "relative to the API host",
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "history.ChangedFile": {
+            "type": "object",
+            "properties": {
+                "after": {
+                    "$ref": "#/definitions/history.FileVersion"
+                },
+                "before": {
+                    "$ref": "#/definitions/history.FileVersion"
+                },
+                "file_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "history.DiffResponse": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/history.FileVersion"
+                    }
+                },
+                "changed": {
+                    "description": "replaced by a new upload in between",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/history.ChangedFile"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/history.FileVersion"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "history.FileVersion": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "file_id": {
+                    "type": "string"
+                },
+                "file_key": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "history.SnapshotResponse": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/history.FileVersion"
+                    }
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "total_size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "reconcile.ObjectDiff": {
+            "type": "object",
+            "properties": {
+                "file_key": {
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "reconcile.ReconcileRequest": {
+            "type": "object",
+            "properties": {
+                "apply": {
+                    "description": "false = dry-run",
+                    "type": "boolean"
+                },
+                "company_slug": {
+                    "description": "empty reconciles every company",
+                    "type": "string"
+                }
+            }
+        },
+        "reconcile.ReconcileResponse": {
+            "type": "object",
+            "properties": {
+                "reports": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reconcile.Report"
+                    }
+                }
+            }
+        },
+        "reconcile.Report": {
+            "type": "object",
+            "properties": {
+                "actual_bytes": {
+                    "type": "integer"
+                },
+                "applied": {
+                    "type": "boolean"
+                },
+                "company_id": {
+                    "type": "string"
+                },
+                "company_slug": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "missing": {
+                    "description": "Missing exist in files_meta but not in the bucket.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reconcile.ObjectDiff"
+                    }
+                },
+                "orphans": {
+                    "description": "Orphans exist in the bucket but not in files_meta.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reconcile.ObjectDiff"
+                    }
+                },
+                "size_mismatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/reconcile.SizeMismatch"
+                    }
+                },
+                "used_quota": {
+                    "type": "integer"
+                }
+            }
+        },
+        "reconcile.SizeMismatch": {
+            "type": "object",
+            "properties": {
+                "actual_size": {
+                    "type": "integer"
+                },
+                "file_key": {
+                    "type": "string"
+                },
+                "recorded_size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "retention.CreateRuleRequest": {
+            "type": "object",
+            "properties": {
+                "company_slug": {
+                    "description": "admin only",
+                    "type": "string"
+                },
+                "days": {
+                    "type": "integer"
+                },
+                "prefix": {
+                    "description": "loc_tag or folder prefix",
+                    "type": "string"
+                },
+                "rule_type": {
+                    "description": "expire | min_retention | transition",
+                    "type": "string"
+                },
+                "storage_class": {
+                    "description": "StorageClass is required for transition rules: STANDARD_IA,\nGLACIER_IR or DEEP_ARCHIVE.",
+                    "type": "string"
+                }
+            }
+        },
+        "retention.ListRulesResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/retention.RuleResponse"
+                    }
+                }
+            }
+        },
+        "retention.RuleResponse": {
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "days": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "rule_type": {
+                    "type": "string"
+                },
+                "storage_class": {
+                    "type": "string"
+                }
+            }
+        },
+        "s3event.IngestEventsResponse": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "received": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/s3event.RecordResult"
+                    }
+                }
+            }
+        },
+        "s3event.Notification": {
+            "type": "object",
+            "properties": {
+                "Message": {
+                    "type": "string"
+                },
+                "Records": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/s3event.Record"
+                    }
+                }
+            }
+        },
+        "s3event.Record": {
+            "type": "object",
+            "properties": {
+                "eventName": {
+                    "type": "string"
+                },
+                "eventTime": {
+                    "type": "string"
+                },
+                "s3": {
+                    "type": "object",
+                    "properties": {
+                        "bucket": {
+                            "type": "object",
+                            "properties": {
+                                "name": {
+                                    "type": "string"
+                                }
+                            }
+                        },
+                        "object": {
+                            "type": "object",
+                            "properties": {
+                                "eTag": {
+                                    "type": "string"
+                                },
+                                "key": {
+                                    "type": "string"
+                                },
+                                "sequencer": {
+                                    "type": "string"
+                                },
+                                "size": {
+                                    "type": "integer"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "s3event.RecordResult": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "bucket": {
+                    "type": "string"
+                },
+                "event_name": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "sequencer": {
+                    "type": "string"
+                }
+            }
+        },
+        "sharelink.AccessItem": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "object_key": {
+                    "type": "string"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "remote_addr": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
+        "sharelink.CreateShareLinkRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "RFC3339",
+                    "type": "string"
+                },
+                "file_key": {
+                    "description": "share a single file",
+                    "type": "string"
+                },
+                "folder_prefix": {
+                    "description": "or every file in a folder",
+                    "type": "string"
+                },
+                "max_downloads": {
+                    "type": "integer"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "sharelink.ListAccessesResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sharelink.AccessItem"
+                    }
+                }
+            }
+        },
+        "sharelink.ListShareLinksResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/sharelink.ShareLinkResponse"
+                    }
+                }
+            }
+        },
+        "sharelink.ShareLinkResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "download_count": {
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "max_downloads": {
+                    "type": "integer"
+                },
+                "password_protected": {
+                    "type": "boolean"
+                },
+                "revoked": {
+                    "type": "boolean"
+                },
+                "target_key": {
+                    "type": "string"
+                },
+                "target_type": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "sharelink.SharedFolderResponse": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "storagemigration.CreateMigrationRequest": {
+            "type": "object",
+            "properties": {
+                "company_slug": {
+                    "type": "string"
+                },
+                "delete_source": {
+                    "type": "boolean"
+                },
+                "target_bucket": {
+                    "description": "or to another bucket with the company's credentials",
+                    "type": "string"
+                },
+                "target_config_id": {
+                    "description": "move to the bucket and credentials of an uploader config",
+                    "type": "string"
+                },
+                "target_region": {
+                    "type": "string"
+                }
+            }
+        },
+        "storagemigration.ListMigrationsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storagemigration.MigrationResponse"
+                    }
+                }
+            }
+        },
+        "storagemigration.MigrationResponse": {
+            "type": "object",
+            "properties": {
+                "company_id": {
+                    "type": "string"
+                },
+                "copied_bytes": {
+                    "type": "integer"
+                },
+                "copied_objects": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delete_source": {
+                    "type": "boolean"
+                },
+                "drain_until": {
+                    "description": "DrainUntil is when the source stops receiving uploads issued before\nthe switch; it is re-synced and deleted after it.",
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_key": {
+                    "type": "string"
+                },
+                "source_bucket": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "switched_at": {
+                    "type": "string"
+                },
+                "target_bucket": {
+                    "type": "string"
+                },
+                "total_bytes": {
+                    "type": "integer"
+                },
+                "total_objects": {
+                    "type": "integer"
+                }
+            }
+        },
+        "uploader.BatchUploadURLItem": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "file_id": {
+                    "type": "string"
+                },
+                "file_key": {
+                    "type": "string"
+                },
+                "headers": {
+                    "description": "Headers must be sent with the upload.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "upload_url": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.BatchUploadURLRequest": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/uploader.GenerateUploadURLRequest"
+                    }
+                }
+            }
+        },
+        "uploader.BatchUploadURLResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/uploader.BatchUploadURLItem"
+                    }
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "uploader.CompanyFileMetaItem": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "file_key": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                },
+                "file_txn_meta": {
+                    "type": "string"
+                },
+                "file_txn_type": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "loc_tag": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.CreateFolderRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "path": {
+                    "description": "loc_tag of the folder, e.g. \"reports/2025\"",
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.CreateUploaderConfigRequest": {
+            "type": "object",
+            "properties": {
+                "aws_access_key": {
+                    "type": "string"
+                },
+                "aws_bucket_name": {
+                    "type": "string"
+                },
+                "aws_bucket_region": {
+                    "type": "string"
+                },
+                "aws_secret_key": {
+                    "type": "string"
+                },
+                "default_quota": {
+                    "type": "integer"
+                },
+                "is_active": {
+                    "type": "integer"
+                },
+                "max_upload_url_expiry": {
+                    "description": "MaxUploadURLExpiry caps presigned upload URLs of the pool, in seconds.",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "pool name, defaults to the bucket name",
+                    "type": "string"
+                },
+                "total_quota": {
+                    "type": "integer"
+                }
+            }
+        },
+        "uploader.CreateUploaderConfigResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.DeleteFileRequest": {
+            "type": "object",
+            "properties": {
+                "file_key": {
+                    "type": "string"
+                },
+                "file_txn_meta": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.DeleteFileResponse": {
+            "type": "object",
+            "properties": {
+                "file_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.DeleteFolderObjectResponse": {
+            "type": "object",
+            "properties": {
+                "deleted_bytes": {
+                    "type": "integer"
+                },
+                "deleted_count": {
+                    "type": "integer"
+                },
+                "folder": {
+                    "$ref": "#/definitions/uploader.FolderResponse"
+                },
+                "permanent": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "uploader.DeleteFolderRequest": {
+            "type": "object",
+            "properties": {
+                "file_txn_meta": {
+                    "type": "string"
+                },
+                "folder_prefix": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.DeleteFolderResponse": {
+            "type": "object",
+            "properties": {
+                "deleted_bytes": {
+                    "type": "integer"
+                },
+                "deleted_count": {
+                    "type": "integer"
+                },
+                "folder_prefix": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.FileDetailResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "file_key": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                },
+                "file_txn_meta": {
+                    "type": "string"
+                },
+                "file_txn_type": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "legal_hold": {
+                    "type": "boolean"
+                },
+                "loc_tag": {
+                    "type": "string"
+                },
+                "lock_mode": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "type": "object"
+                },
+                "object": {
+                    "description": "Object is only returned when fetching a file.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/uploader.FileObjectStats"
+                        }
+                    ]
+                },
+                "retain_until": {
+                    "type": "string"
+                },
+                "storage_class": {
+                    "description": "StorageClass is the class the file was uploaded with.",
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.FileObjectStats": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "exists": {
+                    "type": "boolean"
+                },
+                "last_modified": {
+                    "type": "string"
+                },
+                "restore_required": {
+                    "description": "RestoreRequired is set for archived objects without a restored copy.",
+                    "type": "boolean"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "storage_class": {
+                    "description": "StorageClass is the current class, after lifecycle transitions.",
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.FileSearchItem": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "file_key": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                },
+                "file_txn_meta": {
+                    "type": "string"
+                },
+                "file_txn_type": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "loc_tag": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "uploader.FolderChild": {
+            "type": "object",
+            "properties": {
+                "folder": {
+                    "$ref": "#/definitions/uploader.FolderResponse"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.FolderLockPolicyRequest": {
+            "type": "object",
+            "properties": {
+                "lock_mode": {
+                    "description": "GOVERNANCE or COMPLIANCE",
+                    "type": "string"
+                },
+                "retention_days": {
+                    "type": "integer"
+                }
+            }
+        },
+        "uploader.FolderRenameResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
                     "type": "string"
                 },
-                "contact_number": {
+                "folder_id": {
                     "type": "string"
                 },
-                "full_name": {
+                "from_path": {
                     "type": "string"
                 },
-                "project_details": {
+                "id": {
                     "type": "string"
                 },
-                "project_type": {
+                "moved_bytes": {
+                    "type": "integer"
+                },
+                "moved_count": {
+                    "description": "MovedCount and MovedBytes count the files copied so far.",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "Status is copying, deleting (the files are at the new path, the old\nobjects are being deleted) or completed.",
+                    "type": "string"
+                },
+                "to_path": {
                     "type": "string"
                 }
             }
         },
-        "contactus.CreateContactUsResponse": {
+        "uploader.FolderResponse": {
             "type": "object",
             "properties": {
-                "createdAt": {
+                "created_at": {
                     "type": "string"
                 },
-                "msg": {
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lock_mode": {
+                    "description": "Object Lock policy of uploads to the folder.",
+                    "type": "string"
+                },
+                "lock_retention_days": {
+                    "type": "integer"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "purge_at": {
+                    "description": "PurgeAt is when a trashed folder is deleted permanently.",
+                    "type": "string"
+                },
+                "trashed_at": {
+                    "type": "string"
+                },
+                "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "uploader.CompanyFileMetaItem": {
+        "uploader.GenerateUploadURLRequest": {
             "type": "object",
             "properties": {
-                "created_at": {
+                "cache_control": {
                     "type": "string"
                 },
-                "file_key": {
+                "content_disposition": {
+                    "type": "string"
+                },
+                "content_type": {
+                    "description": "Optional headers pinned into the signature; the upload must send them.",
                     "type": "string"
                 },
+                "expires_in": {
+                    "description": "ExpiresIn is the URL lifetime in seconds, default 900. It is shortened\nto the caps of the company and its storage pool.",
+                    "type": "integer"
+                },
                 "file_name": {
+                    "description": "required",
                     "type": "string"
                 },
                 "file_size": {
+                    "description": "required",
                     "type": "integer"
                 },
                 "file_txn_meta": {
+                    "description": "optional",
                     "type": "string"
                 },
                 "file_txn_type": {
+                    "description": "required (e.g. 1=upload)",
                     "type": "integer"
                 },
-                "id": {
+                "loc_tag": {
+                    "description": "required, folder path e.g. \"reports/2025\"",
+                    "type": "string"
+                },
+                "lock_mode": {
+                    "description": "Optional Object Lock; folder lock policies apply without it.",
+                    "type": "string"
+                },
+                "retain_until": {
+                    "description": "RFC3339",
+                    "type": "string"
+                },
+                "storage_class": {
+                    "description": "Optional storage class: STANDARD (default), STANDARD_IA, GLACIER_IR\nor DEEP_ARCHIVE.",
                     "type": "string"
+                },
+                "tags": {
+                    "description": "optional, searchable labels",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "uploader.DeleteFileRequest": {
+        "uploader.GenerateUploadURLResponse": {
             "type": "object",
             "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "file_id": {
+                    "type": "string"
+                },
                 "file_key": {
                     "type": "string"
                 },
-                "file_txn_meta": {
+                "headers": {
+                    "description": "Headers must be sent with the upload, with Content-MD5 when the\nobject is locked.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "upload_url": {
                     "type": "string"
                 }
             }
         },
-        "uploader.DeleteFileResponse": {
+        "uploader.ListCompanyFilesResponse": {
             "type": "object",
             "properties": {
-                "file_key": {
-                    "type": "string"
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/uploader.CompanyFileMetaItem"
+                    }
+                },
+                "remaining_quota": {
+                    "type": "integer"
+                },
+                "total_usage_quota": {
+                    "type": "integer"
+                },
+                "used_quota": {
+                    "type": "integer"
                 }
             }
         },
-        "uploader.DeleteFolderRequest": {
+        "uploader.ListFolderChildrenResponse": {
             "type": "object",
             "properties": {
-                "file_txn_meta": {
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/uploader.CompanyFileMetaItem"
+                    }
+                },
+                "folders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/uploader.FolderChild"
+                    }
+                },
+                "path": {
                     "type": "string"
+                }
+            }
+        },
+        "uploader.ListFoldersResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "folder_prefix": {
+                "next_token": {
                     "type": "string"
                 }
             }
         },
-        "uploader.DeleteFolderResponse": {
+        "uploader.ListPoolsResponse": {
             "type": "object",
             "properties": {
-                "deleted_bytes": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/uploader.PoolResponse"
+                    }
+                }
+            }
+        },
+        "uploader.ListTrashedFoldersResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/uploader.FolderResponse"
+                    }
+                }
+            }
+        },
+        "uploader.PoolResponse": {
+            "type": "object",
+            "properties": {
+                "allocated_quota": {
                     "type": "integer"
                 },
-                "deleted_count": {
+                "aws_bucket_name": {
+                    "type": "string"
+                },
+                "aws_bucket_region": {
+                    "type": "string"
+                },
+                "capacity": {
                     "type": "integer"
                 },
-                "folder_prefix": {
+                "companies": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "default_quota": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "max_upload_url_expiry": {
+                    "description": "seconds",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "previous_key_expires_at": {
+                    "description": "PreviousKeyExpiresAt is set while the credentials replaced by the last\nrotation may still be in use.",
+                    "type": "string"
+                },
+                "status": {
                     "type": "string"
+                },
+                "used_quota": {
+                    "type": "integer"
                 }
             }
         },
-        "uploader.GenerateUploadURLRequest": {
+        "uploader.RegisterCompanyRequest": {
             "type": "object",
             "properties": {
-                "file_name": {
-                    "description": "required",
+                "company_name": {
                     "type": "string"
                 },
-                "file_size": {
-                    "description": "required",
-                    "type": "integer"
+                "end_date": {
+                    "type": "string"
+                },
+                "isolation_mode": {
+                    "description": "shared_prefix (default) or dedicated_bucket",
+                    "type": "string"
+                },
+                "pool": {
+                    "description": "storage pool name",
+                    "type": "string"
+                },
+                "region": {
+                    "description": "preferred pool region",
+                    "type": "string"
                 },
+                "start_date": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.RegisterCompanyResponse": {
+            "type": "object",
+            "properties": {
+                "company_api_key": {
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.RenameFolderRequest": {
+            "type": "object",
+            "properties": {
                 "file_txn_meta": {
-                    "description": "optional",
                     "type": "string"
                 },
-                "file_txn_type": {
-                    "description": "required (e.g. 1=upload)",
+                "path": {
+                    "description": "new loc_tag of the folder",
+                    "type": "string"
+                }
+            }
+        },
+        "uploader.RestoreFileRequest": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "description": "1 to 30, default 7",
                     "type": "integer"
                 },
-                "loc_tag": {
+                "tier": {
+                    "description": "Standard (default), Bulk or Expedited",
                     "type": "string"
                 }
             }
         },
-        "uploader.GenerateUploadURLResponse": {
+        "uploader.RestoreStatusResponse": {
             "type": "object",
             "properties": {
+                "archived": {
+                    "type": "boolean"
+                },
                 "file_id": {
                     "type": "string"
                 },
-                "file_key": {
+                "restore_in_progress": {
+                    "type": "boolean"
+                },
+                "restore_required": {
+                    "description": "RestoreRequired is set while the file cannot be downloaded.",
+                    "type": "boolean"
+                },
+                "restored_until": {
                     "type": "string"
                 },
-                "upload_url": {
+                "storage_class": {
                     "type": "string"
                 }
             }
         },
-        "uploader.ListCompanyFilesResponse": {
+        "uploader.RotateCredentialsRequest": {
+            "type": "object",
+            "properties": {
+                "aws_access_key": {
+                    "type": "string"
+                },
+                "aws_secret_key": {
+                    "type": "string"
+                },
+                "overlap_minutes": {
+                    "description": "OverlapMinutes is how long the old key stays in use, default 60.",
+                    "type": "integer"
+                }
+            }
+        },
+        "uploader.RotateCredentialsResponse": {
+            "type": "object",
+            "properties": {
+                "companies_updated": {
+                    "type": "integer"
+                },
+                "old_key_valid_until": {
+                    "description": "OldKeyValidUntil is when the old key can be deactivated at the provider.",
+                    "type": "string"
+                },
+                "pool": {
+                    "$ref": "#/definitions/uploader.PoolResponse"
+                }
+            }
+        },
+        "uploader.SearchFilesResponse": {
             "type": "object",
             "properties": {
                 "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/uploader.CompanyFileMetaItem"
+                        "$ref": "#/definitions/uploader.FileSearchItem"
                     }
                 },
-                "remaining_quota": {
-                    "type": "integer"
-                },
-                "total_usage_quota": {
-                    "type": "integer"
-                },
-                "used_quota": {
-                    "type": "integer"
+                "next_cursor": {
+                    "description": "NextCursor fetches the next page; empty on the last page.",
+                    "type": "string"
                 }
             }
         },
-        "uploader.RegisterCompanyRequest": {
+        "uploader.UpdateFileRequest": {
             "type": "object",
             "properties": {
-                "company_name": {
+                "description": {
                     "type": "string"
                 },
-                "end_date": {
+                "file_name": {
                     "type": "string"
                 },
-                "start_date": {
+                "file_txn_meta": {
                     "type": "string"
+                },
+                "metadata": {
+                    "description": "JSON object",
+                    "type": "object"
                 }
             }
         },
-        "uploader.RegisterCompanyResponse": {
+        "uploader.UpdateFolderRequest": {
             "type": "object",
             "properties": {
-                "company_api_key": {
+                "description": {
+                    "type": "string"
+                },
+                "owner": {
                     "type": "string"
                 }
             }
+        },
+        "uploader.UploadURLExpiryRequest": {
+            "type": "object",
+            "properties": {
+                "max_upload_url_expiry": {
+                    "type": "integer"
+                }
+            }
+        },
+        "uploader.UploadURLExpiryResponse": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "description": "Default is the lifetime of URLs requested without expires_in.",
+                    "type": "integer"
+                },
+                "effective_max": {
+                    "description": "EffectiveMax is the cap after the pool's, in seconds.",
+                    "type": "integer"
+                },
+                "max_upload_url_expiry": {
+                    "description": "MaxUploadURLExpiry is the company's own cap, if any.",
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
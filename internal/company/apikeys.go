@@ -0,0 +1,405 @@
+package company
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const (
+	// RegistrationKeyLabel labels the key issued at registration.
+	RegistrationKeyLabel = "registration"
+	maxKeyLabel          = 100
+	// maxActiveKeys bounds the keys a company holds at once.
+	maxActiveKeys = 20
+	// keyPrefixLen covers "bkps_" and a few characters of the key.
+	keyPrefixLen = 11
+
+	defaultKeyOverlap = 24 * time.Hour
+	maxKeyOverlap     = 30 * 24 * time.Hour
+)
+
+var errTooManyKeys = errors.New("too many active API keys, revoke one first")
+
+type APIKeyResponse struct {
+	ID         string  `json:"id"`
+	Label      string  `json:"label"`
+	Prefix     string  `json:"prefix"`
+	Status     string  `json:"status"` // active, expired or revoked
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at,omitempty"`
+	ExpiresAt  *string `json:"expires_at,omitempty"`
+	RevokedAt  *string `json:"revoked_at,omitempty"`
+}
+
+type ListAPIKeysResponse struct {
+	Items []APIKeyResponse `json:"items"`
+}
+
+type CreateAPIKeyRequest struct {
+	Label     string  `json:"label"`
+	ExpiresAt *string `json:"expires_at,omitempty"` // RFC3339
+}
+
+// CreatedAPIKeyResponse carries the key itself, which is shown only once.
+type CreatedAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+type RotateAPIKeyRequest struct {
+	// Label of the new key, default the label of the calling key.
+	Label string `json:"label,omitempty"`
+	// OverlapMinutes is how long the calling key stays valid, default 1440;
+	// 0 expires it at once.
+	OverlapMinutes *int `json:"overlap_minutes,omitempty"`
+}
+
+type RotateAPIKeyResponse struct {
+	Key      CreatedAPIKeyResponse `json:"key"`
+	Previous APIKeyResponse        `json:"previous"`
+}
+
+func toAPIKeyResponse(k APIKey, now time.Time) APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:         k.ID,
+		Label:      k.Label,
+		Prefix:     k.Prefix,
+		Status:     "active",
+		CreatedAt:  k.CreatedAt.Format(time.RFC3339),
+		LastUsedAt: formatTime(k.LastUsedAt),
+		ExpiresAt:  formatTime(k.ExpiresAt),
+		RevokedAt:  formatTime(k.RevokedAt),
+	}
+	switch {
+	case k.RevokedAt != nil:
+		resp.Status = "revoked"
+	case !k.Active(now):
+		resp.Status = "expired"
+	}
+	return resp
+}
+
+func (h *Handler) writeAPIKeys(w http.ResponseWriter, companyID string) {
+	keys, err := h.repo.ListAPIKeys(companyID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	items := make([]APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, toAPIKeyResponse(k, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListAPIKeysResponse{Items: items})
+}
+
+// issueAPIKey generates a key for the company. It returns the row, still to
+// be stored within maxActiveKeys, and the key itself.
+func (h *Handler) issueAPIKey(companyID, label string, now time.Time) (*APIKey, string, error) {
+	key, err := utils.GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	k := h.hasher.NewAPIKey(companyID, label, key)
+	k.CreatedAt = now
+	return k, key, nil
+}
+
+// lookupAPIKey returns the company holding apiKey and the key, or nils if
+// the key is unknown or not active at now.
+func lookupAPIKey(repo Repository, hasher *KeyHasher, apiKey string, now time.Time) (*Company, *APIKey, error) {
+	if len(apiKey) < keyPrefixLen {
+		return nil, nil, nil
+	}
+	candidates, err := repo.ListAPIKeysByPrefix(KeyPrefix(apiKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range candidates {
+		k := &candidates[i]
+		if !hasher.Matches(apiKey, k.KeyHash) {
+			continue
+		}
+		if !k.Active(now) {
+			return nil, nil, nil
+		}
+		c, err := repo.GetByID(k.CompanyID)
+		if err != nil || c == nil {
+			return nil, nil, err
+		}
+		return c, k, nil
+	}
+	return nil, nil, nil
+}
+
+func (h *Handler) createAPIKey(w http.ResponseWriter, r *http.Request, companyID string) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Label == "" || len(req.Label) > maxKeyLabel {
+		http.Error(w, "label must be 1 to 100 characters", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			http.Error(w, "expires_at must be RFC3339", http.StatusBadRequest)
+			return
+		}
+		if !t.After(now) {
+			http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+			return
+		}
+		expiresAt = &t
+	}
+
+	k, key, err := h.issueAPIKey(companyID, req.Label, now)
+	if err != nil {
+		http.Error(w, "failed to issue API key", http.StatusInternalServerError)
+		return
+	}
+	k.ExpiresAt = expiresAt
+	stored, err := h.repo.CreateAPIKey(k, maxActiveKeys)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !stored {
+		http.Error(w, errTooManyKeys.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(CreatedAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(*k, now), Key: key})
+}
+
+// revokeAPIKey revokes the key named by the keyID URL parameter. Unless
+// allowLast is set, the company's last active key cannot be revoked.
+func (h *Handler) revokeAPIKey(w http.ResponseWriter, r *http.Request, companyID string, allowLast bool) {
+	k, err := h.repo.GetAPIKey(companyID, chi.URLParam(r, "keyID"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if k == nil {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if k.RevokedAt != nil {
+		http.Error(w, "API key is already revoked", http.StatusConflict)
+		return
+	}
+	now := time.Now()
+	revoked, err := h.repo.RevokeAPIKey(k, now, !allowLast)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "the last active API key cannot be revoked", http.StatusConflict)
+		return
+	}
+	k.RevokedAt = &now
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toAPIKeyResponse(*k, now))
+}
+
+// ListAPIKeys godoc
+// @Summary      List the API keys of the calling company
+// @Description  Keys are identified by their prefix; the keys themselves are only shown when issued.
+// @Tags         company
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Success      200        {object}  ListAPIKeysResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/api-keys [get]
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	h.writeAPIKeys(w, FromContext(r.Context()).ID)
+}
+
+// CreateAPIKey godoc
+// @Summary      Issue an additional API key for the calling company
+// @Description  The key is returned once. A company holds at most 20 active keys.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string               true  "Company API key"
+// @Param        body       body      CreateAPIKeyRequest  true  "Label and optional expiry"
+// @Success      201        {object}  CreatedAPIKeyResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      409        {string}  string "too many active API keys"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/api-keys [post]
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	h.createAPIKey(w, r, FromContext(r.Context()).ID)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key of the calling company
+// @Description  Takes effect at once. The last active key cannot be revoked; issue another one first.
+// @Tags         company
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        keyID      path      string  true  "API key ID"
+// @Success      200        {object}  APIKeyResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "API key not found"
+// @Failure      409        {string}  string "already revoked or last active key"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/api-keys/{keyID}/revoke [post]
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	h.revokeAPIKey(w, r, FromContext(r.Context()).ID, false)
+}
+
+// RotateAPIKey godoc
+// @Summary      Rotate the calling API key
+// @Description  Issues a new key and lets the key the request is made with expire after overlap_minutes (default 1440, at most 43200; 0 expires it at once), so clients can switch without downtime.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string               true   "Company API key to rotate"
+// @Param        body       body      RotateAPIKeyRequest  false  "Rotation options"
+// @Success      201        {object}  RotateAPIKeyResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      409        {string}  string "too many active API keys"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/api-keys/rotate [post]
+func (h *Handler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	companyRec := FromContext(r.Context())
+	old := APIKeyFromContext(r.Context())
+
+	var req RotateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Label) > maxKeyLabel {
+		http.Error(w, "label must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if req.Label == "" {
+		req.Label = old.Label
+	}
+	overlap := defaultKeyOverlap
+	if req.OverlapMinutes != nil {
+		overlap = time.Duration(*req.OverlapMinutes) * time.Minute
+		if overlap < 0 || overlap > maxKeyOverlap {
+			http.Error(w, "overlap_minutes must be between 0 and 43200", http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	next, key, err := h.issueAPIKey(companyRec.ID, req.Label, now)
+	if err != nil {
+		http.Error(w, "failed to issue API key", http.StatusInternalServerError)
+		return
+	}
+	next.ExpiresAt = old.ExpiresAt
+	oldExpiresAt := now.Add(overlap)
+	stored, err := h.repo.RotateAPIKey(old, next, oldExpiresAt, maxActiveKeys)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !stored {
+		http.Error(w, errTooManyKeys.Error(), http.StatusConflict)
+		return
+	}
+	if old.ExpiresAt == nil || old.ExpiresAt.After(oldExpiresAt) {
+		old.ExpiresAt = &oldExpiresAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(RotateAPIKeyResponse{
+		Key:      CreatedAPIKeyResponse{APIKeyResponse: toAPIKeyResponse(*next, now), Key: key},
+		Previous: toAPIKeyResponse(*old, now),
+	})
+}
+
+// AdminListAPIKeys godoc
+// @Summary      List the API keys of a company
+// @Tags         company
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Company ID"
+// @Success      200            {object}  ListAPIKeysResponse
+// @Failure      404            {string}  string "company not found"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id}/api-keys [get]
+func (h *Handler) AdminListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+	h.writeAPIKeys(w, c.ID)
+}
+
+// AdminCreateAPIKey godoc
+// @Summary      Issue an API key for a company
+// @Description  For companies that lost their keys. The key is returned once.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string               true  "Admin client id"
+// @Param        client_secret  header    string               true  "Admin client secret"
+// @Param        id             path      string               true  "Company ID"
+// @Param        body           body      CreateAPIKeyRequest  true  "Label and optional expiry"
+// @Success      201            {object}  CreatedAPIKeyResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Failure      409            {string}  string "too many active API keys"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id}/api-keys [post]
+func (h *Handler) AdminCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+	h.createAPIKey(w, r, c.ID)
+}
+
+// AdminRevokeAPIKey godoc
+// @Summary      Revoke an API key of a company
+// @Description  Unlike the company's own revoke, this may revoke its last active key.
+// @Tags         company
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Company ID"
+// @Param        keyID          path      string  true  "API key ID"
+// @Success      200            {object}  APIKeyResponse
+// @Failure      404            {string}  string "company or API key not found"
+// @Failure      409            {string}  string "API key is already revoked"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id}/api-keys/{keyID}/revoke [post]
+func (h *Handler) AdminRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+	h.revokeAPIKey(w, r, c.ID, true)
+}
@@ -0,0 +1,120 @@
+package company
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeKeyRepo struct {
+	Repository
+	full         bool
+	oldExpiresAt *time.Time
+}
+
+func (f *fakeKeyRepo) RotateAPIKey(old *APIKey, next *APIKey, oldExpiresAt time.Time, maxActive int64) (bool, error) {
+	if f.full {
+		return false, nil
+	}
+	f.oldExpiresAt = &oldExpiresAt
+	return true, nil
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		full        bool
+		wantStatus  int
+		wantOverlap time.Duration
+	}{
+		{name: "default overlap", body: `{}`, wantStatus: http.StatusCreated, wantOverlap: defaultKeyOverlap},
+		{name: "no body", wantStatus: http.StatusCreated, wantOverlap: defaultKeyOverlap},
+		{name: "zero expires at once", body: `{"overlap_minutes":0}`, wantStatus: http.StatusCreated},
+		{name: "minutes", body: `{"overlap_minutes":90}`, wantStatus: http.StatusCreated, wantOverlap: 90 * time.Minute},
+		{name: "negative", body: `{"overlap_minutes":-1}`, wantStatus: http.StatusBadRequest},
+		{name: "too long", body: `{"overlap_minutes":43201}`, wantStatus: http.StatusBadRequest},
+		{name: "too many keys", body: `{}`, full: true, wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeKeyRepo{full: tt.full}
+			h := NewHandler(repo, nil, NewKeyHasher(localPepper), 0)
+
+			req := httptest.NewRequest(http.MethodPost, "/uploader/api-keys/rotate", strings.NewReader(tt.body))
+			ctx := NewContext(req.Context(), &Company{ID: "c1"})
+			ctx = context.WithValue(ctx, apiKeyContextKey{}, &APIKey{ID: "k1", CompanyID: "c1", Label: "ci"})
+			rec := httptest.NewRecorder()
+			before := time.Now()
+			h.RotateAPIKey(rec, req.WithContext(ctx))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			overlap := repo.oldExpiresAt.Sub(before)
+			if overlap < tt.wantOverlap || overlap > tt.wantOverlap+time.Second {
+				t.Errorf("old key expires after %v, want %v", overlap, tt.wantOverlap)
+			}
+		})
+	}
+}
+
+type fakeRevokeRepo struct {
+	Repository
+	key      *APIKey
+	last     bool
+	keepLast *bool
+}
+
+func (f *fakeRevokeRepo) GetAPIKey(companyID, id string) (*APIKey, error) {
+	return f.key, nil
+}
+
+func (f *fakeRevokeRepo) RevokeAPIKey(k *APIKey, at time.Time, keepLast bool) (bool, error) {
+	f.keepLast = &keepLast
+	return !(keepLast && f.last), nil
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	earlier := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name         string
+		admin        bool
+		key          *APIKey
+		last         bool
+		wantStatus   int
+		wantKeepLast bool
+	}{
+		{name: "one of several", key: &APIKey{ID: "k1", CompanyID: "c1"}, wantStatus: http.StatusOK, wantKeepLast: true},
+		{name: "last key", key: &APIKey{ID: "k1", CompanyID: "c1"}, last: true, wantStatus: http.StatusConflict, wantKeepLast: true},
+		{name: "last key by an admin", admin: true, key: &APIKey{ID: "k1", CompanyID: "c1"}, last: true, wantStatus: http.StatusOK},
+		{name: "already revoked", key: &APIKey{ID: "k1", CompanyID: "c1", RevokedAt: &earlier}, wantStatus: http.StatusConflict},
+		{name: "unknown key", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRevokeRepo{key: tt.key, last: tt.last}
+			h := NewHandler(repo, nil, NewKeyHasher(localPepper), 0)
+
+			req := httptest.NewRequest(http.MethodPost, "/uploader/api-keys/k1/revoke", nil)
+			rec := httptest.NewRecorder()
+			h.revokeAPIKey(rec, req, "c1", tt.admin)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if repo.keepLast != nil && *repo.keepLast != tt.wantKeepLast {
+				t.Errorf("keepLast = %v, want %v", *repo.keepLast, tt.wantKeepLast)
+			}
+		})
+	}
+}
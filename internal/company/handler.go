@@ -0,0 +1,456 @@
+package company
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+// dateLayout is the format of subscription dates, as in registration.
+const dateLayout = "02-01-2006"
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+	maxReasonLen     = 255
+)
+
+type CompanyResponse struct {
+	ID                 string  `json:"id"`
+	CompanyName        string  `json:"company_name"`
+	CompanySlug        string  `json:"company_slug"`
+	IsolationMode      string  `json:"isolation_mode"`
+	PoolID             *string `json:"pool_id,omitempty"`
+	AwsBucketName      *string `json:"aws_bucket_name,omitempty"`
+	StartDate          *string `json:"start_date,omitempty"` // DD-MM-YYYY
+	EndDate            *string `json:"end_date,omitempty"`   // DD-MM-YYYY
+	TotalUsageQuota    *int64  `json:"total_usage_quota,omitempty"`
+	UsedQuota          int64   `json:"used_quota"`
+	MaxUploadURLExpiry *int    `json:"max_upload_url_expiry,omitempty"` // seconds
+	Status             string  `json:"status"`                          // active or suspended
+	SuspendedAt        *string `json:"suspended_at,omitempty"`
+	SuspensionReason   *string `json:"suspension_reason,omitempty"`
+	// Subscription is active, grace (read-only), expired or not_started.
+	Subscription string  `json:"subscription"`
+	GraceUntil   *string `json:"grace_until,omitempty"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+type ListCompaniesResponse struct {
+	Items []CompanyResponse `json:"items"`
+	Total int64             `json:"total"`
+}
+
+type CompanyUsage struct {
+	TotalUsageQuota *int64 `json:"total_usage_quota,omitempty"`
+	UsedQuota       int64  `json:"used_quota"`
+	RemainingQuota  *int64 `json:"remaining_quota,omitempty"`
+	Files           int64  `json:"files"`
+	StoredBytes     int64  `json:"stored_bytes"`
+}
+
+type CompanyDetailResponse struct {
+	CompanyResponse
+	Usage CompanyUsage `json:"usage"`
+}
+
+// UpdateCompanyRequest changes the given fields and keeps the omitted ones.
+type UpdateCompanyRequest struct {
+	CompanyName     *string `json:"company_name,omitempty"`
+	StartDate       *string `json:"start_date,omitempty"` // DD-MM-YYYY
+	EndDate         *string `json:"end_date,omitempty"`   // DD-MM-YYYY
+	TotalUsageQuota *int64  `json:"total_usage_quota,omitempty"`
+}
+
+// RenewSubscriptionRequest either extends the subscription by extend_days
+// or replaces its window with end_date and, optionally, start_date.
+type RenewSubscriptionRequest struct {
+	ExtendDays *int    `json:"extend_days,omitempty"`
+	StartDate  *string `json:"start_date,omitempty"` // DD-MM-YYYY
+	EndDate    *string `json:"end_date,omitempty"`   // DD-MM-YYYY
+}
+
+type SuspendCompanyRequest struct {
+	Reason *string `json:"reason,omitempty"`
+}
+
+// Handler serves the admin API for companies.
+type Handler struct {
+	repo         Repository
+	fileMetaRepo filemeta.Repository
+	hasher       *KeyHasher
+	// grace is how long an ended subscription stays read-only.
+	grace time.Duration
+}
+
+func NewHandler(repo Repository, fileMetaRepo filemeta.Repository, hasher *KeyHasher, grace time.Duration) *Handler {
+	return &Handler{repo: repo, fileMetaRepo: fileMetaRepo, hasher: hasher, grace: grace}
+}
+
+func formatDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(dateLayout)
+	return &s
+}
+
+func (h *Handler) toCompanyResponse(c Company) CompanyResponse {
+	sub := c.Subscription(time.Now(), h.grace)
+	resp := CompanyResponse{
+		ID:                 c.ID,
+		CompanyName:        c.CompanyName,
+		CompanySlug:        c.CompanySlug,
+		IsolationMode:      c.IsolationMode,
+		PoolID:             c.UploaderConfigID,
+		AwsBucketName:      c.AwsBucketName,
+		StartDate:          formatDate(c.StartDate),
+		EndDate:            formatDate(c.EndDate),
+		TotalUsageQuota:    c.TotalUsageQuota,
+		UsedQuota:          c.UsedQuota,
+		MaxUploadURLExpiry: c.MaxUploadURLExpiry,
+		Status:             "active",
+		SuspensionReason:   c.SuspensionReason,
+		Subscription:       sub.State,
+		GraceUntil:         formatTime(sub.GraceUntil),
+		CreatedAt:          c.CreatedAt.Format(time.RFC3339),
+	}
+	if c.Suspended() {
+		suspendedAt := c.SuspendedAt.Format(time.RFC3339)
+		resp.Status = "suspended"
+		resp.SuspendedAt = &suspendedAt
+	}
+	return resp
+}
+
+// company loads the company named by the id URL parameter. It answers 404
+// for unknown companies and returns nil if a response was written.
+func (h *Handler) company(w http.ResponseWriter, r *http.Request) *Company {
+	c, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return nil
+	}
+	if c == nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return nil
+	}
+	return c
+}
+
+// ListCompanies godoc
+// @Summary      List companies
+// @Tags         company
+// @Produce      json
+// @Param        client_id      header    string  true   "Admin client id"
+// @Param        client_secret  header    string  true   "Admin client secret"
+// @Param        q              query     string  false  "Only companies whose name or slug contains q"
+// @Param        limit          query     int     false  "Max number of items (default 50, max 500)"
+// @Param        offset         query     int     false  "Offset for pagination (default 0)"
+// @Success      200            {object}  ListCompaniesResponse
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies [get]
+func (h *Handler) ListCompanies(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := defaultListLimit
+	offset := 0
+	if v := q.Get("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= maxListLimit {
+			limit = parsed
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	companies, total, err := h.repo.List(q.Get("q"), limit, offset)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	items := make([]CompanyResponse, 0, len(companies))
+	for _, c := range companies {
+		items = append(items, h.toCompanyResponse(c))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListCompaniesResponse{Items: items, Total: total})
+}
+
+// GetCompany godoc
+// @Summary      Get a company with its usage
+// @Tags         company
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Company ID"
+// @Success      200            {object}  CompanyDetailResponse
+// @Failure      404            {string}  string "company not found"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id} [get]
+func (h *Handler) GetCompany(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+	h.writeDetail(w, c)
+}
+
+func (h *Handler) writeDetail(w http.ResponseWriter, c *Company) {
+	stats, err := h.fileMetaRepo.Stats(c.ID)
+	if err != nil {
+		http.Error(w, "failed to count files", http.StatusInternalServerError)
+		return
+	}
+	usage := CompanyUsage{
+		TotalUsageQuota: c.TotalUsageQuota,
+		UsedQuota:       c.UsedQuota,
+		Files:           stats.Files,
+		StoredBytes:     stats.Bytes,
+	}
+	if c.TotalUsageQuota != nil {
+		remaining := *c.TotalUsageQuota - c.UsedQuota
+		usage.RemainingQuota = &remaining
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(CompanyDetailResponse{CompanyResponse: h.toCompanyResponse(*c), Usage: usage})
+}
+
+// UpdateCompany godoc
+// @Summary      Update a company
+// @Description  Changes the name, subscription dates (DD-MM-YYYY) and total quota of a company; omitted fields are kept.
+// @Description  The slug, and with it the storage prefix, does not change with the name.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string                true  "Admin client id"
+// @Param        client_secret  header    string                true  "Admin client secret"
+// @Param        id             path      string                true  "Company ID"
+// @Param        body           body      UpdateCompanyRequest  true  "Fields to change"
+// @Success      200            {object}  CompanyDetailResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id} [patch]
+func (h *Handler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+
+	var req UpdateCompanyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.CompanyName != nil {
+		if *req.CompanyName == "" || len(*req.CompanyName) > 144 {
+			http.Error(w, "company_name must be 1 to 144 characters", http.StatusBadRequest)
+			return
+		}
+		c.CompanyName = *req.CompanyName
+	}
+	for name, field := range map[string]struct {
+		value  *string
+		target **time.Time
+	}{"start_date": {req.StartDate, &c.StartDate}, "end_date": {req.EndDate, &c.EndDate}} {
+		if field.value == nil {
+			continue
+		}
+		t, err := time.Parse(dateLayout, *field.value)
+		if err != nil {
+			http.Error(w, name+" must be DD-MM-YYYY", http.StatusBadRequest)
+			return
+		}
+		*field.target = &t
+	}
+	if !validWindow(c) {
+		http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+		return
+	}
+	if req.TotalUsageQuota != nil {
+		if *req.TotalUsageQuota < 0 {
+			http.Error(w, "total_usage_quota must be >= 0", http.StatusBadRequest)
+			return
+		}
+		c.TotalUsageQuota = req.TotalUsageQuota
+	}
+
+	if err := h.repo.UpdateDetails(c); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	h.writeDetail(w, c)
+}
+
+// SuspendCompany godoc
+// @Summary      Suspend a company
+// @Description  The company's API key is rejected with 403 on every uploader endpoint until it is reactivated. Stored files are kept.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string                 true   "Admin client id"
+// @Param        client_secret  header    string                 true   "Admin client secret"
+// @Param        id             path      string                 true   "Company ID"
+// @Param        body           body      SuspendCompanyRequest  false  "Reason"
+// @Success      200            {object}  CompanyResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Failure      409            {string}  string "company is already suspended"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id}/suspend [post]
+func (h *Handler) SuspendCompany(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+
+	var req SuspendCompanyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Reason != nil && len(*req.Reason) > maxReasonLen {
+		http.Error(w, "reason must be at most 255 characters", http.StatusBadRequest)
+		return
+	}
+	if c.Suspended() {
+		http.Error(w, "company is already suspended", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	if err := h.repo.SetSuspended(c.ID, &now, req.Reason); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	c.SuspendedAt, c.SuspensionReason = &now, req.Reason
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.toCompanyResponse(*c))
+}
+
+// ReactivateCompany godoc
+// @Summary      Reactivate a suspended company
+// @Tags         company
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Company ID"
+// @Success      200            {object}  CompanyResponse
+// @Failure      404            {string}  string "company not found"
+// @Failure      409            {string}  string "company is not suspended"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id}/reactivate [post]
+func (h *Handler) ReactivateCompany(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+	if !c.Suspended() {
+		http.Error(w, "company is not suspended", http.StatusConflict)
+		return
+	}
+
+	if err := h.repo.SetSuspended(c.ID, nil, nil); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	c.SuspendedAt, c.SuspensionReason = nil, nil
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.toCompanyResponse(*c))
+}
+
+func validWindow(c *Company) bool {
+	return c.StartDate == nil || c.EndDate == nil || !c.EndDate.Before(*c.StartDate)
+}
+
+// RenewSubscription godoc
+// @Summary      Renew the subscription of a company
+// @Description  extend_days moves the end date by that many days, counted from today if the subscription has already ended.
+// @Description  Otherwise end_date (DD-MM-YYYY) replaces the end date and start_date, if given, the start date.
+// @Description  A renewed company leaves its grace period or expiry at once.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string                    true  "Admin client id"
+// @Param        client_secret  header    string                    true  "Admin client secret"
+// @Param        id             path      string                    true  "Company ID"
+// @Param        body           body      RenewSubscriptionRequest  true  "New window"
+// @Success      200            {object}  CompanyResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/companies/{id}/renew [post]
+func (h *Handler) RenewSubscription(w http.ResponseWriter, r *http.Request) {
+	c := h.company(w, r)
+	if c == nil {
+		return
+	}
+
+	var req RenewSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if (req.ExtendDays == nil) == (req.EndDate == nil) {
+		http.Error(w, "exactly one of extend_days or end_date is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.ExtendDays != nil {
+		if req.StartDate != nil {
+			http.Error(w, "start_date cannot be combined with extend_days", http.StatusBadRequest)
+			return
+		}
+		if *req.ExtendDays <= 0 {
+			http.Error(w, "extend_days must be > 0", http.StatusBadRequest)
+			return
+		}
+		// The end date is the last day of the subscription, so an ended
+		// subscription is extended from yesterday.
+		from := day(time.Now()).AddDate(0, 0, -1)
+		if c.EndDate != nil && day(*c.EndDate).After(from) {
+			from = day(*c.EndDate)
+		}
+		endDate := from.AddDate(0, 0, *req.ExtendDays)
+		c.EndDate = &endDate
+	} else {
+		endDate, err := time.Parse(dateLayout, *req.EndDate)
+		if err != nil {
+			http.Error(w, "end_date must be DD-MM-YYYY", http.StatusBadRequest)
+			return
+		}
+		c.EndDate = &endDate
+		if req.StartDate != nil {
+			startDate, err := time.Parse(dateLayout, *req.StartDate)
+			if err != nil {
+				http.Error(w, "start_date must be DD-MM-YYYY", http.StatusBadRequest)
+				return
+			}
+			c.StartDate = &startDate
+		}
+	}
+	if !validWindow(c) {
+		http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.UpdateDetails(c); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.toCompanyResponse(*c))
+}
@@ -0,0 +1,78 @@
+package company
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const minPepperLen = 32
+
+// localPepper hashes API keys in the local environment when no pepper is
+// configured. It is public, so local hashes protect nothing.
+var localPepper = []byte("local-api-key-pepper-do-not-use-in-prod")
+
+// KeyHasher hashes API keys for storage with HMAC-SHA256 under a server
+// pepper, so a database dump alone does not reveal usable keys.
+type KeyHasher struct {
+	pepper []byte
+}
+
+func NewKeyHasher(pepper []byte) *KeyHasher {
+	return &KeyHasher{pepper: pepper}
+}
+
+// KeyHasherFromConfig builds the hasher from API_KEY_PEPPER. Outside the
+// local environment the pepper is mandatory. Changing it invalidates every
+// stored key.
+func KeyHasherFromConfig(cfg *config.Config) (*KeyHasher, error) {
+	if cfg.APIKeyPepper == "" {
+		if cfg.APP_ENV != config.Local.String() {
+			return nil, errors.New("API_KEY_PEPPER is required")
+		}
+		log.Println("no API key pepper configured, using the local pepper")
+		return NewKeyHasher(localPepper), nil
+	}
+
+	pepper, err := base64.StdEncoding.DecodeString(cfg.APIKeyPepper)
+	if err != nil || len(pepper) < minPepperLen {
+		return nil, errors.New("API_KEY_PEPPER must be at least 32 base64 encoded bytes")
+	}
+	return NewKeyHasher(pepper), nil
+}
+
+// Hash returns the hex encoded HMAC of key.
+func (h *KeyHasher) Hash(key string) string {
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Matches reports in constant time whether hash is the hash of key.
+func (h *KeyHasher) Matches(key, hash string) bool {
+	return hmac.Equal([]byte(h.Hash(key)), []byte(hash))
+}
+
+// NewAPIKey returns a key row for the company holding key. Only the hash and
+// prefix of key are kept.
+func (h *KeyHasher) NewAPIKey(companyID, label, key string) *APIKey {
+	return &APIKey{
+		ID:        utils.GenerateID(),
+		CompanyID: companyID,
+		Label:     label,
+		KeyHash:   h.Hash(key),
+		Prefix:    KeyPrefix(key),
+	}
+}
+
+// KeyPrefix returns the visible start of key, used to find and tell keys
+// apart.
+func KeyPrefix(key string) string {
+	return key[:min(len(key), keyPrefixLen)]
+}
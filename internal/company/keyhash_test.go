@@ -0,0 +1,96 @@
+package company
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"shreshtasmg.in/jupyter/internal/config"
+)
+
+func TestKeyHasherMatches(t *testing.T) {
+	hasher := NewKeyHasher(bytes.Repeat([]byte("p"), minPepperLen))
+	other := NewKeyHasher(bytes.Repeat([]byte("q"), minPepperLen))
+	key := "bkps_0123456789abcdef"
+	hash := hasher.Hash(key)
+
+	tests := []struct {
+		name   string
+		hasher *KeyHasher
+		key    string
+		hash   string
+		want   bool
+	}{
+		{name: "same key", hasher: hasher, key: key, hash: hash, want: true},
+		{name: "other key", hasher: hasher, key: key + "0", hash: hash},
+		{name: "other pepper", hasher: other, key: key, hash: hash},
+		{name: "plaintext stored key", hasher: hasher, key: key, hash: key},
+		{name: "uppercase hash", hasher: hasher, key: key, hash: string(bytes.ToUpper([]byte(hash)))},
+		{name: "empty hash", hasher: hasher, key: key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.Matches(tt.key, tt.hash); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "bkps_0123456789abcdef", want: "bkps_012345"},
+		{key: "bkps_012345", want: "bkps_012345"},
+		{key: "bkps", want: "bkps"},
+		{key: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := KeyPrefix(tt.key); got != tt.want {
+			t.Errorf("KeyPrefix(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestNewAPIKeyKeepsNoPlaintext(t *testing.T) {
+	hasher := NewKeyHasher(localPepper)
+	key := "bkps_0123456789abcdef"
+	k := hasher.NewAPIKey("c1", "ci", key)
+	if k.KeyHash == key || !hasher.Matches(key, k.KeyHash) {
+		t.Errorf("KeyHash = %q, want the hash of the key", k.KeyHash)
+	}
+	if k.Prefix != KeyPrefix(key) {
+		t.Errorf("Prefix = %q, want %q", k.Prefix, KeyPrefix(key))
+	}
+}
+
+func TestKeyHasherFromConfig(t *testing.T) {
+	pepper := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("p"), minPepperLen))
+	short := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("p"), minPepperLen-1))
+
+	tests := []struct {
+		name    string
+		env     string
+		pepper  string
+		wantErr bool
+	}{
+		{name: "configured pepper", env: "prod", pepper: pepper},
+		{name: "local pepper", env: config.Local.String()},
+		{name: "missing pepper outside local", env: "prod", wantErr: true},
+		{name: "short pepper", env: "prod", pepper: short, wantErr: true},
+		{name: "pepper not base64", env: "prod", pepper: "not base64!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := KeyHasherFromConfig(&config.Config{APP_ENV: tt.env, APIKeyPepper: tt.pepper})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
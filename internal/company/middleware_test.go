@@ -0,0 +1,81 @@
+package company
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeLookupRepo struct {
+	Repository
+	keys      []APIKey
+	companies map[string]*Company
+}
+
+func (f *fakeLookupRepo) ListAPIKeysByPrefix(prefix string) ([]APIKey, error) {
+	var keys []APIKey
+	for _, k := range f.keys {
+		if k.Prefix == prefix {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeLookupRepo) GetByID(companyID string) (*Company, error) {
+	return f.companies[companyID], nil
+}
+
+func TestLookupAPIKey(t *testing.T) {
+	hasher := NewKeyHasher(localPepper)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	earlier, later := now.Add(-time.Minute), now.Add(time.Minute)
+
+	key := "bkps_0123456789abcdef"
+	// twin shares the prefix of key, as keys of different companies may.
+	twin := "bkps_0123456789other"
+	active := hasher.NewAPIKey("c1", "active", key)
+	twinKey := hasher.NewAPIKey("c2", "twin", twin)
+
+	tests := []struct {
+		name        string
+		stored      []APIKey
+		key         string
+		wantCompany string
+		wantKey     string
+	}{
+		{name: "active key", stored: []APIKey{*twinKey, *active}, key: key, wantCompany: "c1", wantKey: active.ID},
+		{name: "key sharing a prefix", stored: []APIKey{*twinKey, *active}, key: twin, wantCompany: "c2", wantKey: twinKey.ID},
+		{name: "unknown key", stored: []APIKey{*active}, key: "bkps_0123456789zzzzzz"},
+		{name: "shorter than a prefix", stored: []APIKey{*active}, key: "bkps_01"},
+		{name: "empty", stored: []APIKey{*active}},
+		{name: "plaintext row", stored: []APIKey{{ID: "k1", CompanyID: "c1", KeyHash: key, Prefix: KeyPrefix(key)}}, key: key},
+		{name: "revoked", stored: []APIKey{withKey(*active, func(k *APIKey) { k.RevokedAt = &earlier })}, key: key},
+		{name: "expired", stored: []APIKey{withKey(*active, func(k *APIKey) { k.ExpiresAt = &now })}, key: key},
+		{name: "expiring later", stored: []APIKey{withKey(*active, func(k *APIKey) { k.ExpiresAt = &later })}, key: key, wantCompany: "c1", wantKey: active.ID},
+		{name: "company gone", stored: []APIKey{*hasher.NewAPIKey("c9", "orphan", key)}, key: key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeLookupRepo{keys: tt.stored, companies: map[string]*Company{"c1": {ID: "c1"}, "c2": {ID: "c2"}}}
+			c, k, err := lookupAPIKey(repo, hasher, tt.key, now)
+			if err != nil {
+				t.Fatalf("lookupAPIKey: %v", err)
+			}
+			if tt.wantCompany == "" {
+				if c != nil || k != nil {
+					t.Errorf("found %v, %v, want nothing", c, k)
+				}
+				return
+			}
+			if c == nil || c.ID != tt.wantCompany || k == nil || k.ID != tt.wantKey {
+				t.Errorf("found %v, %v, want company %s key %s", c, k, tt.wantCompany, tt.wantKey)
+			}
+		})
+	}
+}
+
+func withKey(k APIKey, change func(k *APIKey)) APIKey {
+	change(&k)
+	return k
+}
@@ -2,23 +2,79 @@ package company
 
 import "time"
 
+// Storage isolation modes.
+const (
+	// IsolationSharedPrefix stores the company under its slug prefix in a shared bucket.
+	IsolationSharedPrefix = "shared_prefix"
+	// IsolationDedicatedBucket gives the company a bucket of its own.
+	IsolationDedicatedBucket = "dedicated_bucket"
+)
+
 type Company struct {
-	ID              string     `gorm:"type:varchar(40);primaryKey;column:id"`
-	CreatedAt       time.Time  `gorm:"column:created_at;autoCreateTime"`
-	CompanyName     string     `gorm:"type:varchar(144);not null;column:company_name"`
-	CompanySlug     string     `gorm:"type:varchar(255);not null;column:company_slug"`
-	CompanyAPIKey   string     `gorm:"type:varchar(255);not null;column:company_api_key"`
+	ID          string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+	CompanyName string    `gorm:"type:varchar(144);not null;column:company_name"`
+	CompanySlug string    `gorm:"type:varchar(255);not null;column:company_slug"`
+	// CompanyAPIKey held the key of companies registered before API keys had
+	// a table of their own. It is no longer written; the api-keys data
+	// migration copies it to APIKey and clear-company-api-keys clears it.
+	CompanyAPIKey   *string    `gorm:"type:varchar(255);column:company_api_key"`
 	StartDate       *time.Time `gorm:"column:start_date"`
 	EndDate         *time.Time `gorm:"column:end_date"`
 	TotalUsageQuota *int64     `gorm:"column:total_usage_quota"`
 	UsedQuota       int64      `gorm:"column:used_quota;default:0"`
 	AwsBucketName   *string    `gorm:"type:varchar(64);column:aws_bucket_name"`
 	AwsBucketRegion *string    `gorm:"type:varchar(50);column:aws_bucket_region"`
-	AwsAccessKey    *string    `gorm:"type:varchar(25);column:aws_access_key"`
-	AwsSecretKey    *string    `gorm:"type:varchar(45);column:aws_secret_key"`
-	UpdatedAt       time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+	AwsAccessKey    *string    `gorm:"type:varchar(255);column:aws_access_key"`
+	AwsSecretKey    *string    `gorm:"type:varchar(255);column:aws_secret_key"`
+	IsolationMode   string     `gorm:"type:varchar(20);not null;default:shared_prefix;column:isolation_mode"`
+	// UploaderConfigID is the storage pool the company was placed in.
+	UploaderConfigID *string `gorm:"type:varchar(40);index;column:uploader_config_id"`
+	// MaxUploadURLExpiry caps the lifetime of the company's presigned upload
+	// URLs, in seconds, below the cap of its pool.
+	MaxUploadURLExpiry *int `gorm:"column:max_upload_url_expiry"`
+	// AwsIAMUser is the IAM user whose keys, limited to the company's
+	// dedicated bucket, the company holds instead of its pool's keys.
+	AwsIAMUser *string `gorm:"type:varchar(64);column:aws_iam_user"`
+	// SuspendedAt is set while the company is suspended; its API key is
+	// rejected until it is reactivated.
+	SuspendedAt      *time.Time `gorm:"column:suspended_at"`
+	SuspensionReason *string    `gorm:"type:varchar(255);column:suspension_reason"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 func (Company) TableName() string {
 	return "companies"
 }
+
+// Suspended reports whether the company is suspended.
+func (c Company) Suspended() bool {
+	return c.SuspendedAt != nil
+}
+
+// APIKey is a key a company authenticates with. A company may hold several,
+// so keys can be rotated without downtime. The key issued at registration is
+// the company's first APIKey. Keys are stored hashed.
+type APIKey struct {
+	ID        string `gorm:"type:varchar(40);primaryKey;column:id"`
+	CompanyID string `gorm:"type:varchar(40);not null;index;column:company_id"`
+	Label     string `gorm:"type:varchar(100);not null;column:label"`
+	// KeyHash is the hex HMAC of the key, see KeyHasher.
+	KeyHash string `gorm:"type:varchar(255);not null;uniqueIndex;column:api_key"`
+	// Prefix is the start of the key, shown to tell keys apart and used to
+	// look them up.
+	Prefix     string     `gorm:"type:varchar(16);not null;index;column:prefix"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	ExpiresAt  *time.Time `gorm:"column:expires_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+}
+
+func (APIKey) TableName() string {
+	return "company_api_keys"
+}
+
+// Active reports whether the key authenticates requests at now.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
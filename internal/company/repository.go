@@ -2,20 +2,74 @@ package company
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type Repository interface {
-	Create(c *Company) error
-	GetByAPIKey(apiKey string) (*Company, error)
+	// Create stores the company and its registration key as its first API
+	// key.
+	Create(c *Company, key *APIKey) error
 	GetByID(companyId string) (*Company, error)
 	GetBySlug(slug string) (*Company, error)
+	ListAll() ([]Company, error)
+	// List pages through the companies ordered by slug, keeping those whose
+	// name or slug contains search, and returns the total number of matches.
+	List(search string, limit, offset int) ([]Company, int64, error)
+	// UpdateDetails saves the name, dates and total quota of c.
+	UpdateDetails(c *Company) error
+	// SetSuspended suspends the company at at with reason or, with a nil at,
+	// reactivates it.
+	SetSuspended(companyID string, at *time.Time, reason *string) error
 	IncrementUsedQuota(companyID string, delta int64) error
+	// ReserveQuota adds delta to used_quota only if it stays within the total
+	// quota, and reports whether it did.
+	ReserveQuota(companyID string, delta int64) (bool, error)
+	DecrementUsedQuota(companyID string, delta int64) error
+	// Lock locks the company row until the transaction the repository is
+	// bound to ends, so quota changes decided on what the transaction reads
+	// wait for each other.
+	Lock(companyID string) error
 	ResetUsedQuota(companyID string) error
+	SetUsedQuota(companyID string, usedQuota int64) error
+	// SetMaxUploadURLExpiry sets or, with nil, clears the company's cap.
+	SetMaxUploadURLExpiry(companyID string, seconds *int) error
+
+	// CreateAPIKey stores k unless the company already holds maxActive keys
+	// active at k.CreatedAt, and reports whether it stored it.
+	CreateAPIKey(k *APIKey, maxActive int64) (bool, error)
+	GetAPIKey(companyID, id string) (*APIKey, error)
+	// ListAPIKeysByPrefix returns the keys of any company starting with
+	// prefix, see KeyPrefix.
+	ListAPIKeysByPrefix(prefix string) ([]APIKey, error)
+	// ListAPIKeys returns the company's keys, oldest first.
+	ListAPIKeys(companyID string) ([]APIKey, error)
+	// RevokeAPIKey revokes k at at. With keepLast it revokes nothing if k
+	// is the last key of its company active at at, and reports whether it
+	// revoked k.
+	RevokeAPIKey(k *APIKey, at time.Time, keepLast bool) (bool, error)
+	// RotateAPIKey stores next and lets old expire at oldExpiresAt, unless
+	// it expires earlier. Like CreateAPIKey it stores nothing if the company
+	// already holds maxActive keys, and reports whether it stored next.
+	RotateAPIKey(old *APIKey, next *APIKey, oldExpiresAt time.Time, maxActive int64) (bool, error)
+	// TouchAPIKey records a use of the key at at, at most once per
+	// lastUsedResolution.
+	TouchAPIKey(id string, at time.Time) error
+	// HashLegacyAPIKeys gives companies registered before API keys had a
+	// table of their own the hash of their plaintext company_api_key as
+	// first key. It returns the number of companies it gave a key.
+	HashLegacyAPIKeys(hasher *KeyHasher) (int64, error)
+	// ClearLegacyAPIKeys clears company_api_key of the companies whose key
+	// HashLegacyAPIKeys moved and returns how many it cleared.
+	ClearLegacyAPIKeys() (int64, error)
 }
 
+// lastUsedResolution bounds how often last_used_at is written for a key.
+const lastUsedResolution = time.Minute
+
 type repository struct {
 	db *gorm.DB
 }
@@ -24,13 +78,18 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) Create(c *Company) error {
-	return r.db.Create(c).Error
+func (r *repository) Create(c *Company, key *APIKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(c).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
 }
 
 func (r *repository) GetByID(companyId string) (*Company, error) {
 	var c Company
-	if err := r.db.Where("company_id = ?", companyId).First(&c).Error; err != nil {
+	if err := r.db.Where("id = ?", companyId).First(&c).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -50,15 +109,199 @@ func (r *repository) GetBySlug(slug string) (*Company, error) {
 	return &c, nil
 }
 
-func (r *repository) GetByAPIKey(apiKey string) (*Company, error) {
-	var c Company
-	if err := r.db.Where("company_api_key = ?", apiKey).First(&c).Error; err != nil {
+func (r *repository) ListAll() ([]Company, error) {
+	var companies []Company
+	if err := r.db.Order("company_slug").Find(&companies).Error; err != nil {
+		return nil, err
+	}
+	return companies, nil
+}
+
+func (r *repository) List(search string, limit, offset int) ([]Company, int64, error) {
+	q := r.db.Model(&Company{})
+	if search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		q = q.Where("company_name LIKE ? OR company_slug LIKE ?", pattern, pattern)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var companies []Company
+	if err := q.Order("company_slug").Limit(limit).Offset(offset).Find(&companies).Error; err != nil {
+		return nil, 0, err
+	}
+	return companies, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (r *repository) UpdateDetails(c *Company) error {
+	return r.db.Model(c).
+		Select("company_name", "start_date", "end_date", "total_usage_quota").
+		Updates(c).Error
+}
+
+func (r *repository) SetSuspended(companyID string, at *time.Time, reason *string) error {
+	return r.db.Model(&Company{}).
+		Where("id = ?", companyID).
+		Updates(map[string]interface{}{"suspended_at": at, "suspension_reason": reason}).Error
+}
+
+// belowKeyLimit locks the company row, so concurrent key issues of the
+// company wait for tx, and reports whether the company holds fewer than
+// maxActive keys active at now.
+func belowKeyLimit(tx *gorm.DB, companyID string, maxActive int64, now time.Time) (bool, error) {
+	if err := lockCompany(tx, companyID); err != nil {
+		return false, err
+	}
+	n, err := countActiveAPIKeys(tx, companyID, now)
+	return n < maxActive, err
+}
+
+func (r *repository) CreateAPIKey(k *APIKey, maxActive int64) (bool, error) {
+	stored := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		below, err := belowKeyLimit(tx, k.CompanyID, maxActive, k.CreatedAt)
+		if err != nil || !below {
+			return err
+		}
+		if err := tx.Create(k).Error; err != nil {
+			return err
+		}
+		stored = true
+		return nil
+	})
+	return stored, err
+}
+
+func (r *repository) GetAPIKey(companyID, id string) (*APIKey, error) {
+	var k APIKey
+	if err := r.db.Where("company_id = ? AND id = ?", companyID, id).First(&k).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &c, nil
+	return &k, nil
+}
+
+func (r *repository) ListAPIKeysByPrefix(prefix string) ([]APIKey, error) {
+	var keys []APIKey
+	if err := r.db.Where("prefix = ?", prefix).Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *repository) ListAPIKeys(companyID string) ([]APIKey, error) {
+	var keys []APIKey
+	if err := r.db.Where("company_id = ?", companyID).Order("created_at").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func countActiveAPIKeys(db *gorm.DB, companyID string, now time.Time) (int64, error) {
+	var n int64
+	err := db.Model(&APIKey{}).
+		Where("company_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", companyID, now).
+		Count(&n).Error
+	return n, err
+}
+
+func (r *repository) RevokeAPIKey(k *APIKey, at time.Time, keepLast bool) (bool, error) {
+	revoked := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Concurrent revokes of the company wait here, so they cannot
+		// both see another active key.
+		if err := lockCompany(tx, k.CompanyID); err != nil {
+			return err
+		}
+		if keepLast && k.Active(at) {
+			n, err := countActiveAPIKeys(tx, k.CompanyID, at)
+			if err != nil || n <= 1 {
+				return err
+			}
+		}
+		if err := tx.Model(&APIKey{}).
+			Where("id = ? AND revoked_at IS NULL", k.ID).
+			Update("revoked_at", at).Error; err != nil {
+			return err
+		}
+		revoked = true
+		return nil
+	})
+	return revoked, err
+}
+
+func (r *repository) RotateAPIKey(old *APIKey, next *APIKey, oldExpiresAt time.Time, maxActive int64) (bool, error) {
+	stored := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		below, err := belowKeyLimit(tx, next.CompanyID, maxActive, next.CreatedAt)
+		if err != nil || !below {
+			return err
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&APIKey{}).
+			Where("id = ? AND (expires_at IS NULL OR expires_at > ?)", old.ID, oldExpiresAt).
+			Update("expires_at", oldExpiresAt).Error; err != nil {
+			return err
+		}
+		stored = true
+		return nil
+	})
+	return stored, err
+}
+
+func (r *repository) TouchAPIKey(id string, at time.Time) error {
+	return r.db.Model(&APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at.Add(-lastUsedResolution)).
+		UpdateColumn("last_used_at", at).Error
+}
+
+func (r *repository) HashLegacyAPIKeys(hasher *KeyHasher) (int64, error) {
+	// This runs before the API has migrated the schema on upgrades: api_keys
+	// may be missing and company_api_key still NOT NULL.
+	if err := r.db.AutoMigrate(&Company{}, &APIKey{}); err != nil {
+		return 0, err
+	}
+
+	var companies []Company
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("company_api_key IS NOT NULL AND NOT EXISTS (SELECT 1 FROM company_api_keys k WHERE k.company_id = companies.id)").
+			Find(&companies).Error; err != nil {
+			return err
+		}
+		for _, c := range companies {
+			if err := tx.Create(hasher.NewAPIKey(c.ID, RegistrationKeyLabel, *c.CompanyAPIKey)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(companies)), nil
+}
+
+func (r *repository) ClearLegacyAPIKeys() (int64, error) {
+	res := r.db.Model(&Company{}).
+		Where("company_api_key IS NOT NULL AND EXISTS (SELECT 1 FROM company_api_keys k WHERE k.company_id = companies.id)").
+		Update("company_api_key", nil)
+	return res.RowsAffected, res.Error
+}
+
+func (r *repository) SetMaxUploadURLExpiry(companyID string, seconds *int) error {
+	return r.db.Model(&Company{}).
+		Where("id = ?", companyID).
+		Update("max_upload_url_expiry", seconds).Error
 }
 
 func (r *repository) IncrementUsedQuota(companyID string, delta int64) error {
@@ -68,9 +311,43 @@ func (r *repository) IncrementUsedQuota(companyID string, delta int64) error {
 		UpdateColumn("used_quota", gorm.Expr("used_quota + ?", delta)).Error
 }
 
+func (r *repository) ReserveQuota(companyID string, delta int64) (bool, error) {
+	res := r.db.Model(&Company{}).
+		Where("id = ? AND (total_usage_quota IS NULL OR used_quota + ? <= total_usage_quota)", companyID, delta).
+		UpdateColumn("used_quota", gorm.Expr("used_quota + ?", delta))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *repository) Lock(companyID string) error {
+	return lockCompany(r.db, companyID)
+}
+
+func lockCompany(tx *gorm.DB, companyID string) error {
+	var c Company
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", companyID).First(&c).Error
+}
+
+// DecrementUsedQuota refunds delta bytes without letting used_quota go negative.
+func (r *repository) DecrementUsedQuota(companyID string, delta int64) error {
+	return r.db.Model(&Company{}).
+		Where("id = ?", companyID).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		UpdateColumn("used_quota", gorm.Expr("GREATEST(used_quota - ?, 0)", delta)).Error
+}
+
 func (r *repository) ResetUsedQuota(companyID string) error {
 	return r.db.Model(&Company{}).
 		Where("id = ?", companyID).
 		Clauses(clause.Locking{Strength: "UPDATE"}).
 		UpdateColumn("used_quota", 0).Error
 }
+
+func (r *repository) SetUsedQuota(companyID string, usedQuota int64) error {
+	return r.db.Model(&Company{}).
+		Where("id = ?", companyID).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		UpdateColumn("used_quota", usedQuota).Error
+}
@@ -0,0 +1,105 @@
+package company
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Subscription states.
+const (
+	// SubscriptionActive allows every request.
+	SubscriptionActive = "active"
+	// SubscriptionGrace follows the end date: the company is read-only.
+	SubscriptionGrace = "grace"
+	// SubscriptionExpired follows the grace period: the company is blocked.
+	SubscriptionExpired = "expired"
+	// SubscriptionNotStarted precedes the start date: the company is blocked.
+	SubscriptionNotStarted = "not_started"
+)
+
+// Subscription describes the subscription window of a company at a moment.
+// Start and end dates are whole days in UTC, the end date included.
+type Subscription struct {
+	State string
+	// StartsAt and EndsAt bound the window; nil leaves it open.
+	StartsAt *time.Time
+	EndsAt   *time.Time
+	// GraceUntil is when a company in its grace period becomes blocked.
+	GraceUntil *time.Time
+}
+
+// day returns midnight UTC of the calendar day of t.
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Subscription returns the state of the company's subscription at now,
+// with grace as the read-only period after its end.
+func (c Company) Subscription(now time.Time, grace time.Duration) Subscription {
+	sub := Subscription{State: SubscriptionActive}
+	if c.StartDate != nil {
+		startsAt := day(*c.StartDate)
+		sub.StartsAt = &startsAt
+		if now.Before(startsAt) {
+			sub.State = SubscriptionNotStarted
+			return sub
+		}
+	}
+	if c.EndDate != nil {
+		endsAt := day(*c.EndDate).AddDate(0, 0, 1)
+		graceUntil := endsAt.Add(grace)
+		sub.EndsAt, sub.GraceUntil = &endsAt, &graceUntil
+		switch {
+		case now.Before(endsAt):
+		case now.Before(graceUntil):
+			sub.State = SubscriptionGrace
+		default:
+			sub.State = SubscriptionExpired
+		}
+	}
+	return sub
+}
+
+// Blocked reports whether the subscription rejects every request.
+func (s Subscription) Blocked() bool {
+	return s.State == SubscriptionExpired || s.State == SubscriptionNotStarted
+}
+
+// ReadOnly reports whether the subscription rejects changes.
+func (s Subscription) ReadOnly() bool {
+	return s.State != SubscriptionActive
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// writeSubscriptionError answers 403 explaining why the subscription does
+// not allow the request.
+func writeSubscriptionError(w http.ResponseWriter, sub Subscription) {
+	var msg string
+	switch sub.State {
+	case SubscriptionNotStarted:
+		msg = "the subscription has not started yet"
+	case SubscriptionGrace:
+		msg = "the subscription has ended; the company is read-only until the grace period is over"
+	default:
+		msg = "the subscription and its grace period have ended"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       "subscription_" + sub.State,
+		"message":     msg,
+		"starts_at":   formatTime(sub.StartsAt),
+		"ends_at":     formatTime(sub.EndsAt),
+		"grace_until": formatTime(sub.GraceUntil),
+	})
+}
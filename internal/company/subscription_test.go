@@ -0,0 +1,160 @@
+package company
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) *time.Time {
+	t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestSubscription(t *testing.T) {
+	grace := 7 * 24 * time.Hour
+	at := func(d *time.Time, offset time.Duration) time.Time { return d.Add(offset) }
+
+	tests := []struct {
+		name      string
+		start     *time.Time
+		end       *time.Time
+		now       time.Time
+		want      string
+		blocked   bool
+		readOnly  bool
+		wantEnds  *time.Time
+		wantGrace *time.Time
+	}{
+		{
+			name: "open window",
+			now:  at(date(2026, 1, 1), 0),
+			want: SubscriptionActive,
+		},
+		{
+			name:     "before the start day",
+			start:    date(2026, 3, 1),
+			now:      at(date(2026, 3, 1), -time.Nanosecond),
+			want:     SubscriptionNotStarted,
+			blocked:  true,
+			readOnly: true,
+		},
+		{
+			name:  "start of the start day",
+			start: date(2026, 3, 1),
+			now:   at(date(2026, 3, 1), 0),
+			want:  SubscriptionActive,
+		},
+		{
+			name:  "start date time of day is ignored",
+			start: func() *time.Time { t := date(2026, 3, 1).Add(15 * time.Hour); return &t }(),
+			now:   at(date(2026, 3, 1), time.Hour),
+			want:  SubscriptionActive,
+		},
+		{
+			name:      "end day is included",
+			end:       date(2026, 3, 31),
+			now:       at(date(2026, 4, 1), -time.Nanosecond),
+			want:      SubscriptionActive,
+			wantEnds:  date(2026, 4, 1),
+			wantGrace: date(2026, 4, 8),
+		},
+		{
+			name:      "grace starts after the end day",
+			end:       date(2026, 3, 31),
+			now:       at(date(2026, 4, 1), 0),
+			want:      SubscriptionGrace,
+			readOnly:  true,
+			wantEnds:  date(2026, 4, 1),
+			wantGrace: date(2026, 4, 8),
+		},
+		{
+			name:      "last moment of grace",
+			end:       date(2026, 3, 31),
+			now:       at(date(2026, 4, 8), -time.Nanosecond),
+			want:      SubscriptionGrace,
+			readOnly:  true,
+			wantEnds:  date(2026, 4, 1),
+			wantGrace: date(2026, 4, 8),
+		},
+		{
+			name:      "expired after grace",
+			end:       date(2026, 3, 31),
+			now:       at(date(2026, 4, 8), 0),
+			want:      SubscriptionExpired,
+			blocked:   true,
+			readOnly:  true,
+			wantEnds:  date(2026, 4, 1),
+			wantGrace: date(2026, 4, 8),
+		},
+		{
+			name:     "not started wins over ended",
+			start:    date(2026, 5, 1),
+			end:      date(2026, 3, 31),
+			now:      at(date(2026, 4, 20), 0),
+			want:     SubscriptionNotStarted,
+			blocked:  true,
+			readOnly: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Company{StartDate: tt.start, EndDate: tt.end}
+			sub := c.Subscription(tt.now, grace)
+			if sub.State != tt.want {
+				t.Fatalf("state = %q, want %q", sub.State, tt.want)
+			}
+			if sub.Blocked() != tt.blocked || sub.ReadOnly() != tt.readOnly {
+				t.Errorf("blocked, read-only = %v, %v, want %v, %v", sub.Blocked(), sub.ReadOnly(), tt.blocked, tt.readOnly)
+			}
+			if !sameTime(sub.EndsAt, tt.wantEnds) || !sameTime(sub.GraceUntil, tt.wantGrace) {
+				t.Errorf("ends, grace until = %v, %v, want %v, %v", sub.EndsAt, sub.GraceUntil, tt.wantEnds, tt.wantGrace)
+			}
+		})
+	}
+}
+
+func TestSubscriptionWithoutGrace(t *testing.T) {
+	c := Company{EndDate: date(2026, 3, 31)}
+	if sub := c.Subscription(*date(2026, 4, 1), 0); sub.State != SubscriptionExpired {
+		t.Errorf("state = %q, want %q", sub.State, SubscriptionExpired)
+	}
+}
+
+func TestCheckAvailable(t *testing.T) {
+	now := *date(2026, 4, 2)
+	grace := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		company Company
+		want    bool
+	}{
+		{name: "active", company: Company{}, want: true},
+		{name: "grace period still serves", company: Company{EndDate: date(2026, 3, 31)}, want: true},
+		{name: "suspended", company: Company{SuspendedAt: &now}},
+		{name: "expired", company: Company{EndDate: date(2026, 3, 1)}},
+		{name: "not started", company: Company{StartDate: date(2026, 5, 1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if got := CheckAvailable(rec, &tt.company, now, grace); got != tt.want {
+				t.Fatalf("CheckAvailable = %v, want %v", got, tt.want)
+			}
+			if !tt.want && rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -25,6 +28,18 @@ type Config struct {
 	Addr    string // HTTP address
 	DSN     string // MySQL/MariaDB DSN
 	APP_ENV string // local,dev,prod
+
+	StorageCORSOrigins []string // allowed origins on provisioned buckets, none if empty
+
+	CredentialMasterKeys string // "version:base64key" list used to encrypt stored cloud credentials
+	CredentialKeyFile    string // file with the same format, for local use
+	CredentialKeyVersion int    // version new values are encrypted with (0 = highest)
+
+	DownloadTokenKey string // base64 HMAC key signing download tokens
+
+	APIKeyPepper string // base64 HMAC key hashing stored API keys
+
+	SubscriptionGracePeriod time.Duration // how long an ended subscription stays read-only before it is blocked
 }
 
 func Load() *Config {
@@ -43,10 +58,45 @@ func Load() *Config {
 		app_env = Local.String()
 	}
 
+	var corsOrigins []string
+	if v := os.Getenv("STORAGE_CORS_ORIGINS"); v != "" {
+		corsOrigins = strings.Split(v, ",")
+	}
+
+	keyVersion := 0
+	if v := os.Getenv("CREDENTIALS_KEY_VERSION"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatal("CREDENTIALS_KEY_VERSION must be a number")
+		}
+		keyVersion = parsed
+	}
+
+	graceDays := 14
+	if v := os.Getenv("SUBSCRIPTION_GRACE_DAYS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			log.Fatal("SUBSCRIPTION_GRACE_DAYS must be a non-negative number")
+		}
+		graceDays = parsed
+	}
+
 	return &Config{
 		Addr:    addr,
 		DSN:     dsn,
 		APP_ENV: app_env,
+
+		StorageCORSOrigins: corsOrigins,
+
+		CredentialMasterKeys: os.Getenv("CREDENTIALS_MASTER_KEYS"),
+		CredentialKeyFile:    os.Getenv("CREDENTIALS_KEY_FILE"),
+		CredentialKeyVersion: keyVersion,
+
+		DownloadTokenKey: os.Getenv("DOWNLOAD_TOKEN_KEY"),
+
+		APIKeyPepper: os.Getenv("API_KEY_PEPPER"),
+
+		SubscriptionGracePeriod: time.Duration(graceDays) * 24 * time.Hour,
 	}
 }
 
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"net/http"
+)
+
+// RequireAdminClient only lets requests through that carry valid admin
+// client_id / client_secret headers.
+func RequireAdminClient(repo Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientId := r.Header.Get("client_id")
+			clientSecret := r.Header.Get("client_secret")
+
+			if clientId == "" || clientSecret == "" {
+				http.Error(w, "Invalid Client Credentials", http.StatusBadRequest)
+				return
+			}
+
+			adminConfig, err := repo.FindBy(clientId, clientSecret)
+			if err != nil {
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			}
+			if adminConfig == nil {
+				http.Error(w, "cannot find the client credentials", http.StatusNotFound)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
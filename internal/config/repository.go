@@ -30,6 +30,9 @@ func (r *repository) Create(adminClient *AdminClient) error {
 func (r *repository) FindBy(client_id, client_secret string) (*AdminClient, error) {
 	var ac AdminClient
 	if err := r.db.Where("client_id = ? AND client_secret = ? AND is_active=?", client_id, client_secret, true).First(&ac).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return &ac, nil
@@ -0,0 +1,346 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/uploader"
+)
+
+const (
+	defaultTokenTTL = 5 * time.Minute
+	maxTokenTTL     = time.Hour
+)
+
+type CreateDownloadTokenRequest struct {
+	ExpiresIn int `json:"expires_in,omitempty"` // seconds, default 300, at most 3600
+}
+
+type DownloadTokenResponse struct {
+	Token     string `json:"token"`
+	Path      string `json:"path"` // relative to the API host
+	ExpiresAt string `json:"expires_at"`
+}
+
+// Handler streams stored files through the API for clients that cannot
+// reach the storage directly.
+type Handler struct {
+	companyRepo  company.Repository
+	fileMetaRepo filemeta.Repository
+	folderRepo   folder.Repository
+	s3Service    uploader.S3Service
+	signer       *TokenSigner
+	grace        time.Duration // subscription grace period
+}
+
+func NewHandler(companyRepo company.Repository, fileMetaRepo filemeta.Repository, folderRepo folder.Repository, s3Service uploader.S3Service, signer *TokenSigner, grace time.Duration) *Handler {
+	return &Handler{companyRepo: companyRepo, fileMetaRepo: fileMetaRepo, folderRepo: folderRepo, s3Service: s3Service, signer: signer, grace: grace}
+}
+
+// companyFile loads the file created by the upload fileID of the company.
+// It answers 404 for unknown files and files in the trash and 410 for
+// deleted or replaced ones, and returns nil if a response was written.
+func (h *Handler) companyFile(w http.ResponseWriter, companyID, fileID string) *filemeta.File {
+	meta, err := h.fileMetaRepo.GetByID(fileID)
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+		return nil
+	}
+	if meta == nil || meta.CompanyID == nil || *meta.CompanyID != companyID || !filemeta.IsUpload(meta.FileTxnType) || !meta.Completed() {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return nil
+	}
+
+	file, err := h.fileMetaRepo.GetFileByID(companyID, meta.ID)
+	if err != nil {
+		http.Error(w, "failed to look up file", http.StatusInternalServerError)
+		return nil
+	}
+	if file == nil {
+		http.Error(w, "file was deleted or replaced", http.StatusGone)
+		return nil
+	}
+	if file.LocTag != nil {
+		trashed, err := h.folderRepo.InTrash(companyID, *file.LocTag)
+		if err != nil {
+			http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+			return nil
+		}
+		if trashed {
+			http.Error(w, "file is in the trash", http.StatusNotFound)
+			return nil
+		}
+	}
+	return file
+}
+
+// etagListMatches reports whether an If-None-Match list contains etag,
+// comparing weakly.
+func etagListMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since without it.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if list := r.Header.Get("If-None-Match"); list != "" {
+		return etagListMatches(list, etag)
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// requestedRange returns the Range header, unless an If-Range condition
+// shows the client holds another version.
+func requestedRange(r *http.Request, etag string, lastModified time.Time) string {
+	byteRange := r.Header.Get("Range")
+	ifRange := r.Header.Get("If-Range")
+	if byteRange == "" || ifRange == "" {
+		return byteRange
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		if ifRange != etag {
+			return ""
+		}
+		return byteRange
+	}
+	t, err := http.ParseTime(ifRange)
+	if err != nil || lastModified.Truncate(time.Second).After(t) {
+		return ""
+	}
+	return byteRange
+}
+
+// serve streams the object of file, honouring range and conditional
+// requests.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, companyRec *company.Company, file *filemeta.File) {
+	ctx := r.Context()
+	info, err := h.s3Service.HeadObject(ctx, companyRec, file.FileKey)
+	if err != nil {
+		http.Error(w, "failed to read object stats", http.StatusInternalServerError)
+		return
+	}
+	if info == nil {
+		http.Error(w, "object is missing from storage", http.StatusGone)
+		return
+	}
+	if !info.Readable() {
+		uploader.WriteArchived(w, file.FileKey, *info)
+		return
+	}
+
+	etag := `"` + info.ETag + `"`
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	header.Set("Cache-Control", "private, no-cache")
+	header.Set("Accept-Ranges", "bytes")
+	if notModified(r, etag, info.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	name := path.Base(file.FileKey)
+	if file.FileName != nil {
+		name = *file.FileName
+	}
+	disposition := "attachment"
+	if r.URL.Query().Get("inline") == "true" {
+		disposition = "inline"
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+
+	if r.Method == http.MethodHead {
+		header.Set("Content-Type", contentType(info.ContentType))
+		header.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	object, err := h.s3Service.GetObject(ctx, companyRec, file.FileKey, requestedRange(r, etag, info.LastModified))
+	if errors.Is(err, uploader.ErrInvalidRange) {
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		http.Error(w, "requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read object", http.StatusInternalServerError)
+		return
+	}
+	if object == nil {
+		http.Error(w, "object is missing from storage", http.StatusGone)
+		return
+	}
+	defer object.Body.Close()
+
+	header.Set("Content-Type", contentType(object.ContentType))
+	header.Set("Content-Length", strconv.FormatInt(object.ContentLength, 10))
+	status := http.StatusOK
+	if object.ContentRange != "" {
+		header.Set("Content-Range", object.ContentRange)
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+	_, _ = io.Copy(w, object.Body)
+}
+
+func contentType(stored string) string {
+	if stored == "" {
+		return "application/octet-stream"
+	}
+	return stored
+}
+
+// DownloadFile godoc
+// @Summary      Download a file through the API
+// @Description  Streams the file. Range, If-Range, If-None-Match and If-Modified-Since are honoured; ETag, Last-Modified, Content-Type and Content-Length are passed through.
+// @Description  Set inline=true to display the file in the browser instead of saving it.
+// @Tags         download
+// @Produce      octet-stream
+// @Param        X-API-Key  header    string  true   "Company API key"
+// @Param        id         path      string  true   "File ID"
+// @Param        inline     query     bool    false  "Content-Disposition inline"
+// @Param        Range      header    string  false  "Byte range, e.g. bytes=0-1023"
+// @Success      200        {file}    file
+// @Success      206        {file}    file
+// @Success      304
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found or in the trash"
+// @Failure      409        {object}  map[string]interface{} "archived, restore required"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Failure      416        {string}  string "requested range not satisfiable"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/{id}/download [get]
+func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	file := h.companyFile(w, companyRec.ID, chi.URLParam(r, "id"))
+	if file == nil {
+		return
+	}
+	h.serve(w, r, companyRec, file)
+}
+
+// CreateDownloadToken godoc
+// @Summary      Create a download token for a file
+// @Description  Returns a signed token for GET /download/{token}, for clients that cannot send the API key (e.g. video players).
+// @Description  Tokens cannot be revoked and live at most an hour.
+// @Tags         download
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string                      true   "Company API key"
+// @Param        id         path      string                      true   "File ID"
+// @Param        body       body      CreateDownloadTokenRequest  false  "Token lifetime"
+// @Success      201        {object}  DownloadTokenResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found or in the trash"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Router       /uploader/files/{id}/download-token [post]
+func (h *Handler) CreateDownloadToken(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	file := h.companyFile(w, companyRec.ID, chi.URLParam(r, "id"))
+	if file == nil {
+		return
+	}
+
+	var req CreateDownloadTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	ttl := defaultTokenTTL
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+		if ttl <= 0 || ttl > maxTokenTTL {
+			http.Error(w, "expires_in must be between 1 and 3600 seconds", http.StatusBadRequest)
+			return
+		}
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	token := h.signer.Sign(file.FileMetaID, expiresAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(DownloadTokenResponse{
+		Token:     token,
+		Path:      "/api/v1/download/" + token,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// DownloadWithToken godoc
+// @Summary      Download a file with a download token
+// @Description  Same as GET /uploader/files/{id}/download, authorized by the token instead of the API key.
+// @Tags         download
+// @Produce      octet-stream
+// @Param        token   path      string  true   "Download token"
+// @Param        inline  query     bool    false  "Content-Disposition inline"
+// @Param        Range   header    string  false  "Byte range, e.g. bytes=0-1023"
+// @Success      200     {file}    file
+// @Success      206     {file}    file
+// @Success      304
+// @Failure      401     {string}  string "invalid or expired download token"
+// @Failure      403     {string}  string "company suspended or outside its subscription"
+// @Failure      404     {string}  string "file not found or in the trash"
+// @Failure      409     {object}  map[string]interface{} "archived, restore required"
+// @Failure      410     {string}  string "file was deleted or replaced"
+// @Failure      416     {string}  string "requested range not satisfiable"
+// @Router       /download/{token} [get]
+func (h *Handler) DownloadWithToken(w http.ResponseWriter, r *http.Request) {
+	fileID, ok := h.signer.Verify(chi.URLParam(r, "token"), time.Now())
+	if !ok {
+		http.Error(w, "invalid or expired download token", http.StatusUnauthorized)
+		return
+	}
+
+	meta, err := h.fileMetaRepo.GetByID(fileID)
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+		return
+	}
+	if meta == nil || meta.CompanyID == nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	companyRec, err := h.companyRepo.GetByID(*meta.CompanyID)
+	if err != nil {
+		http.Error(w, "failed to look up company", http.StatusInternalServerError)
+		return
+	}
+	if companyRec == nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	if !company.CheckAvailable(w, companyRec, time.Now(), h.grace) {
+		return
+	}
+
+	file := h.companyFile(w, companyRec.ID, fileID)
+	if file == nil {
+		return
+	}
+	h.serve(w, r, companyRec, file)
+}
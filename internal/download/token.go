@@ -0,0 +1,84 @@
+package download
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/config"
+)
+
+const minTokenKeyLen = 32
+
+// TokenSigner issues and verifies download tokens of the form
+// "<file id>.<expiry unix seconds>.<base64url HMAC-SHA256>". Tokens are
+// stateless and cannot be revoked, so they must be short-lived.
+type TokenSigner struct {
+	key []byte
+}
+
+func NewTokenSigner(key []byte) *TokenSigner {
+	return &TokenSigner{key: key}
+}
+
+// SignerFromConfig builds the signer from DOWNLOAD_TOKEN_KEY. Outside the
+// local environment the key is mandatory; locally a random key is used, so
+// tokens do not survive restarts.
+func SignerFromConfig(cfg *config.Config) (*TokenSigner, error) {
+	if cfg.DownloadTokenKey == "" {
+		if cfg.APP_ENV != config.Local.String() {
+			return nil, errors.New("DOWNLOAD_TOKEN_KEY is required")
+		}
+		key := make([]byte, minTokenKeyLen)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		log.Println("no download token key configured, using a random key")
+		return NewTokenSigner(key), nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(cfg.DownloadTokenKey)
+	if err != nil || len(key) < minTokenKeyLen {
+		return nil, errors.New("DOWNLOAD_TOKEN_KEY must be at least 32 base64 encoded bytes")
+	}
+	return NewTokenSigner(key), nil
+}
+
+func (s *TokenSigner) mac(payload string) string {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Sign returns a token granting downloads of fileID until expiresAt.
+func (s *TokenSigner) Sign(fileID string, expiresAt time.Time) string {
+	payload := fileID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + s.mac(payload)
+}
+
+// Verify returns the file id of a valid token that has not expired at now.
+func (s *TokenSigner) Verify(token string, now time.Time) (string, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return "", false
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.mac(payload))) {
+		return "", false
+	}
+	fileID, expiry, ok := strings.Cut(payload, ".")
+	if !ok || fileID == "" {
+		return "", false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return "", false
+	}
+	return fileID, true
+}
@@ -0,0 +1,84 @@
+package download
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/config"
+)
+
+func TestTokenSigner(t *testing.T) {
+	signer := NewTokenSigner(bytes.Repeat([]byte("k"), minTokenKeyLen))
+	now := time.Unix(1_700_000_000, 0)
+	token := signer.Sign("file1", now.Add(time.Minute))
+	payload := token[:strings.LastIndex(token, ".")]
+	signature := token[strings.LastIndex(token, ".")+1:]
+
+	tests := []struct {
+		name   string
+		signer *TokenSigner
+		token  string
+		now    time.Time
+		want   string // file id, "" if rejected
+	}{
+		{name: "valid", token: token, now: now, want: "file1"},
+		{name: "last valid second", token: token, now: now.Add(time.Minute - time.Second), want: "file1"},
+		{name: "expired", token: token, now: now.Add(time.Minute)},
+		{name: "other file", token: "file2" + strings.TrimPrefix(token, "file1"), now: now},
+		{name: "extended expiry", token: "file1.9999999999." + signature, now: now},
+		{name: "tampered signature", token: payload + "." + strings.Repeat("A", len(signature)), now: now},
+		{name: "other key", signer: NewTokenSigner(bytes.Repeat([]byte("x"), minTokenKeyLen)), token: token, now: now},
+		{name: "no signature", token: payload, now: now},
+		{name: "empty", token: "", now: now},
+		{name: "signed without file id", token: signer.Sign("", now.Add(time.Minute)), now: now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := signer
+			if tt.signer != nil {
+				s = tt.signer
+			}
+			got, ok := s.Verify(tt.token, tt.now)
+			if ok != (tt.want != "") || got != tt.want {
+				t.Errorf("Verify = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignerFromConfig(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), minTokenKeyLen))
+	short := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), minTokenKeyLen-1))
+
+	tests := []struct {
+		name    string
+		env     string
+		key     string
+		wantErr bool
+	}{
+		{name: "configured key", env: "prod", key: key},
+		{name: "random key locally", env: config.Local.String()},
+		{name: "missing key outside local", env: "prod", wantErr: true},
+		{name: "short key", env: "prod", key: short, wantErr: true},
+		{name: "key not base64", env: config.Local.String(), key: "not base64!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := SignerFromConfig(&config.Config{APP_ENV: tt.env, DownloadTokenKey: tt.key})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				token := signer.Sign("file1", time.Now().Add(time.Minute))
+				if id, ok := signer.Verify(token, time.Now()); !ok || id != "file1" {
+					t.Errorf("signer does not verify its own token")
+				}
+			}
+		})
+	}
+}
@@ -1,18 +1,158 @@
 package filemeta
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// File transaction types recorded in files_meta. Clients record uploads
+// under positive numbers of their own choosing; types below zero are only
+// recorded by the server.
+const (
+	TxnUpload       int16 = 1
+	TxnDelete       int16 = 2
+	TxnFolderDelete int16 = 3
+	// TxnMetadataUpdate records new display metadata of a stored file. The
+	// row holds the complete metadata after the change.
+	TxnMetadataUpdate int16 = -1
+)
+
+// IsUpload reports whether a transaction of type t stores a file. Clients
+// may record uploads under their own type numbers.
+func IsUpload(t int16) bool {
+	return t > 0 && t != TxnDelete && t != TxnFolderDelete
+}
+
+// Upload statuses. Only complete uploads count as existing files; resumable
+// uploads stay pending until their last byte arrived.
+const (
+	StatusPending  = "pending"
+	StatusComplete = "complete"
+	StatusAborted  = "aborted"
+)
 
 type FileMeta struct {
 	ID          string    `gorm:"type:varchar(40);primaryKey;column:id"`
-	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
-	FileName    *string   `gorm:"type:varchar(255);column:file_name"`
-	FileSize    int64     `gorm:"column:file_size;not null"`
-	FileKey     string    `gorm:"type:varchar(255);not null;column:file_key"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime;index:idx_files_meta_company_created,priority:2"`
+	FileName    *string   `gorm:"type:varchar(255);column:file_name;index:idx_files_meta_company_name,priority:2"`
+	FileSize    int64     `gorm:"column:file_size;not null;index:idx_files_meta_company_size,priority:2"`
+	FileKey     string    `gorm:"type:varchar(255);not null;column:file_key;index:idx_files_meta_company_key,priority:2"`
 	FileTxnType int16     `gorm:"column:file_txn_type;not null"`
 	FileTxnMeta *string   `gorm:"type:varchar(255);column:file_txn_meta"`
-	CompanyID   *string   `gorm:"type:varchar(40);column:company_id"`
+	CompanyID   *string   `gorm:"type:varchar(40);column:company_id;index:idx_files_meta_company_created,priority:1;index:idx_files_meta_company_name,priority:1;index:idx_files_meta_company_size,priority:1;index:idx_files_meta_company_key,priority:1;index:idx_files_meta_company_loc,priority:1;index:idx_files_meta_company_completed,priority:1"`
+	// LocTag is the folder part of FileKey between the company slug and the
+	// file name, kept in its own column for search.
+	LocTag *string `gorm:"type:varchar(255);column:loc_tag;index:idx_files_meta_company_loc,priority:2"`
+	ETag   *string `gorm:"type:varchar(64);column:etag"`
+	Status string  `gorm:"type:varchar(16);not null;default:complete;column:status"`
+	// CompletedAt is when the transaction took effect: when it was recorded,
+	// or when a pending upload completed. The log is replayed in this order.
+	CompletedAt *time.Time `gorm:"column:completed_at;index:idx_files_meta_company_completed,priority:2"`
+	// Description and Metadata (a JSON object) are set by clients after the
+	// upload through metadata updates.
+	Description *string `gorm:"type:varchar(1024);column:description"`
+	Metadata    *string `gorm:"type:text;column:metadata"`
+	// Object Lock state the object was written with; LegalHold can change
+	// later.
+	LockMode    *string    `gorm:"type:varchar(16);column:lock_mode"`
+	RetainUntil *time.Time `gorm:"column:retain_until"`
+	LegalHold   bool       `gorm:"column:legal_hold;not null;default:false"`
+	// StorageClass the object was uploaded with, nil for STANDARD. Lifecycle
+	// transitions move objects later without a record here.
+	StorageClass *string `gorm:"type:varchar(20);column:storage_class"`
 }
 
 func (FileMeta) TableName() string {
 	return "files_meta"
 }
+
+// BeforeCreate derives LocTag from FileKey and dates complete transactions.
+// Folder deletes store a prefix instead of a key and have no LocTag.
+func (m *FileMeta) BeforeCreate(tx *gorm.DB) error {
+	if m.LocTag == nil && m.FileTxnType != TxnFolderDelete {
+		m.LocTag = LocTagOf(m.FileKey)
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	if m.CompletedAt == nil && m.Completed() {
+		completedAt := m.CreatedAt
+		m.CompletedAt = &completedAt
+	}
+	return nil
+}
+
+// AfterCreate applies the transaction to the files projection, inside the
+// transaction of the insert.
+func (m *FileMeta) AfterCreate(tx *gorm.DB) error {
+	return applyToFiles(tx, m)
+}
+
+// Completed reports whether the transaction took effect. Rows written before
+// statuses existed have none and are complete.
+func (m FileMeta) Completed() bool {
+	return m.Status == "" || m.Status == StatusComplete
+}
+
+// LocTagOf returns the loc_tag of a "<slug>/<loc_tag>/<file>" key.
+func LocTagOf(fileKey string) *string {
+	_, rest, ok := strings.Cut(fileKey, "/")
+	if !ok {
+		return nil
+	}
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 {
+		return nil
+	}
+	locTag := rest[:i]
+	return &locTag
+}
+
+// Tag labels a file. Tags are stored lower-cased.
+type Tag struct {
+	FileMetaID string `gorm:"type:varchar(40);primaryKey;column:file_meta_id"`
+	Tag        string `gorm:"type:varchar(64);primaryKey;column:tag;index:idx_file_tags_company_tag,priority:2"`
+	CompanyID  string `gorm:"type:varchar(40);not null;column:company_id;index:idx_file_tags_company_tag,priority:1"`
+}
+
+func (Tag) TableName() string {
+	return "file_tags"
+}
+
+// File is the current state of a stored file: the files_meta upload record
+// that created it with later metadata updates applied, as long as it was not
+// deleted or replaced. It is kept up to date with every files_meta insert and
+// can be rebuilt from the log.
+type File struct {
+	CompanyID  string  `gorm:"type:varchar(40);primaryKey;column:company_id;index:idx_files_company_loc,priority:1;index:idx_files_company_name,priority:1;index:idx_files_company_created,priority:1;index:idx_files_company_size,priority:1;index:idx_files_company_loc_fold,priority:1"`
+	FileKey    string  `gorm:"type:varchar(255);primaryKey;column:file_key"`
+	FileMetaID string  `gorm:"type:varchar(40);not null;index;column:file_meta_id"`
+	FileName   *string `gorm:"type:varchar(255);column:file_name;index:idx_files_company_name,priority:2"`
+	FileSize   int64   `gorm:"column:file_size;not null;index:idx_files_company_size,priority:2"`
+	LocTag     *string `gorm:"type:varchar(255);column:loc_tag;index:idx_files_company_loc,priority:2"`
+	// LocFold is LocTag in the form locations are compared in, locpath.Fold,
+	// compared byte by byte so the index serves case-insensitive lookups.
+	LocFold      *string    `gorm:"type:varchar(255) COLLATE utf8mb4_bin;column:loc_fold;index:idx_files_company_loc_fold,priority:2"`
+	FileTxnType  int16      `gorm:"column:file_txn_type;not null"`
+	FileTxnMeta  *string    `gorm:"type:varchar(255);column:file_txn_meta"`
+	ETag         *string    `gorm:"type:varchar(64);column:etag"`
+	Description  *string    `gorm:"type:varchar(1024);column:description"`
+	Metadata     *string    `gorm:"type:text;column:metadata"`
+	LockMode     *string    `gorm:"type:varchar(16);column:lock_mode"`
+	RetainUntil  *time.Time `gorm:"column:retain_until"`
+	LegalHold    bool       `gorm:"column:legal_hold;not null"` // no default, so upserts always write it
+	StorageClass *string    `gorm:"type:varchar(20);column:storage_class"`
+	CreatedAt    time.Time  `gorm:"column:created_at;index:idx_files_company_created,priority:2"` // time of the upload
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (File) TableName() string {
+	return "files"
+}
+
+// Locked reports whether Object Lock forbids deleting the file at now.
+func (f File) Locked(now time.Time) bool {
+	return f.LegalHold || (f.RetainUntil != nil && f.RetainUntil.After(now))
+}
@@ -0,0 +1,75 @@
+package filemeta
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"shreshtasmg.in/jupyter/internal/locpath"
+)
+
+// FileFromMeta returns the files row for an upload record.
+func FileFromMeta(m FileMeta) File {
+	file := File{
+		FileKey:      m.FileKey,
+		FileMetaID:   m.ID,
+		FileName:     m.FileName,
+		FileSize:     m.FileSize,
+		LocTag:       m.LocTag,
+		FileTxnType:  m.FileTxnType,
+		FileTxnMeta:  m.FileTxnMeta,
+		ETag:         m.ETag,
+		Description:  m.Description,
+		Metadata:     m.Metadata,
+		LockMode:     m.LockMode,
+		RetainUntil:  m.RetainUntil,
+		LegalHold:    m.LegalHold,
+		StorageClass: m.StorageClass,
+		CreatedAt:    m.CreatedAt,
+	}
+	if m.CompanyID != nil {
+		file.CompanyID = *m.CompanyID
+	}
+	if file.LocTag == nil {
+		file.LocTag = LocTagOf(m.FileKey)
+	}
+	if file.LocTag != nil {
+		locFold := locpath.Fold(*file.LocTag)
+		file.LocFold = &locFold
+	}
+	return file
+}
+
+// applyToFiles updates the files projection for one completed transaction,
+// the same way Replay applies it.
+func applyToFiles(tx *gorm.DB, m *FileMeta) error {
+	if !m.Completed() || m.CompanyID == nil {
+		return nil
+	}
+
+	switch {
+	case IsUpload(m.FileTxnType):
+		file := FileFromMeta(*m)
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&file).Error
+	case m.FileTxnType == TxnDelete:
+		return tx.Where("company_id = ? AND file_key = ?", *m.CompanyID, m.FileKey).
+			Delete(&File{}).Error
+	case m.FileTxnType == TxnMetadataUpdate:
+		return tx.Model(&File{}).Where("company_id = ? AND file_key = ?", *m.CompanyID, m.FileKey).
+			Updates(map[string]interface{}{
+				"file_name":     m.FileName,
+				"file_txn_meta": m.FileTxnMeta,
+				"description":   m.Description,
+				"metadata":      m.Metadata,
+			}).Error
+	case m.FileTxnType == TxnFolderDelete:
+		var slugs []string
+		if err := tx.Table("companies").Where("id = ?", *m.CompanyID).Pluck("company_slug", &slugs).Error; err != nil {
+			return err
+		}
+		if len(slugs) == 0 {
+			return nil
+		}
+		return tx.Where("company_id = ? AND file_key LIKE ?", *m.CompanyID, escapeLike(FolderKeyPrefix(slugs[0], m.FileKey))+"%").
+			Delete(&File{}).Error
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package filemeta
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlLog records the statements of a dry run session.
+type sqlLog struct {
+	logger.Interface
+	statements []string
+}
+
+func (l *sqlLog) LogMode(logger.LogLevel) logger.Interface { return l }
+
+func (l *sqlLog) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	l.statements = append(l.statements, sql)
+}
+
+func dryRunDB(t *testing.T) (*gorm.DB, *sqlLog) {
+	t.Helper()
+	log := &sqlLog{Interface: logger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(localhost:3306)/db", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: log})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db, log
+}
+
+func TestApplyToFiles(t *testing.T) {
+	companyID := "c1"
+
+	tests := []struct {
+		name string
+		meta FileMeta
+		want []string // a fragment of each statement, in order
+	}{
+		{
+			name: "upload",
+			meta: FileMeta{ID: "1", FileKey: "acme/a/x.txt", FileTxnType: TxnUpload, FileSize: 10},
+			want: []string{"INSERT INTO `files`"},
+		},
+		{
+			name: "client upload type",
+			meta: FileMeta{ID: "1", FileKey: "acme/a/x.txt", FileTxnType: 4, FileSize: 10},
+			want: []string{"INSERT INTO `files`"},
+		},
+		{
+			name: "delete",
+			meta: FileMeta{ID: "2", FileKey: "acme/a/x.txt", FileTxnType: TxnDelete},
+			want: []string{"DELETE FROM `files` WHERE company_id = 'c1' AND file_key = 'acme/a/x.txt'"},
+		},
+		{
+			name: "metadata update",
+			meta: FileMeta{ID: "3", FileKey: "acme/a/x.txt", FileTxnType: TxnMetadataUpdate, FileName: strPtr("y.txt")},
+			want: []string{"UPDATE `files` SET"},
+		},
+		{
+			name: "folder delete looks up the company slug",
+			meta: FileMeta{ID: "4", FileKey: "a", FileTxnType: TxnFolderDelete},
+			want: []string{"SELECT `company_slug` FROM `companies` WHERE id = 'c1'"},
+		},
+		{
+			name: "pending upload",
+			meta: FileMeta{ID: "5", FileKey: "acme/a/x.txt", FileTxnType: TxnUpload, Status: StatusPending},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, log := dryRunDB(t)
+			meta := tt.meta
+			meta.CompanyID = &companyID
+			if err := applyToFiles(db, &meta); err != nil {
+				t.Fatalf("applyToFiles: %v", err)
+			}
+			if len(log.statements) != len(tt.want) {
+				t.Fatalf("statements = %q, want %d", log.statements, len(tt.want))
+			}
+			for i, fragment := range tt.want {
+				if !strings.Contains(log.statements[i], fragment) {
+					t.Errorf("statement %d = %q, want it to contain %q", i, log.statements[i], fragment)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyToFilesMetadataUpdate(t *testing.T) {
+	db, log := dryRunDB(t)
+	companyID := "c1"
+	meta := FileMeta{ID: "3", FileKey: "acme/a/x.txt", FileTxnType: TxnMetadataUpdate, CompanyID: &companyID,
+		FileName: strPtr("y.txt"), Description: strPtr("d")}
+	if err := applyToFiles(db, &meta); err != nil {
+		t.Fatalf("applyToFiles: %v", err)
+	}
+	if len(log.statements) != 1 {
+		t.Fatalf("statements = %q, want 1", log.statements)
+	}
+	sql := log.statements[0]
+	for _, fragment := range []string{"`file_name`='y.txt'", "`description`='d'", "WHERE company_id = 'c1' AND file_key = 'acme/a/x.txt'"} {
+		if !strings.Contains(sql, fragment) {
+			t.Errorf("%q does not contain %q", sql, fragment)
+		}
+	}
+	if strings.Contains(sql, "file_size") || strings.Contains(sql, "file_meta_id") {
+		t.Errorf("metadata update changes the stored object: %q", sql)
+	}
+}
@@ -0,0 +1,64 @@
+package filemeta
+
+import "strings"
+
+// Replayer applies a company's transaction log, in the order the
+// transactions took effect, and keeps the upload record of every file that
+// still exists, keyed by file_key, with later metadata updates applied.
+// Folder deletes store the folder prefix relative to the company slug.
+type Replayer struct {
+	companySlug string
+	files       map[string]FileMeta
+}
+
+func NewReplayer(companySlug string) *Replayer {
+	return &Replayer{companySlug: companySlug, files: make(map[string]FileMeta)}
+}
+
+// Apply applies the next transactions of the log.
+func (r *Replayer) Apply(log []FileMeta) {
+	for _, m := range log {
+		if !m.Completed() {
+			continue
+		}
+		switch {
+		case IsUpload(m.FileTxnType):
+			r.files[m.FileKey] = m
+		case m.FileTxnType == TxnDelete:
+			delete(r.files, m.FileKey)
+		case m.FileTxnType == TxnMetadataUpdate:
+			if f, ok := r.files[m.FileKey]; ok {
+				f.FileName = m.FileName
+				f.FileTxnMeta = m.FileTxnMeta
+				f.Description = m.Description
+				f.Metadata = m.Metadata
+				r.files[m.FileKey] = f
+			}
+		case m.FileTxnType == TxnFolderDelete:
+			prefix := FolderKeyPrefix(r.companySlug, m.FileKey)
+			for key := range r.files {
+				if strings.HasPrefix(key, prefix) {
+					delete(r.files, key)
+				}
+			}
+		}
+	}
+}
+
+// Files returns the files after the transactions applied so far.
+func (r *Replayer) Files() map[string]FileMeta {
+	return r.files
+}
+
+// Replay applies a whole transaction log (in the order the transactions
+// took effect) and returns the files that still exist.
+func Replay(log []FileMeta, companySlug string) map[string]FileMeta {
+	r := NewReplayer(companySlug)
+	r.Apply(log)
+	return r.Files()
+}
+
+// FolderKeyPrefix returns the object key prefix of a folder of the company.
+func FolderKeyPrefix(companySlug, folderPrefix string) string {
+	return companySlug + "/" + strings.Trim(folderPrefix, "/") + "/"
+}
@@ -0,0 +1,128 @@
+package filemeta
+
+import (
+	"sort"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestReplay(t *testing.T) {
+	upload := func(id, key string) FileMeta {
+		return FileMeta{ID: id, FileKey: key, FileTxnType: TxnUpload, Status: StatusComplete}
+	}
+
+	tests := []struct {
+		name string
+		log  []FileMeta
+		want map[string]string // file_key -> id of the upload record
+	}{
+		{
+			name: "uploads",
+			log:  []FileMeta{upload("1", "acme/a/x.txt"), upload("2", "acme/b/y.txt")},
+			want: map[string]string{"acme/a/x.txt": "1", "acme/b/y.txt": "2"},
+		},
+		{
+			name: "later upload replaces",
+			log:  []FileMeta{upload("1", "acme/a/x.txt"), upload("2", "acme/a/x.txt")},
+			want: map[string]string{"acme/a/x.txt": "2"},
+		},
+		{
+			name: "client upload type",
+			log:  []FileMeta{{ID: "1", FileKey: "acme/a/x.txt", FileTxnType: 7}},
+			want: map[string]string{"acme/a/x.txt": "1"},
+		},
+		{
+			name: "delete",
+			log: []FileMeta{
+				upload("1", "acme/a/x.txt"),
+				{ID: "2", FileKey: "acme/a/x.txt", FileTxnType: TxnDelete},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "pending and aborted uploads do not exist",
+			log: []FileMeta{
+				{ID: "1", FileKey: "acme/a/x.txt", FileTxnType: TxnUpload, Status: StatusPending},
+				{ID: "2", FileKey: "acme/a/y.txt", FileTxnType: TxnUpload, Status: StatusAborted},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "aborted upload keeps the previous file",
+			log: []FileMeta{
+				upload("1", "acme/a/x.txt"),
+				{ID: "2", FileKey: "acme/a/x.txt", FileTxnType: TxnUpload, Status: StatusAborted},
+			},
+			want: map[string]string{"acme/a/x.txt": "1"},
+		},
+		{
+			name: "folder delete removes the folder and its subfolders only",
+			log: []FileMeta{
+				upload("1", "acme/a/x.txt"),
+				upload("2", "acme/a/b/y.txt"),
+				upload("3", "acme/ab/z.txt"),
+				{ID: "4", FileKey: "a", FileTxnType: TxnFolderDelete},
+			},
+			want: map[string]string{"acme/ab/z.txt": "3"},
+		},
+		{
+			name: "upload after folder delete",
+			log: []FileMeta{
+				upload("1", "acme/a/x.txt"),
+				{ID: "2", FileKey: "a/", FileTxnType: TxnFolderDelete},
+				upload("3", "acme/a/x.txt"),
+			},
+			want: map[string]string{"acme/a/x.txt": "3"},
+		},
+		{
+			name: "metadata update of a missing file is ignored",
+			log: []FileMeta{
+				{ID: "1", FileKey: "acme/a/x.txt", FileTxnType: TxnMetadataUpdate, FileName: strPtr("x")},
+			},
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Replay(tt.log, "acme")
+			if len(got) != len(tt.want) {
+				keys := make([]string, 0, len(got))
+				for key := range got {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				t.Fatalf("files = %v, want %v", keys, tt.want)
+			}
+			for key, id := range tt.want {
+				if got[key].ID != id {
+					t.Errorf("%s: id = %q, want %q", key, got[key].ID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayMetadataUpdate(t *testing.T) {
+	log := []FileMeta{
+		{ID: "1", FileKey: "acme/a/x.txt", FileTxnType: TxnUpload, FileName: strPtr("x.txt"), FileSize: 10},
+		{ID: "2", FileKey: "acme/a/x.txt", FileTxnType: TxnMetadataUpdate, FileName: strPtr("renamed.txt"), Description: strPtr("d"), Metadata: strPtr(`{"k":"v"}`)},
+	}
+
+	// Applied in pages, as history snapshots do.
+	r := NewReplayer("acme")
+	for _, m := range log {
+		r.Apply([]FileMeta{m})
+	}
+	file, ok := r.Files()["acme/a/x.txt"]
+	if !ok {
+		t.Fatal("file missing")
+	}
+	if file.ID != "1" || file.FileSize != 10 {
+		t.Errorf("upload record = %s/%d, want 1/10", file.ID, file.FileSize)
+	}
+	if *file.FileName != "renamed.txt" || *file.Description != "d" || *file.Metadata != `{"k":"v"}` {
+		t.Errorf("metadata not applied: %q %q %q", *file.FileName, *file.Description, *file.Metadata)
+	}
+}
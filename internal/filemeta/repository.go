@@ -2,16 +2,78 @@ package filemeta
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"shreshtasmg.in/jupyter/internal/locpath"
 )
 
 type Repository interface {
 	Create(f *FileMeta) error
 	GetByID(id string) (*FileMeta, error)
 	ListByCompanyID(companyID string, limit, offset int) ([]FileMeta, error)
+	// ListLogByCompanyID returns every transaction of a company in the
+	// order they took effect.
+	ListLogByCompanyID(companyID string) ([]FileMeta, error)
+	// ScanLogUntil passes the company's transactions that took effect at or
+	// before until to fn, a page at a time, in the order they took effect.
+	ScanLogUntil(companyID string, until time.Time, fn func(page []FileMeta) error) error
+	FindLatestByKey(companyID, fileKey string) (*FileMeta, error)
+	UpdateObjectStats(id string, fileSize int64, etag string) error
+	SetStatus(id, status string) error
+	SetLegalHold(id string, on bool) error
+	// Search returns the company's current files matching q, leaving out
+	// the files of folders in the trash.
+	Search(companyID string, q SearchQuery) ([]File, error)
+	AddTags(companyID, fileMetaID string, tags []string) error
+	// CreateWithTags records f and its tags in one transaction.
+	CreateWithTags(f *FileMeta, tags []string) error
+	// TagsByFileIDs returns the tags of the given files keyed by file ID.
+	TagsByFileIDs(ids []string) (map[string][]string, error)
+	// BackfillLocTags fills loc_tag of rows written before the column existed
+	// and returns how many it filled.
+	BackfillLocTags() (int64, error)
+	// BackfillCompletedAt dates the complete transactions recorded before
+	// completed_at existed with their creation and returns how many it dated.
+	BackfillCompletedAt() (int64, error)
+
+	// GetFile returns the current file stored under fileKey, or nil.
+	GetFile(companyID, fileKey string) (*File, error)
+	// GetFileByID returns the current file created by the upload fileMetaID,
+	// or nil if it was deleted or replaced.
+	GetFileByID(companyID, fileMetaID string) (*File, error)
+	// ListFiles returns the current files whose key starts with keyPrefix,
+	// ordered by key.
+	ListFiles(companyID, keyPrefix string) ([]File, error)
+	// ListFilesPage pages through the current files of a folder (all files
+	// if locTag is empty) ordered by key, leaving out the files of folders
+	// in the trash.
+	ListFilesPage(companyID, locTag string, recursive bool, limit, offset int) ([]File, error)
+	// ListFolderLocTags returns the distinct loc_tags at or below parent.
+	ListFolderLocTags(companyID, parent string) ([]string, error)
+	// LocTagSpellings returns the distinct loc_tags of files in the tree of
+	// the top-level folder top, compared by locpath.Fold.
+	LocTagSpellings(companyID, top string) ([]string, error)
+	// Stats counts the company's current files and their bytes.
+	Stats(companyID string) (FileStats, error)
+	// RebuildFiles replaces the company's files rows with the state replayed
+	// from its transaction log and returns the number of files.
+	RebuildFiles(companyID, companySlug string) (int, error)
+}
+
+// FileStats summarizes the current files of a company.
+type FileStats struct {
+	Files int64
+	Bytes int64
 }
 
+// notTrashed keeps the rows of the files table f whose folder and parent
+// folders are not in the trash.
+const notTrashed = `NOT EXISTS (SELECT 1 FROM folders t
+	WHERE t.company_id = f.company_id AND t.trashed_at IS NOT NULL
+	AND (f.loc_tag = t.path OR LEFT(f.loc_tag, CHAR_LENGTH(t.path) + 1) = CONCAT(t.path, '/')))`
+
 type repository struct {
 	db *gorm.DB
 }
@@ -38,7 +100,7 @@ func (r *repository) GetByID(id string) (*FileMeta, error) {
 func (r *repository) ListByCompanyID(companyID string, limit, offset int) ([]FileMeta, error) {
 	var metas []FileMeta
 	q := r.db.Where("company_id = ?", companyID).
-		Order("created_at DESC").
+		Order(newestFirst).
 		Limit(limit).
 		Offset(offset)
 
@@ -47,3 +109,342 @@ func (r *repository) ListByCompanyID(companyID string, limit, offset int) ([]Fil
 	}
 	return metas, nil
 }
+
+// newestFirst orders transactions by when they were recorded, newest first.
+// Rows recorded in the same millisecond are ordered by id, so the same row
+// comes first on every read.
+const newestFirst = "created_at DESC, id DESC"
+
+// logOrder orders transactions by when they took effect; rows without
+// completed_at took effect when they were recorded, or never.
+const logOrder = "COALESCE(completed_at, created_at) ASC, id ASC"
+
+// logPageSize is the number of transactions ScanLogUntil loads at a time.
+const logPageSize = 1000
+
+func (r *repository) ListLogByCompanyID(companyID string) ([]FileMeta, error) {
+	var metas []FileMeta
+	if err := r.db.Where("company_id = ?", companyID).
+		Order(logOrder).
+		Find(&metas).Error; err != nil {
+		return nil, err
+	}
+	return metas, nil
+}
+
+func (r *repository) ScanLogUntil(companyID string, until time.Time, fn func(page []FileMeta) error) error {
+	var afterAt time.Time
+	var afterID string
+	for first := true; ; first = false {
+		db := r.db.Where("company_id = ? AND completed_at <= ?", companyID, until)
+		if !first {
+			db = db.Where("(completed_at > ? OR (completed_at = ? AND id > ?))", afterAt, afterAt, afterID)
+		}
+		var page []FileMeta
+		if err := db.Order("completed_at ASC, id ASC").Limit(logPageSize).Find(&page).Error; err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if len(page) < logPageSize {
+			return nil
+		}
+		last := page[len(page)-1]
+		afterAt, afterID = *last.CompletedAt, last.ID
+	}
+}
+
+// FindLatestByKey returns the most recent transaction recorded for fileKey,
+// ignoring aborted uploads and metadata updates.
+func (r *repository) FindLatestByKey(companyID, fileKey string) (*FileMeta, error) {
+	var meta FileMeta
+	if err := r.db.Where("company_id = ? AND file_key = ? AND status <> ? AND file_txn_type <> ?", companyID, fileKey, StatusAborted, TxnMetadataUpdate).
+		Order(newestFirst).
+		First(&meta).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (r *repository) UpdateObjectStats(id string, fileSize int64, etag string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		stats := map[string]interface{}{"file_size": fileSize, "etag": etag}
+		if err := tx.Model(&FileMeta{}).Where("id = ?", id).Updates(stats).Error; err != nil {
+			return err
+		}
+		return tx.Model(&File{}).Where("file_meta_id = ?", id).Updates(stats).Error
+	})
+}
+
+func (r *repository) SetLegalHold(id string, on bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&FileMeta{}).Where("id = ?", id).Update("legal_hold", on).Error; err != nil {
+			return err
+		}
+		return tx.Model(&File{}).Where("file_meta_id = ?", id).Update("legal_hold", on).Error
+	})
+}
+
+// SetStatus changes an upload's status; completing it applies it to the
+// files projection.
+func (r *repository) SetStatus(id, status string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": status}
+		if status == StatusComplete {
+			updates["completed_at"] = time.Now()
+		}
+		if err := tx.Model(&FileMeta{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if status != StatusComplete {
+			return nil
+		}
+		var meta FileMeta
+		if err := tx.Where("id = ?", id).First(&meta).Error; err != nil {
+			return err
+		}
+		return applyToFiles(tx, &meta)
+	})
+}
+
+func (r *repository) Search(companyID string, q SearchQuery) ([]File, error) {
+	column, err := sortColumn(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	db := r.db.Table("files AS f").
+		Select("f.*").
+		Where("f.company_id = ?", companyID).
+		Where(notTrashed)
+
+	if q.NameContains != "" {
+		db = db.Where("f.file_name LIKE ?", "%"+escapeLike(q.NameContains)+"%")
+	}
+	if q.NamePrefix != "" {
+		db = db.Where("f.file_name LIKE ?", escapeLike(q.NamePrefix)+"%")
+	}
+	if q.LocTag != "" {
+		if q.Recursive {
+			db = db.Where("(f.loc_tag = ? OR f.loc_tag LIKE ?)", q.LocTag, escapeLike(q.LocTag)+"/%")
+		} else {
+			db = db.Where("f.loc_tag = ?", q.LocTag)
+		}
+	}
+	if q.TxnType != 0 {
+		db = db.Where("f.file_txn_type = ?", q.TxnType)
+	}
+	if q.MinSize != nil {
+		db = db.Where("f.file_size >= ?", *q.MinSize)
+	}
+	if q.MaxSize != nil {
+		db = db.Where("f.file_size <= ?", *q.MaxSize)
+	}
+	if q.CreatedFrom != nil {
+		db = db.Where("f.created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		db = db.Where("f.created_at < ?", *q.CreatedTo)
+	}
+	if len(q.Tags) > 0 {
+		db = db.Where(`f.file_meta_id IN (
+			SELECT t.file_meta_id FROM file_tags t
+			WHERE t.company_id = ? AND t.tag IN ?
+			GROUP BY t.file_meta_id HAVING COUNT(*) = ?)`, companyID, q.Tags, len(q.Tags))
+	}
+
+	op, order := ">", "ASC"
+	if q.Descending {
+		op, order = "<", "DESC"
+	}
+	if q.After != nil {
+		value, err := cursorValue(q.Sort, q.After.Value)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		db = db.Where("("+column+" "+op+" ? OR ("+column+" = ? AND f.file_key "+op+" ?))", value, value, q.After.Key)
+	}
+
+	var files []File
+	if err := db.Order(column + " " + order).
+		Order("f.file_key " + order).
+		Limit(q.Limit).
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *repository) AddTags(companyID, fileMetaID string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, Tag{FileMetaID: fileMetaID, Tag: tag, CompanyID: companyID})
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+func (r *repository) CreateWithTags(f *FileMeta, tags []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(f).Error; err != nil {
+			return err
+		}
+		var companyID string
+		if f.CompanyID != nil {
+			companyID = *f.CompanyID
+		}
+		return (&repository{db: tx}).AddTags(companyID, f.ID, tags)
+	})
+}
+
+func (r *repository) TagsByFileIDs(ids []string) (map[string][]string, error) {
+	tags := make(map[string][]string)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	var rows []Tag
+	if err := r.db.Where("file_meta_id IN ?", ids).Order("tag").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.FileMetaID] = append(tags[row.FileMetaID], row.Tag)
+	}
+	return tags, nil
+}
+
+func (r *repository) BackfillLocTags() (int64, error) {
+	res := r.db.Model(&FileMeta{}).
+		Where("loc_tag IS NULL AND file_txn_type <> ?", TxnFolderDelete).
+		Where("CHAR_LENGTH(file_key) - CHAR_LENGTH(REPLACE(file_key, '/', '')) >= 2").
+		Update("loc_tag", gorm.Expr(`SUBSTRING(file_key,
+			CHAR_LENGTH(SUBSTRING_INDEX(file_key, '/', 1)) + 2,
+			CHAR_LENGTH(file_key) - CHAR_LENGTH(SUBSTRING_INDEX(file_key, '/', 1)) - CHAR_LENGTH(SUBSTRING_INDEX(file_key, '/', -1)) - 2)`))
+	return res.RowsAffected, res.Error
+}
+
+func (r *repository) BackfillCompletedAt() (int64, error) {
+	res := r.db.Model(&FileMeta{}).
+		Where("completed_at IS NULL AND status IN ?", []string{"", StatusComplete}).
+		Update("completed_at", gorm.Expr("created_at"))
+	return res.RowsAffected, res.Error
+}
+
+func (r *repository) GetFile(companyID, fileKey string) (*File, error) {
+	var file File
+	if err := r.db.Where("company_id = ? AND file_key = ?", companyID, fileKey).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (r *repository) GetFileByID(companyID, fileMetaID string) (*File, error) {
+	var file File
+	if err := r.db.Where("company_id = ? AND file_meta_id = ?", companyID, fileMetaID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (r *repository) ListFiles(companyID, keyPrefix string) ([]File, error) {
+	var files []File
+	if err := r.db.Where("company_id = ? AND file_key LIKE ?", companyID, escapeLike(keyPrefix)+"%").
+		Order("file_key").
+		Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *repository) ListFilesPage(companyID, locTag string, recursive bool, limit, offset int) ([]File, error) {
+	q := r.db.Table("files AS f").
+		Select("f.*").
+		Where("f.company_id = ?", companyID).
+		Where(notTrashed)
+	if locTag != "" {
+		if recursive {
+			q = q.Where("(f.loc_tag = ? OR f.loc_tag LIKE ?)", locTag, escapeLike(locTag)+"/%")
+		} else {
+			q = q.Where("f.loc_tag = ?", locTag)
+		}
+	}
+
+	var files []File
+	if err := q.Order("f.file_key").Limit(limit).Offset(offset).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (r *repository) ListFolderLocTags(companyID, parent string) ([]string, error) {
+	q := r.db.Model(&File{}).Distinct("loc_tag").Where("company_id = ? AND loc_tag IS NOT NULL", companyID)
+	if parent != "" {
+		q = q.Where("loc_tag LIKE ?", escapeLike(parent)+"/%")
+	}
+
+	var locTags []string
+	if err := q.Order("loc_tag").Pluck("loc_tag", &locTags).Error; err != nil {
+		return nil, err
+	}
+	return locTags, nil
+}
+
+func (r *repository) LocTagSpellings(companyID, top string) ([]string, error) {
+	top = locpath.Fold(top)
+	var locTags []string
+	if err := r.db.Model(&File{}).Distinct("loc_tag").
+		Where("company_id = ? AND (loc_fold = ? OR loc_fold LIKE ?)", companyID, top, escapeLike(top)+"/%").
+		Order("loc_tag").
+		Pluck("loc_tag", &locTags).Error; err != nil {
+		return nil, err
+	}
+	return locTags, nil
+}
+
+func (r *repository) Stats(companyID string) (FileStats, error) {
+	var stats FileStats
+	err := r.db.Model(&File{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("company_id = ?", companyID).
+		Scan(&stats).Error
+	return stats, err
+}
+
+func (r *repository) RebuildFiles(companyID, companySlug string) (int, error) {
+	var count int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var log []FileMeta
+		if err := tx.Where("company_id = ?", companyID).Order(logOrder).Find(&log).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("company_id = ?", companyID).Delete(&File{}).Error; err != nil {
+			return err
+		}
+
+		current := Replay(log, companySlug)
+		files := make([]File, 0, len(current))
+		for _, m := range current {
+			files = append(files, FileFromMeta(m))
+		}
+		count = len(files)
+		if len(files) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(files, 500).Error
+	})
+	return count, err
+}
@@ -0,0 +1,16 @@
+package filemeta
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindLatestByKeyBreaksTies(t *testing.T) {
+	db, log := dryRunDB(t)
+	if _, err := NewRepository(db).FindLatestByKey("c1", "acme/a/x.txt"); err != nil {
+		t.Fatalf("FindLatestByKey: %v", err)
+	}
+	if len(log.statements) != 1 || !strings.Contains(log.statements[0], "ORDER BY created_at DESC, id DESC") {
+		t.Errorf("statements = %q, want an order by created_at and id", log.statements)
+	}
+}
@@ -0,0 +1,117 @@
+package filemeta
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Search sort fields.
+const (
+	SortCreatedAt = "created_at"
+	SortFileName  = "file_name"
+	SortFileSize  = "file_size"
+)
+
+const (
+	maxTags      = 20
+	maxTagLength = 64
+)
+
+// SearchQuery filters the current files of a company. Zero values disable a
+// filter.
+type SearchQuery struct {
+	NameContains string
+	NamePrefix   string
+	LocTag       string
+	// Recursive also matches files in sub folders of LocTag.
+	Recursive   bool
+	TxnType     int16
+	MinSize     *int64
+	MaxSize     *int64
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Tags must all be present on a file.
+	Tags []string
+
+	Sort       string
+	Descending bool
+	Limit      int
+	// After continues a previous search after this row.
+	After *SearchCursor
+}
+
+// SearchCursor is the sort value and file key of the last row of a page.
+type SearchCursor struct {
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+// CursorFor returns the cursor pointing after f for the given sort field.
+func CursorFor(f File, sortField string) SearchCursor {
+	switch sortField {
+	case SortFileName:
+		name := ""
+		if f.FileName != nil {
+			name = *f.FileName
+		}
+		return SearchCursor{Value: name, Key: f.FileKey}
+	case SortFileSize:
+		return SearchCursor{Value: strconv.FormatInt(f.FileSize, 10), Key: f.FileKey}
+	}
+	return SearchCursor{Value: f.CreatedAt.UTC().Format(time.RFC3339Nano), Key: f.FileKey}
+}
+
+// NormalizeTags lower-cases, trims and de-duplicates tags.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tags must be at most %d characters", maxTagLength)
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("at most %d tags per file", maxTags)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// sortColumn validates the sort field and returns its SQL expression.
+func sortColumn(field string) (string, error) {
+	switch field {
+	case "", SortCreatedAt:
+		return "f.created_at", nil
+	case SortFileName:
+		return "COALESCE(f.file_name, '')", nil
+	case SortFileSize:
+		return "f.file_size", nil
+	}
+	return "", errors.New("sort must be created_at, file_name or file_size")
+}
+
+// cursorValue converts a cursor value to the type of the sort column.
+func cursorValue(field, value string) (interface{}, error) {
+	switch field {
+	case SortFileName:
+		return value, nil
+	case SortFileSize:
+		return strconv.ParseInt(value, 10, 64)
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,29 @@
+package filemeta
+
+import "strings"
+
+// S3 storage classes. Objects without a class are stored in STANDARD.
+const (
+	StorageClassStandard    = "STANDARD"
+	StorageClassStandardIA  = "STANDARD_IA"
+	StorageClassGlacierIR   = "GLACIER_IR"
+	StorageClassGlacier     = "GLACIER"
+	StorageClassDeepArchive = "DEEP_ARCHIVE"
+)
+
+// ParseStorageClass returns the S3 name of a storage class clients may
+// choose, given in any case. Objects only reach GLACIER through transitions
+// configured outside the service, so it cannot be chosen.
+func ParseStorageClass(s string) (string, bool) {
+	switch class := strings.ToUpper(strings.TrimSpace(s)); class {
+	case StorageClassStandard, StorageClassStandardIA, StorageClassGlacierIR, StorageClassDeepArchive:
+		return class, true
+	}
+	return "", false
+}
+
+// Archived reports whether objects of the storage class must be restored
+// before they can be read.
+func Archived(storageClass string) bool {
+	return storageClass == StorageClassGlacier || storageClass == StorageClassDeepArchive
+}
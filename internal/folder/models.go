@@ -0,0 +1,104 @@
+package folder
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"shreshtasmg.in/jupyter/internal/locpath"
+)
+
+// Folder is an explicit folder of a company. Its Path is the loc_tag of the
+// files it holds; files can also live in folders that have no row.
+type Folder struct {
+	ID        string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	CompanyID string    `gorm:"type:varchar(40);not null;uniqueIndex:idx_folders_company_path;index:idx_folders_company_path_fold,priority:1;column:company_id"`
+	Path      string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_folders_company_path;column:path"`
+	// PathFold is Path in the form locations are compared in, locpath.Fold,
+	// compared byte by byte so the index serves case-insensitive lookups.
+	PathFold    string     `gorm:"type:varchar(255) COLLATE utf8mb4_bin;not null;default:'';index:idx_folders_company_path_fold,priority:2;column:path_fold"`
+	Description *string    `gorm:"type:varchar(1024);column:description"`
+	Owner       *string    `gorm:"type:varchar(255);column:owner"`
+	TrashedAt   *time.Time `gorm:"index;column:trashed_at"`
+	// LockMode and LockRetentionDays are the Object Lock policy of uploads
+	// to the folder and its sub folders.
+	LockMode          *string   `gorm:"type:varchar(16);column:lock_mode"`
+	LockRetentionDays *int      `gorm:"column:lock_retention_days"`
+	UpdatedAt         time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (Folder) TableName() string {
+	return "folders"
+}
+
+// BeforeSave derives PathFold from Path.
+func (f *Folder) BeforeSave(tx *gorm.DB) error {
+	f.PathFold = locpath.Fold(f.Path)
+	return nil
+}
+
+func (f *Folder) Trashed() bool {
+	return f.TrashedAt != nil
+}
+
+// Within reports whether path is parent itself or one of its sub folders.
+func Within(path, parent string) bool {
+	return path == parent || strings.HasPrefix(path, parent+"/")
+}
+
+// WithinAny reports whether path is one of parents or in one of their sub
+// folders.
+func WithinAny(path string, parents []string) bool {
+	for _, parent := range parents {
+		if Within(path, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rename statuses.
+const (
+	RenameCopying   = "copying"
+	RenameDeleting  = "deleting" // files recorded at the new path, old objects being deleted
+	RenameCompleted = "completed"
+)
+
+// Rename moves a folder tree to another path. Until it completes it locks
+// its old and new trees against uploads, deletes and other renames, and it
+// is resumed after a failure: files up to LastKey have been copied.
+type Rename struct {
+	ID          string     `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime"`
+	CompanyID   string     `gorm:"type:varchar(40);not null;index:idx_folder_renames_company_status,priority:1;column:company_id"`
+	FolderID    string     `gorm:"type:varchar(40);not null;column:folder_id"`
+	FromPath    string     `gorm:"type:varchar(255);not null;column:from_path"`
+	ToPath      string     `gorm:"type:varchar(255);not null;column:to_path"`
+	FileTxnMeta *string    `gorm:"type:varchar(255);column:file_txn_meta"`
+	Status      string     `gorm:"type:varchar(20);not null;index:idx_folder_renames_company_status,priority:2;column:status"`
+	LastKey     string     `gorm:"type:varchar(255);column:last_key"`
+	MovedCount  int        `gorm:"column:moved_count;default:0"`
+	MovedBytes  int64      `gorm:"column:moved_bytes;default:0"`
+	Error       *string    `gorm:"type:text;column:error"`
+	FinishedAt  *time.Time `gorm:"column:finished_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (Rename) TableName() string {
+	return "folder_renames"
+}
+
+func (r Rename) Finished() bool {
+	return r.Status == RenameCompleted
+}
+
+// Locks reports whether the unfinished rename holds path: path is in the
+// old or new tree, or contains one of them.
+func (r Rename) Locks(path string) bool {
+	if r.Finished() {
+		return false
+	}
+	return Within(path, r.FromPath) || Within(r.FromPath, path) ||
+		Within(path, r.ToPath) || Within(r.ToPath, path)
+}
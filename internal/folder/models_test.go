@@ -0,0 +1,31 @@
+package folder
+
+import "testing"
+
+func TestRenameLocks(t *testing.T) {
+	rename := Rename{FromPath: "a/b", ToPath: "c", Status: RenameCopying}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a/b", true},
+		{"a/b/x", true},
+		{"a", true}, // holds the old tree
+		{"a/bc", false},
+		{"c", true},
+		{"c/d", true},
+		{"cd", false},
+		{"x", false},
+	}
+	for _, tt := range tests {
+		if got := rename.Locks(tt.path); got != tt.want {
+			t.Errorf("Locks(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	rename.Status = RenameCompleted
+	if rename.Locks("a/b") {
+		t.Error("completed rename still locks its path")
+	}
+}
@@ -0,0 +1,285 @@
+package folder
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/locpath"
+)
+
+type Repository interface {
+	Create(f *Folder) error
+	GetByID(id string) (*Folder, error)
+	// GetByPath returns the company's folder at path, trashed or not.
+	GetByPath(companyID, path string) (*Folder, error)
+	// ListTree returns the folder at path and all its sub folders, trashed
+	// or not, ordered by path.
+	ListTree(companyID, path string) ([]Folder, error)
+	ListByCompanyID(companyID string, trashed bool) ([]Folder, error)
+	// LockPolicy returns the innermost folder at or above path that has an
+	// Object Lock policy, or nil.
+	LockPolicy(companyID, path string) (*Folder, error)
+	// Spellings returns the stored paths in the tree of the top-level folder
+	// top, compared by locpath.Fold.
+	Spellings(companyID, top string) ([]string, error)
+	Update(f *Folder) error
+	// Trash moves the live folders of the tree at path to the trash.
+	Trash(companyID, path string, at time.Time) error
+	// Restore takes the folders of the tree at path that were trashed at
+	// trashedAt out of the trash. Sub folders trashed earlier stay trashed.
+	Restore(companyID, path string, trashedAt time.Time) error
+	// Move changes the path of the tree at from to to.
+	Move(companyID, from, to string) error
+	DeleteTree(companyID, path string) error
+
+	// InTrash reports whether the folder at path or one of its parents is
+	// in the trash.
+	InTrash(companyID, path string) (bool, error)
+	// TrashedPaths returns the paths of the company's trashed folders.
+	TrashedPaths(companyID string) ([]string, error)
+	// ListTrashedBefore returns up to limit folders of any company trashed
+	// before t, ordered by company and path, after the folder at
+	// (afterCompanyID, afterPath).
+	ListTrashedBefore(t time.Time, afterCompanyID, afterPath string, limit int) ([]Folder, error)
+
+	// CreateRename stores rename unless an unfinished rename of the company
+	// locks its old or new path. It reports whether it stored it.
+	CreateRename(rename *Rename) (bool, error)
+	GetRename(id string) (*Rename, error)
+	SaveRename(rename *Rename) error
+	// RenameLocking returns the unfinished rename of the company that locks
+	// path, or nil.
+	RenameLocking(companyID, path string) (*Rename, error)
+	ListUnfinishedRenames() ([]Rename, error)
+
+	// Transaction runs fn with stores bound to a single transaction.
+	Transaction(fn func(tx Stores) error) error
+}
+
+// Stores are the repositories a rename updates together.
+type Stores struct {
+	Folders   Repository
+	FileMetas filemeta.Repository
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// tree scopes a query to the folder at path and its sub folders.
+func (r *repository) tree(companyID, path string) *gorm.DB {
+	return r.db.Model(&Folder{}).
+		Where("company_id = ? AND (path = ? OR path LIKE ?)", companyID, path, escapeLike(path)+"/%")
+}
+
+func (r *repository) Create(f *Folder) error {
+	return r.db.Create(f).Error
+}
+
+func (r *repository) GetByID(id string) (*Folder, error) {
+	var f Folder
+	if err := r.db.Where("id = ?", id).First(&f).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *repository) GetByPath(companyID, path string) (*Folder, error) {
+	var f Folder
+	if err := r.db.Where("company_id = ? AND path = ?", companyID, path).First(&f).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *repository) ListTree(companyID, path string) ([]Folder, error) {
+	var folders []Folder
+	if err := r.tree(companyID, path).Order("path").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (r *repository) ListByCompanyID(companyID string, trashed bool) ([]Folder, error) {
+	q := r.db.Where("company_id = ?", companyID)
+	if trashed {
+		q = q.Where("trashed_at IS NOT NULL")
+	} else {
+		q = q.Where("trashed_at IS NULL")
+	}
+
+	var folders []Folder
+	if err := q.Order("path").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (r *repository) LockPolicy(companyID, path string) (*Folder, error) {
+	var f Folder
+	if err := r.db.Where("company_id = ? AND path IN ? AND lock_mode IS NOT NULL", companyID, locpath.Ancestors(path)).
+		Order("CHAR_LENGTH(path) DESC").
+		First(&f).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *repository) Spellings(companyID, top string) ([]string, error) {
+	top = locpath.Fold(top)
+	var spellings []string
+	if err := r.db.Model(&Folder{}).
+		Where("company_id = ? AND (path_fold = ? OR path_fold LIKE ?)", companyID, top, escapeLike(top)+"/%").
+		Order("created_at").
+		Pluck("path", &spellings).Error; err != nil {
+		return nil, err
+	}
+	return spellings, nil
+}
+
+func (r *repository) Update(f *Folder) error {
+	return r.db.Save(f).Error
+}
+
+func (r *repository) Trash(companyID, path string, at time.Time) error {
+	return r.tree(companyID, path).Where("trashed_at IS NULL").Update("trashed_at", at).Error
+}
+
+func (r *repository) Restore(companyID, path string, trashedAt time.Time) error {
+	return r.tree(companyID, path).Where("trashed_at = ?", trashedAt).Update("trashed_at", nil).Error
+}
+
+func (r *repository) Move(companyID, from, to string) error {
+	// SUBSTRING counts characters from 1, so this keeps everything after
+	// from. Folding keeps the number of characters.
+	return r.tree(companyID, from).Updates(map[string]interface{}{
+		"path":      gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", to, utf8.RuneCountInString(from)+1),
+		"path_fold": gorm.Expr("CONCAT(?, SUBSTRING(path_fold, ?))", locpath.Fold(to), utf8.RuneCountInString(from)+1),
+	}).Error
+}
+
+func (r *repository) DeleteTree(companyID, path string) error {
+	return r.tree(companyID, path).Delete(&Folder{}).Error
+}
+
+func (r *repository) InTrash(companyID, path string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Folder{}).
+		Where("company_id = ? AND path IN ? AND trashed_at IS NOT NULL", companyID, locpath.Ancestors(path)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *repository) TrashedPaths(companyID string) ([]string, error) {
+	var paths []string
+	if err := r.db.Model(&Folder{}).
+		Where("company_id = ? AND trashed_at IS NOT NULL", companyID).
+		Order("path").
+		Pluck("path", &paths).Error; err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (r *repository) ListTrashedBefore(t time.Time, afterCompanyID, afterPath string, limit int) ([]Folder, error) {
+	var folders []Folder
+	if err := r.db.Where("trashed_at < ?", t).
+		Where("(company_id > ? OR (company_id = ? AND path > ?))", afterCompanyID, afterCompanyID, afterPath).
+		Order("company_id, path").
+		Limit(limit).
+		Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (r *repository) CreateRename(rename *Rename) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// The locking read also locks the gaps of the company's index range,
+		// so a concurrent rename of the company waits for this one.
+		var unfinished []Rename
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("company_id = ? AND status <> ?", rename.CompanyID, RenameCompleted).
+			Find(&unfinished).Error; err != nil {
+			return err
+		}
+		for _, other := range unfinished {
+			if other.Locks(rename.FromPath) || other.Locks(rename.ToPath) {
+				return nil
+			}
+		}
+		created = true
+		return tx.Create(rename).Error
+	})
+	return created, err
+}
+
+func (r *repository) GetRename(id string) (*Rename, error) {
+	var rename Rename
+	if err := r.db.Where("id = ?", id).First(&rename).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rename, nil
+}
+
+func (r *repository) SaveRename(rename *Rename) error {
+	return r.db.Save(rename).Error
+}
+
+func (r *repository) RenameLocking(companyID, path string) (*Rename, error) {
+	var unfinished []Rename
+	if err := r.db.Where("company_id = ? AND status <> ?", companyID, RenameCompleted).
+		Order("created_at").
+		Find(&unfinished).Error; err != nil {
+		return nil, err
+	}
+	for i := range unfinished {
+		if unfinished[i].Locks(path) {
+			return &unfinished[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *repository) ListUnfinishedRenames() ([]Rename, error) {
+	var renames []Rename
+	if err := r.db.Where("status <> ?", RenameCompleted).Order("created_at").Find(&renames).Error; err != nil {
+		return nil, err
+	}
+	return renames, nil
+}
+
+func (r *repository) Transaction(fn func(tx Stores) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(Stores{
+			Folders:   &repository{db: tx},
+			FileMetas: filemeta.NewRepository(tx),
+		})
+	})
+}
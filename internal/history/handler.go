@@ -0,0 +1,301 @@
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/locpath"
+)
+
+type FileVersion struct {
+	FileID    string  `json:"file_id"`
+	FileKey   string  `json:"file_key"`
+	FileName  *string `json:"file_name,omitempty"`
+	FileSize  int64   `json:"file_size"`
+	CreatedAt string  `json:"created_at"`
+}
+
+type SnapshotResponse struct {
+	At        string        `json:"at"`
+	Prefix    string        `json:"prefix"`
+	Items     []FileVersion `json:"items"`
+	TotalSize int64         `json:"total_size"`
+}
+
+type ChangedFile struct {
+	FileKey string      `json:"file_key"`
+	Before  FileVersion `json:"before"`
+	After   FileVersion `json:"after"`
+}
+
+type DiffResponse struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	Prefix  string        `json:"prefix"`
+	Added   []FileVersion `json:"added"`
+	Removed []FileVersion `json:"removed"`
+	Changed []ChangedFile `json:"changed"` // replaced by a new upload in between
+}
+
+type Handler struct {
+	companyRepo  company.Repository
+	fileMetaRepo filemeta.Repository
+}
+
+func NewHandler(companyRepo company.Repository, fileMetaRepo filemeta.Repository) *Handler {
+	return &Handler{companyRepo: companyRepo, fileMetaRepo: fileMetaRepo}
+}
+
+func toFileVersion(m filemeta.FileMeta) FileVersion {
+	return FileVersion{
+		FileID:    m.ID,
+		FileKey:   m.FileKey,
+		FileName:  m.FileName,
+		FileSize:  m.FileSize,
+		CreatedAt: m.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// snapshot replays the company's log up to at, by when each transaction
+// took effect, and returns the files under
+// prefix (relative to the company slug, "" for all) at that moment. Like
+// locations, the prefix is matched without regard to case.
+func (h *Handler) snapshot(companyRec *company.Company, prefix string, at time.Time) (map[string]filemeta.FileMeta, error) {
+	replayer := filemeta.NewReplayer(companyRec.CompanySlug)
+	err := h.fileMetaRepo.ScanLogUntil(companyRec.ID, at, func(page []filemeta.FileMeta) error {
+		replayer.Apply(page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyPrefix := companyRec.CompanySlug + "/"
+	if prefix != "" {
+		keyPrefix = filemeta.FolderKeyPrefix(companyRec.CompanySlug, prefix)
+	}
+	files := replayer.Files()
+	for key := range files {
+		if !strings.HasPrefix(locpath.Fold(key), locpath.Fold(keyPrefix)) {
+			delete(files, key)
+		}
+	}
+	return files, nil
+}
+
+func sortedVersions(files map[string]filemeta.FileMeta) []FileVersion {
+	items := make([]FileVersion, 0, len(files))
+	for _, m := range files {
+		items = append(items, toFileVersion(m))
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].FileKey < items[j].FileKey })
+	return items
+}
+
+func parseTime(r *http.Request, name string) (time.Time, string) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, name + " is required"
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, name + " must be an RFC3339 timestamp"
+	}
+	return t, ""
+}
+
+func parsePrefix(r *http.Request) (string, string) {
+	prefix, err := locpath.CleanOptional(r.URL.Query().Get("prefix"))
+	if err != nil {
+		return "", "prefix: " + err.Error()
+	}
+	return prefix, ""
+}
+
+// resolveCompany returns the company of the API key, or for admin routes
+// the one named by company_slug. It writes the error response on failure.
+func (h *Handler) resolveCompany(w http.ResponseWriter, r *http.Request) *company.Company {
+	if companyRec := company.FromContext(r.Context()); companyRec != nil {
+		return companyRec
+	}
+
+	companyRec, err := h.companyRepo.GetBySlug(r.URL.Query().Get("company_slug"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return nil
+	}
+	if companyRec == nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return nil
+	}
+	return companyRec
+}
+
+// Snapshot godoc
+// @Summary      List a folder as it was at a point in time
+// @Description  Replays the transaction log up to at and returns the files that existed under prefix at that moment.
+// @Description  Uploads count from when they completed, so a resumable upload still running at that moment is not listed.
+// @Tags         history
+// @Produce      json
+// @Param        X-API-Key  header  string  true   "Company API key"
+// @Param        at         query   string  true   "Point in time (RFC3339)"
+// @Param        prefix     query   string  false  "Folder (default: all files)"
+// @Success      200        {object}  SnapshotResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Router       /uploader/history/snapshot [get]
+func (h *Handler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	companyRec := h.resolveCompany(w, r)
+	if companyRec == nil {
+		return
+	}
+	at, msg := parseTime(r, "at")
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	prefix, msg := parsePrefix(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	files, err := h.snapshot(companyRec, prefix, at)
+	if err != nil {
+		http.Error(w, "failed to read transaction log", http.StatusInternalServerError)
+		return
+	}
+
+	resp := SnapshotResponse{
+		At:     at.Format(time.RFC3339),
+		Prefix: prefix,
+		Items:  sortedVersions(files),
+	}
+	for _, item := range resp.Items {
+		resp.TotalSize += item.FileSize
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Diff godoc
+// @Summary      Compare a folder between two points in time
+// @Description  Files added, removed or replaced under prefix between from and to
+// @Tags         history
+// @Produce      json
+// @Param        X-API-Key  header  string  true   "Company API key"
+// @Param        from       query   string  true   "Start (RFC3339)"
+// @Param        to         query   string  true   "End (RFC3339)"
+// @Param        prefix     query   string  false  "Folder (default: all files)"
+// @Success      200        {object}  DiffResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Router       /uploader/history/diff [get]
+func (h *Handler) Diff(w http.ResponseWriter, r *http.Request) {
+	companyRec := h.resolveCompany(w, r)
+	if companyRec == nil {
+		return
+	}
+	from, msg := parseTime(r, "from")
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	to, msg := parseTime(r, "to")
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	prefix, msg := parsePrefix(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.snapshot(companyRec, prefix, from)
+	if err != nil {
+		http.Error(w, "failed to read transaction log", http.StatusInternalServerError)
+		return
+	}
+	after, err := h.snapshot(companyRec, prefix, to)
+	if err != nil {
+		http.Error(w, "failed to read transaction log", http.StatusInternalServerError)
+		return
+	}
+
+	added := make(map[string]filemeta.FileMeta)
+	removed := make(map[string]filemeta.FileMeta)
+	resp := DiffResponse{
+		From:    from.Format(time.RFC3339),
+		To:      to.Format(time.RFC3339),
+		Prefix:  prefix,
+		Changed: []ChangedFile{},
+	}
+	for key, old := range before {
+		current, ok := after[key]
+		switch {
+		case !ok:
+			removed[key] = old
+		case current.ID != old.ID:
+			resp.Changed = append(resp.Changed, ChangedFile{FileKey: key, Before: toFileVersion(old), After: toFileVersion(current)})
+		}
+	}
+	for key, current := range after {
+		if _, ok := before[key]; !ok {
+			added[key] = current
+		}
+	}
+	resp.Added = sortedVersions(added)
+	resp.Removed = sortedVersions(removed)
+	sort.Slice(resp.Changed, func(i, j int) bool { return resp.Changed[i].FileKey < resp.Changed[j].FileKey })
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// AdminSnapshot godoc
+// @Summary      List a company folder as it was at a point in time (admin)
+// @Tags         history
+// @Produce      json
+// @Param        client_id      header  string  true   "Admin client id"
+// @Param        client_secret  header  string  true   "Admin client secret"
+// @Param        company_slug   query   string  true   "Company slug"
+// @Param        at             query   string  true   "Point in time (RFC3339)"
+// @Param        prefix         query   string  false  "Folder (default: all files)"
+// @Success      200            {object}  SnapshotResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Router       /storage/history/snapshot [get]
+func (h *Handler) AdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	h.Snapshot(w, r)
+}
+
+// AdminDiff godoc
+// @Summary      Compare a company folder between two points in time (admin)
+// @Tags         history
+// @Produce      json
+// @Param        client_id      header  string  true   "Admin client id"
+// @Param        client_secret  header  string  true   "Admin client secret"
+// @Param        company_slug   query   string  true   "Company slug"
+// @Param        from           query   string  true   "Start (RFC3339)"
+// @Param        to             query   string  true   "End (RFC3339)"
+// @Param        prefix         query   string  false  "Folder (default: all files)"
+// @Success      200            {object}  DiffResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Router       /storage/history/diff [get]
+func (h *Handler) AdminDiff(w http.ResponseWriter, r *http.Request) {
+	h.Diff(w, r)
+}
@@ -6,13 +6,25 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"shreshtasmg.in/jupyter/internal/company"
 	"shreshtasmg.in/jupyter/internal/config"
 	"shreshtasmg.in/jupyter/internal/contactus"
+	"shreshtasmg.in/jupyter/internal/download"
+	"shreshtasmg.in/jupyter/internal/history"
+	"shreshtasmg.in/jupyter/internal/reconcile"
+	"shreshtasmg.in/jupyter/internal/retention"
+	"shreshtasmg.in/jupyter/internal/s3event"
+	"shreshtasmg.in/jupyter/internal/sharelink"
+	"shreshtasmg.in/jupyter/internal/storagemigration"
+	"shreshtasmg.in/jupyter/internal/tus"
 	"shreshtasmg.in/jupyter/internal/uploader"
 )
 
-func NewRouter(config *config.Config,
-	uploaderConfigHandler *uploader.Handler, contactUsHandler *contactus.Handler, configHandler *config.Handler) http.Handler {
+func NewRouter(cfg *config.Config, configRepo config.Repository, companyRepo company.Repository, keyHasher *company.KeyHasher,
+	uploaderConfigHandler *uploader.Handler, contactUsHandler *contactus.Handler, configHandler *config.Handler,
+	s3EventHandler *s3event.Handler, reconcileHandler *reconcile.Handler, retentionHandler *retention.Handler,
+	shareLinkHandler *sharelink.Handler, migrationHandler *storagemigration.Handler, tusHandler *tus.Handler,
+	historyHandler *history.Handler, downloadHandler *download.Handler, companyHandler *company.Handler) http.Handler {
 	r := chi.NewRouter()
 	// Middlewares
 	r.Use(middleware.RequestID)
@@ -21,20 +33,112 @@ func NewRouter(config *config.Config,
 
 	// Swagger UI route
 	// Served at: /swagger/index.html
-	if config.APP_ENV == "local" {
+	if cfg.APP_ENV == "local" {
 		r.Get("/swagger/*", httpSwagger.WrapHandler)
 	}
 
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Post("/uploader/files", uploaderConfigHandler.GenerateUploadURL)
-		r.Post("/uploader/folders/delete", uploaderConfigHandler.DeleteFolder)
-		r.Post("/uploader/files/delete", uploaderConfigHandler.DeleteFile)
-		r.Post("/company/register", uploaderConfigHandler.RegisterCompany)
 		r.Post("/config/storage", uploaderConfigHandler.CreateUploaderConfig)
 		r.Post("/contactus", contactUsHandler.CreateContactUs)
-		r.Post("/config/adminclient/new", configHandler.CreateAdminClient)
 		r.Post("/config/adminclient/validate", configHandler.ValidateAdminClient)
+		r.Get("/share/{token}", shareLinkHandler.ResolveShareLink)
+		r.Post("/share/{token}", shareLinkHandler.ResolveShareLink)
+		r.Get("/download/{token}", downloadHandler.DownloadWithToken)
+		r.Head("/download/{token}", downloadHandler.DownloadWithToken)
+		r.Options("/uploader/tus", tusHandler.Options)
+
+		// Company API key routes; during the grace period after a
+		// subscription ends only the reads are allowed.
+		r.Group(func(r chi.Router) {
+			r.Use(company.RequireAPIKey(companyRepo, keyHasher, cfg.SubscriptionGracePeriod))
+			r.Get("/uploader/files", uploaderConfigHandler.ListCompanyFiles)
+			r.Get("/uploader/files/search", uploaderConfigHandler.SearchFiles)
+			r.Get("/uploader/files/{id}", uploaderConfigHandler.GetFile)
+			r.Get("/uploader/files/{id}/download", downloadHandler.DownloadFile)
+			r.Head("/uploader/files/{id}/download", downloadHandler.DownloadFile)
+			r.Post("/uploader/files/{id}/download-token", downloadHandler.CreateDownloadToken)
+			r.Get("/uploader/files/{id}/restore", uploaderConfigHandler.GetRestoreStatus)
+			r.Post("/uploader/files/{id}/restore", uploaderConfigHandler.RestoreFile)
+			r.Get("/uploader/settings/upload-url-expiry", uploaderConfigHandler.GetUploadURLExpiry)
+			r.Get("/uploader/folders", uploaderConfigHandler.ListFolders)
+			r.Get("/uploader/folders/children", uploaderConfigHandler.ListFolderChildren)
+			r.Get("/uploader/folders/trash", uploaderConfigHandler.ListTrashedFolders)
+			r.Get("/uploader/folders/renames/{id}", uploaderConfigHandler.GetFolderRename)
+			r.Get("/uploader/folders/{id}", uploaderConfigHandler.GetFolder)
+			r.Get("/uploader/history/snapshot", historyHandler.Snapshot)
+			r.Get("/uploader/history/diff", historyHandler.Diff)
+			r.Get("/uploader/retention-rules", retentionHandler.ListCompanyRules)
+			r.Get("/uploader/share-links", shareLinkHandler.ListShareLinks)
+			r.Get("/uploader/share-links/{id}/accesses", shareLinkHandler.ListShareLinkAccesses)
+			r.Head("/uploader/tus/{id}", tusHandler.HeadUpload)
+			r.Get("/uploader/api-keys", companyHandler.ListAPIKeys)
+			// Leaked keys can be revoked even while read-only.
+			r.Post("/uploader/api-keys/{keyID}/revoke", companyHandler.RevokeAPIKey)
+
+			r.Group(func(r chi.Router) {
+				r.Use(company.RequireWritable)
+				r.Post("/uploader/files", uploaderConfigHandler.GenerateUploadURL)
+				r.Post("/uploader/files/delete", uploaderConfigHandler.DeleteFile)
+				r.Post("/uploader/folders/delete", uploaderConfigHandler.DeleteFolder)
+				r.Post("/uploader/files/batch", uploaderConfigHandler.GenerateUploadURLs)
+				r.Patch("/uploader/files/{id}", uploaderConfigHandler.UpdateFile)
+				r.Put("/uploader/files/{id}/legal-hold", uploaderConfigHandler.SetLegalHold)
+				r.Delete("/uploader/files/{id}/legal-hold", uploaderConfigHandler.ReleaseLegalHold)
+				r.Put("/uploader/settings/upload-url-expiry", uploaderConfigHandler.SetUploadURLExpiry)
+				r.Post("/uploader/folders", uploaderConfigHandler.CreateFolder)
+				r.Patch("/uploader/folders/{id}", uploaderConfigHandler.UpdateFolder)
+				r.Delete("/uploader/folders/{id}", uploaderConfigHandler.DeleteFolderObject)
+				r.Post("/uploader/folders/{id}/rename", uploaderConfigHandler.RenameFolder)
+				r.Post("/uploader/folders/renames/{id}/resume", uploaderConfigHandler.ResumeFolderRename)
+				r.Post("/uploader/folders/{id}/restore", uploaderConfigHandler.RestoreFolder)
+				r.Put("/uploader/folders/{id}/object-lock", uploaderConfigHandler.SetFolderLockPolicy)
+				r.Delete("/uploader/folders/{id}/object-lock", uploaderConfigHandler.RemoveFolderLockPolicy)
+				r.Post("/uploader/retention-rules", retentionHandler.CreateCompanyRule)
+				r.Delete("/uploader/retention-rules/{id}", retentionHandler.DeleteCompanyRule)
+				r.Post("/uploader/share-links", shareLinkHandler.CreateShareLink)
+				r.Post("/uploader/share-links/{id}/revoke", shareLinkHandler.RevokeShareLink)
+				r.Post("/uploader/tus", tusHandler.CreateUpload)
+				r.Patch("/uploader/tus/{id}", tusHandler.PatchUpload)
+				r.Delete("/uploader/tus/{id}", tusHandler.TerminateUpload)
+				r.Post("/uploader/api-keys", companyHandler.CreateAPIKey)
+				r.Post("/uploader/api-keys/rotate", companyHandler.RotateAPIKey)
+			})
+		})
+
+		// Admin client routes
+		r.Group(func(r chi.Router) {
+			r.Use(config.RequireAdminClient(configRepo))
+			r.Post("/config/adminclient/new", configHandler.CreateAdminClient)
+			r.Post("/storage/events", s3EventHandler.IngestEvents)
+			r.Post("/storage/reconcile", reconcileHandler.Reconcile)
+			r.Get("/storage/retention-rules", retentionHandler.ListAdminRules)
+			r.Post("/storage/retention-rules", retentionHandler.CreateAdminRule)
+			r.Delete("/storage/retention-rules/{id}", retentionHandler.DeleteAdminRule)
+			r.Post("/company/register", uploaderConfigHandler.RegisterCompany)
+			r.Get("/storage/companies", companyHandler.ListCompanies)
+			r.Get("/storage/companies/{id}", companyHandler.GetCompany)
+			r.Patch("/storage/companies/{id}", companyHandler.UpdateCompany)
+			r.Post("/storage/companies/{id}/suspend", companyHandler.SuspendCompany)
+			r.Post("/storage/companies/{id}/reactivate", companyHandler.ReactivateCompany)
+			r.Post("/storage/companies/{id}/renew", companyHandler.RenewSubscription)
+			r.Get("/storage/companies/{id}/api-keys", companyHandler.AdminListAPIKeys)
+			r.Post("/storage/companies/{id}/api-keys", companyHandler.AdminCreateAPIKey)
+			r.Post("/storage/companies/{id}/api-keys/{keyID}/revoke", companyHandler.AdminRevokeAPIKey)
+			r.Get("/storage/pools", uploaderConfigHandler.ListPools)
+			r.Post("/storage/pools", uploaderConfigHandler.CreateUploaderConfig)
+			r.Post("/storage/pools/{id}/activate", uploaderConfigHandler.ActivatePool)
+			r.Post("/storage/pools/{id}/drain", uploaderConfigHandler.DrainPool)
+			r.Post("/storage/pools/{id}/retire", uploaderConfigHandler.RetirePool)
+			r.Post("/storage/pools/{id}/rotate-credentials", uploaderConfigHandler.RotatePoolCredentials)
+			r.Put("/storage/pools/{id}/upload-url-expiry", uploaderConfigHandler.SetPoolUploadURLExpiry)
+			r.Get("/storage/history/snapshot", historyHandler.AdminSnapshot)
+			r.Get("/storage/history/diff", historyHandler.AdminDiff)
+			r.Get("/storage/migrations", migrationHandler.ListMigrations)
+			r.Post("/storage/migrations", migrationHandler.CreateMigration)
+			r.Get("/storage/migrations/{id}", migrationHandler.GetMigration)
+			r.Post("/storage/migrations/{id}/resume", migrationHandler.ResumeMigration)
+		})
 	})
 
 	return r
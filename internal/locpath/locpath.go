@@ -0,0 +1,133 @@
+// Package locpath defines location paths: the loc_tag of files, the path of
+// folders and the prefixes of retention rules and share links. A path is a
+// sequence of segments joined by '/', relative to the company slug.
+//
+// Paths are NFC normalized and case-preserving, but locations are matched
+// without regard to case: the first spelling of a location is kept and later
+// paths differing only in case resolve to it.
+package locpath
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxDepth is the maximum number of segments.
+	MaxDepth = 16
+	// MaxSegmentLen is the maximum length of a segment, in characters.
+	MaxSegmentLen = 100
+	// MaxLen is the maximum length of a path in bytes, bounded by the
+	// loc_tag and path columns.
+	MaxLen = 255
+)
+
+var (
+	ErrEmpty   = errors.New("path is empty")
+	ErrTooLong = fmt.Errorf("path is longer than %d bytes", MaxLen)
+	ErrTooDeep = fmt.Errorf("path has more than %d segments", MaxDepth)
+)
+
+// punctuation lists the characters allowed in segments besides letters,
+// combining marks, digits and spaces.
+const punctuation = "-_.()+,@&'!~="
+
+// Clean returns the canonical form of path: NFC normalized, without
+// surrounding whitespace and slashes. It rejects empty, "." and ".."
+// segments, segments with surrounding spaces or characters outside the
+// allowed set, and paths that are too deep or too long.
+func Clean(path string) (string, error) {
+	path = strings.Trim(strings.TrimSpace(norm.NFC.String(path)), "/")
+	if path == "" {
+		return "", ErrEmpty
+	}
+	if len(path) > MaxLen {
+		return "", ErrTooLong
+	}
+
+	segments := strings.Split(path, "/")
+	if len(segments) > MaxDepth {
+		return "", ErrTooDeep
+	}
+	for _, segment := range segments {
+		if err := checkSegment(segment); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// CleanOptional is Clean for filters, where an empty path means the root.
+func CleanOptional(path string) (string, error) {
+	if strings.Trim(strings.TrimSpace(path), "/") == "" {
+		return "", nil
+	}
+	return Clean(path)
+}
+
+func checkSegment(segment string) error {
+	if segment == "" {
+		return errors.New("path has an empty segment")
+	}
+	if strings.Trim(segment, ".") == "" {
+		return fmt.Errorf("segment %q is not allowed", segment)
+	}
+	if strings.TrimSpace(segment) != segment {
+		return fmt.Errorf("segment %q starts or ends with a space", segment)
+	}
+	if utf8.RuneCountInString(segment) > MaxSegmentLen {
+		return fmt.Errorf("segment %q is longer than %d characters", segment, MaxSegmentLen)
+	}
+	for _, r := range segment {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && r != ' ' && !strings.ContainsRune(punctuation, r) {
+			return fmt.Errorf("segment %q contains %q", segment, r)
+		}
+	}
+	return nil
+}
+
+// Fold returns the form paths are compared in.
+func Fold(path string) string {
+	return strings.ToLower(path)
+}
+
+// Ancestors returns path and the paths of its parents, innermost first.
+func Ancestors(path string) []string {
+	ancestors := []string{path}
+	for i := strings.LastIndex(path, "/"); i > 0; i = strings.LastIndex(path, "/") {
+		path = path[:i]
+		ancestors = append(ancestors, path)
+	}
+	return ancestors
+}
+
+// Top returns the first segment of path.
+func Top(path string) string {
+	top, _, _ := strings.Cut(path, "/")
+	return top
+}
+
+// Canonical respells path with the spelling of its innermost location among
+// the known paths and their parents, compared by Fold. Earlier known paths
+// take precedence.
+func Canonical(path string, known []string) string {
+	spellings := make(map[string]string)
+	for _, p := range known {
+		for _, ancestor := range Ancestors(p) {
+			if _, ok := spellings[Fold(ancestor)]; !ok {
+				spellings[Fold(ancestor)] = ancestor
+			}
+		}
+	}
+	for _, ancestor := range Ancestors(path) {
+		if spelling, ok := spellings[Fold(ancestor)]; ok {
+			return spelling + path[len(ancestor):]
+		}
+	}
+	return path
+}
@@ -0,0 +1,76 @@
+package locpath
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	deep := strings.Repeat("a/", MaxDepth) + "a"
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", path: "Invoices/2024", want: "Invoices/2024"},
+		{name: "surrounding slashes and spaces", path: "  /a/b/ ", want: "a/b"},
+		{name: "case is kept", path: "Reports/Q1", want: "Reports/Q1"},
+		{name: "decomposed accents are composed", path: "cafe\u0301", want: "caf\u00e9"},
+		{name: "punctuation", path: "a-b_c.d (1)+e,f@g&h'i!j~k=l", want: "a-b_c.d (1)+e,f@g&h'i!j~k=l"},
+		{name: "unicode letters", path: "日本/Ünïcödé", want: "日本/Ünïcödé"},
+		{name: "empty", path: " / ", wantErr: true},
+		{name: "parent segment", path: "a/../b", wantErr: true},
+		{name: "current segment", path: "a/./b", wantErr: true},
+		{name: "dots only", path: "a/...", wantErr: true},
+		{name: "leading parent segment", path: "../a", wantErr: true},
+		{name: "empty segment", path: "a//b", wantErr: true},
+		{name: "backslash", path: `a\..\b`, wantErr: true},
+		{name: "control character", path: "a\x00b", wantErr: true},
+		{name: "segment with surrounding space", path: "a/ b", wantErr: true},
+		{name: "max depth", path: strings.Repeat("a/", MaxDepth-1) + "a", want: strings.Repeat("a/", MaxDepth-1) + "a"},
+		{name: "too deep", path: deep, wantErr: true},
+		{name: "longest segment", path: strings.Repeat("é", MaxSegmentLen), want: strings.Repeat("é", MaxSegmentLen)},
+		{name: "segment too long", path: strings.Repeat("a", MaxSegmentLen+1), wantErr: true},
+		{name: "too long", path: strings.Repeat(strings.Repeat("a", 99)+"/", 3), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Clean(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Clean(%q) error = %v, want error %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Clean(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		known []string
+		want  string
+	}{
+		{name: "unknown location", path: "a/b", known: []string{"c"}, want: "a/b"},
+		{name: "known location", path: "invoices", known: []string{"Invoices"}, want: "Invoices"},
+		{name: "known parent", path: "invoices/2024/q1", known: []string{"Invoices/2024"}, want: "Invoices/2024/q1"},
+		{name: "parent of a known location", path: "INVOICES/new", known: []string{"Invoices/2024"}, want: "Invoices/new"},
+		{name: "innermost location wins", path: "invoices/2024/x", known: []string{"Invoices", "INVOICES/Y2024"}, want: "Invoices/2024/x"},
+		{name: "earlier spelling wins", path: "invoices", known: []string{"Invoices", "INVOICES"}, want: "Invoices"},
+		{name: "non-ASCII case", path: "ÉTÉ/a", known: []string{"été"}, want: "été/a"},
+		{name: "sibling prefix is not a parent", path: "invoices-old", known: []string{"Invoices"}, want: "invoices-old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Canonical(tt.path, tt.known); got != tt.want {
+				t.Errorf("Canonical(%q, %q) = %q, want %q", tt.path, tt.known, got, tt.want)
+			}
+		})
+	}
+}
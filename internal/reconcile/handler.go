@@ -0,0 +1,79 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"shreshtasmg.in/jupyter/internal/company"
+)
+
+type ReconcileRequest struct {
+	CompanySlug string `json:"company_slug,omitempty"` // empty reconciles every company
+	Apply       bool   `json:"apply"`                  // false = dry-run
+}
+
+type ReconcileResponse struct {
+	Reports []Report `json:"reports"`
+}
+
+type Handler struct {
+	reconciler  *Reconciler
+	companyRepo company.Repository
+}
+
+func NewHandler(reconciler *Reconciler, companyRepo company.Repository) *Handler {
+	return &Handler{reconciler: reconciler, companyRepo: companyRepo}
+}
+
+// Reconcile godoc
+// @Summary      Reconcile bucket contents with files_meta and used_quota
+// @Description  Lists every object under the company slug and reports orphans, missing objects and size mismatches. With apply=true the log is corrected and used_quota is recomputed from actual bytes.
+// @Tags         storage
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string            true  "Admin client id"
+// @Param        client_secret  header    string            true  "Admin client secret"
+// @Param        body           body      ReconcileRequest  true  "Reconcile request"
+// @Success      200            {object}  ReconcileResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/reconcile [post]
+func (h *Handler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req ReconcileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	var reports []Report
+	if req.CompanySlug != "" {
+		companyRec, err := h.companyRepo.GetBySlug(req.CompanySlug)
+		if err != nil {
+			http.Error(w, "failed to look up company", http.StatusInternalServerError)
+			return
+		}
+		if companyRec == nil {
+			http.Error(w, "company not found", http.StatusNotFound)
+			return
+		}
+		report, err := h.reconciler.Run(ctx, companyRec, req.Apply)
+		if err != nil {
+			http.Error(w, "failed to reconcile storage", http.StatusInternalServerError)
+			return
+		}
+		reports = []Report{*report}
+	} else {
+		var err error
+		reports, err = h.reconciler.RunAll(ctx, req.Apply)
+		if err != nil {
+			http.Error(w, "failed to reconcile storage", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ReconcileResponse{Reports: reports})
+}
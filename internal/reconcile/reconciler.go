@@ -0,0 +1,182 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/uploader"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const reconcileTxnMeta = "reconcile"
+
+type ObjectDiff struct {
+	FileKey  string `json:"file_key"`
+	FileSize int64  `json:"file_size"`
+}
+
+type SizeMismatch struct {
+	FileKey      string `json:"file_key"`
+	RecordedSize int64  `json:"recorded_size"`
+	ActualSize   int64  `json:"actual_size"`
+}
+
+// Report is the difference between a company's bucket prefix and files_meta.
+type Report struct {
+	CompanyID   string `json:"company_id"`
+	CompanySlug string `json:"company_slug"`
+	// Orphans exist in the bucket but not in files_meta.
+	Orphans []ObjectDiff `json:"orphans"`
+	// Missing exist in files_meta but not in the bucket.
+	Missing        []ObjectDiff   `json:"missing"`
+	SizeMismatches []SizeMismatch `json:"size_mismatches"`
+	UsedQuota      int64          `json:"used_quota"`
+	ActualBytes    int64          `json:"actual_bytes"`
+	Applied        bool           `json:"applied"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// Reconciler compares stored objects with the files_meta log and, in apply
+// mode, corrects the log and used_quota to match the bucket.
+type Reconciler struct {
+	companyRepo  company.Repository
+	fileMetaRepo filemeta.Repository
+	s3Service    uploader.S3Service
+}
+
+func NewReconciler(companyRepo company.Repository, fileMetaRepo filemeta.Repository, s3Service uploader.S3Service) *Reconciler {
+	return &Reconciler{companyRepo: companyRepo, fileMetaRepo: fileMetaRepo, s3Service: s3Service}
+}
+
+// RunAll reconciles every company. Failures are reported per company.
+func (rc *Reconciler) RunAll(ctx context.Context, apply bool) ([]Report, error) {
+	companies, err := rc.companyRepo.ListAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list companies: %w", err)
+	}
+
+	reports := make([]Report, 0, len(companies))
+	for i := range companies {
+		report, err := rc.Run(ctx, &companies[i], apply)
+		if err != nil {
+			report = &Report{
+				CompanyID:   companies[i].ID,
+				CompanySlug: companies[i].CompanySlug,
+				Error:       err.Error(),
+			}
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+func (rc *Reconciler) Run(ctx context.Context, companyRec *company.Company, apply bool) (*Report, error) {
+	objects, err := rc.s3Service.ListObjects(ctx, companyRec, companyRec.CompanySlug+"/")
+	if err != nil {
+		return nil, err
+	}
+	log, err := rc.fileMetaRepo.ListLogByCompanyID(companyRec.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file meta: %w", err)
+	}
+	recorded := filemeta.Replay(log, companyRec.CompanySlug)
+
+	report := &Report{
+		CompanyID:      companyRec.ID,
+		CompanySlug:    companyRec.CompanySlug,
+		Orphans:        []ObjectDiff{},
+		Missing:        []ObjectDiff{},
+		SizeMismatches: []SizeMismatch{},
+		UsedQuota:      companyRec.UsedQuota,
+	}
+
+	actual := make(map[string]uploader.ObjectInfo, len(objects))
+	for _, obj := range objects {
+		// Folder placeholders are not files.
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		actual[obj.Key] = obj
+		report.ActualBytes += obj.Size
+
+		meta, ok := recorded[obj.Key]
+		if !ok {
+			report.Orphans = append(report.Orphans, ObjectDiff{FileKey: obj.Key, FileSize: obj.Size})
+			continue
+		}
+		if meta.FileSize != obj.Size {
+			report.SizeMismatches = append(report.SizeMismatches, SizeMismatch{
+				FileKey:      obj.Key,
+				RecordedSize: meta.FileSize,
+				ActualSize:   obj.Size,
+			})
+		}
+	}
+	for key, meta := range recorded {
+		if _, ok := actual[key]; !ok {
+			report.Missing = append(report.Missing, ObjectDiff{FileKey: key, FileSize: meta.FileSize})
+		}
+	}
+	sort.Slice(report.Missing, func(i, j int) bool { return report.Missing[i].FileKey < report.Missing[j].FileKey })
+
+	if !apply {
+		return report, nil
+	}
+
+	if err := rc.apply(companyRec, report, recorded, actual); err != nil {
+		return nil, err
+	}
+	report.Applied = true
+	return report, nil
+}
+
+func (rc *Reconciler) apply(companyRec *company.Company, report *Report, recorded map[string]filemeta.FileMeta, actual map[string]uploader.ObjectInfo) error {
+	txnMeta := reconcileTxnMeta
+
+	for _, o := range report.Orphans {
+		fileName := path.Base(o.FileKey)
+		etag := actual[o.FileKey].ETag
+		if err := rc.fileMetaRepo.Create(&filemeta.FileMeta{
+			ID:          utils.GenerateID(),
+			FileName:    &fileName,
+			FileSize:    o.FileSize,
+			FileKey:     o.FileKey,
+			FileTxnType: filemeta.TxnUpload,
+			FileTxnMeta: &txnMeta,
+			CompanyID:   &companyRec.ID,
+			ETag:        &etag,
+		}); err != nil {
+			return fmt.Errorf("failed to record orphan %s: %w", o.FileKey, err)
+		}
+	}
+
+	for _, m := range report.Missing {
+		if err := rc.fileMetaRepo.Create(&filemeta.FileMeta{
+			ID:          utils.GenerateID(),
+			FileSize:    m.FileSize,
+			FileKey:     m.FileKey,
+			FileTxnType: filemeta.TxnDelete,
+			FileTxnMeta: &txnMeta,
+			CompanyID:   &companyRec.ID,
+		}); err != nil {
+			return fmt.Errorf("failed to record missing %s: %w", m.FileKey, err)
+		}
+	}
+
+	for _, sm := range report.SizeMismatches {
+		obj := actual[sm.FileKey]
+		if err := rc.fileMetaRepo.UpdateObjectStats(recorded[sm.FileKey].ID, obj.Size, obj.ETag); err != nil {
+			return fmt.Errorf("failed to update size of %s: %w", sm.FileKey, err)
+		}
+	}
+
+	if err := rc.companyRepo.SetUsedQuota(companyRec.ID, report.ActualBytes); err != nil {
+		return fmt.Errorf("failed to update quota: %w", err)
+	}
+	return nil
+}
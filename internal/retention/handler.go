@@ -0,0 +1,355 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/locpath"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+// minStandardIADays is the minimum age S3 accepts for transitions to
+// STANDARD_IA.
+const minStandardIADays = 30
+
+// LifecycleConfigurer applies transition rules to a company's bucket.
+// uploader.S3Service satisfies it.
+type LifecycleConfigurer interface {
+	PutTransitionRules(ctx context.Context, companyRec *company.Company, rules []Rule) error
+}
+
+type CreateRuleRequest struct {
+	CompanySlug string `json:"company_slug,omitempty"` // admin only
+	Prefix      string `json:"prefix"`                 // loc_tag or folder prefix
+	RuleType    string `json:"rule_type"`              // expire | min_retention | transition
+	Days        int    `json:"days"`
+	// StorageClass is required for transition rules: STANDARD_IA,
+	// GLACIER_IR or DEEP_ARCHIVE.
+	StorageClass string `json:"storage_class,omitempty"`
+}
+
+type RuleResponse struct {
+	ID           string  `json:"id"`
+	CompanyID    string  `json:"company_id"`
+	Prefix       string  `json:"prefix"`
+	RuleType     string  `json:"rule_type"`
+	Days         int     `json:"days"`
+	StorageClass *string `json:"storage_class,omitempty"`
+	CreatedBy    string  `json:"created_by"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+type ListRulesResponse struct {
+	Items []RuleResponse `json:"items"`
+}
+
+type Handler struct {
+	repo        Repository
+	companyRepo company.Repository
+	lifecycle   LifecycleConfigurer
+}
+
+func NewHandler(repo Repository, companyRepo company.Repository, lifecycle LifecycleConfigurer) *Handler {
+	return &Handler{repo: repo, companyRepo: companyRepo, lifecycle: lifecycle}
+}
+
+func toRuleResponse(rule Rule) RuleResponse {
+	return RuleResponse{
+		ID:           rule.ID,
+		CompanyID:    rule.CompanyID,
+		Prefix:       rule.Prefix,
+		RuleType:     rule.RuleType,
+		Days:         rule.Days,
+		StorageClass: rule.StorageClass,
+		CreatedBy:    rule.CreatedBy,
+		CreatedAt:    rule.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func validateRule(req *CreateRuleRequest) string {
+	prefix, err := locpath.Clean(req.Prefix)
+	if errors.Is(err, locpath.ErrEmpty) {
+		return "prefix is required"
+	}
+	if err != nil {
+		return "prefix: " + err.Error()
+	}
+	req.Prefix = prefix
+	if req.RuleType != RuleExpire && req.RuleType != RuleMinRetention && req.RuleType != RuleTransition {
+		return "rule_type must be expire, min_retention or transition"
+	}
+	if req.Days <= 0 {
+		return "days must be > 0"
+	}
+	if req.RuleType != RuleTransition {
+		if req.StorageClass != "" {
+			return "storage_class is only valid for transition rules"
+		}
+		return ""
+	}
+	class, ok := filemeta.ParseStorageClass(req.StorageClass)
+	if !ok || class == filemeta.StorageClassStandard {
+		return "storage_class must be STANDARD_IA, GLACIER_IR or DEEP_ARCHIVE"
+	}
+	if class == filemeta.StorageClassStandardIA && req.Days < minStandardIADays {
+		return "transitions to STANDARD_IA need days >= 30"
+	}
+	req.StorageClass = class
+	return ""
+}
+
+func (h *Handler) createRule(w http.ResponseWriter, r *http.Request, companyRec *company.Company, createdBy string, req CreateRuleRequest) {
+	rule := &Rule{
+		ID:        utils.GenerateID(),
+		CompanyID: companyRec.ID,
+		Prefix:    req.Prefix,
+		RuleType:  req.RuleType,
+		Days:      req.Days,
+		CreatedBy: createdBy,
+	}
+	if req.StorageClass != "" {
+		rule.StorageClass = &req.StorageClass
+	}
+	if rule.RuleType == RuleTransition {
+		// The bucket is configured first so a failure leaves no rule behind
+		// that S3 does not apply.
+		rules, err := h.repo.ListByCompanyID(companyRec.ID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if err := h.lifecycle.PutTransitionRules(r.Context(), companyRec, append(rules, *rule)); err != nil {
+			http.Error(w, "failed to update bucket lifecycle", http.StatusBadGateway)
+			return
+		}
+	}
+	if err := h.repo.Create(rule); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(toRuleResponse(*rule))
+}
+
+// deleteRule removes rule of companyRec, first from the bucket lifecycle
+// for transition rules.
+func (h *Handler) deleteRule(w http.ResponseWriter, r *http.Request, companyRec *company.Company, rule *Rule) {
+	if rule.RuleType == RuleTransition {
+		rules, err := h.repo.ListByCompanyID(companyRec.ID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		remaining := make([]Rule, 0, len(rules))
+		for _, other := range rules {
+			if other.ID != rule.ID {
+				remaining = append(remaining, other)
+			}
+		}
+		if err := h.lifecycle.PutTransitionRules(r.Context(), companyRec, remaining); err != nil {
+			http.Error(w, "failed to update bucket lifecycle", http.StatusBadGateway)
+			return
+		}
+	}
+
+	if err := h.repo.Delete(rule.ID); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listRules(w http.ResponseWriter, companyID string) {
+	rules, err := h.repo.ListByCompanyID(companyID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]RuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		items = append(items, toRuleResponse(rule))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListRulesResponse{Items: items})
+}
+
+// CreateCompanyRule godoc
+// @Summary      Attach a retention rule to a folder
+// @Description  expire deletes files under prefix after N days, min_retention blocks deletes for N days,
+// @Description  transition moves files under prefix to storage_class after N days through the bucket lifecycle
+// @Tags         retention
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string             true  "Company API key"
+// @Param        body       body      CreateRuleRequest  true  "Retention rule"
+// @Success      201        {object}  RuleResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Failure      502        {string}  string "failed to update bucket lifecycle"
+// @Router       /uploader/retention-rules [post]
+func (h *Handler) CreateCompanyRule(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+
+	var req CreateRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateRule(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	h.createRule(w, r, companyRec, CreatedByCompany, req)
+}
+
+// ListCompanyRules godoc
+// @Summary      List retention rules of the calling company
+// @Tags         retention
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Success      200        {object}  ListRulesResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/retention-rules [get]
+func (h *Handler) ListCompanyRules(w http.ResponseWriter, r *http.Request) {
+	h.listRules(w, company.FromContext(r.Context()).ID)
+}
+
+// DeleteCompanyRule godoc
+// @Summary      Remove a retention rule
+// @Description  Companies cannot remove rules created by an admin
+// @Tags         retention
+// @Param        X-API-Key  header  string  true  "Company API key"
+// @Param        id         path    string  true  "Rule ID"
+// @Success      204
+// @Failure      403  {string}  string "rule is managed by an admin"
+// @Failure      404  {string}  string "rule not found"
+// @Router       /uploader/retention-rules/{id} [delete]
+func (h *Handler) DeleteCompanyRule(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+
+	rule, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if rule == nil || rule.CompanyID != companyRec.ID {
+		http.Error(w, "rule not found", http.StatusNotFound)
+		return
+	}
+	if rule.CreatedBy == CreatedByAdmin {
+		http.Error(w, "rule is managed by an admin", http.StatusForbidden)
+		return
+	}
+
+	h.deleteRule(w, r, companyRec, rule)
+}
+
+// CreateAdminRule godoc
+// @Summary      Attach a retention rule to a company folder (admin)
+// @Tags         retention
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string             true  "Admin client id"
+// @Param        client_secret  header    string             true  "Admin client secret"
+// @Param        body           body      CreateRuleRequest  true  "Retention rule with company_slug"
+// @Success      201            {object}  RuleResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Router       /storage/retention-rules [post]
+func (h *Handler) CreateAdminRule(w http.ResponseWriter, r *http.Request) {
+	var req CreateRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.CompanySlug == "" {
+		http.Error(w, "company_slug is required", http.StatusBadRequest)
+		return
+	}
+	if msg := validateRule(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	companyRec, err := h.companyRepo.GetBySlug(req.CompanySlug)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if companyRec == nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+
+	h.createRule(w, r, companyRec, CreatedByAdmin, req)
+}
+
+// ListAdminRules godoc
+// @Summary      List retention rules of a company (admin)
+// @Tags         retention
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        company_slug   query     string  true  "Company slug"
+// @Success      200            {object}  ListRulesResponse
+// @Failure      404            {string}  string "company not found"
+// @Router       /storage/retention-rules [get]
+func (h *Handler) ListAdminRules(w http.ResponseWriter, r *http.Request) {
+	companyRec, err := h.companyRepo.GetBySlug(r.URL.Query().Get("company_slug"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if companyRec == nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+	h.listRules(w, companyRec.ID)
+}
+
+// DeleteAdminRule godoc
+// @Summary      Remove any retention rule (admin)
+// @Tags         retention
+// @Param        client_id      header  string  true  "Admin client id"
+// @Param        client_secret  header  string  true  "Admin client secret"
+// @Param        id             path    string  true  "Rule ID"
+// @Success      204
+// @Failure      404  {string}  string "rule not found"
+// @Router       /storage/retention-rules/{id} [delete]
+func (h *Handler) DeleteAdminRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if rule == nil {
+		http.Error(w, "rule not found", http.StatusNotFound)
+		return
+	}
+	companyRec, err := h.companyRepo.GetByID(rule.CompanyID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if companyRec == nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+
+	h.deleteRule(w, r, companyRec, rule)
+}
@@ -0,0 +1,38 @@
+package retention
+
+import "time"
+
+// Rule types.
+const (
+	// RuleExpire deletes files under the prefix once they are older than Days.
+	RuleExpire = "expire"
+	// RuleMinRetention blocks deletes of files younger than Days.
+	RuleMinRetention = "min_retention"
+	// RuleTransition moves files under the prefix to StorageClass once they
+	// are older than Days. S3 applies it through the bucket lifecycle.
+	RuleTransition = "transition"
+)
+
+// Rule creators.
+const (
+	CreatedByAdmin   = "admin"
+	CreatedByCompany = "company"
+)
+
+// Rule attaches a retention policy to a folder (loc_tag prefix) of a company.
+type Rule struct {
+	ID        string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	CompanyID string    `gorm:"type:varchar(40);not null;index;column:company_id"`
+	Prefix    string    `gorm:"type:varchar(255);not null;column:prefix"`
+	RuleType  string    `gorm:"type:varchar(20);not null;index;column:rule_type"`
+	Days      int       `gorm:"not null;column:days"`
+	CreatedBy string    `gorm:"type:varchar(20);not null;column:created_by"`
+	// StorageClass is the target of transition rules.
+	StorageClass *string   `gorm:"type:varchar(20);column:storage_class"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (Rule) TableName() string {
+	return "retention_rules"
+}
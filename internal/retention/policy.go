@@ -0,0 +1,39 @@
+package retention
+
+import (
+	"strings"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/locpath"
+)
+
+// Matches reports whether the rule covers relKey, a file key relative to the
+// company slug (i.e. "<loc_tag>/<file_name>"). Locations are compared
+// without regard to case.
+func (rule Rule) Matches(relKey string) bool {
+	relKey, prefix := locpath.Fold(relKey), locpath.Fold(rule.Prefix)
+	return relKey == prefix || strings.HasPrefix(relKey, prefix+"/")
+}
+
+// Until returns the instant the rule stops applying to a file created at t.
+func (rule Rule) Until(createdAt time.Time) time.Time {
+	return createdAt.AddDate(0, 0, rule.Days)
+}
+
+// BlockingRule returns the min-retention rule that forbids deleting a file
+// created at createdAt, or nil if the delete is allowed.
+func BlockingRule(rules []Rule, relKey string, createdAt, now time.Time) *Rule {
+	var blocking *Rule
+	for i := range rules {
+		rule := rules[i]
+		if rule.RuleType != RuleMinRetention || !rule.Matches(relKey) {
+			continue
+		}
+		if now.Before(rule.Until(createdAt)) {
+			if blocking == nil || rule.Until(createdAt).After(blocking.Until(createdAt)) {
+				blocking = &rule
+			}
+		}
+	}
+	return blocking
+}
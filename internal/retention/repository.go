@@ -0,0 +1,58 @@
+package retention
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(rule *Rule) error
+	GetByID(id string) (*Rule, error)
+	ListByCompanyID(companyID string) ([]Rule, error)
+	ListByType(ruleType string) ([]Rule, error)
+	Delete(id string) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(rule *Rule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *repository) GetByID(id string) (*Rule, error) {
+	var rule Rule
+	if err := r.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *repository) ListByCompanyID(companyID string) ([]Rule, error) {
+	var rules []Rule
+	if err := r.db.Where("company_id = ?", companyID).Order("prefix").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *repository) ListByType(ruleType string) ([]Rule, error) {
+	var rules []Rule
+	if err := r.db.Where("rule_type = ?", ruleType).Order("company_id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *repository) Delete(id string) error {
+	return r.db.Where("id = ?", id).Delete(&Rule{}).Error
+}
@@ -0,0 +1,138 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+// ObjectDeleter removes an object from a company's storage.
+// uploader.S3Service satisfies it.
+type ObjectDeleter interface {
+	DeleteObject(ctx context.Context, companyRec *company.Company, objectKey string) error
+}
+
+type SweepResult struct {
+	DeletedCount int
+	DeletedBytes int64
+	Failed       int
+}
+
+// Sweeper deletes files whose expire rule has elapsed, records the delete in
+// files_meta and refunds the company's quota.
+type Sweeper struct {
+	repo         Repository
+	companyRepo  company.Repository
+	fileMetaRepo filemeta.Repository
+	storage      ObjectDeleter
+}
+
+func NewSweeper(repo Repository, companyRepo company.Repository, fileMetaRepo filemeta.Repository, storage ObjectDeleter) *Sweeper {
+	return &Sweeper{repo: repo, companyRepo: companyRepo, fileMetaRepo: fileMetaRepo, storage: storage}
+}
+
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) (SweepResult, error) {
+	var result SweepResult
+
+	expireRules, err := s.repo.ListByType(RuleExpire)
+	if err != nil {
+		return result, fmt.Errorf("failed to list expire rules: %w", err)
+	}
+
+	companyIDs := make(map[string]struct{})
+	for _, rule := range expireRules {
+		companyIDs[rule.CompanyID] = struct{}{}
+	}
+	ids := make([]string, 0, len(companyIDs))
+	for id := range companyIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, companyID := range ids {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if err := s.sweepCompany(ctx, companyID, now, &result); err != nil {
+			log.Printf("retention sweep of company %s failed: %v", companyID, err)
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+func (s *Sweeper) sweepCompany(ctx context.Context, companyID string, now time.Time, result *SweepResult) error {
+	companyRec, err := s.companyRepo.GetByID(companyID)
+	if err != nil {
+		return err
+	}
+	if companyRec == nil {
+		return nil
+	}
+
+	rules, err := s.repo.ListByCompanyID(companyID)
+	if err != nil {
+		return err
+	}
+	slugPrefix := companyRec.CompanySlug + "/"
+	files, err := s.fileMetaRepo.ListFiles(companyID, slugPrefix)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		key := file.FileKey
+		relKey := strings.TrimPrefix(key, slugPrefix)
+		rule := expiredBy(rules, relKey, file.CreatedAt, now)
+		if rule == nil || BlockingRule(rules, relKey, file.CreatedAt, now) != nil {
+			continue
+		}
+		if file.Locked(now) {
+			// Object Lock outranks expire rules; try again on a later sweep.
+			continue
+		}
+
+		if err := s.storage.DeleteObject(ctx, companyRec, key); err != nil {
+			log.Printf("retention sweep failed to delete %s: %v", key, err)
+			result.Failed++
+			continue
+		}
+
+		txnMeta := "retention rule " + rule.ID
+		if err := s.fileMetaRepo.Create(&filemeta.FileMeta{
+			ID:          utils.GenerateID(),
+			FileSize:    file.FileSize,
+			FileKey:     key,
+			FileTxnType: filemeta.TxnDelete,
+			FileTxnMeta: &txnMeta,
+			CompanyID:   &companyRec.ID,
+		}); err != nil {
+			return fmt.Errorf("failed to record delete of %s: %w", key, err)
+		}
+		if err := s.companyRepo.DecrementUsedQuota(companyRec.ID, file.FileSize); err != nil {
+			return fmt.Errorf("failed to refund quota: %w", err)
+		}
+
+		result.DeletedCount++
+		result.DeletedBytes += file.FileSize
+	}
+	return nil
+}
+
+// expiredBy returns the expire rule under which the file is due, if any.
+func expiredBy(rules []Rule, relKey string, createdAt, now time.Time) *Rule {
+	for i := range rules {
+		rule := rules[i]
+		if rule.RuleType == RuleExpire && rule.Matches(relKey) && !now.Before(rule.Until(createdAt)) {
+			return &rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package s3event
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type IngestEventsResponse struct {
+	Received int            `json:"received"`
+	Applied  int            `json:"applied"`
+	Failed   int            `json:"failed"`
+	Results  []RecordResult `json:"results"`
+}
+
+type Handler struct {
+	processor *Processor
+}
+
+func NewHandler(processor *Processor) *Handler {
+	return &Handler{processor: processor}
+}
+
+// IngestEvents godoc
+// @Summary      Ingest S3 bucket event notifications
+// @Description  Applies ObjectCreated/ObjectRemoved notifications (raw S3 or SNS wrapped) to files_meta and quotas. Redelivered events are ignored by sequencer.
+// @Tags         storage
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string        true  "Admin client id"
+// @Param        client_secret  header    string        true  "Admin client secret"
+// @Param        body           body      Notification  true  "S3 event notification"
+// @Success      200            {object}  IngestEventsResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "unknown client"
+// @Router       /storage/events [post]
+func (h *Handler) IngestEvents(w http.ResponseWriter, r *http.Request) {
+	var n Notification
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	records, err := Unwrap(n)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := h.processor.Process(records)
+	resp := Summarize(results)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Summarize counts applied and failed records.
+func Summarize(results []RecordResult) IngestEventsResponse {
+	resp := IngestEventsResponse{Received: len(results), Results: results}
+	for _, res := range results {
+		switch res.Action {
+		case ActionCreated, ActionUpdated, ActionDeleted:
+			resp.Applied++
+		case ActionFailed:
+			resp.Failed++
+		}
+	}
+	return resp
+}
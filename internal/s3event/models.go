@@ -0,0 +1,71 @@
+package s3event
+
+import (
+	"strings"
+	"time"
+)
+
+// Notification is the JSON body S3 sends for bucket event notifications.
+// When delivered through SNS the S3 payload is wrapped as a string in Message.
+type Notification struct {
+	Records []Record `json:"Records"`
+	Message string   `json:"Message,omitempty"`
+}
+
+type Record struct {
+	EventName string    `json:"eventName"`
+	EventTime time.Time `json:"eventTime"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      int64  `json:"size"`
+			ETag      string `json:"eTag"`
+			Sequencer string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// ProcessedEvent remembers which (bucket, key, sequencer) triples have been
+// applied so redelivered notifications are ignored.
+type ProcessedEvent struct {
+	ID         string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime"`
+	BucketName string    `gorm:"type:varchar(64);not null;column:bucket_name;uniqueIndex:idx_s3_events_object_seq"`
+	ObjectKey  string    `gorm:"type:varchar(255);not null;column:object_key;uniqueIndex:idx_s3_events_object_seq"`
+	Sequencer  string    `gorm:"type:varchar(64);not null;column:sequencer;uniqueIndex:idx_s3_events_object_seq"`
+	EventName  string    `gorm:"type:varchar(64);not null;column:event_name"`
+	CompanyID  *string   `gorm:"type:varchar(40);column:company_id"`
+}
+
+func (ProcessedEvent) TableName() string {
+	return "s3_events"
+}
+
+// ObjectSequencer holds the sequencer of the latest event applied to an
+// object, so events delivered out of order do not undo newer ones.
+type ObjectSequencer struct {
+	BucketName string    `gorm:"type:varchar(64);primaryKey;column:bucket_name"`
+	ObjectKey  string    `gorm:"type:varchar(255);primaryKey;column:object_key"`
+	Sequencer  string    `gorm:"type:varchar(64);not null;column:sequencer"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (ObjectSequencer) TableName() string {
+	return "s3_object_sequencers"
+}
+
+// CompareSequencers orders the sequencers of two events on one object key.
+// They are hex values of varying length, compared after left-padding the
+// shorter one with zeros.
+func CompareSequencers(a, b string) int {
+	a, b = strings.ToUpper(a), strings.ToUpper(b)
+	if n := len(b) - len(a); n > 0 {
+		a = strings.Repeat("0", n) + a
+	} else if n < 0 {
+		b = strings.Repeat("0", -n) + b
+	}
+	return strings.Compare(a, b)
+}
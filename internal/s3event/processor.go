@@ -0,0 +1,233 @@
+package s3event
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/uploader"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const eventTxnMeta = "s3 event"
+
+// Result actions reported per record.
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionDeleted   = "deleted"
+	ActionDuplicate = "duplicate"
+	// ActionStale marks events older than one already applied to the object.
+	ActionStale   = "stale"
+	ActionSkipped = "skipped"
+	ActionFailed  = "failed"
+)
+
+type RecordResult struct {
+	EventName string `json:"event_name"`
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	Sequencer string `json:"sequencer"`
+	Action    string `json:"action"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Processor applies S3 event notifications to files_meta and company quotas.
+type Processor struct {
+	repo        Repository
+	companyRepo company.Repository
+}
+
+func NewProcessor(repo Repository, companyRepo company.Repository) *Processor {
+	return &Processor{repo: repo, companyRepo: companyRepo}
+}
+
+// Unwrap returns the S3 records of n, decoding an SNS envelope if needed.
+func Unwrap(n Notification) ([]Record, error) {
+	if len(n.Records) == 0 && n.Message != "" {
+		var inner Notification
+		if err := json.Unmarshal([]byte(n.Message), &inner); err != nil {
+			return nil, fmt.Errorf("invalid SNS message: %w", err)
+		}
+		return inner.Records, nil
+	}
+	return n.Records, nil
+}
+
+func (p *Processor) Process(records []Record) []RecordResult {
+	results := make([]RecordResult, 0, len(records))
+	for _, rec := range records {
+		results = append(results, p.processRecord(rec))
+	}
+	return results
+}
+
+func (p *Processor) processRecord(rec Record) RecordResult {
+	res := RecordResult{
+		EventName: rec.EventName,
+		Bucket:    rec.S3.Bucket.Name,
+		Sequencer: rec.S3.Object.Sequencer,
+	}
+
+	// Object keys arrive URL-encoded, with '+' for spaces.
+	key, err := url.QueryUnescape(rec.S3.Object.Key)
+	if err != nil {
+		res.Key = rec.S3.Object.Key
+		res.Action = ActionFailed
+		res.Reason = "invalid object key encoding"
+		return res
+	}
+	res.Key = key
+
+	if strings.HasSuffix(key, "/") {
+		res.Action = ActionSkipped
+		res.Reason = "folder placeholder"
+		return res
+	}
+
+	created := strings.HasPrefix(rec.EventName, "ObjectCreated:")
+	removed := strings.HasPrefix(rec.EventName, "ObjectRemoved:")
+	if !created && !removed {
+		res.Action = ActionSkipped
+		res.Reason = "unsupported event type"
+		return res
+	}
+	if res.Sequencer == "" {
+		res.Action = ActionFailed
+		res.Reason = "missing sequencer"
+		return res
+	}
+
+	slug, _, found := strings.Cut(key, "/")
+	if !found || slug == "" {
+		res.Action = ActionSkipped
+		res.Reason = "key has no company prefix"
+		return res
+	}
+	companyRec, err := p.companyRepo.GetBySlug(slug)
+	if err != nil {
+		res.Action = ActionFailed
+		res.Reason = "failed to look up company"
+		return res
+	}
+	if companyRec == nil {
+		res.Action = ActionSkipped
+		res.Reason = "no company for key prefix"
+		return res
+	}
+	if companyRec.AwsBucketName == nil || *companyRec.AwsBucketName != res.Bucket {
+		res.Action = ActionSkipped
+		res.Reason = "bucket does not belong to company"
+		return res
+	}
+
+	event := &ProcessedEvent{
+		ID:         utils.GenerateID(),
+		BucketName: res.Bucket,
+		ObjectKey:  key,
+		Sequencer:  res.Sequencer,
+		EventName:  rec.EventName,
+		CompanyID:  &companyRec.ID,
+	}
+	err = p.repo.Transaction(func(tx Stores) error {
+		claimed, err := tx.Events.Claim(event)
+		if err != nil {
+			return fmt.Errorf("failed to record event")
+		}
+		if !claimed {
+			res.Action = ActionDuplicate
+			return nil
+		}
+		newer, err := tx.Events.Advance(res.Bucket, key, res.Sequencer)
+		if err != nil {
+			return fmt.Errorf("failed to compare sequencer")
+		}
+		if !newer {
+			res.Action = ActionStale
+			res.Reason = "a newer event was already applied"
+			return nil
+		}
+
+		if created {
+			res.Action, err = applyCreated(tx, companyRec, key, rec.S3.Object.Size, strings.Trim(rec.S3.Object.ETag, `"`))
+		} else {
+			res.Action, err = applyRemoved(tx, companyRec, key)
+		}
+		return err
+	})
+	if err != nil {
+		// Nothing was recorded, a redelivery of the same event tries again.
+		res.Action = ActionFailed
+		res.Reason = err.Error()
+	}
+	return res
+}
+
+func applyCreated(tx Stores, companyRec *company.Company, key string, size int64, etag string) (string, error) {
+	latest, err := tx.FileMetas.FindLatestByKey(companyRec.ID, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up file meta")
+	}
+
+	if latest != nil && filemeta.IsUpload(latest.FileTxnType) {
+		if err := tx.FileMetas.UpdateObjectStats(latest.ID, size, etag); err != nil {
+			return "", fmt.Errorf("failed to update file meta")
+		}
+		if err := adjustQuota(tx, companyRec.ID, size-latest.FileSize); err != nil {
+			return "", err
+		}
+		return ActionUpdated, nil
+	}
+
+	// The object appeared without a presigned upload, record it now.
+	fileName := path.Base(key)
+	txnMeta := eventTxnMeta
+	meta := &filemeta.FileMeta{
+		ID:          utils.GenerateID(),
+		FileName:    &fileName,
+		FileSize:    size,
+		FileKey:     key,
+		FileTxnType: filemeta.TxnUpload,
+		FileTxnMeta: &txnMeta,
+		CompanyID:   &companyRec.ID,
+		ETag:        &etag,
+	}
+	if err := tx.FileMetas.Create(meta); err != nil {
+		return "", fmt.Errorf("failed to create file meta")
+	}
+	if err := adjustQuota(tx, companyRec.ID, size); err != nil {
+		return "", err
+	}
+	return ActionCreated, nil
+}
+
+func applyRemoved(tx Stores, companyRec *company.Company, key string) (string, error) {
+	// Deletes through the API are recorded and refunded already.
+	txnMeta := eventTxnMeta
+	recorded, err := uploader.RecordFileDelete(uploader.Stores{Companies: tx.Companies, FileMetas: tx.FileMetas}, companyRec.ID, key, &txnMeta)
+	if err != nil {
+		return "", fmt.Errorf("failed to record delete")
+	}
+	if !recorded {
+		return ActionSkipped, nil
+	}
+	return ActionDeleted, nil
+}
+
+func adjustQuota(tx Stores, companyID string, delta int64) error {
+	var err error
+	switch {
+	case delta > 0:
+		err = tx.Companies.IncrementUsedQuota(companyID, delta)
+	case delta < 0:
+		err = tx.Companies.DecrementUsedQuota(companyID, -delta)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update quota")
+	}
+	return nil
+}
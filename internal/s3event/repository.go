@@ -0,0 +1,72 @@
+package s3event
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+type Repository interface {
+	// Claim records the event and reports false if it was already recorded.
+	Claim(e *ProcessedEvent) (bool, error)
+	// Advance records sequencer as the latest applied to the object and
+	// reports whether it is newer than the one recorded before. The object
+	// row stays locked until the transaction ends.
+	Advance(bucket, key, sequencer string) (bool, error)
+	// Transaction runs fn with repositories bound to one transaction, so an
+	// event is claimed and applied together or not at all.
+	Transaction(fn func(tx Stores) error) error
+}
+
+// Stores are the repositories an event is applied with.
+type Stores struct {
+	Events    Repository
+	Companies company.Repository
+	FileMetas filemeta.Repository
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Claim(e *ProcessedEvent) (bool, error) {
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(e)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *repository) Advance(bucket, key, sequencer string) (bool, error) {
+	latest := ObjectSequencer{BucketName: bucket, ObjectKey: key}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&latest).Error; err != nil {
+		return false, err
+	}
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("bucket_name = ? AND object_key = ?", bucket, key).
+		First(&latest).Error; err != nil {
+		return false, err
+	}
+	if latest.Sequencer != "" && CompareSequencers(sequencer, latest.Sequencer) <= 0 {
+		return false, nil
+	}
+	err := r.db.Model(&ObjectSequencer{}).
+		Where("bucket_name = ? AND object_key = ?", bucket, key).
+		Update("sequencer", sequencer).Error
+	return err == nil, err
+}
+
+func (r *repository) Transaction(fn func(tx Stores) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(Stores{
+			Events:    &repository{db: tx},
+			Companies: company.NewRepository(tx),
+			FileMetas: filemeta.NewRepository(tx),
+		})
+	})
+}
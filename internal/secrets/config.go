@@ -0,0 +1,24 @@
+package secrets
+
+import (
+	"errors"
+	"log"
+
+	"shreshtasmg.in/jupyter/internal/config"
+)
+
+// FromConfig loads the credential keyring. Outside the local environment a
+// master key is mandatory.
+func FromConfig(cfg *config.Config) (*Keyring, error) {
+	keyring, err := LoadKeyring(cfg.CredentialMasterKeys, cfg.CredentialKeyFile, cfg.CredentialKeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !keyring.Enabled() {
+		if cfg.APP_ENV != config.Local.String() {
+			return nil, errors.New("CREDENTIALS_MASTER_KEYS or CREDENTIALS_KEY_FILE is required")
+		}
+		log.Println("no credential master key configured, storage credentials are stored in plaintext")
+	}
+	return keyring, nil
+}
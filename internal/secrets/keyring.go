@@ -0,0 +1,222 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Sealed values look like "enc:v<version>:<wrapped data key>:<ciphertext>".
+// Each value is encrypted with its own random data key, which is in turn
+// encrypted with the master key of the given version (envelope encryption).
+const sealedPrefix = "enc:"
+
+var ErrUnknownKeyVersion = errors.New("unknown credential key version")
+
+// Keyring holds the versioned master keys. New values are sealed with the
+// current version, any known version can be opened.
+type Keyring struct {
+	current int
+	keys    map[int][]byte
+}
+
+// ParseKeys reads "version:base64key" entries separated by commas or newlines.
+// Lines starting with '#' are ignored.
+func ParseKeys(s string) (map[int][]byte, error) {
+	keys := make(map[int][]byte)
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+		v, k, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key entry, expected version:base64key")
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(v, "v"))
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid key version %q", v)
+		}
+		key, err := base64.StdEncoding.DecodeString(k)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key version %d must be 32 base64 encoded bytes", version)
+		}
+		keys[version] = key
+	}
+	return keys, nil
+}
+
+// NewKeyring builds a keyring from master keys; current 0 picks the highest
+// version. A keyring without keys leaves values in plaintext.
+func NewKeyring(keys map[int][]byte, current int) (*Keyring, error) {
+	if len(keys) == 0 {
+		return &Keyring{keys: keys}, nil
+	}
+	if current == 0 {
+		for version := range keys {
+			current = max(current, version)
+		}
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, current)
+	}
+	return &Keyring{current: current, keys: keys}, nil
+}
+
+// LoadKeyring reads the master keys from the inline value or, if empty, from
+// keyFile.
+func LoadKeyring(inline, keyFile string, current int) (*Keyring, error) {
+	if inline == "" && keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		inline = string(data)
+	}
+	keys, err := ParseKeys(inline)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(keys, current)
+}
+
+func (k *Keyring) Enabled() bool {
+	return len(k.keys) > 0
+}
+
+func (k *Keyring) CurrentVersion() int {
+	return k.current
+}
+
+// IsSealed reports whether value was produced by Seal.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Version returns the key version a sealed value was sealed with, 0 for plaintext.
+func Version(value string) int {
+	if !IsSealed(value) {
+		return 0
+	}
+	v, _, _ := strings.Cut(strings.TrimPrefix(value, sealedPrefix+"v"), ":")
+	version, _ := strconv.Atoi(v)
+	return version
+}
+
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	if !k.Enabled() {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := encrypt(k.keys[k.current], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := encrypt(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%sv%d:%s:%s", sealedPrefix, k.current,
+		base64.RawStdEncoding.EncodeToString(wrappedKey),
+		base64.RawStdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// Open decrypts a sealed value. Plaintext values written before encryption
+// was enabled are returned unchanged.
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, sealedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed sealed credential")
+	}
+	masterKey, ok := k.keys[Version(value)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKeyVersion, parts[0])
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("malformed sealed credential")
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed sealed credential")
+	}
+
+	dataKey, err := decrypt(masterKey, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := decrypt(dataKey, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Reseal re-encrypts value with the current key version. It reports false
+// when the value already uses it.
+func (k *Keyring) Reseal(value string) (string, bool, error) {
+	if !k.Enabled() || Version(value) == k.current {
+		return value, false, nil
+	}
+	plaintext, err := k.Open(value)
+	if err != nil {
+		return "", false, err
+	}
+	sealed, err := k.Seal(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return sealed, true, nil
+}
+
+// encrypt returns nonce|ciphertext using AES-256-GCM.
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("malformed sealed credential")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt credential")
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,561 @@
+package sharelink
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/locpath"
+	"shreshtasmg.in/jupyter/internal/uploader"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+// downloadURLExpiry is how long the presigned GET behind a share link lives.
+const downloadURLExpiry = 5 * time.Minute
+
+// Wrong passwords are limited per link: after maxPasswordFailures within
+// passwordFailureWindow further attempts are refused until older ones age out.
+const (
+	maxPasswordFailures   = 10
+	passwordFailureWindow = 15 * time.Minute
+)
+
+type CreateShareLinkRequest struct {
+	FileKey      string  `json:"file_key,omitempty"`      // share a single file
+	FolderPrefix string  `json:"folder_prefix,omitempty"` // or every file in a folder
+	ExpiresAt    *string `json:"expires_at,omitempty"`    // RFC3339
+	Password     *string `json:"password,omitempty"`
+	MaxDownloads *int    `json:"max_downloads,omitempty"`
+}
+
+type ShareLinkResponse struct {
+	ID                string  `json:"id"`
+	Token             string  `json:"token"`
+	TargetType        string  `json:"target_type"`
+	TargetKey         string  `json:"target_key"`
+	PasswordProtected bool    `json:"password_protected"`
+	ExpiresAt         *string `json:"expires_at,omitempty"`
+	MaxDownloads      *int    `json:"max_downloads,omitempty"`
+	DownloadCount     int     `json:"download_count"`
+	Revoked           bool    `json:"revoked"`
+	CreatedAt         string  `json:"created_at"`
+}
+
+type ListShareLinksResponse struct {
+	Items []ShareLinkResponse `json:"items"`
+}
+
+type AccessItem struct {
+	CreatedAt  string  `json:"created_at"`
+	ObjectKey  *string `json:"object_key,omitempty"`
+	Outcome    string  `json:"outcome"`
+	RemoteAddr string  `json:"remote_addr"`
+	UserAgent  string  `json:"user_agent"`
+}
+
+type ListAccessesResponse struct {
+	Items []AccessItem `json:"items"`
+}
+
+type SharedFolderResponse struct {
+	Files []string `json:"files"`
+}
+
+type Handler struct {
+	repo         Repository
+	companyRepo  company.Repository
+	fileMetaRepo filemeta.Repository
+	folderRepo   folder.Repository
+	s3Service    uploader.S3Service
+	grace        time.Duration // subscription grace period
+}
+
+func NewHandler(repo Repository, companyRepo company.Repository, fileMetaRepo filemeta.Repository, folderRepo folder.Repository, s3Service uploader.S3Service, grace time.Duration) *Handler {
+	return &Handler{repo: repo, companyRepo: companyRepo, fileMetaRepo: fileMetaRepo, folderRepo: folderRepo, s3Service: s3Service, grace: grace}
+}
+
+// inTrash reports whether the folder of objectKey or one of its parents is
+// in the trash.
+func (h *Handler) inTrash(companyID, objectKey string) (bool, error) {
+	locTag := filemeta.LocTagOf(objectKey)
+	if locTag == nil {
+		return false, nil
+	}
+	return h.folderRepo.InTrash(companyID, *locTag)
+}
+
+func toShareLinkResponse(link ShareLink) ShareLinkResponse {
+	resp := ShareLinkResponse{
+		ID:                link.ID,
+		Token:             link.Token,
+		TargetType:        link.TargetType,
+		TargetKey:         link.TargetKey,
+		PasswordProtected: link.PasswordHash != nil,
+		MaxDownloads:      link.MaxDownloads,
+		DownloadCount:     link.DownloadCount,
+		Revoked:           link.RevokedAt != nil,
+		CreatedAt:         link.CreatedAt.Format(time.RFC3339),
+	}
+	if link.ExpiresAt != nil {
+		expiresAt := link.ExpiresAt.Format(time.RFC3339)
+		resp.ExpiresAt = &expiresAt
+	}
+	return resp
+}
+
+// CreateShareLink godoc
+// @Summary      Create a public share link
+// @Description  Shares a file (file_key) or folder (folder_prefix) through an opaque token with optional expiry, password and download limit
+// @Tags         share
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string                  true  "Company API key"
+// @Param        body       body      CreateShareLinkRequest  true  "Share link"
+// @Success      201        {object}  ShareLinkResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found or in the trash"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/share-links [post]
+func (h *Handler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+
+	var req CreateShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if (req.FileKey == "") == (req.FolderPrefix == "") {
+		http.Error(w, "exactly one of file_key or folder_prefix is required", http.StatusBadRequest)
+		return
+	}
+
+	link := &ShareLink{
+		ID:           utils.GenerateID(),
+		CompanyID:    companyRec.ID,
+		MaxDownloads: req.MaxDownloads,
+	}
+
+	if req.FileKey != "" {
+		locTag, name, err := uploader.SplitFileKey(companyRec, req.FileKey)
+		if errors.Is(err, uploader.ErrKeyOutsidePrefix) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fileKey := companyRec.CompanySlug + "/" + name
+		if locTag != "" {
+			if locTag, err = uploader.CanonicalLocTag(h.folderRepo, h.fileMetaRepo, companyRec.ID, locTag); err != nil {
+				http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+				return
+			}
+			fileKey = companyRec.CompanySlug + "/" + locTag + "/" + name
+		}
+		file, err := h.fileMetaRepo.GetFile(companyRec.ID, fileKey)
+		if err != nil {
+			http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+			return
+		}
+		if file == nil {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		trashed, err := h.inTrash(companyRec.ID, file.FileKey)
+		if err != nil {
+			http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+			return
+		}
+		if trashed {
+			http.Error(w, "file is in the trash", http.StatusNotFound)
+			return
+		}
+		link.TargetType = TargetFile
+		link.TargetKey = file.FileKey
+		link.FileMetaID = &file.FileMetaID
+	} else {
+		prefix, err := locpath.Clean(req.FolderPrefix)
+		if err != nil {
+			http.Error(w, "folder_prefix: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		prefix, err = uploader.CanonicalLocTag(h.folderRepo, h.fileMetaRepo, companyRec.ID, prefix)
+		if err != nil {
+			http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+			return
+		}
+		trashed, err := h.folderRepo.InTrash(companyRec.ID, prefix)
+		if err != nil {
+			http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+			return
+		}
+		if trashed {
+			http.Error(w, "folder is in the trash", http.StatusNotFound)
+			return
+		}
+		link.TargetType = TargetFolder
+		link.TargetKey = filemeta.FolderKeyPrefix(companyRec.CompanySlug, prefix)
+	}
+
+	if req.ExpiresAt != nil {
+		expiresAt, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			http.Error(w, "expires_at must be RFC3339", http.StatusBadRequest)
+			return
+		}
+		if !expiresAt.After(time.Now()) {
+			http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+			return
+		}
+		link.ExpiresAt = &expiresAt
+	}
+	if req.MaxDownloads != nil && *req.MaxDownloads <= 0 {
+		http.Error(w, "max_downloads must be > 0", http.StatusBadRequest)
+		return
+	}
+	if req.Password != nil && *req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, "invalid password", http.StatusBadRequest)
+			return
+		}
+		passwordHash := string(hash)
+		link.PasswordHash = &passwordHash
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	link.Token = token
+
+	if err := h.repo.Create(link); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(toShareLinkResponse(*link))
+}
+
+// ListShareLinks godoc
+// @Summary      List share links of the calling company
+// @Tags         share
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Success      200        {object}  ListShareLinksResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/share-links [get]
+func (h *Handler) ListShareLinks(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+
+	links, err := h.repo.ListByCompanyID(companyRec.ID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]ShareLinkResponse, 0, len(links))
+	for _, link := range links {
+		items = append(items, toShareLinkResponse(link))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListShareLinksResponse{Items: items})
+}
+
+// companyLink loads a share link owned by the calling company or writes 404.
+func (h *Handler) companyLink(w http.ResponseWriter, r *http.Request) *ShareLink {
+	companyRec := company.FromContext(r.Context())
+
+	link, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return nil
+	}
+	if link == nil || link.CompanyID != companyRec.ID {
+		http.Error(w, "share link not found", http.StatusNotFound)
+		return nil
+	}
+	return link
+}
+
+// RevokeShareLink godoc
+// @Summary      Revoke a share link
+// @Tags         share
+// @Param        X-API-Key  header  string  true  "Company API key"
+// @Param        id         path    string  true  "Share link ID"
+// @Success      204
+// @Failure      404  {string}  string "share link not found"
+// @Router       /uploader/share-links/{id}/revoke [post]
+func (h *Handler) RevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	link := h.companyLink(w, r)
+	if link == nil {
+		return
+	}
+	if err := h.repo.Revoke(link.ID); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListShareLinkAccesses godoc
+// @Summary      List access log of a share link
+// @Tags         share
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "Share link ID"
+// @Success      200        {object}  ListAccessesResponse
+// @Failure      404        {string}  string "share link not found"
+// @Router       /uploader/share-links/{id}/accesses [get]
+func (h *Handler) ListShareLinkAccesses(w http.ResponseWriter, r *http.Request) {
+	link := h.companyLink(w, r)
+	if link == nil {
+		return
+	}
+
+	accesses, err := h.repo.ListAccesses(link.ID, 500)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]AccessItem, 0, len(accesses))
+	for _, a := range accesses {
+		items = append(items, AccessItem{
+			CreatedAt:  a.CreatedAt.Format(time.RFC3339),
+			ObjectKey:  a.ObjectKey,
+			Outcome:    a.Outcome,
+			RemoteAddr: a.RemoteAddr,
+			UserAgent:  a.UserAgent,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListAccessesResponse{Items: items})
+}
+
+// ResolveShareLink godoc
+// @Summary      Open a share link
+// @Description  Redirects to a short-lived presigned download URL. Folder links list their files unless a file is selected. The password can be sent as X-Share-Password header or as a "password" form field (POST).
+// @Tags         share
+// @Produce      json
+// @Param        token             path      string  true   "Share token"
+// @Param        file              query     string  false  "File name inside a shared folder"
+// @Param        X-Share-Password  header    string  false  "Share link password"
+// @Success      200               {object}  SharedFolderResponse
+// @Success      302
+// @Failure      401               {string}  string "password required"
+// @Failure      403               {string}  string "company suspended or outside its subscription"
+// @Failure      404               {string}  string "share link not found"
+// @Failure      409               {object}  map[string]interface{} "archived, restore required"
+// @Failure      410               {string}  string "share link expired or used up, or its file no longer exists"
+// @Failure      429               {string}  string "too many wrong passwords"
+// @Router       /share/{token} [get]
+func (h *Handler) ResolveShareLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	link, err := h.repo.GetByToken(chi.URLParam(r, "token"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		http.Error(w, "share link not found", http.StatusNotFound)
+		return
+	}
+
+	if link.RevokedAt != nil {
+		h.logAccess(r, link, nil, OutcomeRevoked)
+		http.Error(w, "share link has been revoked", http.StatusGone)
+		return
+	}
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		h.logAccess(r, link, nil, OutcomeExpired)
+		http.Error(w, "share link expired", http.StatusGone)
+		return
+	}
+	if link.PasswordHash != nil {
+		password := r.Header.Get("X-Share-Password")
+		if password == "" && r.Method == http.MethodPost {
+			password = r.FormValue("password")
+		}
+		if password == "" {
+			h.logAccess(r, link, nil, OutcomePasswordRequired)
+			http.Error(w, "password required", http.StatusUnauthorized)
+			return
+		}
+		failures, err := h.repo.CountAccesses(link.ID, OutcomeWrongPassword, time.Now().Add(-passwordFailureWindow))
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if failures >= maxPasswordFailures {
+			h.logAccess(r, link, nil, OutcomeThrottled)
+			w.Header().Set("Retry-After", strconv.Itoa(int(passwordFailureWindow.Seconds())))
+			http.Error(w, "too many wrong passwords, try again later", http.StatusTooManyRequests)
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(*link.PasswordHash), []byte(password)) != nil {
+			h.logAccess(r, link, nil, OutcomeWrongPassword)
+			http.Error(w, "wrong password", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	companyRec, err := h.companyRepo.GetByID(link.CompanyID)
+	if err != nil {
+		http.Error(w, "failed to look up company", http.StatusInternalServerError)
+		return
+	}
+	if companyRec == nil {
+		http.Error(w, "share link not found", http.StatusNotFound)
+		return
+	}
+	if !company.CheckAvailable(w, companyRec, time.Now(), h.grace) {
+		h.logAccess(r, link, nil, OutcomeUnavailable)
+		return
+	}
+
+	objectKey := link.TargetKey
+	if link.TargetType == TargetFolder {
+		files, err := h.folderFiles(companyRec, link.TargetKey)
+		if err != nil {
+			http.Error(w, "failed to list folder", http.StatusInternalServerError)
+			return
+		}
+
+		name := r.URL.Query().Get("file")
+		if name == "" {
+			h.logAccess(r, link, nil, OutcomeListed)
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(SharedFolderResponse{Files: files})
+			return
+		}
+
+		objectKey = link.TargetKey + name
+		if !slices.Contains(files, name) {
+			h.logAccess(r, link, &objectKey, OutcomeNotFound)
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		trashed, err := h.inTrash(companyRec.ID, objectKey)
+		if err != nil {
+			http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+			return
+		}
+		if trashed {
+			h.logAccess(r, link, &objectKey, OutcomeNotFound)
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		file, err := h.fileMetaRepo.GetFile(companyRec.ID, objectKey)
+		if err != nil {
+			http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+			return
+		}
+		if file == nil || (link.FileMetaID != nil && file.FileMetaID != *link.FileMetaID) {
+			h.logAccess(r, link, &objectKey, OutcomeNotFound)
+			http.Error(w, "shared file no longer exists", http.StatusGone)
+			return
+		}
+	}
+
+	// Archived objects cannot be downloaded until restored; the attempt does
+	// not count against the download limit.
+	info, err := h.s3Service.HeadObject(ctx, companyRec, objectKey)
+	if err != nil {
+		http.Error(w, "failed to read object stats", http.StatusInternalServerError)
+		return
+	}
+	if info == nil {
+		h.logAccess(r, link, &objectKey, OutcomeNotFound)
+		if link.TargetType == TargetFile {
+			http.Error(w, "shared file no longer exists", http.StatusGone)
+		} else {
+			http.Error(w, "file not found", http.StatusNotFound)
+		}
+		return
+	}
+	if !info.Readable() {
+		h.logAccess(r, link, &objectKey, OutcomeArchived)
+		uploader.WriteArchived(w, objectKey, *info)
+		return
+	}
+
+	// Count the download only once there is a URL to hand out.
+	downloadURL, err := h.s3Service.GeneratePresignedDownloadURL(ctx, companyRec, objectKey, downloadURLExpiry)
+	if err != nil {
+		http.Error(w, "failed to generate presigned URL", http.StatusInternalServerError)
+		return
+	}
+
+	ok, err := h.repo.ConsumeDownload(link.ID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.logAccess(r, link, &objectKey, OutcomeLimitReached)
+		http.Error(w, "download limit reached", http.StatusGone)
+		return
+	}
+
+	h.logAccess(r, link, &objectKey, OutcomeGranted)
+	http.Redirect(w, r, downloadURL, http.StatusFound)
+}
+
+// folderFiles lists the files currently stored under folderKey, relative to
+// it, leaving out the files of folders in the trash.
+func (h *Handler) folderFiles(companyRec *company.Company, folderKey string) ([]string, error) {
+	stored, err := h.fileMetaRepo.ListFiles(companyRec.ID, folderKey)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := h.folderRepo.TrashedPaths(companyRec.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(stored))
+	for _, file := range stored {
+		if file.LocTag != nil && folder.WithinAny(*file.LocTag, trashed) {
+			continue
+		}
+		files = append(files, strings.TrimPrefix(file.FileKey, folderKey))
+	}
+	return files, nil
+}
+
+func (h *Handler) logAccess(r *http.Request, link *ShareLink, objectKey *string, outcome string) {
+	userAgent := r.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	_ = h.repo.LogAccess(&Access{
+		ID:          utils.GenerateID(),
+		ShareLinkID: link.ID,
+		ObjectKey:   objectKey,
+		Outcome:     outcome,
+		RemoteAddr:  r.RemoteAddr,
+		UserAgent:   userAgent,
+	})
+}
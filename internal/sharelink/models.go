@@ -0,0 +1,67 @@
+package sharelink
+
+import "time"
+
+// Share link targets.
+const (
+	TargetFile   = "file"
+	TargetFolder = "folder"
+)
+
+// Access outcomes recorded in share_link_accesses.
+const (
+	OutcomeGranted       = "granted"
+	OutcomeListed        = "listed"
+	OutcomeNotFound      = "not_found"
+	OutcomeRevoked       = "revoked"
+	OutcomeExpired       = "expired"
+	OutcomeLimitReached  = "limit_reached"
+	OutcomeWrongPassword = "wrong_password"
+	// OutcomePasswordRequired is logged when no password was sent.
+	OutcomePasswordRequired = "password_required"
+	// OutcomeArchived is logged when the file must be restored first.
+	OutcomeArchived = "archived"
+	// OutcomeThrottled is logged when too many wrong passwords were sent.
+	OutcomeThrottled = "throttled"
+	// OutcomeUnavailable is logged when the company is suspended or outside
+	// its subscription.
+	OutcomeUnavailable = "unavailable"
+)
+
+// ShareLink gives people without an API key access to a file or folder.
+type ShareLink struct {
+	ID         string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime"`
+	CompanyID  string    `gorm:"type:varchar(40);not null;index;column:company_id"`
+	Token      string    `gorm:"type:varchar(64);not null;uniqueIndex;column:token"`
+	TargetType string    `gorm:"type:varchar(10);not null;column:target_type"`
+	TargetKey  string    `gorm:"type:varchar(255);not null;column:target_key"`
+	// FileMetaID is the upload a file link shares. The link ends when the
+	// file is deleted or replaced by a new upload.
+	FileMetaID    *string    `gorm:"type:varchar(40);column:file_meta_id"`
+	PasswordHash  *string    `gorm:"type:varchar(72);column:password_hash"`
+	ExpiresAt     *time.Time `gorm:"column:expires_at"`
+	MaxDownloads  *int       `gorm:"column:max_downloads"`
+	DownloadCount int        `gorm:"column:download_count;default:0"`
+	RevokedAt     *time.Time `gorm:"column:revoked_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (ShareLink) TableName() string {
+	return "share_links"
+}
+
+// Access is one attempt to use a share link.
+type Access struct {
+	ID          string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+	ShareLinkID string    `gorm:"type:varchar(40);not null;index;column:share_link_id"`
+	ObjectKey   *string   `gorm:"type:varchar(255);column:object_key"`
+	Outcome     string    `gorm:"type:varchar(20);not null;column:outcome"`
+	RemoteAddr  string    `gorm:"type:varchar(64);column:remote_addr"`
+	UserAgent   string    `gorm:"type:varchar(255);column:user_agent"`
+}
+
+func (Access) TableName() string {
+	return "share_link_accesses"
+}
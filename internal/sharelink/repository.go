@@ -0,0 +1,104 @@
+package sharelink
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(link *ShareLink) error
+	GetByID(id string) (*ShareLink, error)
+	GetByToken(token string) (*ShareLink, error)
+	ListByCompanyID(companyID string) ([]ShareLink, error)
+	Revoke(id string) error
+	// ConsumeDownload counts a download and reports false if the link's
+	// download limit has already been reached.
+	ConsumeDownload(id string) (bool, error)
+	LogAccess(a *Access) error
+	// CountAccesses counts the link's accesses with outcome since since.
+	CountAccesses(shareLinkID, outcome string, since time.Time) (int64, error)
+	ListAccesses(shareLinkID string, limit int) ([]Access, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(link *ShareLink) error {
+	return r.db.Create(link).Error
+}
+
+func (r *repository) GetByID(id string) (*ShareLink, error) {
+	var link ShareLink
+	if err := r.db.Where("id = ?", id).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *repository) GetByToken(token string) (*ShareLink, error) {
+	var link ShareLink
+	if err := r.db.Where("token = ?", token).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *repository) ListByCompanyID(companyID string) ([]ShareLink, error) {
+	var links []ShareLink
+	if err := r.db.Where("company_id = ?", companyID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (r *repository) Revoke(id string) error {
+	return r.db.Model(&ShareLink{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		UpdateColumn("revoked_at", time.Now()).Error
+}
+
+func (r *repository) ConsumeDownload(id string) (bool, error) {
+	res := r.db.Model(&ShareLink{}).
+		Where("id = ? AND (max_downloads IS NULL OR download_count < max_downloads)", id).
+		UpdateColumn("download_count", gorm.Expr("download_count + 1"))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *repository) LogAccess(a *Access) error {
+	return r.db.Create(a).Error
+}
+
+func (r *repository) CountAccesses(shareLinkID, outcome string, since time.Time) (int64, error) {
+	var n int64
+	err := r.db.Model(&Access{}).
+		Where("share_link_id = ? AND outcome = ? AND created_at >= ?", shareLinkID, outcome, since).
+		Count(&n).Error
+	return n, err
+}
+
+func (r *repository) ListAccesses(shareLinkID string, limit int) ([]Access, error) {
+	var accesses []Access
+	if err := r.db.Where("share_link_id = ?", shareLinkID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&accesses).Error; err != nil {
+		return nil, err
+	}
+	return accesses, nil
+}
@@ -0,0 +1,289 @@
+package storagemigration
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/uploader"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+type CreateMigrationRequest struct {
+	CompanySlug    string  `json:"company_slug"`
+	TargetConfigID *string `json:"target_config_id,omitempty"` // move to the bucket and credentials of an uploader config
+	TargetBucket   *string `json:"target_bucket,omitempty"`    // or to another bucket with the company's credentials
+	TargetRegion   *string `json:"target_region,omitempty"`
+	DeleteSource   bool    `json:"delete_source"`
+}
+
+type MigrationResponse struct {
+	ID            string  `json:"id"`
+	CompanyID     string  `json:"company_id"`
+	Status        string  `json:"status"`
+	SourceBucket  string  `json:"source_bucket"`
+	TargetBucket  string  `json:"target_bucket"`
+	DeleteSource  bool    `json:"delete_source"`
+	TotalObjects  int     `json:"total_objects"`
+	CopiedObjects int     `json:"copied_objects"`
+	TotalBytes    int64   `json:"total_bytes"`
+	CopiedBytes   int64   `json:"copied_bytes"`
+	LastKey       string  `json:"last_key,omitempty"`
+	Error         *string `json:"error,omitempty"`
+	CreatedAt     string  `json:"created_at"`
+	SwitchedAt    *string `json:"switched_at,omitempty"`
+	// DrainUntil is when the source stops receiving uploads issued before
+	// the switch; it is re-synced and deleted after it.
+	DrainUntil *string `json:"drain_until,omitempty"`
+	FinishedAt *string `json:"finished_at,omitempty"`
+}
+
+type ListMigrationsResponse struct {
+	Items []MigrationResponse `json:"items"`
+}
+
+type Handler struct {
+	repo         Repository
+	companyRepo  company.Repository
+	uploaderRepo uploader.Repository
+	runner       *Runner
+}
+
+func NewHandler(repo Repository, companyRepo company.Repository, uploaderRepo uploader.Repository, runner *Runner) *Handler {
+	return &Handler{repo: repo, companyRepo: companyRepo, uploaderRepo: uploaderRepo, runner: runner}
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+func toMigrationResponse(job Job) MigrationResponse {
+	return MigrationResponse{
+		ID:            job.ID,
+		CompanyID:     job.CompanyID,
+		Status:        job.Status,
+		SourceBucket:  job.SourceBucket,
+		TargetBucket:  job.TargetBucket,
+		DeleteSource:  job.DeleteSource,
+		TotalObjects:  job.TotalObjects,
+		CopiedObjects: job.CopiedObjects,
+		TotalBytes:    job.TotalBytes,
+		CopiedBytes:   job.CopiedBytes,
+		LastKey:       job.LastKey,
+		Error:         job.Error,
+		CreatedAt:     job.CreatedAt.Format(time.RFC3339),
+		SwitchedAt:    formatTime(job.SwitchedAt),
+		DrainUntil:    formatTime(job.DrainUntil()),
+		FinishedAt:    formatTime(job.FinishedAt),
+	}
+}
+
+func writeJob(w http.ResponseWriter, status int, job *Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(toMigrationResponse(*job))
+}
+
+// CreateMigration godoc
+// @Summary      Start moving a company's storage
+// @Description  Copies every object under the company slug to the target, verifies ETags/sizes and switches the company's storage pointer.
+// @Description  Uploads issued before the switch may still reach the source until drain_until; the job then copies them and optionally deletes the source.
+// @Tags         storage
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string                  true  "Admin client id"
+// @Param        client_secret  header    string                  true  "Admin client secret"
+// @Param        body           body      CreateMigrationRequest  true  "Migration"
+// @Success      202            {object}  MigrationResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "company not found"
+// @Failure      409            {string}  string "a migration is already in progress"
+// @Router       /storage/migrations [post]
+func (h *Handler) CreateMigration(w http.ResponseWriter, r *http.Request) {
+	var req CreateMigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	companyRec, err := h.companyRepo.GetBySlug(req.CompanySlug)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if companyRec == nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+	if companyRec.AwsBucketName == nil || companyRec.AwsBucketRegion == nil ||
+		companyRec.AwsAccessKey == nil || companyRec.AwsSecretKey == nil {
+		http.Error(w, "company storage is not configured", http.StatusBadRequest)
+		return
+	}
+
+	job := &Job{
+		ID:              utils.GenerateID(),
+		CompanyID:       companyRec.ID,
+		Status:          StatusPending,
+		SourceBucket:    *companyRec.AwsBucketName,
+		SourceRegion:    *companyRec.AwsBucketRegion,
+		SourceAccessKey: *companyRec.AwsAccessKey,
+		SourceSecretKey: *companyRec.AwsSecretKey,
+		DeleteSource:    req.DeleteSource,
+	}
+	if companyRec.AwsIAMUser == nil {
+		job.SourceConfigID = companyRec.UploaderConfigID
+	}
+
+	switch {
+	case req.TargetConfigID != nil:
+		cfg, err := h.uploaderRepo.GetByID(*req.TargetConfigID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if cfg == nil {
+			http.Error(w, "target config not found", http.StatusNotFound)
+			return
+		}
+		job.TargetConfigID = &cfg.ID
+		job.TargetBucket = cfg.AwsBucketName
+		job.TargetRegion = cfg.AwsBucketRegion
+		job.TargetAccessKey = cfg.AwsAccessKey
+		job.TargetSecretKey = cfg.AwsSecretKey
+	case req.TargetBucket != nil && *req.TargetBucket != "":
+		if companyRec.AwsIAMUser != nil {
+			http.Error(w, "the company's keys are limited to its bucket, migrate to a target_config_id", http.StatusBadRequest)
+			return
+		}
+		job.TargetBucket = *req.TargetBucket
+		job.TargetRegion = job.SourceRegion
+		if req.TargetRegion != nil && *req.TargetRegion != "" {
+			job.TargetRegion = *req.TargetRegion
+		}
+		job.TargetAccessKey = job.SourceAccessKey
+		job.TargetSecretKey = job.SourceSecretKey
+	default:
+		http.Error(w, "target_config_id or target_bucket is required", http.StatusBadRequest)
+		return
+	}
+	if job.TargetBucket == job.SourceBucket {
+		http.Error(w, "target bucket equals the current bucket", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.repo.FindUnfinishedByCompanyID(companyRec.ID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		http.Error(w, "a migration is already in progress", http.StatusConflict)
+		return
+	}
+
+	if err := h.repo.Create(job); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	h.runner.Start(job)
+
+	writeJob(w, http.StatusAccepted, job)
+}
+
+// GetMigration godoc
+// @Summary      Storage migration status
+// @Tags         storage
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Migration ID"
+// @Success      200            {object}  MigrationResponse
+// @Failure      404            {string}  string "migration not found"
+// @Router       /storage/migrations/{id} [get]
+func (h *Handler) GetMigration(w http.ResponseWriter, r *http.Request) {
+	job, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "migration not found", http.StatusNotFound)
+		return
+	}
+	writeJob(w, http.StatusOK, job)
+}
+
+// ListMigrations godoc
+// @Summary      List storage migrations of a company
+// @Tags         storage
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        company_slug   query     string  true  "Company slug"
+// @Success      200            {object}  ListMigrationsResponse
+// @Failure      404            {string}  string "company not found"
+// @Router       /storage/migrations [get]
+func (h *Handler) ListMigrations(w http.ResponseWriter, r *http.Request) {
+	companyRec, err := h.companyRepo.GetBySlug(r.URL.Query().Get("company_slug"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if companyRec == nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+
+	jobs, err := h.repo.ListByCompanyID(companyRec.ID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	items := make([]MigrationResponse, 0, len(jobs))
+	for _, job := range jobs {
+		items = append(items, toMigrationResponse(job))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListMigrationsResponse{Items: items})
+}
+
+// ResumeMigration godoc
+// @Summary      Resume an interrupted or failed storage migration
+// @Tags         storage
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Migration ID"
+// @Success      202            {object}  MigrationResponse
+// @Failure      404            {string}  string "migration not found"
+// @Failure      409            {string}  string "migration is running or completed"
+// @Router       /storage/migrations/{id}/resume [post]
+func (h *Handler) ResumeMigration(w http.ResponseWriter, r *http.Request) {
+	job, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "migration not found", http.StatusNotFound)
+		return
+	}
+	if job.Status == StatusCompleted {
+		http.Error(w, "migration is completed", http.StatusConflict)
+		return
+	}
+	if !h.runner.Start(job) {
+		http.Error(w, "migration is already running", http.StatusConflict)
+		return
+	}
+
+	writeJob(w, http.StatusAccepted, job)
+}
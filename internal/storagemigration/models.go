@@ -0,0 +1,73 @@
+package storagemigration
+
+import (
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/tus"
+	"shreshtasmg.in/jupyter/internal/uploader"
+)
+
+// Job statuses.
+const (
+	StatusPending   = "pending"
+	StatusCopying   = "copying"
+	StatusSwitched  = "switched" // company points at the target, draining and source cleanup pending
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// drainPeriod is how long after the switch uploads may still reach the
+// source: upload URLs and tus sessions issued before it stay valid.
+const drainPeriod = uploader.MaxUploadURLExpiry + tus.SessionLifetime
+
+// Job moves every object of a company from one storage location to another.
+// LastKey is the resume cursor: objects up to it have been copied and verified.
+// After the switch the job waits out drainPeriod, copies what reached the
+// source since SyncedAt and only then deletes the source.
+type Job struct {
+	ID              string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime"`
+	CompanyID       string    `gorm:"type:varchar(40);not null;index;column:company_id"`
+	Status          string    `gorm:"type:varchar(20);not null;column:status"`
+	SourceBucket    string    `gorm:"type:varchar(64);not null;column:source_bucket"`
+	SourceRegion    string    `gorm:"type:varchar(50);not null;column:source_region"`
+	SourceAccessKey string    `gorm:"type:varchar(255);not null;column:source_access_key"`
+	SourceSecretKey string    `gorm:"type:varchar(255);not null;column:source_secret_key"`
+	// SourceConfigID is the pool whose keys the source uses, nil when the
+	// company has keys of its own. Pool rotations update the job by it.
+	SourceConfigID  *string    `gorm:"type:varchar(40);index;column:source_config_id"`
+	TargetBucket    string     `gorm:"type:varchar(64);not null;column:target_bucket"`
+	TargetRegion    string     `gorm:"type:varchar(50);not null;column:target_region"`
+	TargetAccessKey string     `gorm:"type:varchar(255);not null;column:target_access_key"`
+	TargetSecretKey string     `gorm:"type:varchar(255);not null;column:target_secret_key"`
+	TargetConfigID  *string    `gorm:"type:varchar(40);index;column:target_config_id"`
+	DeleteSource    bool       `gorm:"column:delete_source;default:false"`
+	TotalObjects    int        `gorm:"column:total_objects;default:0"`
+	CopiedObjects   int        `gorm:"column:copied_objects;default:0"`
+	TotalBytes      int64      `gorm:"column:total_bytes;default:0"`
+	CopiedBytes     int64      `gorm:"column:copied_bytes;default:0"`
+	LastKey         string     `gorm:"type:varchar(255);column:last_key"`
+	Error           *string    `gorm:"type:text;column:error"`
+	SyncedAt        *time.Time `gorm:"column:synced_at"`
+	SwitchedAt      *time.Time `gorm:"column:switched_at"`
+	FinishedAt      *time.Time `gorm:"column:finished_at"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// credentialColumns are written only when a job is created and by pool
+// rotations, never from a running job's copy.
+var credentialColumns = []string{"source_access_key", "source_secret_key", "target_access_key", "target_secret_key"}
+
+func (Job) TableName() string {
+	return "storage_migrations"
+}
+
+// DrainUntil is when the source stops receiving uploads, or nil before the
+// switch.
+func (job Job) DrainUntil() *time.Time {
+	if job.SwitchedAt == nil {
+		return nil
+	}
+	until := job.SwitchedAt.Add(drainPeriod)
+	return &until
+}
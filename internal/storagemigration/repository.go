@@ -0,0 +1,122 @@
+package storagemigration
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"shreshtasmg.in/jupyter/internal/company"
+)
+
+type Repository interface {
+	Create(job *Job) error
+	GetByID(id string) (*Job, error)
+	ListByCompanyID(companyID string) ([]Job, error)
+	FindUnfinishedByCompanyID(companyID string) (*Job, error)
+	ListByStatus(status string) ([]Job, error)
+	// Save writes the job's progress. The credentials are left as stored.
+	Save(job *Job) error
+	// SwitchCompanyStorage points the company at the job's target and marks
+	// the job switched, in one transaction.
+	SwitchCompanyStorage(job *Job) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(job *Job) error {
+	return r.db.Create(job).Error
+}
+
+func (r *repository) GetByID(id string) (*Job, error) {
+	var job Job
+	if err := r.db.Where("id = ?", id).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *repository) ListByCompanyID(companyID string) ([]Job, error) {
+	var jobs []Job
+	if err := r.db.Where("company_id = ?", companyID).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *repository) FindUnfinishedByCompanyID(companyID string) (*Job, error) {
+	var job Job
+	if err := r.db.Where("company_id = ? AND status IN ?", companyID, []string{StatusPending, StatusCopying, StatusSwitched}).
+		Order("created_at DESC").
+		First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *repository) ListByStatus(status string) ([]Job, error) {
+	var jobs []Job
+	if err := r.db.Where("status = ?", status).Order("created_at").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (r *repository) Save(job *Job) error {
+	return r.db.Omit(credentialColumns...).Save(job).Error
+}
+
+func (r *repository) SwitchCompanyStorage(job *Job) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// The company gets the target keys as stored now, after any
+		// rotation during the copy.
+		var stored Job
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select(credentialColumns).
+			Where("id = ?", job.ID).
+			First(&stored).Error; err != nil {
+			return err
+		}
+		job.SourceAccessKey, job.SourceSecretKey = stored.SourceAccessKey, stored.SourceSecretKey
+		job.TargetAccessKey, job.TargetSecretKey = stored.TargetAccessKey, stored.TargetSecretKey
+
+		updates := map[string]interface{}{
+			"aws_bucket_name":   job.TargetBucket,
+			"aws_bucket_region": job.TargetRegion,
+			"aws_access_key":    job.TargetAccessKey,
+			"aws_secret_key":    job.TargetSecretKey,
+		}
+		if job.TargetConfigID != nil {
+			updates["uploader_config_id"] = *job.TargetConfigID
+			// The company now holds the pool's keys.
+			updates["aws_iam_user"] = nil
+		}
+		res := tx.Model(&company.Company{}).
+			Where("id = ? AND aws_bucket_name = ?", job.CompanyID, job.SourceBucket).
+			Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return fmt.Errorf("company storage changed during migration")
+		}
+
+		now := time.Now()
+		job.Status = StatusSwitched
+		job.SwitchedAt = &now
+		return tx.Omit(credentialColumns...).Save(job).Error
+	})
+}
@@ -0,0 +1,298 @@
+package storagemigration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/uploader"
+)
+
+// clockSkew widens the window of objects re-synced after the drain, as S3
+// timestamps come from another clock.
+const clockSkew = 5 * time.Minute
+
+// Runner copies, verifies and switches a company's storage. A job can be
+// run again after a failure and continues after its LastKey cursor.
+type Runner struct {
+	repo        Repository
+	companyRepo company.Repository
+	s3Service   uploader.S3Service
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewRunner(repo Repository, companyRepo company.Repository, s3Service uploader.S3Service) *Runner {
+	return &Runner{repo: repo, companyRepo: companyRepo, s3Service: s3Service, running: make(map[string]bool)}
+}
+
+// Start runs a copy of the job in the background unless it is already
+// running here.
+func (rn *Runner) Start(job *Job) bool {
+	jobCopy := *job
+	job = &jobCopy
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if rn.running[job.ID] {
+		return false
+	}
+	rn.running[job.ID] = true
+
+	go func() {
+		defer func() {
+			rn.mu.Lock()
+			delete(rn.running, job.ID)
+			rn.mu.Unlock()
+		}()
+		if err := rn.Run(context.Background(), job); err != nil {
+			log.Printf("storage migration %s failed: %v", job.ID, err)
+		}
+	}()
+	return true
+}
+
+// ResumeSwitched starts the switched jobs, which wait for their drain
+// period to end. It is called at startup.
+func (rn *Runner) ResumeSwitched() error {
+	jobs, err := rn.repo.ListByStatus(StatusSwitched)
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		rn.Start(&jobs[i])
+	}
+	return nil
+}
+
+func (rn *Runner) Run(ctx context.Context, job *Job) error {
+	err := rn.run(ctx, job)
+	if err != nil {
+		msg := err.Error()
+		job.Status = StatusFailed
+		job.Error = &msg
+		_ = rn.repo.Save(job)
+	}
+	return err
+}
+
+func (rn *Runner) run(ctx context.Context, job *Job) error {
+	companyRec, err := rn.companyRepo.GetByID(job.CompanyID)
+	if err != nil {
+		return fmt.Errorf("failed to look up company: %w", err)
+	}
+	if companyRec == nil {
+		return fmt.Errorf("company %s not found", job.CompanyID)
+	}
+	if err := rn.reloadCredentials(job); err != nil {
+		return err
+	}
+	source, target := job.locations(companyRec)
+	prefix := companyRec.CompanySlug + "/"
+
+	job.Error = nil
+	if job.SwitchedAt == nil {
+		job.Status = StatusCopying
+		if err := rn.repo.Save(job); err != nil {
+			return err
+		}
+
+		if err := rn.copyAll(ctx, job, source, target, prefix); err != nil {
+			return err
+		}
+		// Objects uploaded or overwritten at the source while the first
+		// pass ran.
+		syncedAt := time.Now()
+		if err := rn.syncChanged(ctx, job, source, target, prefix, time.Time{}); err != nil {
+			return err
+		}
+		job.SyncedAt = &syncedAt
+
+		if err := rn.repo.SwitchCompanyStorage(job); err != nil {
+			return fmt.Errorf("failed to switch company storage: %w", err)
+		}
+	} else if job.Status != StatusSwitched {
+		job.Status = StatusSwitched
+		if err := rn.repo.Save(job); err != nil {
+			return err
+		}
+	}
+
+	// Upload URLs and tus sessions issued before the switch keep writing to
+	// the source until they expire.
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(*job.DrainUntil())):
+	}
+	if err := rn.reloadCredentials(job); err != nil {
+		return err
+	}
+	source, target = job.locations(companyRec)
+	since := *job.SwitchedAt
+	if job.SyncedAt != nil {
+		since = *job.SyncedAt
+	}
+	if err := rn.syncChanged(ctx, job, source, target, prefix, since.Add(-clockSkew)); err != nil {
+		return err
+	}
+
+	if job.DeleteSource {
+		objects, err := rn.s3Service.ListObjects(ctx, source, prefix)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			if err := rn.s3Service.DeleteObject(ctx, source, obj.Key); err != nil {
+				return fmt.Errorf("failed to delete source object %s: %w", obj.Key, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	job.Status = StatusCompleted
+	job.FinishedAt = &now
+	return rn.repo.Save(job)
+}
+
+func (rn *Runner) copyAll(ctx context.Context, job *Job, source, target *company.Company, prefix string) error {
+	objects, err := rn.s3Service.ListObjects(ctx, source, prefix)
+	if err != nil {
+		return err
+	}
+
+	job.TotalObjects = len(objects)
+	job.TotalBytes = 0
+	for _, obj := range objects {
+		job.TotalBytes += obj.Size
+	}
+
+	// ListObjectsV2 returns keys in ascending order, which makes LastKey a
+	// valid resume cursor.
+	for _, obj := range objects {
+		if job.LastKey != "" && obj.Key <= job.LastKey {
+			continue
+		}
+		if err := rn.copyAndVerify(ctx, source, target, obj); err != nil {
+			return err
+		}
+
+		job.CopiedObjects++
+		job.CopiedBytes += obj.Size
+		job.LastKey = obj.Key
+		if err := rn.repo.Save(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncChanged copies the source objects modified since the given time
+// that are missing at the target or differ from their copy there.
+func (rn *Runner) syncChanged(ctx context.Context, job *Job, source, target *company.Company, prefix string, since time.Time) error {
+	sourceObjects, err := rn.s3Service.ListObjects(ctx, source, prefix)
+	if err != nil {
+		return err
+	}
+	targetObjects, err := rn.s3Service.ListObjects(ctx, target, prefix)
+	if err != nil {
+		return err
+	}
+	copies := make(map[string]uploader.ObjectInfo, len(targetObjects))
+	for _, obj := range targetObjects {
+		copies[obj.Key] = obj
+	}
+
+	for _, obj := range sourceObjects {
+		if obj.LastModified.Before(since) {
+			continue
+		}
+		if copied, ok := copies[obj.Key]; ok && !changedSince(obj, copied) {
+			continue
+		}
+		if err := rn.copyAndVerify(ctx, source, target, obj); err != nil {
+			return err
+		}
+		job.TotalObjects++
+		job.TotalBytes += obj.Size
+		job.CopiedObjects++
+		job.CopiedBytes += obj.Size
+		if err := rn.repo.Save(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedSince reports whether the source object was changed after copied
+// was written: its size or plain ETag differ, or it is newer.
+func changedSince(obj, copied uploader.ObjectInfo) bool {
+	if obj.Size != copied.Size {
+		return true
+	}
+	if plainETag(obj.ETag) && plainETag(copied.ETag) && obj.ETag != copied.ETag {
+		return true
+	}
+	return obj.LastModified.After(copied.LastModified)
+}
+
+// plainETag reports whether etag is the MD5 of the object; multipart ETags
+// ("<md5>-<parts>") change when copied.
+func plainETag(etag string) bool {
+	return !strings.Contains(etag, "-")
+}
+
+func (rn *Runner) copyAndVerify(ctx context.Context, source, target *company.Company, obj uploader.ObjectInfo) error {
+	if err := rn.s3Service.CopyObject(ctx, source, target, obj.Key); err != nil {
+		return err
+	}
+
+	copied, err := rn.s3Service.HeadObject(ctx, target, obj.Key)
+	if err != nil {
+		return err
+	}
+	if copied == nil || copied.Size != obj.Size {
+		return fmt.Errorf("verification failed for %s: size mismatch", obj.Key)
+	}
+	if plainETag(obj.ETag) && copied.ETag != obj.ETag {
+		return fmt.Errorf("verification failed for %s: etag mismatch", obj.Key)
+	}
+	return nil
+}
+
+// reloadCredentials reads the job's credentials again, as a pool rotation
+// may have replaced them since the job was loaded.
+func (rn *Runner) reloadCredentials(job *Job) error {
+	stored, err := rn.repo.GetByID(job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to reload migration: %w", err)
+	}
+	if stored == nil {
+		return fmt.Errorf("migration %s not found", job.ID)
+	}
+	job.SourceAccessKey, job.SourceSecretKey = stored.SourceAccessKey, stored.SourceSecretKey
+	job.TargetAccessKey, job.TargetSecretKey = stored.TargetAccessKey, stored.TargetSecretKey
+	return nil
+}
+
+// locations returns the company record pointed at the source and at the target.
+func (job *Job) locations(companyRec *company.Company) (*company.Company, *company.Company) {
+	source := *companyRec
+	source.AwsBucketName = &job.SourceBucket
+	source.AwsBucketRegion = &job.SourceRegion
+	source.AwsAccessKey = &job.SourceAccessKey
+	source.AwsSecretKey = &job.SourceSecretKey
+
+	target := *companyRec
+	target.AwsBucketName = &job.TargetBucket
+	target.AwsBucketRegion = &job.TargetRegion
+	target.AwsAccessKey = &job.TargetAccessKey
+	target.AwsSecretKey = &job.TargetSecretKey
+
+	return &source, &target
+}
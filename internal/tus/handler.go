@@ -0,0 +1,642 @@
+package tus
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/locpath"
+	"shreshtasmg.in/jupyter/internal/uploader"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,checksum,expiration"
+	tusChecksums  = "sha1,md5,sha256"
+
+	// partSize is the S3 part size; S3 needs at least 5 MiB for all but the
+	// last part and allows 10000 parts.
+	partSize      = 8 << 20
+	maxUploadSize = partSize * 10000
+	// maxChunkSize caps the body of a single PATCH request.
+	maxChunkSize = 64 << 20
+
+	// statusChecksumMismatch is the tus checksum extension's status code.
+	statusChecksumMismatch = 460
+)
+
+type Handler struct {
+	repo         Repository
+	companyRepo  company.Repository
+	fileMetaRepo filemeta.Repository
+	folderRepo   folder.Repository
+	s3Service    uploader.S3Service
+
+	mu     sync.Mutex
+	active map[string]bool // uploads with a request in progress
+}
+
+func NewHandler(repo Repository, companyRepo company.Repository, fileMetaRepo filemeta.Repository, folderRepo folder.Repository, s3Service uploader.S3Service) *Handler {
+	return &Handler{
+		repo:         repo,
+		companyRepo:  companyRepo,
+		fileMetaRepo: fileMetaRepo,
+		folderRepo:   folderRepo,
+		s3Service:    s3Service,
+		active:       make(map[string]bool),
+	}
+}
+
+// lock marks the upload busy; concurrent requests for it get 423 Locked.
+func (h *Handler) lock(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.active[id] {
+		return false
+	}
+	h.active[id] = true
+	return true
+}
+
+func (h *Handler) unlock(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.active, id)
+}
+
+// checkVersion rejects requests not speaking tus 1.0.0.
+func checkVersion(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// parseMetadata decodes the Upload-Metadata header: comma separated
+// "key base64value" pairs.
+func parseMetadata(header string) (map[string]string, bool) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, false
+		}
+		meta[key] = string(value)
+	}
+	return meta, true
+}
+
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "sha1":
+		return sha1.New()
+	case "md5":
+		return md5.New()
+	case "sha256":
+		return sha256.New()
+	}
+	return nil
+}
+
+// verifyChecksum checks chunk against the Upload-Checksum header, if present.
+// It writes the error response and returns false on failure.
+func verifyChecksum(w http.ResponseWriter, header string, chunk []byte) bool {
+	if header == "" {
+		return true
+	}
+	algorithm, encoded, _ := strings.Cut(header, " ")
+	hasher := newChecksumHash(algorithm)
+	if hasher == nil {
+		http.Error(w, "unsupported checksum algorithm", http.StatusBadRequest)
+		return false
+	}
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		http.Error(w, "invalid Upload-Checksum header", http.StatusBadRequest)
+		return false
+	}
+	hasher.Write(chunk)
+	if !bytes.Equal(hasher.Sum(nil), expected) {
+		http.Error(w, "checksum mismatch", statusChecksumMismatch)
+		return false
+	}
+	return true
+}
+
+// Options godoc
+// @Summary      Describe the tus server
+// @Description  Returns Tus-Version, Tus-Extension, Tus-Max-Size and Tus-Checksum-Algorithm headers
+// @Tags         tus
+// @Success      204
+// @Router       /uploader/tus [options]
+func (h *Handler) Options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(maxUploadSize, 10))
+	w.Header().Set("Tus-Checksum-Algorithm", tusChecksums)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUpload godoc
+// @Summary      Start a resumable upload (tus creation)
+// @Description  Upload-Metadata must carry filename and loc_tag, tags (comma separated) is optional. The file is recorded as pending.
+// @Description  Upload-Length is charged to quota at once and refunded if the upload is terminated or expires; uploads expire 24 hours after creation (Upload-Expires).
+// @Description  lock_mode and retain_until (RFC3339) lock the object; folder lock policies apply without them.
+// @Description  storage_class stores the object in STANDARD (default), STANDARD_IA, GLACIER_IR or DEEP_ARCHIVE.
+// @Tags         tus
+// @Param        X-API-Key        header  string  true   "Company API key"
+// @Param        Tus-Resumable    header  string  true   "1.0.0"
+// @Param        Upload-Length    header  int     true   "Total size in bytes"
+// @Param        Upload-Metadata  header  string  true   "filename <base64>,loc_tag <base64>"
+// @Success      201
+// @Header       201  {string}  Location  "Upload URL"
+// @Header       201  {string}  Upload-Expires  "When the upload expires"
+// @Failure      400  {string}  string "invalid request"
+// @Failure      403  {object}  map[string]interface{} "quota_exceeded"
+// @Failure      409  {string}  string "folder in trash or being renamed, or file under object lock"
+// @Failure      413  {string}  string "upload too large"
+// @Router       /uploader/tus [post]
+func (h *Handler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkVersion(w, r) {
+		return
+	}
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, "Upload-Defer-Length is not supported", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "Upload-Length must be > 0", http.StatusBadRequest)
+		return
+	}
+	if length > maxUploadSize {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	meta, ok := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if !ok {
+		http.Error(w, "invalid Upload-Metadata header", http.StatusBadRequest)
+		return
+	}
+	if meta["filename"] == "" || meta["loc_tag"] == "" {
+		http.Error(w, "filename and loc_tag metadata are required", http.StatusBadRequest)
+		return
+	}
+	locTag, err := locpath.Clean(meta["loc_tag"])
+	if err != nil {
+		http.Error(w, "loc_tag: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := filemeta.NormalizeTags(strings.Split(meta["tags"], ","))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	locTag, err = uploader.CanonicalLocTag(h.folderRepo, h.fileMetaRepo, companyRec.ID, locTag)
+	if err != nil {
+		http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+		return
+	}
+	fileKey, err := uploader.UploadKey(companyRec, locTag, meta["filename"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !uploader.CheckWritable(w, h.folderRepo, companyRec.ID, locTag) {
+		return
+	}
+	if !uploader.CheckOverwritable(w, h.fileMetaRepo, companyRec.ID, fileKey) {
+		return
+	}
+
+	lock, msg, err := uploader.ResolveObjectLock(h.folderRepo, companyRec, locTag, meta["lock_mode"], meta["retain_until"], time.Now())
+	if err != nil {
+		http.Error(w, "failed to look up folder lock policy", http.StatusInternalServerError)
+		return
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if lock != nil {
+		enabled, err := h.s3Service.ObjectLockEnabled(ctx, companyRec)
+		if err != nil {
+			http.Error(w, "failed to read object lock configuration", http.StatusInternalServerError)
+			return
+		}
+		if !enabled {
+			http.Error(w, "object lock is not enabled on the company bucket", http.StatusBadRequest)
+			return
+		}
+	}
+
+	storageClass, ok := uploader.ParseUploadStorageClass(meta["storage_class"])
+	if !ok {
+		http.Error(w, "storage_class must be STANDARD, STANDARD_IA, GLACIER_IR or DEEP_ARCHIVE", http.StatusBadRequest)
+		return
+	}
+
+	reserved, err := h.companyRepo.ReserveQuota(companyRec.ID, length)
+	if err != nil {
+		http.Error(w, "failed to update quota", http.StatusInternalServerError)
+		return
+	}
+	if !reserved {
+		writeQuotaExceeded(w, companyRec, length)
+		return
+	}
+
+	multipartID, err := h.s3Service.CreateMultipartUpload(ctx, companyRec, fileKey, uploader.UploadOptions{Lock: lock, StorageClass: storageClass})
+	if err != nil {
+		_ = h.companyRepo.DecrementUsedQuota(companyRec.ID, length)
+		http.Error(w, "failed to start upload", http.StatusInternalServerError)
+		return
+	}
+
+	fileName := meta["filename"]
+	txnMeta := "tus"
+	fileMeta := &filemeta.FileMeta{
+		ID:          utils.GenerateID(),
+		FileName:    &fileName,
+		FileSize:    length,
+		FileKey:     fileKey,
+		FileTxnType: filemeta.TxnUpload,
+		FileTxnMeta: &txnMeta,
+		CompanyID:   &companyRec.ID,
+		Status:      filemeta.StatusPending,
+	}
+	if lock != nil {
+		fileMeta.LockMode = &lock.Mode
+		fileMeta.RetainUntil = &lock.RetainUntil
+	}
+	if storageClass != "" {
+		fileMeta.StorageClass = &storageClass
+	}
+	if err := h.fileMetaRepo.CreateWithTags(fileMeta, tags); err != nil {
+		_ = h.s3Service.AbortMultipartUpload(ctx, companyRec, fileKey, multipartID)
+		_ = h.companyRepo.DecrementUsedQuota(companyRec.ID, length)
+		http.Error(w, "failed to create file meta", http.StatusInternalServerError)
+		return
+	}
+
+	upload := &Upload{
+		ID:          utils.GenerateID(),
+		CompanyID:   companyRec.ID,
+		FileMetaID:  fileMeta.ID,
+		FileKey:     fileKey,
+		Length:      length,
+		MultipartID: multipartID,
+		// Charged above.
+		QuotaReserved: true,
+	}
+	if err := h.repo.Create(upload); err != nil {
+		_ = h.s3Service.AbortMultipartUpload(ctx, companyRec, fileKey, multipartID)
+		_ = h.fileMetaRepo.SetStatus(fileMeta.ID, filemeta.StatusAborted)
+		_ = h.companyRepo.DecrementUsedQuota(companyRec.ID, length)
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+upload.ID)
+	w.Header().Set("Upload-Offset", "0")
+	w.Header().Set("Upload-Expires", upload.ExpiresAt().UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// loadUpload returns the calling company's upload from the URL, writing a
+// 404 if there is none.
+func (h *Handler) loadUpload(w http.ResponseWriter, r *http.Request) *Upload {
+	upload, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return nil
+	}
+	if upload == nil || upload.CompanyID != company.FromContext(r.Context()).ID {
+		http.Error(w, "upload not found", http.StatusNotFound)
+		return nil
+	}
+	return upload
+}
+
+// HeadUpload godoc
+// @Summary      Get the offset of a resumable upload
+// @Tags         tus
+// @Param        X-API-Key      header  string  true  "Company API key"
+// @Param        Tus-Resumable  header  string  true  "1.0.0"
+// @Param        id             path    string  true  "Upload ID"
+// @Success      200
+// @Header       200  {integer}  Upload-Offset  "Bytes received"
+// @Header       200  {integer}  Upload-Length  "Total size"
+// @Header       200  {string}   Upload-Expires  "When the upload expires, while it is unfinished"
+// @Failure      404  {string}  string "upload not found"
+// @Failure      410  {string}  string "upload expired"
+// @Router       /uploader/tus/{id} [head]
+func (h *Handler) HeadUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkVersion(w, r) {
+		return
+	}
+	upload := h.loadUpload(w, r)
+	if upload == nil {
+		return
+	}
+	if upload.Expired(time.Now()) {
+		http.Error(w, "upload expired", http.StatusGone)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	setExpires(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchUpload godoc
+// @Summary      Append bytes to a resumable upload
+// @Description  Upload-Offset must equal the current offset. An optional Upload-Checksum ("sha1|md5|sha256 <base64>") is verified before anything is stored.
+// @Tags         tus
+// @Accept       application/offset+octet-stream
+// @Param        X-API-Key        header  string  true   "Company API key"
+// @Param        Tus-Resumable    header  string  true   "1.0.0"
+// @Param        Upload-Offset    header  int     true   "Current offset"
+// @Param        Upload-Checksum  header  string  false  "Checksum of the chunk"
+// @Param        id               path    string  true   "Upload ID"
+// @Success      204
+// @Header       204  {integer}  Upload-Offset  "New offset"
+// @Failure      403  {object}  map[string]interface{} "quota_exceeded"
+// @Failure      404  {string}  string "upload not found"
+// @Failure      409  {string}  string "offset mismatch or upload being completed"
+// @Failure      410  {string}  string "upload expired"
+// @Failure      415  {string}  string "unsupported content type"
+// @Failure      423  {string}  string "upload is locked"
+// @Failure      460  {string}  string "checksum mismatch"
+// @Router       /uploader/tus/{id} [patch]
+func (h *Handler) PatchUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+
+	if !h.lock(chi.URLParam(r, "id")) {
+		http.Error(w, "upload is locked by another request", http.StatusLocked)
+		return
+	}
+	defer h.unlock(chi.URLParam(r, "id"))
+
+	upload := h.loadUpload(w, r)
+	if upload == nil {
+		return
+	}
+	if offset != upload.Offset {
+		http.Error(w, "offset mismatch", http.StatusConflict)
+		return
+	}
+	if upload.FinishedAt != nil {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if upload.Expired(time.Now()) {
+		http.Error(w, "upload expired", http.StatusGone)
+		return
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(r.Body, maxChunkSize+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(chunk) > maxChunkSize || upload.Offset+int64(len(chunk)) > upload.Length {
+		http.Error(w, "chunk exceeds the upload length or chunk size limit", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !verifyChecksum(w, r.Header.Get("Upload-Checksum"), chunk) {
+		return
+	}
+
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+
+	if len(chunk) > 0 {
+		data := append(upload.PendingPart, chunk...)
+		newOffset := upload.Offset + int64(len(chunk))
+		final := newOffset == upload.Length
+
+		parts := upload.PartsUploaded
+		for len(data) >= partSize || (final && len(data) > 0) {
+			n := min(len(data), partSize)
+			if err := h.s3Service.UploadPart(ctx, companyRec, upload.FileKey, upload.MultipartID, parts+1, data[:n]); err != nil {
+				log.Printf("tus upload %s: %v", upload.ID, err)
+				http.Error(w, "failed to store chunk", http.StatusInternalServerError)
+				return
+			}
+			parts++
+			data = data[n:]
+		}
+
+		fromOffset := upload.Offset
+		upload.Offset = newOffset
+		upload.PartsUploaded = parts
+		upload.PendingPart = data
+		advanced, err := h.repo.Advance(upload, fromOffset)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if !advanced {
+			http.Error(w, "offset mismatch", http.StatusConflict)
+			return
+		}
+	}
+
+	// A completion that failed before is retried by an empty PATCH.
+	if upload.Offset == upload.Length {
+		if !h.finish(w, r, companyRec, upload) {
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	setExpires(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setExpires sets Upload-Expires while the upload is unfinished.
+func setExpires(w http.ResponseWriter, upload *Upload) {
+	if upload.FinishedAt == nil && upload.CompletingAt == nil {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt().UTC().Format(http.TimeFormat))
+	}
+}
+
+// finish marks the upload completing, completes the multipart upload and
+// marks the file complete. A retry after a failure neither charges the
+// quota again nor completes twice. It writes the error response and returns
+// false on failure.
+func (h *Handler) finish(w http.ResponseWriter, r *http.Request, companyRec *company.Company, upload *Upload) bool {
+	ctx := r.Context()
+
+	if upload.CompletingAt == nil {
+		// Sessions created before quota was charged at creation.
+		charge := !upload.QuotaReserved
+		if charge {
+			reserved, err := h.companyRepo.ReserveQuota(companyRec.ID, upload.Length)
+			if err != nil {
+				http.Error(w, "failed to update quota", http.StatusInternalServerError)
+				return false
+			}
+			if !reserved {
+				h.abort(r, companyRec, upload)
+				writeQuotaExceeded(w, companyRec, upload.Length)
+				return false
+			}
+		}
+
+		now := time.Now()
+		marked, err := h.repo.MarkCompleting(upload.ID, now)
+		if err != nil || !marked {
+			if charge {
+				_ = h.companyRepo.DecrementUsedQuota(companyRec.ID, upload.Length)
+			}
+			if err != nil {
+				http.Error(w, "database error", http.StatusInternalServerError)
+			} else {
+				http.Error(w, "upload is being completed", http.StatusConflict)
+			}
+			return false
+		}
+		upload.CompletingAt = &now
+		upload.QuotaReserved = true
+	}
+
+	etag, err := h.s3Service.CompleteMultipartUpload(ctx, companyRec, upload.FileKey, upload.MultipartID)
+	if errors.Is(err, uploader.ErrNoSuchUpload) {
+		// An earlier attempt completed the upload and failed afterwards.
+		info, headErr := h.s3Service.HeadObject(ctx, companyRec, upload.FileKey)
+		if headErr == nil && info != nil && info.Size == upload.Length {
+			etag, err = info.ETag, nil
+		}
+	}
+	if err != nil {
+		log.Printf("tus upload %s: %v", upload.ID, err)
+		http.Error(w, "failed to complete upload", http.StatusInternalServerError)
+		return false
+	}
+
+	if err := h.fileMetaRepo.UpdateObjectStats(upload.FileMetaID, upload.Length, etag); err != nil {
+		http.Error(w, "failed to update file meta", http.StatusInternalServerError)
+		return false
+	}
+	if err := h.fileMetaRepo.SetStatus(upload.FileMetaID, filemeta.StatusComplete); err != nil {
+		http.Error(w, "failed to update file meta", http.StatusInternalServerError)
+		return false
+	}
+	if err := h.repo.MarkFinished(upload.ID, time.Now()); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// abort releases the multipart upload and the quota and drops the session.
+func (h *Handler) abort(r *http.Request, companyRec *company.Company, upload *Upload) {
+	if err := abortUpload(r.Context(), h.repo, h.companyRepo, h.fileMetaRepo, h.s3Service, companyRec, upload); err != nil {
+		log.Printf("tus upload %s: %v", upload.ID, err)
+	}
+}
+
+// TerminateUpload godoc
+// @Summary      Cancel a resumable upload (tus termination)
+// @Description  Aborts an unfinished upload and refunds its quota; for a finished upload only the session is removed, the file stays.
+// @Tags         tus
+// @Param        X-API-Key      header  string  true  "Company API key"
+// @Param        Tus-Resumable  header  string  true  "1.0.0"
+// @Param        id             path    string  true  "Upload ID"
+// @Success      204
+// @Failure      404  {string}  string "upload not found"
+// @Failure      409  {string}  string "upload is being completed"
+// @Failure      423  {string}  string "upload is locked"
+// @Router       /uploader/tus/{id} [delete]
+func (h *Handler) TerminateUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkVersion(w, r) {
+		return
+	}
+	if !h.lock(chi.URLParam(r, "id")) {
+		http.Error(w, "upload is locked by another request", http.StatusLocked)
+		return
+	}
+	defer h.unlock(chi.URLParam(r, "id"))
+
+	upload := h.loadUpload(w, r)
+	if upload == nil {
+		return
+	}
+
+	if upload.FinishedAt != nil {
+		if _, err := h.repo.Delete(upload.ID); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+	} else if upload.CompletingAt != nil {
+		http.Error(w, "upload is being completed", http.StatusConflict)
+		return
+	} else {
+		h.abort(r, company.FromContext(r.Context()), upload)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeQuotaExceeded(w http.ResponseWriter, companyRec *company.Company, requested int64) {
+	var total int64
+	if companyRec.TotalUsageQuota != nil {
+		total = *companyRec.TotalUsageQuota
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":           "quota_exceeded",
+		"total_quota":     total,
+		"used_quota":      companyRec.UsedQuota,
+		"remaining_quota": total - companyRec.UsedQuota,
+		"requested_size":  requested,
+	})
+}
@@ -0,0 +1,337 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/uploader"
+)
+
+type fakeRepo struct {
+	uploads map[string]*Upload
+}
+
+func (f *fakeRepo) Create(upload *Upload) error {
+	f.uploads[upload.ID] = upload
+	return nil
+}
+
+func (f *fakeRepo) GetByID(id string) (*Upload, error) {
+	upload, ok := f.uploads[id]
+	if !ok {
+		return nil, nil
+	}
+	c := *upload
+	return &c, nil
+}
+
+func (f *fakeRepo) Advance(upload *Upload, fromOffset int64) (bool, error) {
+	stored := f.uploads[upload.ID]
+	if stored == nil || stored.Offset != fromOffset {
+		return false, nil
+	}
+	stored.Offset = upload.Offset
+	stored.PartsUploaded = upload.PartsUploaded
+	stored.PendingPart = upload.PendingPart
+	return true, nil
+}
+
+func (f *fakeRepo) MarkCompleting(id string, at time.Time) (bool, error) {
+	stored := f.uploads[id]
+	if stored == nil || stored.CompletingAt != nil {
+		return false, nil
+	}
+	stored.CompletingAt = &at
+	stored.QuotaReserved = true
+	return true, nil
+}
+
+func (f *fakeRepo) MarkFinished(id string, finishedAt time.Time) error {
+	f.uploads[id].FinishedAt = &finishedAt
+	f.uploads[id].PendingPart = nil
+	return nil
+}
+
+func (f *fakeRepo) Delete(id string) (bool, error) {
+	_, ok := f.uploads[id]
+	delete(f.uploads, id)
+	return ok, nil
+}
+
+func (f *fakeRepo) ListCreatedBefore(t time.Time, limit int) ([]Upload, error) {
+	return nil, nil
+}
+
+type fakeCompanies struct {
+	company.Repository
+	reserveOK bool
+	reserved  int64
+	refunded  int64
+}
+
+func (f *fakeCompanies) ReserveQuota(companyID string, delta int64) (bool, error) {
+	if !f.reserveOK {
+		return false, nil
+	}
+	f.reserved += delta
+	return true, nil
+}
+
+func (f *fakeCompanies) DecrementUsedQuota(companyID string, delta int64) error {
+	f.refunded += delta
+	return nil
+}
+
+type fakeFileMetas struct {
+	filemeta.Repository
+	status string
+	etag   string
+}
+
+func (f *fakeFileMetas) UpdateObjectStats(id string, fileSize int64, etag string) error {
+	f.etag = etag
+	return nil
+}
+
+func (f *fakeFileMetas) SetStatus(id, status string) error {
+	f.status = status
+	return nil
+}
+
+type fakeS3 struct {
+	uploader.S3Service
+	parts       [][]byte
+	completeErr error
+	completes   int
+	aborted     bool
+	object      *uploader.ObjectInfo
+}
+
+func (f *fakeS3) UploadPart(ctx context.Context, companyRec *company.Company, objectKey, uploadID string, partNumber int32, data []byte) error {
+	f.parts = append(f.parts, append([]byte(nil), data...))
+	return nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey, uploadID string) (string, error) {
+	f.completes++
+	if f.completeErr != nil {
+		return "", f.completeErr
+	}
+	return "etag", nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey, uploadID string) error {
+	f.aborted = true
+	return nil
+}
+
+func (f *fakeS3) HeadObject(ctx context.Context, companyRec *company.Company, objectKey string) (*uploader.ObjectInfo, error) {
+	return f.object, nil
+}
+
+func TestPatchUpload(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		upload      Upload
+		offset      int64
+		chunk       []byte
+		reserveOK   bool
+		completeErr error
+		object      *uploader.ObjectInfo
+
+		wantStatus    int
+		wantOffset    string
+		wantParts     int
+		wantCompletes int
+		wantReserved  int64
+		wantRefunded  int64
+		wantFileState string
+		wantAborted   bool
+	}{
+		{
+			name:       "offset mismatch",
+			upload:     Upload{Length: 10, Offset: 4, QuotaReserved: true},
+			offset:     2,
+			chunk:      []byte("ab"),
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "chunk past length",
+			upload:     Upload{Length: 3, QuotaReserved: true},
+			chunk:      []byte("abcd"),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "partial chunk is kept pending",
+			upload:     Upload{Length: 10, QuotaReserved: true},
+			chunk:      []byte("abcd"),
+			wantStatus: http.StatusNoContent,
+			wantOffset: "4",
+		},
+		{
+			name:          "final chunk completes without charging again",
+			upload:        Upload{Length: 6, Offset: 4, PendingPart: []byte("abcd"), QuotaReserved: true},
+			offset:        4,
+			chunk:         []byte("ef"),
+			wantStatus:    http.StatusNoContent,
+			wantOffset:    "6",
+			wantParts:     1,
+			wantCompletes: 1,
+			wantFileState: filemeta.StatusComplete,
+		},
+		{
+			name:          "session without reserved quota is charged once",
+			upload:        Upload{Length: 2, QuotaReserved: false},
+			chunk:         []byte("ab"),
+			reserveOK:     true,
+			wantStatus:    http.StatusNoContent,
+			wantOffset:    "2",
+			wantParts:     1,
+			wantCompletes: 1,
+			wantReserved:  2,
+			wantFileState: filemeta.StatusComplete,
+		},
+		{
+			name:          "session without reserved quota over quota is aborted",
+			upload:        Upload{Length: 2, QuotaReserved: false},
+			chunk:         []byte("ab"),
+			wantStatus:    http.StatusForbidden,
+			wantParts:     1,
+			wantFileState: filemeta.StatusAborted,
+			wantAborted:   true,
+		},
+		{
+			name:          "failed completion keeps the charge",
+			upload:        Upload{Length: 2, QuotaReserved: true},
+			chunk:         []byte("ab"),
+			completeErr:   context.DeadlineExceeded,
+			wantStatus:    http.StatusInternalServerError,
+			wantParts:     1,
+			wantCompletes: 1,
+		},
+		{
+			name:          "retry after completion uses the stored object",
+			upload:        Upload{Length: 2, Offset: 2, QuotaReserved: true, CompletingAt: &earlier},
+			offset:        2,
+			completeErr:   uploader.ErrNoSuchUpload,
+			object:        &uploader.ObjectInfo{Size: 2, ETag: "stored"},
+			wantStatus:    http.StatusNoContent,
+			wantOffset:    "2",
+			wantCompletes: 1,
+			wantFileState: filemeta.StatusComplete,
+		},
+		{
+			name:          "retry without the object fails",
+			upload:        Upload{Length: 2, Offset: 2, QuotaReserved: true, CompletingAt: &earlier},
+			offset:        2,
+			completeErr:   uploader.ErrNoSuchUpload,
+			wantStatus:    http.StatusInternalServerError,
+			wantCompletes: 1,
+		},
+		{
+			name:       "finished upload reports its offset",
+			upload:     Upload{Length: 2, Offset: 2, QuotaReserved: true, FinishedAt: &earlier},
+			offset:     2,
+			wantStatus: http.StatusNoContent,
+			wantOffset: "2",
+		},
+		{
+			name:       "expired upload",
+			upload:     Upload{Length: 10, QuotaReserved: true, CreatedAt: now.Add(-SessionLifetime - time.Minute)},
+			chunk:      []byte("ab"),
+			wantStatus: http.StatusGone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			companyRec := &company.Company{ID: "c1"}
+			upload := tt.upload
+			upload.ID = "u1"
+			upload.CompanyID = companyRec.ID
+			upload.FileMetaID = "f1"
+			if upload.CreatedAt.IsZero() {
+				upload.CreatedAt = now
+			}
+			repo := &fakeRepo{uploads: map[string]*Upload{upload.ID: &upload}}
+			companies := &fakeCompanies{reserveOK: tt.reserveOK}
+			fileMetas := &fakeFileMetas{}
+			s3 := &fakeS3{completeErr: tt.completeErr, object: tt.object}
+			h := NewHandler(repo, companies, fileMetas, nil, s3)
+
+			req := httptest.NewRequest(http.MethodPatch, "/uploader/tus/u1", bytes.NewReader(tt.chunk))
+			req.Header.Set("Tus-Resumable", tusVersion)
+			req.Header.Set("Content-Type", "application/offset+octet-stream")
+			req.Header.Set("Upload-Offset", strconv.FormatInt(tt.offset, 10))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", upload.ID)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(company.NewContext(ctx, companyRec))
+
+			rec := httptest.NewRecorder()
+			h.PatchUpload(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("Upload-Offset"); got != tt.wantOffset {
+				t.Errorf("Upload-Offset = %q, want %q", got, tt.wantOffset)
+			}
+			if len(s3.parts) != tt.wantParts {
+				t.Errorf("parts uploaded = %d, want %d", len(s3.parts), tt.wantParts)
+			}
+			if s3.completes != tt.wantCompletes {
+				t.Errorf("completions = %d, want %d", s3.completes, tt.wantCompletes)
+			}
+			if companies.reserved != tt.wantReserved {
+				t.Errorf("reserved = %d, want %d", companies.reserved, tt.wantReserved)
+			}
+			if companies.refunded != tt.wantRefunded {
+				t.Errorf("refunded = %d, want %d", companies.refunded, tt.wantRefunded)
+			}
+			if fileMetas.status != tt.wantFileState {
+				t.Errorf("file status = %q, want %q", fileMetas.status, tt.wantFileState)
+			}
+			if s3.aborted != tt.wantAborted {
+				t.Errorf("aborted = %v, want %v", s3.aborted, tt.wantAborted)
+			}
+		})
+	}
+}
+
+func TestTerminateCompletingUpload(t *testing.T) {
+	now := time.Now()
+	upload := &Upload{ID: "u1", CompanyID: "c1", Length: 2, Offset: 2, QuotaReserved: true, CreatedAt: now, CompletingAt: &now}
+	repo := &fakeRepo{uploads: map[string]*Upload{upload.ID: upload}}
+	companies := &fakeCompanies{}
+	s3 := &fakeS3{}
+	h := NewHandler(repo, companies, &fakeFileMetas{}, nil, s3)
+
+	req := httptest.NewRequest(http.MethodDelete, "/uploader/tus/u1", nil)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", upload.ID)
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	req = req.WithContext(company.NewContext(ctx, &company.Company{ID: "c1"}))
+
+	rec := httptest.NewRecorder()
+	h.TerminateUpload(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if s3.aborted || companies.refunded != 0 || repo.uploads[upload.ID] == nil {
+		t.Errorf("completing upload was aborted")
+	}
+}
@@ -0,0 +1,47 @@
+package tus
+
+import "time"
+
+// SessionLifetime is how long an upload session accepts bytes after it is
+// created. Unfinished sessions are aborted by the tus sweeper after it.
+const SessionLifetime = 24 * time.Hour
+
+// Upload is a resumable upload session backed by an S3 multipart upload.
+// Bytes that do not fill a whole part yet are kept in PendingPart.
+type Upload struct {
+	ID            string    `gorm:"type:varchar(40);primaryKey;column:id"`
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime;index"`
+	CompanyID     string    `gorm:"type:varchar(40);not null;index;column:company_id"`
+	FileMetaID    string    `gorm:"type:varchar(40);not null;column:file_meta_id"`
+	FileKey       string    `gorm:"type:varchar(255);not null;column:file_key"`
+	Length        int64     `gorm:"column:upload_length;not null"`
+	Offset        int64     `gorm:"column:upload_offset;not null;default:0"`
+	MultipartID   string    `gorm:"type:varchar(255);not null;column:multipart_id"`
+	PartsUploaded int32     `gorm:"column:parts_uploaded;not null;default:0"`
+	PendingPart   []byte    `gorm:"type:mediumblob;column:pending_part"`
+	// QuotaReserved is set once Length is charged to the company's quota,
+	// which happens at creation. Sessions created before that are charged
+	// when they complete.
+	QuotaReserved bool `gorm:"column:quota_reserved;not null;default:false"`
+	// CompletingAt is set before the multipart upload is completed. From
+	// then on the session is neither charged again nor aborted, and a
+	// failed completion is retried by an empty PATCH.
+	CompletingAt *time.Time `gorm:"column:completing_at"`
+	FinishedAt   *time.Time `gorm:"column:finished_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (Upload) TableName() string {
+	return "tus_uploads"
+}
+
+// ExpiresAt is when the session stops accepting bytes.
+func (u Upload) ExpiresAt() time.Time {
+	return u.CreatedAt.Add(SessionLifetime)
+}
+
+// Expired reports whether the session is unfinished, not completing and
+// past its lifetime.
+func (u Upload) Expired(now time.Time) bool {
+	return u.FinishedAt == nil && u.CompletingAt == nil && !now.Before(u.ExpiresAt())
+}
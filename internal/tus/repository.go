@@ -0,0 +1,95 @@
+package tus
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(upload *Upload) error
+	GetByID(id string) (*Upload, error)
+	// Advance stores the upload's progress if its offset is still
+	// fromOffset, and reports whether it did.
+	Advance(upload *Upload, fromOffset int64) (bool, error)
+	// MarkCompleting marks the upload completing and its quota charged,
+	// and reports whether it was not completing yet.
+	MarkCompleting(id string, at time.Time) (bool, error)
+	MarkFinished(id string, finishedAt time.Time) error
+	// Delete removes the session and reports whether it existed.
+	Delete(id string) (bool, error)
+	// ListCreatedBefore returns the sessions created before t that are not
+	// completing, finished or not.
+	ListCreatedBefore(t time.Time, limit int) ([]Upload, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Create(upload *Upload) error {
+	return r.db.Create(upload).Error
+}
+
+func (r *repository) GetByID(id string) (*Upload, error) {
+	var upload Upload
+	if err := r.db.Where("id = ?", id).First(&upload).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *repository) Advance(upload *Upload, fromOffset int64) (bool, error) {
+	res := r.db.Model(&Upload{}).
+		Where("id = ? AND upload_offset = ?", upload.ID, fromOffset).
+		Updates(map[string]interface{}{
+			"upload_offset":  upload.Offset,
+			"parts_uploaded": upload.PartsUploaded,
+			"pending_part":   upload.PendingPart,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *repository) MarkCompleting(id string, at time.Time) (bool, error) {
+	res := r.db.Model(&Upload{}).
+		Where("id = ? AND completing_at IS NULL", id).
+		Updates(map[string]interface{}{"completing_at": at, "quota_reserved": true})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *repository) MarkFinished(id string, finishedAt time.Time) error {
+	return r.db.Model(&Upload{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"finished_at": finishedAt, "pending_part": nil}).Error
+}
+
+func (r *repository) Delete(id string) (bool, error) {
+	res := r.db.Where("id = ?", id).Delete(&Upload{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *repository) ListCreatedBefore(t time.Time, limit int) ([]Upload, error) {
+	var uploads []Upload
+	err := r.db.Where("created_at < ? AND (completing_at IS NULL OR finished_at IS NOT NULL)", t).
+		Order("created_at").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
@@ -0,0 +1,122 @@
+package tus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+const (
+	// sweepBatch is the number of sessions loaded at a time.
+	sweepBatch = 500
+	// sweepGrace lets requests accepted just before a session expired end
+	// before the session is aborted.
+	sweepGrace = time.Hour
+)
+
+// MultipartAborter aborts a multipart upload in a company's storage.
+// uploader.S3Service satisfies it.
+type MultipartAborter interface {
+	AbortMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey, uploadID string) error
+}
+
+type SweepResult struct {
+	Aborted int
+	Removed int
+	Failed  int
+}
+
+// Sweeper aborts expired upload sessions, refunding their quota, and
+// removes finished sessions past their lifetime.
+type Sweeper struct {
+	repo         Repository
+	companyRepo  company.Repository
+	fileMetaRepo filemeta.Repository
+	storage      MultipartAborter
+}
+
+func NewSweeper(repo Repository, companyRepo company.Repository, fileMetaRepo filemeta.Repository, storage MultipartAborter) *Sweeper {
+	return &Sweeper{repo: repo, companyRepo: companyRepo, fileMetaRepo: fileMetaRepo, storage: storage}
+}
+
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) (SweepResult, error) {
+	var result SweepResult
+	companies := make(map[string]*company.Company)
+	for {
+		uploads, err := s.repo.ListCreatedBefore(now.Add(-SessionLifetime-sweepGrace), sweepBatch)
+		if err != nil {
+			return result, fmt.Errorf("failed to list expired uploads: %w", err)
+		}
+		progressed := false
+		for i := range uploads {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			upload := &uploads[i]
+			if upload.FinishedAt != nil {
+				if _, err := s.repo.Delete(upload.ID); err != nil {
+					return result, err
+				}
+				result.Removed++
+				progressed = true
+				continue
+			}
+
+			companyRec, ok := companies[upload.CompanyID]
+			if !ok {
+				companyRec, err = s.companyRepo.GetByID(upload.CompanyID)
+				if err != nil {
+					return result, err
+				}
+				companies[upload.CompanyID] = companyRec
+			}
+			if companyRec == nil {
+				if _, err := s.repo.Delete(upload.ID); err != nil {
+					return result, err
+				}
+				result.Removed++
+				progressed = true
+				continue
+			}
+
+			if err := abortUpload(ctx, s.repo, s.companyRepo, s.fileMetaRepo, s.storage, companyRec, upload); err != nil {
+				log.Printf("tus sweep failed to abort upload %s: %v", upload.ID, err)
+				result.Failed++
+				continue
+			}
+			result.Aborted++
+			progressed = true
+		}
+		// Failed sessions stay listed; stop once a batch only has those.
+		if len(uploads) < sweepBatch || !progressed {
+			return result, nil
+		}
+	}
+}
+
+// abortUpload aborts the multipart upload, marks the file aborted, refunds
+// the quota charged for it and drops the session. The session is kept when
+// the multipart upload cannot be aborted, so a later sweep retries.
+func abortUpload(ctx context.Context, repo Repository, companyRepo company.Repository, fileMetaRepo filemeta.Repository, storage MultipartAborter, companyRec *company.Company, upload *Upload) error {
+	if err := storage.AbortMultipartUpload(ctx, companyRec, upload.FileKey, upload.MultipartID); err != nil {
+		return err
+	}
+	if err := fileMetaRepo.SetStatus(upload.FileMetaID, filemeta.StatusAborted); err != nil {
+		return fmt.Errorf("failed to mark file meta aborted: %w", err)
+	}
+	// Only the caller that deletes the session refunds its quota.
+	deleted, err := repo.Delete(upload.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if deleted && upload.QuotaReserved {
+		if err := companyRepo.DecrementUsedQuota(companyRec.ID, upload.Length); err != nil {
+			return fmt.Errorf("failed to refund quota: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/locpath"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const (
+	maxBatchItems = 500
+	// batchPresignWorkers bounds the concurrent presign calls of one batch.
+	batchPresignWorkers = 8
+	maxPinnedHeader     = 1024
+)
+
+type BatchUploadURLRequest struct {
+	Items []GenerateUploadURLRequest `json:"items"`
+}
+
+// BatchUploadURLItem is the result for the request item at Index; either
+// the upload fields or Error are set.
+type BatchUploadURLItem struct {
+	Index     int    `json:"index"`
+	FileID    string `json:"file_id,omitempty"`
+	FileKey   string `json:"file_key,omitempty"`
+	UploadURL string `json:"upload_url,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	// Headers must be sent with the upload.
+	Headers map[string]string `json:"headers,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type BatchUploadURLResponse struct {
+	Items     []BatchUploadURLItem `json:"items"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+}
+
+// validateUploadRequest returns the validation error of an upload request,
+// or "" if it is valid, and cleans its loc_tag.
+func validateUploadRequest(req *GenerateUploadURLRequest) string {
+	if req.LocTag == "" {
+		return "loc_tag is required"
+	}
+	locTag, err := locpath.Clean(req.LocTag)
+	if err != nil {
+		return "loc_tag: " + err.Error()
+	}
+	req.LocTag = locTag
+	if req.FileName == "" {
+		return "file_name is required"
+	}
+	if req.FileSize <= 0 {
+		return "file_size must be > 0"
+	}
+	if req.FileTxnType == 0 {
+		return "file_txn_type is required"
+	}
+	if !filemeta.IsUpload(req.FileTxnType) {
+		return "file_txn_type is reserved"
+	}
+	if _, ok := ParseUploadStorageClass(req.StorageClass); !ok {
+		return "storage_class must be STANDARD, STANDARD_IA, GLACIER_IR or DEEP_ARCHIVE"
+	}
+	if req.ExpiresIn < 0 {
+		return "expires_in must be > 0"
+	}
+	if req.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(req.ContentType); err != nil {
+			return "content_type is invalid"
+		}
+	}
+	if len(req.ContentType) > maxPinnedHeader || len(req.CacheControl) > maxPinnedHeader || len(req.ContentDisposition) > maxPinnedHeader {
+		return "pinned headers must be at most 1024 bytes"
+	}
+	if strings.ContainsAny(req.ContentType+req.CacheControl+req.ContentDisposition, "\r\n") {
+		return "pinned headers must be a single line"
+	}
+	return ""
+}
+
+// uploadOptions returns the settings of the object of a valid request.
+func uploadOptions(req GenerateUploadURLRequest, lock *ObjectLock) UploadOptions {
+	storageClass, _ := ParseUploadStorageClass(req.StorageClass)
+	return UploadOptions{
+		Lock:               lock,
+		StorageClass:       storageClass,
+		ContentType:        req.ContentType,
+		CacheControl:       req.CacheControl,
+		ContentDisposition: req.ContentDisposition,
+	}
+}
+
+// GenerateUploadURLs godoc
+// @Summary      Generate presigned upload URLs for many files
+// @Description  Reserves quota for the total size of all valid items at once, then presigns every item.
+// @Description  Each item reports its own result; quota of failed items is refunded.
+// @Tags         uploader
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string                 true  "Company API key"
+// @Param        body       body      BatchUploadURLRequest  true  "Files to upload"
+// @Success      200        {object}  BatchUploadURLResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      403        {object}  map[string]interface{} "quota_exceeded"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/batch [post]
+func (h *Handler) GenerateUploadURLs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+
+	var req BatchUploadURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, "items is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) > maxBatchItems {
+		http.Error(w, fmt.Sprintf("at most %d items per batch", maxBatchItems), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]BatchUploadURLItem, len(req.Items))
+	fileKeys := make([]string, len(req.Items))
+	tags := make([][]string, len(req.Items))
+	opts := make([]UploadOptions, len(req.Items))
+	seen := make(map[string]int)
+	var pending []int
+	var locked bool
+	now := time.Now()
+	// known caches the locations per top-level folder, extended by the
+	// items so far, so items differing only in case share a location.
+	known := make(map[string][]string)
+	for i := range req.Items {
+		results[i].Index = i
+		if msg := validateUploadRequest(&req.Items[i]); msg != "" {
+			results[i].Error = msg
+			continue
+		}
+		item := req.Items[i]
+		itemTags, err := filemeta.NormalizeTags(item.Tags)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		top := locpath.Fold(locpath.Top(item.LocTag))
+		locations, ok := known[top]
+		if !ok {
+			if locations, err = KnownLocations(h.folderRepo, h.fileMetaRepo, companyRec.ID, top); err != nil {
+				http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+				return
+			}
+		}
+		item.LocTag = locpath.Canonical(item.LocTag, locations)
+		known[top] = append(locations, item.LocTag)
+		fileKey, err := UploadKey(companyRec, item.LocTag, item.FileName)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if first, ok := seen[fileKey]; ok {
+			results[i].Error = fmt.Sprintf("same file key as item %d", first)
+			continue
+		}
+		blockMsg, err := WriteBlock(h.folderRepo, companyRec.ID, item.LocTag)
+		if err != nil {
+			http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+			return
+		}
+		if blockMsg != "" {
+			results[i].Error = blockMsg
+			continue
+		}
+		blockMsg, err = OverwriteBlock(h.fileMetaRepo, companyRec.ID, fileKey, now)
+		if err != nil {
+			http.Error(w, "failed to look up files", http.StatusInternalServerError)
+			return
+		}
+		if blockMsg != "" {
+			results[i].Error = blockMsg
+			continue
+		}
+		lock, msg, err := ResolveObjectLock(h.folderRepo, companyRec, item.LocTag, item.LockMode, item.RetainUntil, now)
+		if err != nil {
+			http.Error(w, "failed to look up folder lock policy", http.StatusInternalServerError)
+			return
+		}
+		if msg != "" {
+			results[i].Error = msg
+			continue
+		}
+		seen[fileKey] = i
+		fileKeys[i] = fileKey
+		tags[i] = itemTags
+		opts[i] = uploadOptions(item, lock)
+		locked = locked || lock != nil
+		pending = append(pending, i)
+	}
+
+	expiryLimit, err := h.uploadURLExpiryLimit(companyRec)
+	if err != nil {
+		http.Error(w, "failed to look up storage pool", http.StatusInternalServerError)
+		return
+	}
+
+	lockEnabled := false
+	if locked {
+		enabled, err := h.s3Service.ObjectLockEnabled(ctx, companyRec)
+		if err != nil {
+			http.Error(w, "failed to read object lock configuration", http.StatusInternalServerError)
+			return
+		}
+		lockEnabled = enabled
+	}
+	var totalSize int64
+	valid := pending[:0]
+	for _, i := range pending {
+		if opts[i].Lock != nil && !lockEnabled {
+			results[i].Error = "object lock is not enabled on the company bucket"
+			continue
+		}
+		valid = append(valid, i)
+		totalSize += req.Items[i].FileSize
+	}
+	pending = valid
+
+	if totalSize > 0 {
+		reserved, err := h.companyRepo.ReserveQuota(companyRec.ID, totalSize)
+		if err != nil {
+			http.Error(w, "failed to update quota", http.StatusInternalServerError)
+			return
+		}
+		if !reserved {
+			var total int64
+			if companyRec.TotalUsageQuota != nil {
+				total = *companyRec.TotalUsageQuota
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":           "quota_exceeded",
+				"total_quota":     total,
+				"used_quota":      companyRec.UsedQuota,
+				"remaining_quota": total - companyRec.UsedQuota,
+				"requested_size":  totalSize,
+			})
+			return
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		refund   int64
+		sem      = make(chan struct{}, batchPresignWorkers)
+		failItem = func(i int, msg string) {
+			mu.Lock()
+			refund += req.Items[i].FileSize
+			mu.Unlock()
+			results[i].Error = msg
+		}
+	)
+	for _, i := range pending {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			item := req.Items[i]
+			expiry := uploadURLExpiry(item.ExpiresIn, expiryLimit)
+			expiresAt := time.Now().Add(expiry)
+			uploadURL, err := h.s3Service.GeneratePresignedUploadURL(ctx, companyRec, fileKeys[i], item.FileSize, expiry, opts[i])
+			if err != nil {
+				failItem(i, "failed to generate presigned URL")
+				return
+			}
+
+			fileName := item.FileName
+			meta := &filemeta.FileMeta{
+				ID:          utils.GenerateID(),
+				FileName:    &fileName,
+				FileSize:    item.FileSize,
+				FileKey:     fileKeys[i],
+				FileTxnType: item.FileTxnType,
+				FileTxnMeta: item.FileTxnMeta,
+				CompanyID:   &companyRec.ID,
+			}
+			if lock := opts[i].Lock; lock != nil {
+				meta.LockMode = &lock.Mode
+				meta.RetainUntil = &lock.RetainUntil
+			}
+			meta.StorageClass = optionalString(opts[i].StorageClass)
+			if err := h.fileMetaRepo.CreateWithTags(meta, tags[i]); err != nil {
+				failItem(i, "failed to create file meta")
+				return
+			}
+
+			results[i].FileID = meta.ID
+			results[i].FileKey = meta.FileKey
+			results[i].UploadURL = uploadURL
+			results[i].ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+			results[i].Headers = opts[i].Headers()
+		}(i)
+	}
+	wg.Wait()
+
+	if refund > 0 {
+		if err := h.companyRepo.DecrementUsedQuota(companyRec.ID, refund); err != nil {
+			http.Error(w, "failed to update quota", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := BatchUploadURLResponse{Items: results}
+	for _, item := range results {
+		if item.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
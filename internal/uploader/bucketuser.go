@@ -0,0 +1,196 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"shreshtasmg.in/jupyter/internal/company"
+)
+
+const (
+	// bucketUserPath groups the IAM users created for dedicated buckets.
+	bucketUserPath   = "/bkps/"
+	bucketPolicyName = "bucket-access"
+	// keyPropagationWait bounds the wait for new IAM keys to be accepted.
+	keyPropagationWait = 30 * time.Second
+)
+
+// bucketPolicy allows everything on bucket and its objects except removing
+// the bucket or opening it up.
+func bucketPolicy(bucket string) (string, error) {
+	arn := "arn:aws:s3:::" + bucket
+	doc := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   "s3:*",
+				"Resource": []string{arn, arn + "/*"},
+			},
+			{
+				"Effect": "Deny",
+				"Action": []string{
+					"s3:DeleteBucket",
+					"s3:PutBucketPolicy",
+					"s3:DeleteBucketPolicy",
+					"s3:PutBucketPublicAccessBlock",
+					"s3:PutBucketAcl",
+				},
+				"Resource": arn,
+			},
+		},
+	}
+	out, err := json.Marshal(doc)
+	return string(out), err
+}
+
+// buildIAMClient returns an IAM client with the keys of admin.
+func (s *s3Service) buildIAMClient(ctx context.Context, admin *company.Company) (*iam.Client, error) {
+	accessKey, secretKey, err := s.credentials(admin)
+	if err != nil {
+		return nil, err
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(
+		ctx,
+		awsconfig.WithRegion(*admin.AwsBucketRegion),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return iam.NewFromConfig(awsCfg), nil
+}
+
+// createBucketUser creates the IAM user of the company's bucket with the
+// keys of admin and hands its keys to companyRec. admin records the user as
+// soon as it exists, so DeprovisionBucket can remove it.
+func (s *s3Service) createBucketUser(ctx context.Context, companyRec, admin *company.Company) error {
+	client, err := s.buildIAMClient(ctx, admin)
+	if err != nil {
+		return err
+	}
+	// Bucket names are valid IAM user names and unique.
+	userName := *companyRec.AwsBucketName
+	if _, err := client.CreateUser(ctx, &iam.CreateUserInput{
+		UserName: aws.String(userName),
+		Path:     aws.String(bucketUserPath),
+		Tags:     []iamtypes.Tag{{Key: aws.String("company-id"), Value: aws.String(companyRec.ID)}},
+	}); err != nil {
+		return fmt.Errorf("failed to create IAM user: %w", err)
+	}
+	admin.AwsIAMUser = &userName
+
+	policy, err := bucketPolicy(userName)
+	if err != nil {
+		return err
+	}
+	if _, err := client.PutUserPolicy(ctx, &iam.PutUserPolicyInput{
+		UserName:       aws.String(userName),
+		PolicyName:     aws.String(bucketPolicyName),
+		PolicyDocument: aws.String(policy),
+	}); err != nil {
+		return fmt.Errorf("failed to attach IAM policy: %w", err)
+	}
+
+	out, err := client.CreateAccessKey(ctx, &iam.CreateAccessKeyInput{UserName: aws.String(userName)})
+	if err != nil {
+		return fmt.Errorf("failed to create IAM access key: %w", err)
+	}
+	accessKey, secretKey := *out.AccessKey.AccessKeyId, *out.AccessKey.SecretAccessKey
+	s.awaitCredentials(ctx, *companyRec.AwsBucketRegion, *companyRec.AwsBucketName, accessKey, secretKey)
+
+	sealedAccessKey, err := s.keyring.Seal(accessKey)
+	if err != nil {
+		return err
+	}
+	sealedSecretKey, err := s.keyring.Seal(secretKey)
+	if err != nil {
+		return err
+	}
+	companyRec.AwsAccessKey = &sealedAccessKey
+	companyRec.AwsSecretKey = &sealedSecretKey
+	companyRec.AwsIAMUser = &userName
+	return nil
+}
+
+// awaitCredentials waits until new IAM keys are accepted for bucket, which
+// takes a few seconds. It gives up after keyPropagationWait; the first
+// requests of the company may then fail until they are.
+func (s *s3Service) awaitCredentials(ctx context.Context, region, bucket, accessKey, secretKey string) {
+	deadline := time.Now().Add(keyPropagationWait)
+	for delay := time.Second; ; delay *= 2 {
+		err := s.CheckCredentials(ctx, region, bucket, accessKey, secretKey)
+		if err == nil {
+			return
+		}
+		if time.Now().Add(delay).After(deadline) {
+			log.Printf("IAM keys for bucket %s are not accepted yet: %v", bucket, err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (s *s3Service) DeprovisionBucket(ctx context.Context, companyRec *company.Company, pool *UploaderConfig) error {
+	admin := withPoolCredentials(companyRec, pool)
+	var errs []error
+	if companyRec.AwsIAMUser != nil {
+		errs = append(errs, s.deleteBucketUser(ctx, admin, *companyRec.AwsIAMUser))
+	}
+
+	client, err := s.buildS3Client(ctx, admin)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	if _, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: companyRec.AwsBucketName}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete bucket: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// deleteBucketUser deletes the IAM user with its keys and policy.
+func (s *s3Service) deleteBucketUser(ctx context.Context, admin *company.Company, userName string) error {
+	client, err := s.buildIAMClient(ctx, admin)
+	if err != nil {
+		return err
+	}
+
+	keys, err := client.ListAccessKeys(ctx, &iam.ListAccessKeysInput{UserName: aws.String(userName)})
+	if err != nil {
+		return fmt.Errorf("failed to list IAM access keys: %w", err)
+	}
+	for _, key := range keys.AccessKeyMetadata {
+		if _, err := client.DeleteAccessKey(ctx, &iam.DeleteAccessKeyInput{
+			UserName:    aws.String(userName),
+			AccessKeyId: key.AccessKeyId,
+		}); err != nil {
+			return fmt.Errorf("failed to delete IAM access key: %w", err)
+		}
+	}
+
+	var noSuchEntity *iamtypes.NoSuchEntityException
+	if _, err := client.DeleteUserPolicy(ctx, &iam.DeleteUserPolicyInput{
+		UserName:   aws.String(userName),
+		PolicyName: aws.String(bucketPolicyName),
+	}); err != nil && !errors.As(err, &noSuchEntity) {
+		return fmt.Errorf("failed to delete IAM policy: %w", err)
+	}
+	if _, err := client.DeleteUser(ctx, &iam.DeleteUserInput{UserName: aws.String(userName)}); err != nil {
+		return fmt.Errorf("failed to delete IAM user: %w", err)
+	}
+	return nil
+}
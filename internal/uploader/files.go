@@ -0,0 +1,302 @@
+package uploader
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const (
+	maxFileDescription = 1024
+	// maxFileMetadata bounds the size of the compacted custom metadata.
+	maxFileMetadata = 16 << 10
+)
+
+// FileObjectStats are the live stats of the stored object.
+type FileObjectStats struct {
+	Exists       bool   `json:"exists"`
+	Size         int64  `json:"size,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// StorageClass is the current class, after lifecycle transitions.
+	StorageClass string `json:"storage_class,omitempty"`
+	// RestoreRequired is set for archived objects without a restored copy.
+	RestoreRequired bool `json:"restore_required,omitempty"`
+}
+
+type FileDetailResponse struct {
+	ID          string          `json:"id"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	FileName    *string         `json:"file_name,omitempty"`
+	FileSize    int64           `json:"file_size"`
+	FileKey     string          `json:"file_key"`
+	LocTag      *string         `json:"loc_tag,omitempty"`
+	FileTxnType int16           `json:"file_txn_type"`
+	FileTxnMeta *string         `json:"file_txn_meta,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty" swaggertype:"object"`
+	ETag        *string         `json:"etag,omitempty"`
+	Tags        []string        `json:"tags"`
+	LockMode    *string         `json:"lock_mode,omitempty"`
+	RetainUntil *string         `json:"retain_until,omitempty"`
+	LegalHold   bool            `json:"legal_hold"`
+	// StorageClass is the class the file was uploaded with.
+	StorageClass string `json:"storage_class"`
+	// Object is only returned when fetching a file.
+	Object *FileObjectStats `json:"object,omitempty"`
+}
+
+// UpdateFileRequest changes the fields that are set. Empty strings clear
+// description and file_txn_meta, a null metadata clears the metadata.
+type UpdateFileRequest struct {
+	FileName    *string         `json:"file_name,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty" swaggertype:"object"` // JSON object
+	FileTxnMeta *string         `json:"file_txn_meta,omitempty"`
+}
+
+func (h *Handler) toFileDetail(f filemeta.File) (FileDetailResponse, error) {
+	tags, err := h.fileMetaRepo.TagsByFileIDs([]string{f.FileMetaID})
+	if err != nil {
+		return FileDetailResponse{}, err
+	}
+	resp := FileDetailResponse{
+		ID:           f.FileMetaID,
+		CreatedAt:    f.CreatedAt.Format(time.RFC3339Nano),
+		UpdatedAt:    f.UpdatedAt.Format(time.RFC3339Nano),
+		FileName:     f.FileName,
+		FileSize:     f.FileSize,
+		FileKey:      f.FileKey,
+		LocTag:       f.LocTag,
+		FileTxnType:  f.FileTxnType,
+		FileTxnMeta:  f.FileTxnMeta,
+		Description:  f.Description,
+		ETag:         f.ETag,
+		Tags:         tags[f.FileMetaID],
+		LockMode:     f.LockMode,
+		LegalHold:    f.LegalHold,
+		StorageClass: storageClassName(aws.ToString(f.StorageClass)),
+	}
+	if f.RetainUntil != nil {
+		retainUntil := f.RetainUntil.Format(time.RFC3339)
+		resp.RetainUntil = &retainUntil
+	}
+	if f.Metadata != nil {
+		resp.Metadata = json.RawMessage(*f.Metadata)
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	return resp, nil
+}
+
+// currentFile loads the file created by the upload of the id URL param. It
+// answers 404 for unknown files and 410 for deleted or replaced ones, and
+// returns nil if a response was written.
+func (h *Handler) currentFile(w http.ResponseWriter, r *http.Request, companyRec *company.Company) *filemeta.File {
+	meta, err := h.fileMetaRepo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+		return nil
+	}
+	if meta == nil || meta.CompanyID == nil || *meta.CompanyID != companyRec.ID || !filemeta.IsUpload(meta.FileTxnType) || !meta.Completed() {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return nil
+	}
+
+	file, err := h.fileMetaRepo.GetFileByID(companyRec.ID, meta.ID)
+	if err != nil {
+		http.Error(w, "failed to look up file", http.StatusInternalServerError)
+		return nil
+	}
+	if file == nil {
+		http.Error(w, "file was deleted or replaced", http.StatusGone)
+		return nil
+	}
+	return file
+}
+
+// optionalString returns the cleared or new value of an optional field.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// GetFile godoc
+// @Summary      Get a file
+// @Description  Returns the current metadata of the file uploaded as id, with live stats of the stored object.
+// @Tags         uploader
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "File ID"
+// @Success      200        {object}  FileDetailResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/{id} [get]
+func (h *Handler) GetFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+	file := h.currentFile(w, r, companyRec)
+	if file == nil {
+		return
+	}
+
+	resp, err := h.toFileDetail(*file)
+	if err != nil {
+		http.Error(w, "failed to load tags", http.StatusInternalServerError)
+		return
+	}
+	info, err := h.s3Service.HeadObject(ctx, companyRec, file.FileKey)
+	if err != nil {
+		http.Error(w, "failed to read object stats", http.StatusInternalServerError)
+		return
+	}
+	resp.Object = &FileObjectStats{}
+	if info != nil {
+		resp.Object = &FileObjectStats{
+			Exists:          true,
+			Size:            info.Size,
+			ETag:            info.ETag,
+			ContentType:     info.ContentType,
+			LastModified:    info.LastModified.Format(time.RFC3339),
+			StorageClass:    storageClassName(info.StorageClass),
+			RestoreRequired: !info.Readable(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// UpdateFile godoc
+// @Summary      Update a file's metadata
+// @Description  Changes the display name, description, custom metadata or file_txn_meta of a file. The object key is unchanged.
+// @Description  Every change is recorded as a files_meta transaction with file_txn_type=-1.
+// @Tags         uploader
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string             true  "Company API key"
+// @Param        id         path      string             true  "File ID"
+// @Param        body       body      UpdateFileRequest  true  "Metadata changes"
+// @Success      200        {object}  FileDetailResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/{id} [patch]
+func (h *Handler) UpdateFile(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	file := h.currentFile(w, r, companyRec)
+	if file == nil {
+		return
+	}
+
+	var req UpdateFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.FileName == nil && req.Description == nil && req.Metadata == nil && req.FileTxnMeta == nil {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	meta := &filemeta.FileMeta{
+		ID:          utils.GenerateID(),
+		FileName:    file.FileName,
+		FileKey:     file.FileKey,
+		FileTxnType: filemeta.TxnMetadataUpdate,
+		FileTxnMeta: file.FileTxnMeta,
+		Description: file.Description,
+		Metadata:    file.Metadata,
+		CompanyID:   &companyRec.ID,
+	}
+	if req.FileName != nil {
+		name := strings.TrimSpace(*req.FileName)
+		if name == "" || len(name) > 255 {
+			http.Error(w, "file_name must be 1 to 255 bytes", http.StatusBadRequest)
+			return
+		}
+		meta.FileName = &name
+	}
+	if req.Description != nil {
+		if len(*req.Description) > maxFileDescription {
+			http.Error(w, "description is too long", http.StatusBadRequest)
+			return
+		}
+		meta.Description = optionalString(*req.Description)
+	}
+	if req.FileTxnMeta != nil {
+		if len(*req.FileTxnMeta) > 255 {
+			http.Error(w, "file_txn_meta is too long", http.StatusBadRequest)
+			return
+		}
+		meta.FileTxnMeta = optionalString(*req.FileTxnMeta)
+	}
+	if req.Metadata != nil {
+		if string(req.Metadata) == "null" {
+			meta.Metadata = nil
+		} else {
+			var object map[string]interface{}
+			var compacted bytes.Buffer
+			if json.Unmarshal(req.Metadata, &object) != nil || json.Compact(&compacted, req.Metadata) != nil {
+				http.Error(w, "metadata must be a JSON object", http.StatusBadRequest)
+				return
+			}
+			if compacted.Len() > maxFileMetadata {
+				http.Error(w, "metadata is too large", http.StatusBadRequest)
+				return
+			}
+			meta.Metadata = optionalString(compacted.String())
+		}
+	}
+
+	changed := !sameString(meta.FileName, file.FileName) ||
+		!sameString(meta.Description, file.Description) ||
+		!sameString(meta.FileTxnMeta, file.FileTxnMeta) ||
+		!sameString(meta.Metadata, file.Metadata)
+	if changed {
+		if err := h.fileMetaRepo.Create(meta); err != nil {
+			http.Error(w, "failed to create file meta", http.StatusInternalServerError)
+			return
+		}
+		file.FileName = meta.FileName
+		file.Description = meta.Description
+		file.FileTxnMeta = meta.FileTxnMeta
+		file.Metadata = meta.Metadata
+		file.UpdatedAt = meta.CreatedAt
+	}
+
+	resp, err := h.toFileDetail(*file)
+	if err != nil {
+		http.Error(w, "failed to load tags", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
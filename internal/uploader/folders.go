@@ -0,0 +1,673 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/locpath"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+const maxFolderDescription = 1024
+
+type CreateFolderRequest struct {
+	Path        string  `json:"path"` // loc_tag of the folder, e.g. "reports/2025"
+	Description *string `json:"description,omitempty"`
+	Owner       *string `json:"owner,omitempty"`
+}
+
+// UpdateFolderRequest changes the fields that are set; an empty string
+// clears a field.
+type UpdateFolderRequest struct {
+	Description *string `json:"description,omitempty"`
+	Owner       *string `json:"owner,omitempty"`
+}
+
+type FolderResponse struct {
+	ID          string  `json:"id"`
+	CreatedAt   string  `json:"created_at"`
+	Path        string  `json:"path"`
+	Description *string `json:"description,omitempty"`
+	Owner       *string `json:"owner,omitempty"`
+	TrashedAt   *string `json:"trashed_at,omitempty"`
+	// PurgeAt is when a trashed folder is deleted permanently.
+	PurgeAt   *string `json:"purge_at,omitempty"`
+	UpdatedAt string  `json:"updated_at"`
+	// Object Lock policy of uploads to the folder.
+	LockMode          *string `json:"lock_mode,omitempty"`
+	LockRetentionDays *int    `json:"lock_retention_days,omitempty"`
+}
+
+// DeleteFolderObjectResponse reports a folder moved to the trash, or the
+// objects removed when it was deleted permanently.
+type DeleteFolderObjectResponse struct {
+	Folder       FolderResponse `json:"folder"`
+	Permanent    bool           `json:"permanent"`
+	DeletedCount int            `json:"deleted_count"`
+	DeletedBytes int64          `json:"deleted_bytes"`
+}
+
+// FolderChild is a direct sub folder; Folder is set if it has a folder
+// resource.
+type FolderChild struct {
+	Name   string          `json:"name"`
+	Path   string          `json:"path"`
+	Folder *FolderResponse `json:"folder,omitempty"`
+}
+
+type ListFolderChildrenResponse struct {
+	Path    string                `json:"path"`
+	Folders []FolderChild         `json:"folders"`
+	Files   []CompanyFileMetaItem `json:"files"`
+}
+
+type ListTrashedFoldersResponse struct {
+	Items []FolderResponse `json:"items"`
+}
+
+func toFolderResponse(f folder.Folder) FolderResponse {
+	resp := FolderResponse{
+		ID:          f.ID,
+		CreatedAt:   f.CreatedAt.Format(time.RFC3339),
+		Path:        f.Path,
+		Description: f.Description,
+		Owner:       f.Owner,
+		UpdatedAt:   f.UpdatedAt.Format(time.RFC3339),
+
+		LockMode:          f.LockMode,
+		LockRetentionDays: f.LockRetentionDays,
+	}
+	if f.TrashedAt != nil {
+		trashedAt := f.TrashedAt.Format(time.RFC3339)
+		purgeAt := f.TrashedAt.Add(TrashRetention).Format(time.RFC3339)
+		resp.TrashedAt = &trashedAt
+		resp.PurgeAt = &purgeAt
+	}
+	return resp
+}
+
+// validateFolderFields returns the validation error of the folder metadata,
+// or "" if it is valid.
+func validateFolderFields(description, owner *string) string {
+	if description != nil && len(*description) > maxFolderDescription {
+		return "description is too long"
+	}
+	if owner != nil && len(*owner) > 255 {
+		return "owner is too long"
+	}
+	return ""
+}
+
+// emptyToNil turns a cleared field into NULL.
+func emptyToNil(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
+
+// companyFolder loads the folder of the id URL param, answering 404 if it
+// does not belong to companyRec. It returns nil if a response was written.
+func (h *Handler) companyFolder(w http.ResponseWriter, r *http.Request, companyRec *company.Company) *folder.Folder {
+	f, err := h.folderRepo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "failed to look up folder", http.StatusInternalServerError)
+		return nil
+	}
+	if f == nil || f.CompanyID != companyRec.ID {
+		http.Error(w, "folder not found", http.StatusNotFound)
+		return nil
+	}
+	return f
+}
+
+// WriteBlock returns why files cannot be uploaded to the folder locTag, or
+// "": the folder or a parent is in the trash, or a folder rename holds it.
+func WriteBlock(folderRepo folder.Repository, companyID, locTag string) (string, error) {
+	if locTag == "" {
+		return "", nil
+	}
+	trashed, err := folderRepo.InTrash(companyID, locTag)
+	if err != nil {
+		return "", err
+	}
+	if trashed {
+		return "folder is in the trash", nil
+	}
+	return renameBlock(folderRepo, companyID, locTag)
+}
+
+// renameBlock returns why path cannot change, or "" unless an unfinished
+// folder rename holds it.
+func renameBlock(folderRepo folder.Repository, companyID, path string) (string, error) {
+	rename, err := folderRepo.RenameLocking(companyID, path)
+	if err != nil {
+		return "", err
+	}
+	if rename != nil {
+		return "folder " + rename.FromPath + " is being renamed to " + rename.ToPath, nil
+	}
+	return "", nil
+}
+
+// CheckWritable answers 409 and returns false if files cannot be uploaded
+// to locTag, see WriteBlock.
+func CheckWritable(w http.ResponseWriter, folderRepo folder.Repository, companyID, locTag string) bool {
+	msg, err := WriteBlock(folderRepo, companyID, locTag)
+	if err != nil {
+		http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+		return false
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// checkNotRenaming answers 409 and returns false if a folder rename holds
+// path.
+func (h *Handler) checkNotRenaming(w http.ResponseWriter, companyID, path string) bool {
+	msg, err := renameBlock(h.folderRepo, companyID, path)
+	if err != nil {
+		http.Error(w, "failed to look up folder renames", http.StatusInternalServerError)
+		return false
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// locationFilter cleans the optional location filter value of the query
+// parameter param and respells it like the existing location. It answers
+// 400 or 500 and returns false on failure.
+func (h *Handler) locationFilter(w http.ResponseWriter, companyID, param, value string) (string, bool) {
+	path, err := locpath.CleanOptional(value)
+	if err != nil {
+		http.Error(w, param+": "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	if path == "" {
+		return "", true
+	}
+	if path, err = CanonicalLocTag(h.folderRepo, h.fileMetaRepo, companyID, path); err != nil {
+		http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+		return "", false
+	}
+	return path, true
+}
+
+// childFolders returns the direct sub folders of parent ("" for the top
+// level), from both folder resources and the loc_tags of current files.
+// Folders in the trash are left out.
+func (h *Handler) childFolders(companyID, parent string) ([]FolderChild, error) {
+	locTags, err := h.fileMetaRepo.ListFolderLocTags(companyID, parent)
+	if err != nil {
+		return nil, err
+	}
+	live, err := h.folderRepo.ListByCompanyID(companyID, false)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := h.folderRepo.TrashedPaths(companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make(map[string]folder.Folder, len(live))
+	paths := locTags
+	for _, f := range live {
+		resources[f.Path] = f
+		paths = append(paths, f.Path)
+	}
+
+	children := []FolderChild{}
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		rest := path
+		if parent != "" {
+			var ok bool
+			if rest, ok = strings.CutPrefix(path, parent+"/"); !ok {
+				continue
+			}
+		}
+		name, _, _ := strings.Cut(rest, "/")
+		childPath := name
+		if parent != "" {
+			childPath = parent + "/" + name
+		}
+		if seen[childPath] || folder.WithinAny(childPath, trashed) {
+			continue
+		}
+		seen[childPath] = true
+
+		child := FolderChild{Name: name, Path: childPath}
+		if f, ok := resources[childPath]; ok {
+			resp := toFolderResponse(f)
+			child.Folder = &resp
+		}
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Path < children[j].Path })
+	return children, nil
+}
+
+// purgeFolder deletes every object under locTag, records the folder delete
+// and refunds the deleted bytes. It returns false if a response was written.
+func (h *Handler) purgeFolder(ctx context.Context, w http.ResponseWriter, companyRec *company.Company, locTag string, files []filemeta.File, txnMeta *string) (*DeleteFolderResponse, bool) {
+	blocked, err := h.writeRetentionBlock(w, companyRec, files)
+	if err != nil {
+		http.Error(w, "failed to check retention rules", http.StatusInternalServerError)
+		return nil, false
+	}
+	if blocked {
+		return nil, false
+	}
+
+	deletedCount, deletedBytes, err := deleteFolderObjects(ctx, h.s3Service, h.fileMetaRepo, h.companyRepo, companyRec, locTag, txnMeta)
+	if err != nil {
+		log.Printf("delete folder %s of company %s: %v", locTag, companyRec.ID, err)
+		http.Error(w, "failed to delete folder", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return &DeleteFolderResponse{
+		FolderPrefix: locTag,
+		DeletedCount: deletedCount,
+		DeletedBytes: deletedBytes,
+	}, true
+}
+
+// CreateFolder godoc
+// @Summary      Create a folder
+// @Description  Creates a folder resource and its placeholder object, so the folder exists while it is empty.
+// @Description  Creating a folder at a path that already holds files adopts it.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string               true  "Company API key"
+// @Param        body       body      CreateFolderRequest  true  "Folder"
+// @Success      201        {object}  FolderResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      409        {string}  string "folder already exists, parent in trash or being renamed"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders [post]
+func (h *Handler) CreateFolder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+
+	var req CreateFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	path, err := locpath.Clean(req.Path)
+	if err != nil {
+		http.Error(w, "path: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if path, err = CanonicalLocTag(h.folderRepo, h.fileMetaRepo, companyRec.ID, path); err != nil {
+		http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+		return
+	}
+	folderKey, err := FolderKey(companyRec, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msg := validateFolderFields(req.Description, req.Owner); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.folderRepo.GetByPath(companyRec.ID, path)
+	if err != nil {
+		http.Error(w, "failed to look up folder", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		if existing.Trashed() {
+			http.Error(w, "folder is in the trash, restore it instead", http.StatusConflict)
+			return
+		}
+		http.Error(w, "folder already exists", http.StatusConflict)
+		return
+	}
+	trashed, err := h.folderRepo.InTrash(companyRec.ID, path)
+	if err != nil {
+		http.Error(w, "failed to look up folder", http.StatusInternalServerError)
+		return
+	}
+	if trashed {
+		http.Error(w, "a parent folder is in the trash", http.StatusConflict)
+		return
+	}
+	if !h.checkNotRenaming(w, companyRec.ID, path) {
+		return
+	}
+
+	if err := h.s3Service.PutFolderPlaceholder(ctx, companyRec, folderKey); err != nil {
+		http.Error(w, "failed to create folder in storage", http.StatusInternalServerError)
+		return
+	}
+
+	f := &folder.Folder{
+		ID:          utils.GenerateID(),
+		CompanyID:   companyRec.ID,
+		Path:        path,
+		Description: emptyToNil(req.Description),
+		Owner:       emptyToNil(req.Owner),
+	}
+	if err := h.folderRepo.Create(f); err != nil {
+		http.Error(w, "failed to create folder", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(toFolderResponse(*f))
+}
+
+// GetFolder godoc
+// @Summary      Get a folder
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "Folder ID"
+// @Success      200        {object}  FolderResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder not found"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/{id} [get]
+func (h *Handler) GetFolder(w http.ResponseWriter, r *http.Request) {
+	f := h.companyFolder(w, r, company.FromContext(r.Context()))
+	if f == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toFolderResponse(*f))
+}
+
+// UpdateFolder godoc
+// @Summary      Describe a folder
+// @Description  Sets the description and owner of a folder. Omitted fields are kept, empty strings clear them.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string               true  "Company API key"
+// @Param        id         path      string               true  "Folder ID"
+// @Param        body       body      UpdateFolderRequest  true  "Folder metadata"
+// @Success      200        {object}  FolderResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder not found"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/{id} [patch]
+func (h *Handler) UpdateFolder(w http.ResponseWriter, r *http.Request) {
+	f := h.companyFolder(w, r, company.FromContext(r.Context()))
+	if f == nil {
+		return
+	}
+
+	var req UpdateFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if msg := validateFolderFields(req.Description, req.Owner); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.Description != nil {
+		f.Description = emptyToNil(req.Description)
+	}
+	if req.Owner != nil {
+		f.Owner = emptyToNil(req.Owner)
+	}
+	if err := h.folderRepo.Update(f); err != nil {
+		http.Error(w, "failed to update folder", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toFolderResponse(*f))
+}
+
+// ListFolderChildren godoc
+// @Summary      List the contents of a folder
+// @Description  Returns the direct sub folders (folder resources and folders implied by file loc_tags) and the files of a folder.
+// @Description  Folders in the trash are left out.
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true   "Company API key"
+// @Param        path       query     string  false  "Folder path (default: top level)"
+// @Success      200        {object}  ListFolderChildrenResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder is in the trash"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/children [get]
+func (h *Handler) ListFolderChildren(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	path, ok := h.locationFilter(w, companyRec.ID, "path", r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+
+	resp := ListFolderChildrenResponse{Path: path, Files: []CompanyFileMetaItem{}}
+	if path != "" {
+		trashed, err := h.folderRepo.InTrash(companyRec.ID, path)
+		if err != nil {
+			http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+			return
+		}
+		if trashed {
+			http.Error(w, "folder is in the trash", http.StatusNotFound)
+			return
+		}
+
+		files, err := h.fileMetaRepo.ListFiles(companyRec.ID, filemeta.FolderKeyPrefix(companyRec.CompanySlug, path))
+		if err != nil {
+			http.Error(w, "failed to list file meta", http.StatusInternalServerError)
+			return
+		}
+		for _, f := range files {
+			if f.LocTag == nil || *f.LocTag != path {
+				continue
+			}
+			resp.Files = append(resp.Files, CompanyFileMetaItem{
+				ID:          f.FileMetaID,
+				CreatedAt:   f.CreatedAt.Format(time.RFC3339Nano),
+				FileName:    f.FileName,
+				FileSize:    f.FileSize,
+				FileKey:     f.FileKey,
+				LocTag:      f.LocTag,
+				FileTxnType: f.FileTxnType,
+				FileTxnMeta: f.FileTxnMeta,
+			})
+		}
+	}
+
+	children, err := h.childFolders(companyRec.ID, path)
+	if err != nil {
+		http.Error(w, "failed to list folders", http.StatusInternalServerError)
+		return
+	}
+	resp.Folders = children
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ListTrashedFolders godoc
+// @Summary      List the folders in the trash
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Success      200        {object}  ListTrashedFoldersResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/trash [get]
+func (h *Handler) ListTrashedFolders(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+
+	folders, err := h.folderRepo.ListByCompanyID(companyRec.ID, true)
+	if err != nil {
+		http.Error(w, "failed to list folders", http.StatusInternalServerError)
+		return
+	}
+	resp := ListTrashedFoldersResponse{Items: make([]FolderResponse, 0, len(folders))}
+	for _, f := range folders {
+		resp.Items = append(resp.Items, toFolderResponse(f))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteFolderObject godoc
+// @Summary      Delete a folder
+// @Description  Moves the folder to the trash; its files are kept and still count against the quota until it is deleted permanently, by permanent=true or 30 days later by the trash purger.
+// @Description  Files in the trash are left out of listings, search, downloads and share links, and cannot be uploaded to.
+// @Description  permanent=true deletes every object under the folder and its folder resources, also for a folder already in the trash.
+// @Description  A folder holding files or sub folders is only deleted with recursive=true.
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true   "Company API key"
+// @Param        id         path      string  true   "Folder ID"
+// @Param        recursive  query     bool    false  "Also delete the folder contents"
+// @Param        permanent  query     bool    false  "Delete instead of moving to the trash"
+// @Success      200        {object}  DeleteFolderObjectResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder not found"
+// @Failure      409        {object}  map[string]interface{} "folder not empty, already in trash, being renamed, retention_active or object_locked"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/{id} [delete]
+func (h *Handler) DeleteFolderObject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+	f := h.companyFolder(w, r, companyRec)
+	if f == nil {
+		return
+	}
+	recursive := r.URL.Query().Get("recursive") == "true"
+	permanent := r.URL.Query().Get("permanent") == "true"
+	if !h.checkNotRenaming(w, companyRec.ID, f.Path) {
+		return
+	}
+
+	files, err := h.fileMetaRepo.ListFiles(companyRec.ID, filemeta.FolderKeyPrefix(companyRec.CompanySlug, f.Path))
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+		return
+	}
+	tree, err := h.folderRepo.ListTree(companyRec.ID, f.Path)
+	if err != nil {
+		http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+		return
+	}
+	if !recursive && (len(files) > 0 || len(tree) > 1) {
+		http.Error(w, "folder is not empty, pass recursive=true to delete its contents", http.StatusConflict)
+		return
+	}
+
+	resp := DeleteFolderObjectResponse{Permanent: permanent}
+	if permanent {
+		deleted, ok := h.purgeFolder(ctx, w, companyRec, f.Path, files, nil)
+		if !ok {
+			return
+		}
+		if err := h.folderRepo.DeleteTree(companyRec.ID, f.Path); err != nil {
+			http.Error(w, "failed to delete folders", http.StatusInternalServerError)
+			return
+		}
+		resp.DeletedCount = deleted.DeletedCount
+		resp.DeletedBytes = deleted.DeletedBytes
+	} else {
+		if f.Trashed() {
+			http.Error(w, "folder is already in the trash", http.StatusConflict)
+			return
+		}
+		if err := h.folderRepo.Trash(companyRec.ID, f.Path, time.Now()); err != nil {
+			http.Error(w, "failed to move folder to the trash", http.StatusInternalServerError)
+			return
+		}
+		if f, err = h.folderRepo.GetByID(f.ID); err != nil || f == nil {
+			http.Error(w, "failed to look up folder", http.StatusInternalServerError)
+			return
+		}
+	}
+	resp.Folder = toFolderResponse(*f)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// RestoreFolder godoc
+// @Summary      Restore a folder from the trash
+// @Description  Restores the folder with the sub folders that were trashed along with it.
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "Folder ID"
+// @Success      200        {object}  FolderResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder not found"
+// @Failure      409        {string}  string "folder not in trash, parent in trash or being renamed"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/{id}/restore [post]
+func (h *Handler) RestoreFolder(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	f := h.companyFolder(w, r, companyRec)
+	if f == nil {
+		return
+	}
+	if !f.Trashed() {
+		http.Error(w, "folder is not in the trash", http.StatusConflict)
+		return
+	}
+
+	if i := strings.LastIndex(f.Path, "/"); i > 0 {
+		trashed, err := h.folderRepo.InTrash(companyRec.ID, f.Path[:i])
+		if err != nil {
+			http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+			return
+		}
+		if trashed {
+			http.Error(w, "a parent folder is in the trash, restore it first", http.StatusConflict)
+			return
+		}
+	}
+	if !h.checkNotRenaming(w, companyRec.ID, f.Path) {
+		return
+	}
+
+	if err := h.folderRepo.Restore(companyRec.ID, f.Path, *f.TrashedAt); err != nil {
+		http.Error(w, "failed to restore folder", http.StatusInternalServerError)
+		return
+	}
+	f.TrashedAt = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toFolderResponse(*f))
+}
@@ -2,7 +2,7 @@ package uploader
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"path/filepath"
@@ -13,10 +13,13 @@ import (
 	"shreshtasmg.in/jupyter/internal/company"
 	"shreshtasmg.in/jupyter/internal/config"
 	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/retention"
 	"shreshtasmg.in/jupyter/internal/utils"
 )
 
 type CreateUploaderConfigRequest struct {
+	Name            string `json:"name"` // pool name, defaults to the bucket name
 	AwsBucketName   string `json:"aws_bucket_name"`
 	AwsBucketRegion string `json:"aws_bucket_region"`
 	AwsAccessKey    string `json:"aws_access_key"`
@@ -24,6 +27,8 @@ type CreateUploaderConfigRequest struct {
 	TotalQuota      *int64 `json:"total_quota,omitempty"`
 	DefaultQuota    *int64 `json:"default_quota,omitempty"`
 	IsActive        *int16 `json:"is_active,omitempty"`
+	// MaxUploadURLExpiry caps presigned upload URLs of the pool, in seconds.
+	MaxUploadURLExpiry *int `json:"max_upload_url_expiry,omitempty"`
 }
 
 type CreateUploaderConfigResponse struct {
@@ -31,11 +36,25 @@ type CreateUploaderConfigResponse struct {
 }
 
 type GenerateUploadURLRequest struct {
-	LocTag      string  `json:"loc_tag"`
-	FileName    string  `json:"file_name"`               // required
-	FileSize    int64   `json:"file_size"`               // required
-	FileTxnType int16   `json:"file_txn_type"`           // required (e.g. 1=upload)
-	FileTxnMeta *string `json:"file_txn_meta,omitempty"` // optional
+	LocTag      string   `json:"loc_tag"`                 // required, folder path e.g. "reports/2025"
+	FileName    string   `json:"file_name"`               // required
+	FileSize    int64    `json:"file_size"`               // required
+	FileTxnType int16    `json:"file_txn_type"`           // required (e.g. 1=upload)
+	FileTxnMeta *string  `json:"file_txn_meta,omitempty"` // optional
+	Tags        []string `json:"tags,omitempty"`          // optional, searchable labels
+	// Optional Object Lock; folder lock policies apply without it.
+	LockMode    string `json:"lock_mode,omitempty"`    // GOVERNANCE or COMPLIANCE
+	RetainUntil string `json:"retain_until,omitempty"` // RFC3339
+	// Optional storage class: STANDARD (default), STANDARD_IA, GLACIER_IR
+	// or DEEP_ARCHIVE.
+	StorageClass string `json:"storage_class,omitempty"`
+	// ExpiresIn is the URL lifetime in seconds, default 900. It is shortened
+	// to the caps of the company and its storage pool.
+	ExpiresIn int `json:"expires_in,omitempty"`
+	// Optional headers pinned into the signature; the upload must send them.
+	ContentType        string `json:"content_type,omitempty"`
+	CacheControl       string `json:"cache_control,omitempty"`
+	ContentDisposition string `json:"content_disposition,omitempty"`
 }
 
 // GenerateUploadURLResponse is returned to the client.
@@ -43,6 +62,10 @@ type GenerateUploadURLResponse struct {
 	FileID    string `json:"file_id"`
 	FileKey   string `json:"file_key"`
 	UploadURL string `json:"upload_url"`
+	ExpiresAt string `json:"expires_at"`
+	// Headers must be sent with the upload, with Content-MD5 when the
+	// object is locked.
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 type CompanyFileMetaItem struct {
@@ -51,6 +74,7 @@ type CompanyFileMetaItem struct {
 	FileName    *string `json:"file_name,omitempty"`
 	FileSize    int64   `json:"file_size"`
 	FileKey     string  `json:"file_key"`
+	LocTag      *string `json:"loc_tag,omitempty"`
 	FileTxnType int16   `json:"file_txn_type"`
 	FileTxnMeta *string `json:"file_txn_meta,omitempty"`
 }
@@ -95,9 +119,12 @@ type ListFilesResponse struct {
 }
 
 type RegisterCompanyRequest struct {
-	CompanyName string `json:"company_name"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date"`
+	CompanyName   string `json:"company_name"`
+	StartDate     string `json:"start_date"`
+	EndDate       string `json:"end_date"`
+	IsolationMode string `json:"isolation_mode,omitempty"` // shared_prefix (default) or dedicated_bucket
+	Pool          string `json:"pool,omitempty"`           // storage pool name
+	Region        string `json:"region,omitempty"`         // preferred pool region
 }
 
 type RegisterCompanyResponse struct {
@@ -111,25 +138,89 @@ func sanitizeFileName(name string) string {
 }
 
 type Handler struct {
-	repo         Repository
-	companyRepo  company.Repository
-	s3Service    S3Service
-	fileMetaRepo filemeta.Repository
-	configRepo   config.Repository
+	repo          Repository
+	companyRepo   company.Repository
+	s3Service     S3Service
+	fileMetaRepo  filemeta.Repository
+	configRepo    config.Repository
+	retentionRepo retention.Repository
+	folderRepo    folder.Repository
+	renamer       *FolderRenamer
+	keyHasher     *company.KeyHasher
 }
 
-func NewHandler(repo Repository, companyRepo company.Repository, s3Service S3Service, fileMetaRepo filemeta.Repository, configRepo config.Repository) *Handler {
-	return &Handler{repo: repo, companyRepo: companyRepo, s3Service: s3Service, fileMetaRepo: fileMetaRepo, configRepo: configRepo}
+func NewHandler(repo Repository, companyRepo company.Repository, s3Service S3Service, fileMetaRepo filemeta.Repository, configRepo config.Repository, retentionRepo retention.Repository, folderRepo folder.Repository, renamer *FolderRenamer, keyHasher *company.KeyHasher) *Handler {
+	return &Handler{repo: repo, companyRepo: companyRepo, s3Service: s3Service, fileMetaRepo: fileMetaRepo, configRepo: configRepo, retentionRepo: retentionRepo, folderRepo: folderRepo, renamer: renamer, keyHasher: keyHasher}
+}
+
+// retentionBlock returns the 409 body if Object Lock or a min-retention rule
+// protects any of the given files at now, or nil.
+func retentionBlock(retentionRepo retention.Repository, companyRec *company.Company, files []filemeta.File, now time.Time) (map[string]interface{}, error) {
+	for _, file := range files {
+		if !file.Locked(now) {
+			continue
+		}
+		body := map[string]interface{}{
+			"error":      "object_locked",
+			"file_key":   file.FileKey,
+			"legal_hold": file.LegalHold,
+		}
+		if file.RetainUntil != nil && file.RetainUntil.After(now) {
+			body["lock_mode"] = file.LockMode
+			body["retain_until"] = file.RetainUntil.Format(time.RFC3339)
+		}
+		return body, nil
+	}
+
+	rules, err := retentionRepo.ListByCompanyID(companyRec.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		relKey := strings.TrimPrefix(file.FileKey, companyRec.CompanySlug+"/")
+		rule := retention.BlockingRule(rules, relKey, file.CreatedAt, now)
+		if rule == nil {
+			continue
+		}
+		return map[string]interface{}{
+			"error":        "retention_active",
+			"file_key":     file.FileKey,
+			"rule_id":      rule.ID,
+			"prefix":       rule.Prefix,
+			"retain_until": rule.Until(file.CreatedAt).Format(time.RFC3339),
+		}, nil
+	}
+	return nil, nil
+}
+
+// writeRetentionBlock answers 409 if Object Lock or a min-retention rule
+// protects any of the given files. It reports whether it did.
+func (h *Handler) writeRetentionBlock(w http.ResponseWriter, companyRec *company.Company, files []filemeta.File) (bool, error) {
+	body, err := retentionBlock(h.retentionRepo, companyRec, files, time.Now())
+	if err != nil || body == nil {
+		return false, err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(body)
+	return true, nil
 }
 
 // @Summary Register a company
-// @Description Register a new company and generate an API key with dates of format DD-MM-YYYY
+// @Description Register a new company and generate an API key with dates of format DD-MM-YYYY.
+// @Description isolation_mode=dedicated_bucket provisions a bucket for the company, shared_prefix claims the company slug in the shared bucket.
+// @Description The company is placed in the named pool, else the least used active pool of region, else the least used active pool.
+// @Description Dedicated buckets get an IAM user limited to the bucket, whose keys the company uses instead of the pool's.
 // @Tags company
 // @Accept json
 // @Produce json
+// @Param client_id header string true "Admin client id"
+// @Param client_secret header string true "Admin client secret"
 // @Param request body RegisterCompanyRequest true "Register Company Request"
 // @Success 200 {object} RegisterCompanyResponse
 // @Failure 400 {string} string "Bad Request"
+// @Failure 409 {string} string "storage prefix already in use"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /company/register [post]
 func (h *Handler) RegisterCompany(w http.ResponseWriter, r *http.Request) {
@@ -142,6 +233,13 @@ func (h *Handler) RegisterCompany(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "company name is required", http.StatusBadRequest)
 		return
 	}
+	if req.IsolationMode == "" {
+		req.IsolationMode = company.IsolationSharedPrefix
+	}
+	if req.IsolationMode != company.IsolationSharedPrefix && req.IsolationMode != company.IsolationDedicatedBucket {
+		http.Error(w, "isolation_mode must be shared_prefix or dedicated_bucket", http.StatusBadRequest)
+		return
+	}
 	if req.StartDate == "" {
 		log.Println("start date is not provided, using current date")
 		req.StartDate = time.Now().Format("02-01-2006")
@@ -155,8 +253,12 @@ func (h *Handler) RegisterCompany(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	foundActiveConfig, err := h.repo.FindActiveConfig()
+	foundActiveConfig, err := h.placeCompany(req.Pool, req.Region)
 	if err != nil {
+		if errors.Is(err, errPoolNotFound) || errors.Is(err, errPoolUnavailable) || errors.Is(err, errNoPoolCapacity) || errors.Is(err, errPoolFull) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "database error", http.StatusInternalServerError)
 		return
 	}
@@ -182,12 +284,15 @@ func (h *Handler) RegisterCompany(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, endDateErr.Error(), http.StatusBadRequest)
 		return
 	}
-	company := &company.Company{
+	bucketName := foundActiveConfig.AwsBucketName
+	if req.IsolationMode == company.IsolationDedicatedBucket {
+		bucketName = dedicatedBucketName(foundActiveConfig.AwsBucketName, companySlug)
+	}
+	companyRec := &company.Company{
 		ID:              utils.GenerateID(),
 		CompanyName:     req.CompanyName,
 		CompanySlug:     companySlug,
-		CompanyAPIKey:   apiKey,
-		AwsBucketName:   &foundActiveConfig.AwsBucketName,
+		AwsBucketName:   &bucketName,
 		AwsBucketRegion: &foundActiveConfig.AwsBucketRegion,
 		AwsAccessKey:    &foundActiveConfig.AwsAccessKey,
 		AwsSecretKey:    &foundActiveConfig.AwsSecretKey,
@@ -195,9 +300,38 @@ func (h *Handler) RegisterCompany(w http.ResponseWriter, r *http.Request) {
 		UsedQuota:       0,
 		StartDate:       &startDate,
 		EndDate:         &endDate,
+		IsolationMode:   req.IsolationMode,
+
+		UploaderConfigID: &foundActiveConfig.ID,
+	}
+
+	if companyRec.IsolationMode == company.IsolationDedicatedBucket {
+		if err := h.s3Service.ProvisionBucket(r.Context(), companyRec, foundActiveConfig); err != nil {
+			log.Printf("failed to provision bucket %s: %v", bucketName, err)
+			http.Error(w, "failed to provision storage", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// The slug prefix must be unused, objects left by an earlier tenant
+		// would otherwise become visible to the new company.
+		inUse, err := h.s3Service.PrefixInUse(r.Context(), companyRec, companySlug+"/")
+		if err != nil {
+			http.Error(w, "failed to check storage prefix", http.StatusInternalServerError)
+			return
+		}
+		if inUse {
+			http.Error(w, "storage prefix already in use", http.StatusConflict)
+			return
+		}
 	}
 
-	if err := h.companyRepo.Create(company); err != nil {
+	registrationKey := h.keyHasher.NewAPIKey(companyRec.ID, company.RegistrationKeyLabel, apiKey)
+	if err := h.companyRepo.Create(companyRec, registrationKey); err != nil {
+		if companyRec.IsolationMode == company.IsolationDedicatedBucket {
+			if cleanupErr := h.s3Service.DeprovisionBucket(r.Context(), companyRec, foundActiveConfig); cleanupErr != nil {
+				log.Printf("failed to remove bucket %s of unregistered company: %v", bucketName, cleanupErr)
+			}
+		}
 		http.Error(w, "database error", http.StatusInternalServerError)
 		return
 	}
@@ -207,6 +341,21 @@ func (h *Handler) RegisterCompany(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(RegisterCompanyResponse{CompanyApiKey: apiKey})
 }
 
+// CreateUploaderConfig godoc
+// @Summary      Create a storage pool
+// @Description  Adds a named storage pool (bucket, region, credentials, capacity and default company quota). is_active=1 opens it for new companies.
+// @Tags         storage
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string                       true  "Admin client id"
+// @Param        client_secret  header    string                       true  "Admin client secret"
+// @Param        body           body      CreateUploaderConfigRequest  true  "Storage pool"
+// @Success      201            {object}  CreateUploaderConfigResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "unknown client"
+// @Failure      500            {string}  string "internal error"
+// @Router       /config/storage [post]
+// @Router       /storage/pools [post]
 func (h *Handler) CreateUploaderConfig(w http.ResponseWriter, r *http.Request) {
 	var req CreateUploaderConfigRequest
 
@@ -233,20 +382,37 @@ func (h *Handler) CreateUploaderConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	foundActiveConfig, err := h.repo.FindActiveConfig()
+	// Basic validation
+	if req.AwsBucketName == "" || req.AwsBucketRegion == "" || req.AwsAccessKey == "" || req.AwsSecretKey == "" {
+		http.Error(w, "missing required fields", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.AwsBucketName
+	}
+	if !validUploadURLExpiry(req.MaxUploadURLExpiry) {
+		http.Error(w, "max_upload_url_expiry must be between 60 and 3600 seconds", http.StatusBadRequest)
+		return
+	}
+
+	existingPool, err := h.repo.GetByName(req.Name)
 	if err != nil {
 		http.Error(w, "database error", http.StatusInternalServerError)
 		return
 	}
-
-	if foundActiveConfig != nil {
-		http.Error(w, "another config is already active", http.StatusBadRequest)
+	if existingPool != nil {
+		http.Error(w, "a pool with this name already exists", http.StatusBadRequest)
 		return
 	}
 
-	// Basic validation
-	if req.AwsBucketName == "" || req.AwsBucketRegion == "" || req.AwsAccessKey == "" || req.AwsSecretKey == "" {
-		http.Error(w, "missing required fields", http.StatusBadRequest)
+	accessKey, err := h.s3Service.SealCredential(req.AwsAccessKey)
+	if err != nil {
+		http.Error(w, "failed to encrypt credentials", http.StatusInternalServerError)
+		return
+	}
+	secretKey, err := h.s3Service.SealCredential(req.AwsSecretKey)
+	if err != nil {
+		http.Error(w, "failed to encrypt credentials", http.StatusInternalServerError)
 		return
 	}
 
@@ -255,10 +421,14 @@ func (h *Handler) CreateUploaderConfig(w http.ResponseWriter, r *http.Request) {
 
 	cfg := &UploaderConfig{
 		ID:              id,
+		Name:            req.Name,
+		Status:          PoolInactive,
 		AwsBucketName:   req.AwsBucketName,
 		AwsBucketRegion: req.AwsBucketRegion,
-		AwsAccessKey:    req.AwsAccessKey,
-		AwsSecretKey:    req.AwsSecretKey,
+		AwsAccessKey:    accessKey,
+		AwsSecretKey:    secretKey,
+
+		MaxUploadURLExpiry: req.MaxUploadURLExpiry,
 	}
 
 	if req.TotalQuota != nil {
@@ -271,6 +441,9 @@ func (h *Handler) CreateUploaderConfig(w http.ResponseWriter, r *http.Request) {
 
 	if req.IsActive != nil {
 		cfg.IsActive = *req.IsActive
+		if cfg.IsActive == 1 {
+			cfg.Status = PoolActive
+		}
 	}
 
 	if err := h.repo.Create(cfg); err != nil {
@@ -287,6 +460,9 @@ func (h *Handler) CreateUploaderConfig(w http.ResponseWriter, r *http.Request) {
 // GenerateUploadURL godoc
 // @Summary      Generate S3 presigned upload URL and create file meta
 // @Description  Validates API key, generates a presigned S3 upload URL using company AWS config, and stores files_meta
+// @Description  The upload must send the returned headers: pinned content_type, cache_control and content_disposition, the storage class and, for locked uploads (lock_mode and retain_until, or a folder lock policy), the lock headers with Content-MD5.
+// @Description  The URL lives expires_in seconds (default 900), shortened to the caps of the company and its storage pool.
+// @Description  loc_tag is a folder path of at most 16 segments and 255 bytes; segments hold letters, digits, spaces and -_.()+,@&'!~= and cannot be "." or "..". Paths are compared without regard to case: an existing folder keeps its spelling.
 // @Tags         uploader
 // @Accept       json
 // @Produce      json
@@ -295,26 +471,13 @@ func (h *Handler) CreateUploaderConfig(w http.ResponseWriter, r *http.Request) {
 // @Success      201        {object}  GenerateUploadURLResponse
 // @Failure      400        {string}  string "invalid request"
 // @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      409        {string}  string "folder in trash or being renamed, or file under object lock"
 // @Failure      500        {string}  string "internal error"
 // @Router       /uploader/files [post]
 func (h *Handler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "missing X-API-Key header", http.StatusUnauthorized)
-		return
-	}
-
-	companyRec, err := h.companyRepo.GetByAPIKey(apiKey)
-	if err != nil {
-		http.Error(w, "failed to look up company", http.StatusInternalServerError)
-		return
-	}
-	if companyRec == nil {
-		http.Error(w, "invalid API key", http.StatusUnauthorized)
-		return
-	}
+	companyRec := company.FromContext(ctx)
 
 	var req GenerateUploadURLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -322,21 +485,13 @@ func (h *Handler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.LocTag == "" {
-		http.Error(w, "loc_tag is required", http.StatusBadRequest)
-		return
-	}
-
-	if req.FileName == "" {
-		http.Error(w, "file_name is required", http.StatusBadRequest)
+	if msg := validateUploadRequest(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
-	if req.FileSize <= 0 {
-		http.Error(w, "file_size must be > 0", http.StatusBadRequest)
-		return
-	}
-	if req.FileTxnType == 0 {
-		http.Error(w, "file_txn_type is required", http.StatusBadRequest)
+	tags, err := filemeta.NormalizeTags(req.Tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -361,12 +516,46 @@ func (h *Handler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	safeName := sanitizeFileName(req.FileName)
 	fileID := utils.GenerateID()
-	fileKey := fmt.Sprintf("%s/%s/%s", companyRec.CompanySlug, req.LocTag, safeName)
+	locTag, err := CanonicalLocTag(h.folderRepo, h.fileMetaRepo, companyRec.ID, req.LocTag)
+	if err != nil {
+		http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+		return
+	}
+	fileKey, err := UploadKey(companyRec, locTag, req.FileName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !CheckWritable(w, h.folderRepo, companyRec.ID, locTag) {
+		return
+	}
+	if !CheckOverwritable(w, h.fileMetaRepo, companyRec.ID, fileKey) {
+		return
+	}
+	lock, msg, err := ResolveObjectLock(h.folderRepo, companyRec, locTag, req.LockMode, req.RetainUntil, time.Now())
+	if err != nil {
+		http.Error(w, "failed to look up folder lock policy", http.StatusInternalServerError)
+		return
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if lock != nil && !h.requireObjectLock(w, r, companyRec) {
+		return
+	}
+	opts := uploadOptions(req, lock)
+	expiryLimit, err := h.uploadURLExpiryLimit(companyRec)
+	if err != nil {
+		http.Error(w, "failed to look up storage pool", http.StatusInternalServerError)
+		return
+	}
+	expiry := uploadURLExpiry(req.ExpiresIn, expiryLimit)
+	expiresAt := time.Now().Add(expiry)
 
 	// Generate presigned URL
-	uploadURL, err := h.s3Service.GeneratePresignedUploadURL(ctx, companyRec, fileKey, req.FileSize)
+	uploadURL, err := h.s3Service.GeneratePresignedUploadURL(ctx, companyRec, fileKey, req.FileSize, expiry, opts)
 	if err != nil {
 		http.Error(w, "failed to generate presigned URL", http.StatusInternalServerError)
 		return
@@ -384,8 +573,13 @@ func (h *Handler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
 		FileTxnMeta: req.FileTxnMeta,
 		CompanyID:   &companyRec.ID,
 	}
+	if lock != nil {
+		meta.LockMode = &lock.Mode
+		meta.RetainUntil = &lock.RetainUntil
+	}
+	meta.StorageClass = optionalString(opts.StorageClass)
 
-	if err := h.fileMetaRepo.Create(meta); err != nil {
+	if err := h.fileMetaRepo.CreateWithTags(meta, tags); err != nil {
 		http.Error(w, "failed to create file meta", http.StatusInternalServerError)
 		return
 	}
@@ -401,6 +595,8 @@ func (h *Handler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
 		FileID:    fileID,
 		FileKey:   fileKey,
 		UploadURL: uploadURL,
+		ExpiresAt: expiresAt.UTC().Format(time.RFC3339),
+		Headers:   opts.Headers(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -410,34 +606,22 @@ func (h *Handler) GenerateUploadURL(w http.ResponseWriter, r *http.Request) {
 
 // ListCompanyFiles godoc
 // @Summary      List files for the calling company
-// @Description  Uses X-API-Key to identify company and returns its files_meta records
+// @Description  Uses X-API-Key to identify company and returns its current files ordered by key, without the files of folders in the trash
 // @Tags         uploader
 // @Produce      json
 // @Param        X-API-Key  header  string  true   "Company API key"
+// @Param        loc_tag    query   string  false  "Only files in this folder"
+// @Param        recursive  query   bool    false  "Include sub folders of loc_tag"
 // @Param        limit      query   int     false  "Max number of items (default 50)"
 // @Param        offset     query   int     false  "Offset for pagination (default 0)"
 // @Success      200        {object}  ListCompanyFilesResponse
+// @Failure      400        {string}  string "invalid loc_tag"
 // @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
 // @Failure      500        {string}  string "internal error"
 // @Router       /uploader/files [get]
 func (h *Handler) ListCompanyFiles(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "missing X-API-Key header", http.StatusUnauthorized)
-		return
-	}
-
-	companyRec, err := h.companyRepo.GetByAPIKey(apiKey)
-	if err != nil {
-		http.Error(w, "failed to look up company", http.StatusInternalServerError)
-		return
-	}
-	if companyRec == nil {
-		http.Error(w, "invalid API key", http.StatusUnauthorized)
-		return
-	}
+	companyRec := company.FromContext(r.Context())
 
 	// Parse pagination params
 	q := r.URL.Query()
@@ -455,22 +639,27 @@ func (h *Handler) ListCompanyFiles(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	metas, err := h.fileMetaRepo.ListByCompanyID(companyRec.ID, limit, offset)
+	locTag, ok := h.locationFilter(w, companyRec.ID, "loc_tag", q.Get("loc_tag"))
+	if !ok {
+		return
+	}
+	files, err := h.fileMetaRepo.ListFilesPage(companyRec.ID, locTag, q.Get("recursive") == "true", limit, offset)
 	if err != nil {
 		http.Error(w, "failed to list file meta", http.StatusInternalServerError)
 		return
 	}
 
-	items := make([]CompanyFileMetaItem, 0, len(metas))
-	for _, m := range metas {
+	items := make([]CompanyFileMetaItem, 0, len(files))
+	for _, f := range files {
 		item := CompanyFileMetaItem{
-			ID:          m.ID,
-			CreatedAt:   m.CreatedAt.Format(time.RFC3339Nano),
-			FileName:    m.FileName,
-			FileSize:    m.FileSize,
-			FileKey:     m.FileKey,
-			FileTxnType: m.FileTxnType,
-			FileTxnMeta: m.FileTxnMeta,
+			ID:          f.FileMetaID,
+			CreatedAt:   f.CreatedAt.Format(time.RFC3339Nano),
+			FileName:    f.FileName,
+			FileSize:    f.FileSize,
+			FileKey:     f.FileKey,
+			LocTag:      f.LocTag,
+			FileTxnType: f.FileTxnType,
+			FileTxnMeta: f.FileTxnMeta,
 		}
 		items = append(items, item)
 	}
@@ -492,13 +681,45 @@ func (h *Handler) ListCompanyFiles(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ListFolders godoc
+// @Summary      List the sub folders of a folder
+// @Description  Folders are the folder resources and the folders derived from the loc_tag of the current files, without the ones in the trash
+// @Tags         uploader
+// @Produce      json
+// @Param        X-API-Key  header  string  true   "Company API key"
+// @Param        parent     query   string  false  "Parent folder (default: top level)"
+// @Success      200        {object}  ListFoldersResponse
+// @Failure      400        {string}  string "invalid parent"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders [get]
+func (h *Handler) ListFolders(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	parent, ok := h.locationFilter(w, companyRec.ID, "parent", r.URL.Query().Get("parent"))
+	if !ok {
+		return
+	}
+
+	children, err := h.childFolders(companyRec.ID, parent)
+	if err != nil {
+		http.Error(w, "failed to list folders", http.StatusInternalServerError)
+		return
+	}
+	folders := make([]string, 0, len(children))
+	for _, child := range children {
+		folders = append(folders, child.Name)
+	}
 
-	_ = ctx // currently unused, but kept if you want to add timeouts/cancellation later
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListFoldersResponse{Items: folders})
 }
 
 // DeleteFile godoc
 // @Summary      Delete a single file by key
-// @Description  Deletes a file from S3 for the authenticated company and records a files_meta entry with file_txn_type=2
+// @Description  Deletes a file from S3 for the authenticated company, records a files_meta entry with file_txn_type=2 and the file's size, and refunds that size from the used quota.
 // @Tags         uploader
 // @Accept       json
 // @Produce      json
@@ -507,26 +728,13 @@ func (h *Handler) ListCompanyFiles(w http.ResponseWriter, r *http.Request) {
 // @Success      200        {object}  DeleteFileResponse
 // @Failure      400        {string}  string "invalid request"
 // @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      409        {object}  map[string]interface{} "retention_active, object_locked or folder being renamed"
 // @Failure      500        {string}  string "internal error"
 // @Router       /uploader/files/delete [post]
 func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "missing X-API-Key header", http.StatusUnauthorized)
-		return
-	}
-
-	companyRec, err := h.companyRepo.GetByAPIKey(apiKey)
-	if err != nil {
-		http.Error(w, "failed to look up company", http.StatusInternalServerError)
-		return
-	}
-	if companyRec == nil {
-		http.Error(w, "invalid API key", http.StatusUnauthorized)
-		return
-	}
+	companyRec := company.FromContext(ctx)
 
 	var req DeleteFileRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -540,32 +748,41 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Basic safety: ensure this key belongs to this company
-	expectedPrefix := fmt.Sprintf("companies/%s/", companyRec.ID)
-	if !strings.HasPrefix(req.FileKey, expectedPrefix) {
-		http.Error(w, "file_key does not belong to this company", http.StatusForbidden)
+	if err := validateObjectKey(companyRec, req.FileKey); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
 
+	file, err := h.fileMetaRepo.GetFile(companyRec.ID, req.FileKey)
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+		return
+	}
+	if locTag := filemeta.LocTagOf(req.FileKey); locTag != nil && !h.checkNotRenaming(w, companyRec.ID, *locTag) {
+		return
+	}
+	if file != nil {
+		blocked, err := h.writeRetentionBlock(w, companyRec, []filemeta.File{*file})
+		if err != nil {
+			http.Error(w, "failed to check retention rules", http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			return
+		}
+	}
+
 	// Delete from S3
 	if err := h.s3Service.DeleteObject(ctx, companyRec, req.FileKey); err != nil {
 		http.Error(w, "failed to delete file from storage", http.StatusInternalServerError)
 		return
 	}
 
-	// Create a files_meta record for this delete (file_txn_type=2)
-	// We don't know size here unless you want to include it in the request; store 0.
-	txnMeta := req.FileTxnMeta
-	meta := &filemeta.FileMeta{
-		ID:          utils.GenerateID(),
-		FileName:    nil,
-		FileSize:    0,
-		FileKey:     req.FileKey,
-		FileTxnType: 2,
-		FileTxnMeta: txnMeta,
-		CompanyID:   &companyRec.ID,
-	}
-	if err := h.fileMetaRepo.Create(meta); err != nil {
-		http.Error(w, "failed to create delete file meta", http.StatusInternalServerError)
+	if err := h.repo.Transaction(func(tx Stores) error {
+		_, err := RecordFileDelete(tx, companyRec.ID, req.FileKey, req.FileTxnMeta)
+		return err
+	}); err != nil {
+		http.Error(w, "failed to record the delete", http.StatusInternalServerError)
 		return
 	}
 
@@ -577,9 +794,49 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+// RecordFileDelete records the delete of the object at key in files_meta
+// (file_txn_type=2) with the size of its upload and refunds that size,
+// unless the delete is already recorded, and reports whether it recorded
+// it. The API and the S3 event of the delete both call it; whichever comes
+// first records the delete.
+func RecordFileDelete(tx Stores, companyID, key string, txnMeta *string) (bool, error) {
+	if err := tx.Companies.Lock(companyID); err != nil {
+		return false, err
+	}
+	latest, err := tx.FileMetas.FindLatestByKey(companyID, key)
+	if err != nil {
+		return false, err
+	}
+	if latest == nil || !filemeta.IsUpload(latest.FileTxnType) {
+		return false, nil
+	}
+	if latest.Completed() {
+		// Gone from files when it was deleted with its folder, which
+		// refunded it.
+		file, err := tx.FileMetas.GetFile(companyID, key)
+		if err != nil || file == nil {
+			return false, err
+		}
+	}
+
+	meta := &filemeta.FileMeta{
+		ID:          utils.GenerateID(),
+		FileSize:    latest.FileSize,
+		FileKey:     key,
+		FileTxnType: filemeta.TxnDelete,
+		FileTxnMeta: txnMeta,
+		CompanyID:   &companyID,
+	}
+	if err := tx.FileMetas.Create(meta); err != nil {
+		return false, err
+	}
+	return true, tx.Companies.DecrementUsedQuota(companyID, latest.FileSize)
+}
+
 // DeleteFolder godoc
 // @Summary      Delete all files under a folder (prefix)
-// @Description  Deletes all objects under folder_prefix for the authenticated company and records a files_meta entry with file_txn_type=3
+// @Description  Deletes all objects under folder_prefix for the authenticated company, bypassing the trash, and records a files_meta entry with file_txn_type=3.
+// @Description  Folder resources under the prefix are deleted too and the deleted bytes are refunded from the used quota.
 // @Tags         uploader
 // @Accept       json
 // @Produce      json
@@ -588,26 +845,13 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 // @Success      200        {object}  DeleteFolderResponse
 // @Failure      400        {string}  string "invalid request"
 // @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      409        {object}  map[string]interface{} "retention_active, object_locked or folder being renamed"
 // @Failure      500        {string}  string "internal error"
 // @Router       /uploader/folders/delete [post]
 func (h *Handler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-
-	apiKey := r.Header.Get("X-API-Key")
-	if apiKey == "" {
-		http.Error(w, "missing X-API-Key header", http.StatusUnauthorized)
-		return
-	}
-
-	companyRec, err := h.companyRepo.GetByAPIKey(apiKey)
-	if err != nil {
-		http.Error(w, "failed to look up company", http.StatusInternalServerError)
-		return
-	}
-	if companyRec == nil {
-		http.Error(w, "invalid API key", http.StatusUnauthorized)
-		return
-	}
+	companyRec := company.FromContext(ctx)
 
 	var req DeleteFolderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -621,42 +865,31 @@ func (h *Handler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ensure prefix belongs to this company
-	expectedPrefix := fmt.Sprintf("%s/%s/", companyRec.CompanySlug, req.FolderPrefix)
-
-	deletedCount, deletedBytes, err := h.s3Service.DeletePrefix(ctx, companyRec, expectedPrefix)
-	if err != nil {
-		http.Error(w, "failed to delete files from storage", http.StatusInternalServerError)
+	folderPrefix, ok := h.locationFilter(w, companyRec.ID, "folder_prefix", req.FolderPrefix)
+	if !ok {
 		return
 	}
-
-	// Record a single files_meta entry, representing this bulk delete (file_txn_type=3)
-	// file_key stores the folder_prefix, file_size = deletedBytes
-	txnMeta := req.FileTxnMeta
-	meta := &filemeta.FileMeta{
-		ID:          utils.GenerateID(),
-		FileName:    nil,
-		FileSize:    deletedBytes,
-		FileKey:     req.FolderPrefix,
-		FileTxnType: 3,
-		FileTxnMeta: txnMeta,
-		CompanyID:   &companyRec.ID,
+	expectedPrefix, err := FolderKey(companyRec, folderPrefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if err := h.fileMetaRepo.Create(meta); err != nil {
-		http.Error(w, "failed to create folder delete meta", http.StatusInternalServerError)
+	if !h.checkNotRenaming(w, companyRec.ID, folderPrefix) {
 		return
 	}
 
-	if err := h.companyRepo.ResetUsedQuota(companyRec.ID); err != nil {
-		// We already created meta, but quota update failed -> log and continue
-		// In a stricter system you'd wrap this whole operation in a DB transaction.
-		http.Error(w, "failed to update quota", http.StatusInternalServerError)
+	folderFiles, err := h.fileMetaRepo.ListFiles(companyRec.ID, expectedPrefix)
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
 		return
 	}
-
-	resp := DeleteFolderResponse{
-		FolderPrefix: req.FolderPrefix,
-		DeletedCount: deletedCount,
-		DeletedBytes: deletedBytes,
+	resp, ok := h.purgeFolder(ctx, w, companyRec, folderPrefix, folderFiles, req.FileTxnMeta)
+	if !ok {
+		return
+	}
+	if err := h.folderRepo.DeleteTree(companyRec.ID, folderPrefix); err != nil {
+		http.Error(w, "failed to delete folders", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,89 @@
+package uploader
+
+import (
+	"testing"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+type fakeQuota struct {
+	company.Repository
+	locked   bool
+	refunded int64
+}
+
+func (f *fakeQuota) Lock(companyID string) error {
+	f.locked = true
+	return nil
+}
+
+func (f *fakeQuota) DecrementUsedQuota(companyID string, delta int64) error {
+	f.refunded += delta
+	return nil
+}
+
+type fakeDeleteLog struct {
+	fakeFileMetas
+	latest   *filemeta.FileMeta
+	recorded []filemeta.FileMeta
+}
+
+func (f *fakeDeleteLog) FindLatestByKey(companyID, fileKey string) (*filemeta.FileMeta, error) {
+	return f.latest, nil
+}
+
+func (f *fakeDeleteLog) Create(m *filemeta.FileMeta) error {
+	f.recorded = append(f.recorded, *m)
+	return nil
+}
+
+func TestRecordFileDelete(t *testing.T) {
+	key := "acme/a/x.txt"
+	upload := func(status string) *filemeta.FileMeta {
+		return &filemeta.FileMeta{ID: "1", FileKey: key, FileSize: 100, FileTxnType: filemeta.TxnUpload, Status: status}
+	}
+	current := map[string]*filemeta.File{key: {FileKey: key, FileMetaID: "1", FileSize: 100}}
+
+	tests := []struct {
+		name     string
+		latest   *filemeta.FileMeta
+		files    map[string]*filemeta.File
+		wantSize int64
+		want     bool
+	}{
+		{name: "stored file", latest: upload(filemeta.StatusComplete), files: current, wantSize: 100, want: true},
+		{name: "client upload type", latest: &filemeta.FileMeta{ID: "1", FileKey: key, FileSize: 100, FileTxnType: 7}, files: current, wantSize: 100, want: true},
+		{name: "pending upload", latest: upload(filemeta.StatusPending), wantSize: 100, want: true},
+		{name: "delete already recorded", latest: &filemeta.FileMeta{ID: "2", FileKey: key, FileSize: 100, FileTxnType: filemeta.TxnDelete}, files: current},
+		{name: "deleted with its folder", latest: upload(filemeta.StatusComplete)},
+		{name: "unknown object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := &fakeQuota{}
+			log := &fakeDeleteLog{fakeFileMetas: fakeFileMetas{files: tt.files}, latest: tt.latest}
+			recorded, err := RecordFileDelete(Stores{Companies: quota, FileMetas: log}, "c1", key, nil)
+			if err != nil {
+				t.Fatalf("RecordFileDelete: %v", err)
+			}
+			if !quota.locked {
+				t.Error("company not locked")
+			}
+			wantRows := 0
+			if tt.want {
+				wantRows = 1
+			}
+			if recorded != tt.want || len(log.recorded) != wantRows {
+				t.Fatalf("recorded = %v with %d rows, want %v", recorded, len(log.recorded), tt.want)
+			}
+			if quota.refunded != tt.wantSize {
+				t.Errorf("refunded %d, want %d", quota.refunded, tt.wantSize)
+			}
+			if tt.want && (log.recorded[0].FileTxnType != filemeta.TxnDelete || log.recorded[0].FileSize != tt.wantSize) {
+				t.Errorf("recorded type %d size %d, want a delete of %d bytes", log.recorded[0].FileTxnType, log.recorded[0].FileSize, tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package uploader
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/locpath"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+// ErrKeyOutsidePrefix is returned for keys outside the company's prefix.
+var ErrKeyOutsidePrefix = errors.New("file_key does not belong to this company")
+
+// maxKeyLen bounds object keys by the file_key column.
+const maxKeyLen = 255
+
+// validateObjectKey makes sure key lies strictly inside the company's slug
+// prefix, so companies sharing a bucket can never address each other's objects.
+func validateObjectKey(companyRec *company.Company, key string) error {
+	rest, ok := strings.CutPrefix(key, companyRec.CompanySlug+"/")
+	if !ok || rest == "" {
+		return ErrKeyOutsidePrefix
+	}
+	if len(key) > maxKeyLen {
+		return errors.New("file_key is too long")
+	}
+	for _, segment := range strings.Split(rest, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return errors.New("file_key has an invalid path segment")
+		}
+	}
+	for _, r := range rest {
+		if r == '\\' || unicode.IsControl(r) {
+			return errors.New("file_key contains invalid characters")
+		}
+	}
+	return nil
+}
+
+// UploadKey builds the object key of an upload to locTag, rejecting invalid
+// locations and keys outside the company's prefix.
+func UploadKey(companyRec *company.Company, locTag, fileName string) (string, error) {
+	locTag, err := locpath.Clean(locTag)
+	if err != nil {
+		return "", err
+	}
+	key := companyRec.CompanySlug + "/" + locTag + "/" + sanitizeFileName(fileName)
+	if err := validateObjectKey(companyRec, key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// SplitFileKey checks key like the keys of uploads and returns its clean
+// location, empty for files at the company's root, and its file name.
+func SplitFileKey(companyRec *company.Company, key string) (string, string, error) {
+	if err := validateObjectKey(companyRec, key); err != nil {
+		return "", "", err
+	}
+	rest := strings.TrimPrefix(key, companyRec.CompanySlug+"/")
+	i := strings.LastIndex(rest, "/")
+	if i < 0 {
+		return "", rest, nil
+	}
+	locTag, err := locpath.Clean(rest[:i])
+	if err != nil {
+		return "", "", err
+	}
+	return locTag, rest[i+1:], nil
+}
+
+// dedicatedBucketName derives a globally unique, S3-valid bucket name for a
+// company from the pool bucket name and the company slug.
+func dedicatedBucketName(baseBucket, slug string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(baseBucket + "-" + slug) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	suffix := "-" + utils.GenerateID()[:8]
+
+	name := b.String()
+	if len(name) > 63-len(suffix) {
+		name = name[:63-len(suffix)]
+	}
+	return strings.Trim(name, "-") + suffix
+}
+
+// FolderKey returns the key prefix (ending in '/') of the folder at path,
+// which is also the key of its placeholder object.
+func FolderKey(companyRec *company.Company, path string) (string, error) {
+	path, err := locpath.Clean(path)
+	if err != nil {
+		return "", err
+	}
+	key := companyRec.CompanySlug + "/" + path
+	if err := validateObjectKey(companyRec, key); err != nil {
+		return "", err
+	}
+	return key + "/", nil
+}
+
+// KnownLocations returns the spellings of the folders and file locations in
+// the tree of the top-level folder top, folders first.
+func KnownLocations(folderRepo folder.Repository, fileMetaRepo filemeta.Repository, companyID, top string) ([]string, error) {
+	known, err := folderRepo.Spellings(companyID, top)
+	if err != nil {
+		return nil, err
+	}
+	locTags, err := fileMetaRepo.LocTagSpellings(companyID, top)
+	if err != nil {
+		return nil, err
+	}
+	return append(known, locTags...), nil
+}
+
+// CanonicalLocTag respells the clean locTag like the existing location it
+// names, so locations differing only in case share keys.
+func CanonicalLocTag(folderRepo folder.Repository, fileMetaRepo filemeta.Repository, companyID, locTag string) (string, error) {
+	known, err := KnownLocations(folderRepo, fileMetaRepo, companyID, locpath.Top(locTag))
+	if err != nil {
+		return "", err
+	}
+	return locpath.Canonical(locTag, known), nil
+}
@@ -0,0 +1,46 @@
+package uploader
+
+import (
+	"errors"
+	"testing"
+
+	"shreshtasmg.in/jupyter/internal/company"
+)
+
+func TestSplitFileKey(t *testing.T) {
+	companyRec := &company.Company{CompanySlug: "acme"}
+
+	tests := []struct {
+		name        string
+		key         string
+		wantLoc     string
+		wantName    string
+		wantErr     bool
+		wantOutside bool
+	}{
+		{name: "root file", key: "acme/a.txt", wantName: "a.txt"},
+		{name: "nested file", key: "acme/Invoices/2024/a.txt", wantLoc: "Invoices/2024", wantName: "a.txt"},
+		{name: "decomposed location is composed", key: "acme/cafe\u0301/a.txt", wantLoc: "caf\u00e9", wantName: "a.txt"},
+		{name: "other company", key: "other/a.txt", wantErr: true, wantOutside: true},
+		{name: "slug without slash", key: "acmea.txt", wantErr: true, wantOutside: true},
+		{name: "parent segment", key: "acme/../other/a.txt", wantErr: true},
+		{name: "empty segment", key: "acme/a//b.txt", wantErr: true},
+		{name: "folder key", key: "acme/a/", wantErr: true},
+		{name: "invalid location character", key: "acme/a*b/c.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, name, err := SplitFileKey(companyRec, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrKeyOutsidePrefix) != tt.wantOutside {
+				t.Errorf("error = %v, want outside prefix %v", err, tt.wantOutside)
+			}
+			if loc != tt.wantLoc || name != tt.wantName {
+				t.Errorf("SplitFileKey = %q, %q, want %q, %q", loc, name, tt.wantLoc, tt.wantName)
+			}
+		})
+	}
+}
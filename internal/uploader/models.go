@@ -4,19 +4,54 @@ import (
 	"time"
 )
 
+// Storage pool statuses.
+const (
+	// PoolInactive pools are configured but do not take companies yet.
+	PoolInactive = "inactive"
+	// PoolActive pools take new companies.
+	PoolActive = "active"
+	// PoolDraining pools keep serving their companies but take no new ones.
+	PoolDraining = "draining"
+	// PoolRetired pools have no companies left and are kept for history.
+	PoolRetired = "retired"
+)
+
+// UploaderConfig is a named storage pool. TotalQuota is the pool's capacity:
+// the bytes of quota it can hand out to companies.
 type UploaderConfig struct {
 	ID              string    `gorm:"type:varchar(40);primaryKey;column:id"`
 	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime"`
+	Name            string    `gorm:"type:varchar(64);uniqueIndex:idx_uploader_config_name_unique;column:name"`
 	AwsBucketName   string    `gorm:"type:varchar(64);not null;column:aws_bucket_name"`
 	AwsBucketRegion string    `gorm:"type:varchar(50);not null;column:aws_bucket_region"`
-	AwsAccessKey    string    `gorm:"type:varchar(25);not null;column:aws_access_key"`
-	AwsSecretKey    string    `gorm:"type:varchar(45);not null;column:aws_secret_key"`
+	AwsAccessKey    string    `gorm:"type:varchar(255);not null;column:aws_access_key"`
+	AwsSecretKey    string    `gorm:"type:varchar(255);not null;column:aws_secret_key"`
 	TotalQuota      int64     `gorm:"column:total_quota;default:5368709120"`  // 5GB
 	DefaultQuota    int64     `gorm:"column:default_quota;default:262144000"` // 250MB
 	IsActive        int16     `gorm:"column:is_active;default:0"`
-	UpdatedAt       time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	Status          string    `gorm:"type:varchar(20);not null;default:inactive;column:status"`
+	// The credentials replaced by the last rotation and until when they may
+	// still be in use (cached clients, presigned URLs already handed out).
+	PreviousAccessKey    *string    `gorm:"type:varchar(255);column:previous_access_key"`
+	PreviousSecretKey    *string    `gorm:"type:varchar(255);column:previous_secret_key"`
+	PreviousKeyExpiresAt *time.Time `gorm:"column:previous_key_expires_at"`
+	// CredentialsVersion counts rotations. The sealed keys cannot be
+	// compared, as every seal of a value differs.
+	CredentialsVersion int `gorm:"column:credentials_version;not null;default:0"`
+	// MaxUploadURLExpiry caps the lifetime of presigned upload URLs of the
+	// pool's companies, in seconds.
+	MaxUploadURLExpiry *int      `gorm:"column:max_upload_url_expiry"`
+	UpdatedAt          time.Time `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 func (UploaderConfig) TableName() string {
 	return "uploader_config"
 }
+
+// PoolUsage sums what a pool has handed out to its companies.
+type PoolUsage struct {
+	UploaderConfigID string `gorm:"column:uploader_config_id"`
+	Companies        int64  `gorm:"column:companies"`
+	AllocatedQuota   int64  `gorm:"column:allocated_quota"`
+	UsedQuota        int64  `gorm:"column:used_quota"`
+}
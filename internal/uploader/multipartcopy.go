@@ -0,0 +1,166 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// maxSinglePartSize is the largest object S3 copies or stores in a
+	// single CopyObject or PutObject request.
+	maxSinglePartSize = 5 << 30
+	// copyPartSize is the part size of server side multipart copies.
+	copyPartSize = 512 << 20
+	// streamPartSize is the part size of multipart uploads streamed through
+	// this process; each part is buffered in memory.
+	streamPartSize = 64 << 20
+)
+
+// copyWithin copies sourceBucket/sourceKey to bucket/key server side, in
+// parts when the object is too large for one CopyObject request. An empty
+// storageClass keeps the bucket default.
+func copyWithin(ctx context.Context, client *s3.Client, bucket, key, sourceBucket, sourceKey, storageClass string) error {
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(sourceBucket),
+		Key:    aws.String(sourceKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read source object: %w", err)
+	}
+	size := aws.ToInt64(head.ContentLength)
+	if size <= maxSinglePartSize {
+		_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:       aws.String(bucket),
+			Key:          aws.String(key),
+			CopySource:   aws.String(copySource(sourceBucket, sourceKey)),
+			StorageClass: types.StorageClass(storageClass),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy object: %w", err)
+		}
+		return nil
+	}
+
+	create := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(bucket),
+		Key:                aws.String(key),
+		StorageClass:       types.StorageClass(storageClass),
+		ContentType:        head.ContentType,
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		Metadata:           head.Metadata,
+	}
+	return multipartUpload(ctx, client, create, func(uploadID string, partNumber int32) (*string, bool, error) {
+		start := int64(partNumber-1) * copyPartSize
+		end := min(start+copyPartSize, size) - 1
+		out, err := client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(bucket),
+			Key:             aws.String(key),
+			UploadId:        aws.String(uploadID),
+			PartNumber:      aws.Int32(partNumber),
+			CopySource:      aws.String(copySource(sourceBucket, sourceKey)),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to copy part %d: %w", partNumber, err)
+		}
+		return out.CopyPartResult.ETag, end+1 >= size, nil
+	})
+}
+
+// streamObject writes size bytes of body to bucket/key, in parts when they
+// are too large for one PutObject request.
+func streamObject(ctx context.Context, client *s3.Client, bucket, key string, body io.Reader, size int64, contentType *string) error {
+	if size <= maxSinglePartSize {
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			Body:          body,
+			ContentLength: aws.Int64(size),
+			ContentType:   contentType,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write target object: %w", err)
+		}
+		return nil
+	}
+
+	create := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: contentType,
+	}
+	buf := make([]byte, streamPartSize)
+	var written int64
+	return multipartUpload(ctx, client, create, func(uploadID string, partNumber int32) (*string, bool, error) {
+		n, err := io.ReadFull(body, buf[:min(streamPartSize, size-written)])
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read source object: %w", err)
+		}
+		out, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			UploadId:      aws.String(uploadID),
+			PartNumber:    aws.Int32(partNumber),
+			Body:          bytes.NewReader(buf[:n]),
+			ContentLength: aws.Int64(int64(n)),
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to write part %d: %w", partNumber, err)
+		}
+		written += int64(n)
+		return out.ETag, written >= size, nil
+	})
+}
+
+// multipartUpload runs a multipart upload, calling part for part numbers
+// from 1 until it reports the last part. The upload is aborted on error.
+func multipartUpload(ctx context.Context, client *s3.Client, create *s3.CreateMultipartUploadInput, part func(uploadID string, partNumber int32) (etag *string, last bool, err error)) error {
+	upload, err := client.CreateMultipartUpload(ctx, create)
+	if err != nil {
+		return fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	uploadID := aws.ToString(upload.UploadId)
+
+	var parts []types.CompletedPart
+	for partNumber := int32(1); ; partNumber++ {
+		etag, last, err := part(uploadID, partNumber)
+		if err != nil {
+			abortUpload(client, create, uploadID)
+			return err
+		}
+		parts = append(parts, types.CompletedPart{ETag: etag, PartNumber: aws.Int32(partNumber)})
+		if last {
+			break
+		}
+	}
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          create.Bucket,
+		Key:             create.Key,
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		abortUpload(client, create, uploadID)
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// abortUpload aborts a failed multipart upload so its parts are not kept,
+// even when the request context is done.
+func abortUpload(client *s3.Client, create *s3.CreateMultipartUploadInput, uploadID string) {
+	_, _ = client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   create.Bucket,
+		Key:      create.Key,
+		UploadId: aws.String(uploadID),
+	})
+}
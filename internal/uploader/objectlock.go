@@ -0,0 +1,308 @@
+package uploader
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+)
+
+// Object Lock retention modes, as named by S3.
+const (
+	LockModeGovernance = "GOVERNANCE"
+	LockModeCompliance = "COMPLIANCE"
+)
+
+const maxLockRetentionDays = 36500
+
+// ObjectLock is the retention an object is written with.
+type ObjectLock struct {
+	Mode        string
+	RetainUntil time.Time
+}
+
+// Headers returns the headers a client must send with a presigned upload of
+// a locked object, besides Content-MD5.
+func (l *ObjectLock) Headers() map[string]string {
+	if l == nil {
+		return nil
+	}
+	return map[string]string{
+		"x-amz-object-lock-mode":              l.Mode,
+		"x-amz-object-lock-retain-until-date": l.RetainUntil.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// covers reports whether l protects an object at least as long and as
+// strictly as other.
+func (l ObjectLock) covers(other ObjectLock) bool {
+	if other.Mode == LockModeCompliance && l.Mode != LockModeCompliance {
+		return false
+	}
+	return !l.RetainUntil.Before(other.RetainUntil)
+}
+
+// parseLockMode returns the S3 name of a retention mode given in any case.
+func parseLockMode(s string) (string, bool) {
+	switch mode := strings.ToUpper(s); mode {
+	case LockModeGovernance, LockModeCompliance:
+		return mode, true
+	}
+	return "", false
+}
+
+// ResolveObjectLock returns the lock of an upload to locTag: the requested
+// mode and retain-until date (RFC3339), else the policy of the innermost
+// folder that has one. A requested lock may not be weaker than the policy.
+// It returns nil if the upload is not locked, and a message for invalid
+// requests.
+func ResolveObjectLock(folderRepo folder.Repository, companyRec *company.Company, locTag, mode, retainUntil string, now time.Time) (*ObjectLock, string, error) {
+	var requested *ObjectLock
+	if mode != "" || retainUntil != "" {
+		lockMode, ok := parseLockMode(mode)
+		if !ok {
+			return nil, "lock_mode must be GOVERNANCE or COMPLIANCE", nil
+		}
+		until, err := time.Parse(time.RFC3339, retainUntil)
+		if err != nil {
+			return nil, "retain_until must be an RFC3339 timestamp", nil
+		}
+		if !until.After(now) || until.After(now.AddDate(0, 0, maxLockRetentionDays)) {
+			return nil, "retain_until must be in the future and at most 100 years ahead", nil
+		}
+		requested = &ObjectLock{Mode: lockMode, RetainUntil: until.UTC().Truncate(time.Second)}
+	}
+
+	policy, err := folderRepo.LockPolicy(companyRec.ID, strings.Trim(locTag, "/"))
+	if err != nil {
+		return nil, "", err
+	}
+	if policy == nil || policy.LockRetentionDays == nil {
+		return requested, "", nil
+	}
+	required := ObjectLock{
+		Mode:        *policy.LockMode,
+		RetainUntil: now.UTC().AddDate(0, 0, *policy.LockRetentionDays).Truncate(time.Second),
+	}
+	if requested == nil {
+		return &required, "", nil
+	}
+	if !requested.covers(required) {
+		return nil, "the requested object lock is weaker than the policy of folder " + policy.Path, nil
+	}
+	return requested, "", nil
+}
+
+// OverwriteBlock returns why an upload may not replace the file stored under
+// fileKey, or "" if it may: a file under retention or legal hold at now
+// keeps its key until the lock ends.
+func OverwriteBlock(fileMetaRepo filemeta.Repository, companyID, fileKey string, now time.Time) (string, error) {
+	file, err := fileMetaRepo.GetFile(companyID, fileKey)
+	if err != nil {
+		return "", err
+	}
+	if file != nil && file.Locked(now) {
+		return "file " + fileKey + " is under object lock and cannot be replaced", nil
+	}
+	return "", nil
+}
+
+// CheckOverwritable answers 409 and returns false if an upload may not
+// replace the file stored under fileKey, see OverwriteBlock.
+func CheckOverwritable(w http.ResponseWriter, fileMetaRepo filemeta.Repository, companyID, fileKey string) bool {
+	msg, err := OverwriteBlock(fileMetaRepo, companyID, fileKey, time.Now())
+	if err != nil {
+		http.Error(w, "failed to look up file", http.StatusInternalServerError)
+		return false
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// requireObjectLock answers 400 unless the company bucket has Object Lock
+// enabled, and reports whether it does.
+func (h *Handler) requireObjectLock(w http.ResponseWriter, r *http.Request, companyRec *company.Company) bool {
+	enabled, err := h.s3Service.ObjectLockEnabled(r.Context(), companyRec)
+	if err != nil {
+		http.Error(w, "failed to read object lock configuration", http.StatusInternalServerError)
+		return false
+	}
+	if !enabled {
+		http.Error(w, "object lock is not enabled on the company bucket", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+type FolderLockPolicyRequest struct {
+	LockMode      string `json:"lock_mode"` // GOVERNANCE or COMPLIANCE
+	RetentionDays int    `json:"retention_days"`
+}
+
+// SetFolderLockPolicy godoc
+// @Summary      Set the Object Lock policy of a folder
+// @Description  Uploads to the folder and its sub folders are locked for retention_days from the upload, unless they request a longer lock.
+// @Description  The company bucket must have Object Lock enabled. A COMPLIANCE policy can only be extended.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string                   true  "Company API key"
+// @Param        id         path      string                   true  "Folder ID"
+// @Param        body       body      FolderLockPolicyRequest  true  "Policy"
+// @Success      200        {object}  FolderResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder not found"
+// @Failure      409        {string}  string "compliance policy cannot be weakened"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/{id}/object-lock [put]
+func (h *Handler) SetFolderLockPolicy(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	f := h.companyFolder(w, r, companyRec)
+	if f == nil {
+		return
+	}
+
+	var req FolderLockPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	mode, ok := parseLockMode(req.LockMode)
+	if !ok {
+		http.Error(w, "lock_mode must be GOVERNANCE or COMPLIANCE", http.StatusBadRequest)
+		return
+	}
+	if req.RetentionDays <= 0 || req.RetentionDays > maxLockRetentionDays {
+		http.Error(w, "retention_days must be between 1 and 36500", http.StatusBadRequest)
+		return
+	}
+	if f.LockMode != nil && *f.LockMode == LockModeCompliance &&
+		(mode != LockModeCompliance || req.RetentionDays < *f.LockRetentionDays) {
+		http.Error(w, "a COMPLIANCE policy cannot be weakened", http.StatusConflict)
+		return
+	}
+	if !h.requireObjectLock(w, r, companyRec) {
+		return
+	}
+
+	f.LockMode = &mode
+	f.LockRetentionDays = &req.RetentionDays
+	if err := h.folderRepo.Update(f); err != nil {
+		http.Error(w, "failed to update folder", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toFolderResponse(*f))
+}
+
+// RemoveFolderLockPolicy godoc
+// @Summary      Remove the Object Lock policy of a folder
+// @Description  Objects already locked stay locked. A COMPLIANCE policy cannot be removed.
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "Folder ID"
+// @Success      200        {object}  FolderResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder not found"
+// @Failure      409        {string}  string "compliance policy cannot be removed"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/{id}/object-lock [delete]
+func (h *Handler) RemoveFolderLockPolicy(w http.ResponseWriter, r *http.Request) {
+	f := h.companyFolder(w, r, company.FromContext(r.Context()))
+	if f == nil {
+		return
+	}
+	if f.LockMode != nil && *f.LockMode == LockModeCompliance {
+		http.Error(w, "a COMPLIANCE policy cannot be removed", http.StatusConflict)
+		return
+	}
+
+	f.LockMode = nil
+	f.LockRetentionDays = nil
+	if err := h.folderRepo.Update(f); err != nil {
+		http.Error(w, "failed to update folder", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toFolderResponse(*f))
+}
+
+// SetLegalHold godoc
+// @Summary      Place a legal hold on a file
+// @Description  A file under legal hold cannot be deleted or moved until the hold is released, whatever its retention.
+// @Tags         uploader
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "File ID"
+// @Success      200        {object}  FileDetailResponse
+// @Failure      400        {string}  string "object lock not enabled"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/{id}/legal-hold [put]
+func (h *Handler) SetLegalHold(w http.ResponseWriter, r *http.Request) {
+	h.setLegalHold(w, r, true)
+}
+
+// ReleaseLegalHold godoc
+// @Summary      Release the legal hold of a file
+// @Tags         uploader
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "File ID"
+// @Success      200        {object}  FileDetailResponse
+// @Failure      400        {string}  string "object lock not enabled"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/{id}/legal-hold [delete]
+func (h *Handler) ReleaseLegalHold(w http.ResponseWriter, r *http.Request) {
+	h.setLegalHold(w, r, false)
+}
+
+func (h *Handler) setLegalHold(w http.ResponseWriter, r *http.Request, on bool) {
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+	file := h.currentFile(w, r, companyRec)
+	if file == nil {
+		return
+	}
+	if !h.requireObjectLock(w, r, companyRec) {
+		return
+	}
+
+	if err := h.s3Service.SetLegalHold(ctx, companyRec, file.FileKey, on); err != nil {
+		http.Error(w, "failed to set legal hold", http.StatusInternalServerError)
+		return
+	}
+	if err := h.fileMetaRepo.SetLegalHold(file.FileMetaID, on); err != nil {
+		http.Error(w, "failed to update file meta", http.StatusInternalServerError)
+		return
+	}
+	file.LegalHold = on
+
+	resp, err := h.toFileDetail(*file)
+	if err != nil {
+		http.Error(w, "failed to load tags", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,166 @@
+package uploader
+
+import (
+	"testing"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+)
+
+type fakeFolders struct {
+	folder.Repository
+	policy *folder.Folder
+}
+
+func (f *fakeFolders) LockPolicy(companyID, path string) (*folder.Folder, error) {
+	return f.policy, nil
+}
+
+type fakeFileMetas struct {
+	filemeta.Repository
+	files map[string]*filemeta.File
+}
+
+func (f *fakeFileMetas) GetFile(companyID, fileKey string) (*filemeta.File, error) {
+	return f.files[fileKey], nil
+}
+
+func strPtr(s string) *string { return &s }
+
+func intPtr(i int) *int { return &i }
+
+func TestResolveObjectLock(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	in := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	governance30 := &folder.Folder{Path: "a", LockMode: strPtr(LockModeGovernance), LockRetentionDays: intPtr(30)}
+	compliance30 := &folder.Folder{Path: "a", LockMode: strPtr(LockModeCompliance), LockRetentionDays: intPtr(30)}
+
+	tests := []struct {
+		name        string
+		policy      *folder.Folder
+		mode        string
+		retainUntil string
+
+		want    *ObjectLock
+		wantMsg string
+	}{
+		{
+			name: "no request and no policy",
+		},
+		{
+			name:        "requested lock",
+			mode:        "governance",
+			retainUntil: in(time.Hour),
+			want:        &ObjectLock{Mode: LockModeGovernance, RetainUntil: now.Add(time.Hour)},
+		},
+		{
+			name:        "unknown mode",
+			mode:        "strict",
+			retainUntil: in(time.Hour),
+			wantMsg:     "lock_mode must be GOVERNANCE or COMPLIANCE",
+		},
+		{
+			name:    "mode without date",
+			mode:    LockModeGovernance,
+			wantMsg: "retain_until must be an RFC3339 timestamp",
+		},
+		{
+			name:        "date in the past",
+			mode:        LockModeGovernance,
+			retainUntil: in(-time.Hour),
+			wantMsg:     "retain_until must be in the future and at most 100 years ahead",
+		},
+		{
+			name:        "date too far ahead",
+			mode:        LockModeGovernance,
+			retainUntil: now.AddDate(0, 0, maxLockRetentionDays+1).Format(time.RFC3339),
+			wantMsg:     "retain_until must be in the future and at most 100 years ahead",
+		},
+		{
+			name:   "policy applies without a request",
+			policy: governance30,
+			want:   &ObjectLock{Mode: LockModeGovernance, RetainUntil: now.AddDate(0, 0, 30)},
+		},
+		{
+			name:   "folder without policy",
+			policy: &folder.Folder{Path: "a"},
+		},
+		{
+			name:        "longer request than the policy",
+			policy:      governance30,
+			mode:        LockModeCompliance,
+			retainUntil: in(60 * 24 * time.Hour),
+			want:        &ObjectLock{Mode: LockModeCompliance, RetainUntil: now.Add(60 * 24 * time.Hour)},
+		},
+		{
+			name:        "shorter request than the policy",
+			policy:      governance30,
+			mode:        LockModeGovernance,
+			retainUntil: in(24 * time.Hour),
+			wantMsg:     "the requested object lock is weaker than the policy of folder a",
+		},
+		{
+			name:        "governance request under a compliance policy",
+			policy:      compliance30,
+			mode:        LockModeGovernance,
+			retainUntil: in(60 * 24 * time.Hour),
+			wantMsg:     "the requested object lock is weaker than the policy of folder a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folders := &fakeFolders{policy: tt.policy}
+			got, msg, err := ResolveObjectLock(folders, &company.Company{ID: "c1"}, "a/b", tt.mode, tt.retainUntil, now)
+			if err != nil {
+				t.Fatalf("ResolveObjectLock: %v", err)
+			}
+			if msg != tt.wantMsg {
+				t.Fatalf("msg = %q, want %q", msg, tt.wantMsg)
+			}
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("lock = %+v, want %+v", got, tt.want)
+			}
+			if got != nil && (got.Mode != tt.want.Mode || !got.RetainUntil.Equal(tt.want.RetainUntil)) {
+				t.Errorf("lock = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverwriteBlock(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		file    *filemeta.File
+		blocked bool
+	}{
+		{name: "new key"},
+		{name: "unlocked file", file: &filemeta.File{}},
+		{name: "retention ended", file: &filemeta.File{RetainUntil: &earlier}},
+		{name: "under retention", file: &filemeta.File{RetainUntil: &later}, blocked: true},
+		{name: "legal hold", file: &filemeta.File{LegalHold: true}, blocked: true},
+		{name: "legal hold after retention", file: &filemeta.File{RetainUntil: &earlier, LegalHold: true}, blocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileMetas := &fakeFileMetas{files: map[string]*filemeta.File{}}
+			if tt.file != nil {
+				fileMetas.files["acme/a/x.txt"] = tt.file
+			}
+			msg, err := OverwriteBlock(fileMetas, "c1", "acme/a/x.txt", now)
+			if err != nil {
+				t.Fatalf("OverwriteBlock: %v", err)
+			}
+			if (msg != "") != tt.blocked {
+				t.Errorf("msg = %q, want blocked %v", msg, tt.blocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package uploader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errPoolNotFound    = errors.New("storage pool not found")
+	errPoolUnavailable = errors.New("storage pool is not active")
+	errNoPoolCapacity  = errors.New("no active storage pool has capacity")
+	errPoolFull        = errors.New("storage pool has no capacity left")
+)
+
+type PoolResponse struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	AwsBucketName   string `json:"aws_bucket_name"`
+	AwsBucketRegion string `json:"aws_bucket_region"`
+	Capacity        int64  `json:"capacity"`
+	DefaultQuota    int64  `json:"default_quota"`
+	Companies       int64  `json:"companies"`
+	AllocatedQuota  int64  `json:"allocated_quota"`
+	UsedQuota       int64  `json:"used_quota"`
+	CreatedAt       string `json:"created_at"`
+	// PreviousKeyExpiresAt is set while the credentials replaced by the last
+	// rotation may still be in use.
+	PreviousKeyExpiresAt *string `json:"previous_key_expires_at,omitempty"`
+	MaxUploadURLExpiry   *int    `json:"max_upload_url_expiry,omitempty"` // seconds
+}
+
+type ListPoolsResponse struct {
+	Items []PoolResponse `json:"items"`
+}
+
+type RotateCredentialsRequest struct {
+	AwsAccessKey string `json:"aws_access_key"`
+	AwsSecretKey string `json:"aws_secret_key"`
+	// OverlapMinutes is how long the old key stays in use, default 60.
+	OverlapMinutes int `json:"overlap_minutes,omitempty"`
+}
+
+type RotateCredentialsResponse struct {
+	Pool             PoolResponse `json:"pool"`
+	CompaniesUpdated int64        `json:"companies_updated"`
+	// OldKeyValidUntil is when the old key can be deactivated at the provider.
+	OldKeyValidUntil string `json:"old_key_valid_until"`
+}
+
+const (
+	defaultRotationOverlap = time.Hour
+	maxRotationOverlap     = 24 * time.Hour
+)
+
+func toPoolResponse(cfg UploaderConfig, usage PoolUsage) PoolResponse {
+	resp := PoolResponse{
+		ID:              cfg.ID,
+		Name:            cfg.Name,
+		Status:          cfg.Status,
+		AwsBucketName:   cfg.AwsBucketName,
+		AwsBucketRegion: cfg.AwsBucketRegion,
+		Capacity:        cfg.TotalQuota,
+		DefaultQuota:    cfg.DefaultQuota,
+		Companies:       usage.Companies,
+		AllocatedQuota:  usage.AllocatedQuota,
+		UsedQuota:       usage.UsedQuota,
+		CreatedAt:       cfg.CreatedAt.Format(time.RFC3339),
+
+		MaxUploadURLExpiry: cfg.MaxUploadURLExpiry,
+	}
+	if cfg.PreviousKeyExpiresAt != nil && cfg.PreviousKeyExpiresAt.After(time.Now()) {
+		expiresAt := cfg.PreviousKeyExpiresAt.Format(time.RFC3339)
+		resp.PreviousKeyExpiresAt = &expiresAt
+	}
+	return resp
+}
+
+// placeCompany picks the pool for a new company: the named pool if given,
+// otherwise the least used active pool (in region, if given). Either way the
+// pool must still be able to hand out its default quota.
+func (h *Handler) placeCompany(poolName, region string) (*UploaderConfig, error) {
+	usage, err := h.repo.Usage()
+	if err != nil {
+		return nil, err
+	}
+
+	if poolName != "" {
+		pool, err := h.repo.GetByName(poolName)
+		if err != nil {
+			return nil, err
+		}
+		if pool == nil {
+			return nil, errPoolNotFound
+		}
+		if pool.Status != PoolActive {
+			return nil, errPoolUnavailable
+		}
+		if !hasCapacity(pool, usage[pool.ID]) {
+			return nil, errPoolFull
+		}
+		return pool, nil
+	}
+
+	pools, err := h.repo.ListByStatus(PoolActive)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *UploaderConfig
+	var bestLoad float64
+	for i := range pools {
+		pool := &pools[i]
+		if region != "" && pool.AwsBucketRegion != region {
+			continue
+		}
+		if !hasCapacity(pool, usage[pool.ID]) {
+			continue
+		}
+		load := float64(usage[pool.ID].AllocatedQuota) / float64(pool.TotalQuota)
+		if best == nil || load < bestLoad {
+			best, bestLoad = pool, load
+		}
+	}
+	if best == nil {
+		return nil, errNoPoolCapacity
+	}
+	return best, nil
+}
+
+// hasCapacity reports whether the pool can hand out its default quota.
+func hasCapacity(pool *UploaderConfig, usage PoolUsage) bool {
+	return pool.TotalQuota > 0 && usage.AllocatedQuota+pool.DefaultQuota <= pool.TotalQuota
+}
+
+// ListPools godoc
+// @Summary      List storage pools with usage
+// @Tags         storage
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Success      200            {object}  ListPoolsResponse
+// @Failure      500            {string}  string "internal error"
+// @Router       /storage/pools [get]
+func (h *Handler) ListPools(w http.ResponseWriter, r *http.Request) {
+	pools, err := h.repo.List()
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	usage, err := h.repo.Usage()
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]PoolResponse, 0, len(pools))
+	for _, pool := range pools {
+		items = append(items, toPoolResponse(pool, usage[pool.ID]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ListPoolsResponse{Items: items})
+}
+
+// ActivatePool godoc
+// @Summary      Open a storage pool for new companies
+// @Tags         storage
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Pool ID"
+// @Success      200            {object}  PoolResponse
+// @Failure      404            {string}  string "storage pool not found"
+// @Failure      409            {string}  string "invalid transition"
+// @Router       /storage/pools/{id}/activate [post]
+func (h *Handler) ActivatePool(w http.ResponseWriter, r *http.Request) {
+	h.setPoolStatus(w, r, PoolActive)
+}
+
+// DrainPool godoc
+// @Summary      Stop placing new companies in a storage pool
+// @Description  Existing companies keep using the pool
+// @Tags         storage
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Pool ID"
+// @Success      200            {object}  PoolResponse
+// @Failure      404            {string}  string "storage pool not found"
+// @Failure      409            {string}  string "invalid transition"
+// @Router       /storage/pools/{id}/drain [post]
+func (h *Handler) DrainPool(w http.ResponseWriter, r *http.Request) {
+	h.setPoolStatus(w, r, PoolDraining)
+}
+
+// RetirePool godoc
+// @Summary      Retire an empty storage pool
+// @Description  Only pools without companies can be retired, migrate them away first
+// @Tags         storage
+// @Produce      json
+// @Param        client_id      header    string  true  "Admin client id"
+// @Param        client_secret  header    string  true  "Admin client secret"
+// @Param        id             path      string  true  "Pool ID"
+// @Success      200            {object}  PoolResponse
+// @Failure      404            {string}  string "storage pool not found"
+// @Failure      409            {string}  string "pool still has companies"
+// @Router       /storage/pools/{id}/retire [post]
+func (h *Handler) RetirePool(w http.ResponseWriter, r *http.Request) {
+	h.setPoolStatus(w, r, PoolRetired)
+}
+
+func (h *Handler) setPoolStatus(w http.ResponseWriter, r *http.Request, status string) {
+	pool, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if pool == nil {
+		http.Error(w, errPoolNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if pool.Status == PoolRetired && status != PoolRetired {
+		http.Error(w, "retired pools cannot be reopened", http.StatusConflict)
+		return
+	}
+
+	usage, err := h.repo.Usage()
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if status == PoolRetired && usage[pool.ID].Companies > 0 {
+		http.Error(w, "pool still has companies", http.StatusConflict)
+		return
+	}
+
+	if err := h.repo.SetStatus(pool.ID, status); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	pool.Status = status
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toPoolResponse(*pool, usage[pool.ID]))
+}
+
+// RotatePoolCredentials godoc
+// @Summary      Rotate a storage pool's access key
+// @Description  Tests the new key against the pool's bucket, then switches the pool and every company placed in it to the new key.
+// @Description  The old key must stay valid at the provider until old_key_valid_until.
+// @Tags         storage
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string                    true  "Admin client id"
+// @Param        client_secret  header    string                    true  "Admin client secret"
+// @Param        id             path      string                    true  "Pool ID"
+// @Param        body           body      RotateCredentialsRequest  true  "New credentials"
+// @Success      200            {object}  RotateCredentialsResponse
+// @Failure      400            {string}  string "invalid request or credentials"
+// @Failure      404            {string}  string "storage pool not found"
+// @Failure      409            {string}  string "previous rotation still in overlap"
+// @Router       /storage/pools/{id}/rotate-credentials [post]
+func (h *Handler) RotatePoolCredentials(w http.ResponseWriter, r *http.Request) {
+	var req RotateCredentialsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.AwsAccessKey == "" || req.AwsSecretKey == "" {
+		http.Error(w, "aws_access_key and aws_secret_key are required", http.StatusBadRequest)
+		return
+	}
+	overlap := defaultRotationOverlap
+	if req.OverlapMinutes != 0 {
+		overlap = time.Duration(req.OverlapMinutes) * time.Minute
+	}
+	if overlap < clientCacheTTL || overlap > maxRotationOverlap {
+		http.Error(w, fmt.Sprintf("overlap_minutes must be between %d and %d",
+			int(clientCacheTTL.Minutes()), int(maxRotationOverlap.Minutes())), http.StatusBadRequest)
+		return
+	}
+
+	pool, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if pool == nil {
+		http.Error(w, errPoolNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if pool.Status == PoolRetired {
+		http.Error(w, "retired pools cannot be rotated", http.StatusConflict)
+		return
+	}
+	// A second rotation inside the window would drop a key that may still
+	// be in use.
+	if pool.PreviousKeyExpiresAt != nil && pool.PreviousKeyExpiresAt.After(time.Now()) {
+		http.Error(w, "previous rotation is still in its overlap window", http.StatusConflict)
+		return
+	}
+
+	if err := h.s3Service.CheckCredentials(r.Context(), pool.AwsBucketRegion, pool.AwsBucketName, req.AwsAccessKey, req.AwsSecretKey); err != nil {
+		http.Error(w, "new credentials cannot access the pool bucket", http.StatusBadRequest)
+		return
+	}
+
+	accessKey, err := h.s3Service.SealCredential(req.AwsAccessKey)
+	if err != nil {
+		http.Error(w, "failed to encrypt credentials", http.StatusInternalServerError)
+		return
+	}
+	secretKey, err := h.s3Service.SealCredential(req.AwsSecretKey)
+	if err != nil {
+		http.Error(w, "failed to encrypt credentials", http.StatusInternalServerError)
+		return
+	}
+
+	overlapUntil := time.Now().Add(overlap)
+	companies, err := h.repo.RotateCredentials(pool, accessKey, secretKey, overlapUntil)
+	if errors.Is(err, errConcurrentRotation) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	h.s3Service.InvalidateClients()
+
+	pool, err = h.repo.GetByID(pool.ID)
+	if err != nil || pool == nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	usage, err := h.repo.Usage()
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RotateCredentialsResponse{
+		Pool:             toPoolResponse(*pool, usage[pool.ID]),
+		CompaniesUpdated: companies,
+		OldKeyValidUntil: overlapUntil.Format(time.RFC3339),
+	})
+}
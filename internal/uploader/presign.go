@@ -0,0 +1,184 @@
+package uploader
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+)
+
+const (
+	defaultUploadURLExpiry = 15 * time.Minute
+	minUploadURLExpiry     = time.Minute
+	// MaxUploadURLExpiry bounds the lifetime of every upload URL. It is far
+	// below the week S3 signatures support so that suspensions, renames and
+	// storage migrations are not bypassed by URLs issued before them.
+	MaxUploadURLExpiry = time.Hour
+)
+
+// UploadURLExpiryRequest sets a cap in seconds; null removes it.
+type UploadURLExpiryRequest struct {
+	MaxUploadURLExpiry *int `json:"max_upload_url_expiry"`
+}
+
+type UploadURLExpiryResponse struct {
+	// MaxUploadURLExpiry is the company's own cap, if any.
+	MaxUploadURLExpiry *int `json:"max_upload_url_expiry,omitempty"`
+	// EffectiveMax is the cap after the pool's, in seconds.
+	EffectiveMax int `json:"effective_max"`
+	// Default is the lifetime of URLs requested without expires_in.
+	Default int `json:"default"`
+}
+
+// validUploadURLExpiry reports whether a cap in seconds is supported.
+func validUploadURLExpiry(seconds *int) bool {
+	if seconds == nil {
+		return true
+	}
+	d := time.Duration(*seconds) * time.Second
+	return d >= minUploadURLExpiry && d <= MaxUploadURLExpiry
+}
+
+// uploadURLExpiryLimit returns the longest upload URL lifetime the company
+// may request: the tightest of its own cap, its pool's and the S3 limit.
+func (h *Handler) uploadURLExpiryLimit(companyRec *company.Company) (time.Duration, error) {
+	limit := MaxUploadURLExpiry
+	if companyRec.UploaderConfigID != nil {
+		pool, err := h.repo.GetByID(*companyRec.UploaderConfigID)
+		if err != nil {
+			return 0, err
+		}
+		if pool != nil && pool.MaxUploadURLExpiry != nil {
+			limit = min(limit, time.Duration(*pool.MaxUploadURLExpiry)*time.Second)
+		}
+	}
+	if companyRec.MaxUploadURLExpiry != nil {
+		limit = min(limit, time.Duration(*companyRec.MaxUploadURLExpiry)*time.Second)
+	}
+	return limit, nil
+}
+
+// uploadURLExpiry returns the lifetime of an upload URL requested for
+// expiresIn seconds (0 for the default), clamped to limit.
+func uploadURLExpiry(expiresIn int, limit time.Duration) time.Duration {
+	expiry := defaultUploadURLExpiry
+	if expiresIn > 0 {
+		expiry = time.Duration(expiresIn) * time.Second
+	}
+	return min(expiry, limit)
+}
+
+// GetUploadURLExpiry godoc
+// @Summary      Get the upload URL lifetime settings of the calling company
+// @Tags         uploader
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Success      200        {object}  UploadURLExpiryResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/settings/upload-url-expiry [get]
+func (h *Handler) GetUploadURLExpiry(w http.ResponseWriter, r *http.Request) {
+	h.writeUploadURLExpiry(w, company.FromContext(r.Context()))
+}
+
+// SetUploadURLExpiry godoc
+// @Summary      Cap the lifetime of the calling company's upload URLs
+// @Description  Requests for longer URLs are shortened to the cap. The cap of the company's storage pool still applies.
+// @Tags         uploader
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string                  true  "Company API key"
+// @Param        body       body      UploadURLExpiryRequest  true  "Cap in seconds (60 to 3600), null to remove"
+// @Success      200        {object}  UploadURLExpiryResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/settings/upload-url-expiry [put]
+func (h *Handler) SetUploadURLExpiry(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+
+	var req UploadURLExpiryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if !validUploadURLExpiry(req.MaxUploadURLExpiry) {
+		http.Error(w, "max_upload_url_expiry must be between 60 and 3600 seconds", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.companyRepo.SetMaxUploadURLExpiry(companyRec.ID, req.MaxUploadURLExpiry); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	companyRec.MaxUploadURLExpiry = req.MaxUploadURLExpiry
+	h.writeUploadURLExpiry(w, companyRec)
+}
+
+func (h *Handler) writeUploadURLExpiry(w http.ResponseWriter, companyRec *company.Company) {
+	limit, err := h.uploadURLExpiryLimit(companyRec)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(UploadURLExpiryResponse{
+		MaxUploadURLExpiry: companyRec.MaxUploadURLExpiry,
+		EffectiveMax:       int(limit / time.Second),
+		Default:            int(uploadURLExpiry(0, limit) / time.Second),
+	})
+}
+
+// SetPoolUploadURLExpiry godoc
+// @Summary      Cap the lifetime of upload URLs in a storage pool
+// @Description  Applies to every company placed in the pool, on top of their own caps.
+// @Tags         storage
+// @Accept       json
+// @Produce      json
+// @Param        client_id      header    string                  true  "Admin client id"
+// @Param        client_secret  header    string                  true  "Admin client secret"
+// @Param        id             path      string                  true  "Pool ID"
+// @Param        body           body      UploadURLExpiryRequest  true  "Cap in seconds (60 to 3600), null to remove"
+// @Success      200            {object}  PoolResponse
+// @Failure      400            {string}  string "invalid request"
+// @Failure      404            {string}  string "storage pool not found"
+// @Router       /storage/pools/{id}/upload-url-expiry [put]
+func (h *Handler) SetPoolUploadURLExpiry(w http.ResponseWriter, r *http.Request) {
+	var req UploadURLExpiryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if !validUploadURLExpiry(req.MaxUploadURLExpiry) {
+		http.Error(w, "max_upload_url_expiry must be between 60 and 3600 seconds", http.StatusBadRequest)
+		return
+	}
+
+	pool, err := h.repo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if pool == nil {
+		http.Error(w, errPoolNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err := h.repo.SetMaxUploadURLExpiry(pool.ID, req.MaxUploadURLExpiry); err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	pool.MaxUploadURLExpiry = req.MaxUploadURLExpiry
+
+	usage, err := h.repo.Usage()
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toPoolResponse(*pool, usage[pool.ID]))
+}
@@ -0,0 +1,553 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/locpath"
+	"shreshtasmg.in/jupyter/internal/retention"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+type RenameFolderRequest struct {
+	Path        string  `json:"path"` // new loc_tag of the folder
+	FileTxnMeta *string `json:"file_txn_meta,omitempty"`
+}
+
+type FolderRenameResponse struct {
+	ID       string `json:"id"`
+	FolderID string `json:"folder_id"`
+	FromPath string `json:"from_path"`
+	ToPath   string `json:"to_path"`
+	// Status is copying, deleting (the files are at the new path, the old
+	// objects are being deleted) or completed.
+	Status string `json:"status"`
+	// MovedCount and MovedBytes count the files copied so far.
+	MovedCount int     `json:"moved_count"`
+	MovedBytes int64   `json:"moved_bytes"`
+	Error      *string `json:"error,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+	FinishedAt *string `json:"finished_at,omitempty"`
+}
+
+func toFolderRenameResponse(rename folder.Rename) FolderRenameResponse {
+	resp := FolderRenameResponse{
+		ID:         rename.ID,
+		FolderID:   rename.FolderID,
+		FromPath:   rename.FromPath,
+		ToPath:     rename.ToPath,
+		Status:     rename.Status,
+		MovedCount: rename.MovedCount,
+		MovedBytes: rename.MovedBytes,
+		Error:      rename.Error,
+		CreatedAt:  rename.CreatedAt.Format(time.RFC3339),
+	}
+	if rename.FinishedAt != nil {
+		finishedAt := rename.FinishedAt.Format(time.RFC3339)
+		resp.FinishedAt = &finishedAt
+	}
+	return resp
+}
+
+func writeFolderRename(w http.ResponseWriter, status int, rename *folder.Rename) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(toFolderRenameResponse(*rename))
+}
+
+// FolderRenamer runs folder renames in the background. A rename copies the
+// files to the new path, records the copies and the folder move in one
+// transaction and then deletes the old objects. Run again after a failure,
+// it continues where it stopped.
+type FolderRenamer struct {
+	folderRepo    folder.Repository
+	companyRepo   company.Repository
+	fileMetaRepo  filemeta.Repository
+	retentionRepo retention.Repository
+	storage       S3Service
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewFolderRenamer(folderRepo folder.Repository, companyRepo company.Repository, fileMetaRepo filemeta.Repository, retentionRepo retention.Repository, storage S3Service) *FolderRenamer {
+	return &FolderRenamer{
+		folderRepo:    folderRepo,
+		companyRepo:   companyRepo,
+		fileMetaRepo:  fileMetaRepo,
+		retentionRepo: retentionRepo,
+		storage:       storage,
+		running:       make(map[string]bool),
+	}
+}
+
+// Start runs a copy of the rename in the background unless it is already
+// running here.
+func (rn *FolderRenamer) Start(rename *folder.Rename) bool {
+	renameCopy := *rename
+	rename = &renameCopy
+
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if rn.running[rename.ID] {
+		return false
+	}
+	rn.running[rename.ID] = true
+
+	go func() {
+		defer func() {
+			rn.mu.Lock()
+			delete(rn.running, rename.ID)
+			rn.mu.Unlock()
+		}()
+		if err := rn.Run(context.Background(), rename); err != nil {
+			log.Printf("folder rename %s failed: %v", rename.ID, err)
+		}
+	}()
+	return true
+}
+
+// ResumeUnfinished starts the renames that have not completed. It is
+// called at startup.
+func (rn *FolderRenamer) ResumeUnfinished() error {
+	renames, err := rn.folderRepo.ListUnfinishedRenames()
+	if err != nil {
+		return err
+	}
+	for i := range renames {
+		rn.Start(&renames[i])
+	}
+	return nil
+}
+
+// Run renames until completed. On failure the error is stored and the
+// rename keeps its paths locked until it is resumed.
+func (rn *FolderRenamer) Run(ctx context.Context, rename *folder.Rename) error {
+	err := rn.run(ctx, rename)
+	if err != nil {
+		msg := err.Error()
+		rename.Error = &msg
+		_ = rn.folderRepo.SaveRename(rename)
+	}
+	return err
+}
+
+func (rn *FolderRenamer) run(ctx context.Context, rename *folder.Rename) error {
+	companyRec, err := rn.companyRepo.GetByID(rename.CompanyID)
+	if err != nil {
+		return fmt.Errorf("failed to look up company: %w", err)
+	}
+	if companyRec == nil {
+		return fmt.Errorf("company %s not found", rename.CompanyID)
+	}
+	oldKey := filemeta.FolderKeyPrefix(companyRec.CompanySlug, rename.FromPath)
+	newKey := filemeta.FolderKeyPrefix(companyRec.CompanySlug, rename.ToPath)
+
+	if rename.Error != nil {
+		rename.Error = nil
+		if err := rn.folderRepo.SaveRename(rename); err != nil {
+			return err
+		}
+	}
+	if rename.Status == folder.RenameCopying {
+		if err := rn.copyFiles(ctx, companyRec, rename, oldKey, newKey); err != nil {
+			return err
+		}
+		if err := rn.switchPath(ctx, companyRec, rename, oldKey, newKey); err != nil {
+			return err
+		}
+	}
+	if err := rn.deleteOld(ctx, companyRec, oldKey); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rename.Status = folder.RenameCompleted
+	rename.FinishedAt = &now
+	return rn.folderRepo.SaveRename(rename)
+}
+
+// copyFile copies sourceKey to targetKey in its current storage class,
+// which lifecycle transitions may have changed since the upload. It does
+// nothing if the source is gone.
+func (rn *FolderRenamer) copyFile(ctx context.Context, companyRec *company.Company, sourceKey, targetKey string) error {
+	info, err := rn.storage.HeadObject(ctx, companyRec, sourceKey)
+	if err != nil {
+		return fmt.Errorf("failed to read object stats of %s: %w", sourceKey, err)
+	}
+	if info == nil {
+		return nil
+	}
+	if !info.Readable() {
+		return fmt.Errorf("file %s is archived, restore it and resume the rename", sourceKey)
+	}
+	if err := rn.storage.CopyObjectWithin(ctx, companyRec, sourceKey, targetKey, info.StorageClass); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", sourceKey, err)
+	}
+	return nil
+}
+
+// copyFiles copies the files after the LastKey cursor and the sub folders
+// to the new path.
+func (rn *FolderRenamer) copyFiles(ctx context.Context, companyRec *company.Company, rename *folder.Rename, oldKey, newKey string) error {
+	files, err := rn.fileMetaRepo.ListFiles(companyRec.ID, oldKey)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	for _, file := range files {
+		if file.FileKey <= rename.LastKey {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := rn.copyFile(ctx, companyRec, file.FileKey, newKey+strings.TrimPrefix(file.FileKey, oldKey)); err != nil {
+			return err
+		}
+		rename.LastKey = file.FileKey
+		rename.MovedCount++
+		rename.MovedBytes += file.FileSize
+		if err := rn.folderRepo.SaveRename(rename); err != nil {
+			return err
+		}
+	}
+
+	tree, err := rn.folderRepo.ListTree(companyRec.ID, rename.FromPath)
+	if err != nil {
+		return fmt.Errorf("failed to list folders: %w", err)
+	}
+	for _, sub := range tree {
+		if err := rn.storage.PutFolderPlaceholder(ctx, companyRec, newKey+strings.TrimPrefix(sub.Path+"/", rename.FromPath+"/")); err != nil {
+			return fmt.Errorf("failed to create folder %s in storage: %w", sub.Path, err)
+		}
+	}
+	return nil
+}
+
+// switchPath records the copies as uploads, the old path as a folder
+// delete and moves the folder resources, all in one transaction. Files
+// that reached the old path through upload URLs issued before the rename
+// are copied first.
+func (rn *FolderRenamer) switchPath(ctx context.Context, companyRec *company.Company, rename *folder.Rename, oldKey, newKey string) error {
+	files, err := rn.fileMetaRepo.ListFiles(companyRec.ID, oldKey)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	block, err := retentionBlock(rn.retentionRepo, companyRec, files, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to check retention rules: %w", err)
+	}
+	if block != nil {
+		return fmt.Errorf("file %v is protected (%v), resume the rename once it is released", block["file_key"], block["error"])
+	}
+
+	targetKeys := make([]string, len(files))
+	copies := make([]*ObjectInfo, len(files))
+	for i, file := range files {
+		targetKey := newKey + strings.TrimPrefix(file.FileKey, oldKey)
+		targetKeys[i] = targetKey
+		info, err := rn.storage.HeadObject(ctx, companyRec, targetKey)
+		if err != nil {
+			return fmt.Errorf("failed to read object stats of %s: %w", targetKey, err)
+		}
+		if info == nil || info.Size != file.FileSize {
+			if err := rn.copyFile(ctx, companyRec, file.FileKey, targetKey); err != nil {
+				return err
+			}
+			if info, err = rn.storage.HeadObject(ctx, companyRec, targetKey); err != nil {
+				return fmt.Errorf("failed to read object stats of %s: %w", targetKey, err)
+			}
+			if info == nil {
+				return fmt.Errorf("file %s is missing from storage", file.FileKey)
+			}
+		}
+		copies[i] = info
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.FileMetaID)
+	}
+	tags, err := rn.fileMetaRepo.TagsByFileIDs(ids)
+	if err != nil {
+		return fmt.Errorf("failed to load tags: %w", err)
+	}
+
+	switched := *rename
+	switched.Status = folder.RenameDeleting
+	switched.MovedCount = len(files)
+	switched.MovedBytes = 0
+	err = rn.folderRepo.Transaction(func(tx folder.Stores) error {
+		for i, file := range files {
+			meta := &filemeta.FileMeta{
+				ID:           utils.GenerateID(),
+				FileName:     file.FileName,
+				FileSize:     file.FileSize,
+				FileKey:      targetKeys[i],
+				FileTxnType:  file.FileTxnType,
+				FileTxnMeta:  rename.FileTxnMeta,
+				ETag:         optionalString(copies[i].ETag),
+				Description:  file.Description,
+				Metadata:     file.Metadata,
+				StorageClass: optionalString(copies[i].StorageClass),
+				CompanyID:    &companyRec.ID,
+			}
+			if err := tx.FileMetas.Create(meta); err != nil {
+				return err
+			}
+			if err := tx.FileMetas.AddTags(companyRec.ID, meta.ID, tags[file.FileMetaID]); err != nil {
+				return err
+			}
+			switched.MovedBytes += file.FileSize
+		}
+		if err := tx.Folders.Move(companyRec.ID, rename.FromPath, rename.ToPath); err != nil {
+			return err
+		}
+		if err := tx.FileMetas.Create(&filemeta.FileMeta{
+			ID:          utils.GenerateID(),
+			FileSize:    switched.MovedBytes,
+			FileKey:     rename.FromPath,
+			FileTxnType: filemeta.TxnFolderDelete,
+			FileTxnMeta: rename.FileTxnMeta,
+			CompanyID:   &companyRec.ID,
+		}); err != nil {
+			return err
+		}
+		return tx.Folders.SaveRename(&switched)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record the move: %w", err)
+	}
+	*rename = switched
+	return nil
+}
+
+// deleteOld deletes the objects left under the old path, keeping files
+// uploaded there after the switch.
+func (rn *FolderRenamer) deleteOld(ctx context.Context, companyRec *company.Company, oldKey string) error {
+	objects, err := rn.storage.ListObjects(ctx, companyRec, oldKey)
+	if err != nil {
+		return fmt.Errorf("failed to list old objects: %w", err)
+	}
+	current, err := rn.fileMetaRepo.ListFiles(companyRec.ID, oldKey)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	keep := make(map[string]bool, len(current))
+	for _, file := range current {
+		keep[file.FileKey] = true
+	}
+	for _, object := range objects {
+		if keep[object.Key] {
+			continue
+		}
+		if err := rn.storage.DeleteObject(ctx, companyRec, object.Key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", object.Key, err)
+		}
+	}
+	return nil
+}
+
+// RenameFolder godoc
+// @Summary      Rename or move a folder
+// @Description  Starts a rename that copies every file and sub folder to the new path, records the copies as uploads and the old path as a folder delete, then deletes the old objects; used quota is unchanged.
+// @Description  Until it completes, uploads, deletes and renames in the old and new folders are refused with 409. Poll GET /uploader/folders/renames/{id}; a failed rename reports its error and is resumed with POST /uploader/folders/renames/{id}/resume.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string               true  "Company API key"
+// @Param        id         path      string               true  "Folder ID"
+// @Param        body       body      RenameFolderRequest  true  "New path"
+// @Success      202        {object}  FolderRenameResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "folder not found"
+// @Failure      409        {object}  map[string]interface{} "path in use, folder in trash or being renamed, retention_active, object_locked or archived file"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/{id}/rename [post]
+func (h *Handler) RenameFolder(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+	f := h.companyFolder(w, r, companyRec)
+	if f == nil {
+		return
+	}
+	if f.Trashed() {
+		http.Error(w, "folder is in the trash", http.StatusConflict)
+		return
+	}
+
+	var req RenameFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	// Paths differing only in case name the same location, so a rename
+	// cannot change just the case.
+	newPath, err := locpath.Clean(req.Path)
+	if err != nil {
+		http.Error(w, "path: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if newPath, err = CanonicalLocTag(h.folderRepo, h.fileMetaRepo, companyRec.ID, newPath); err != nil {
+		http.Error(w, "failed to look up locations", http.StatusInternalServerError)
+		return
+	}
+	newKey, err := FolderKey(companyRec, newPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if newPath == f.Path {
+		http.Error(w, "path is unchanged", http.StatusBadRequest)
+		return
+	}
+	if folder.Within(newPath, f.Path) {
+		http.Error(w, "a folder cannot be moved into itself", http.StatusBadRequest)
+		return
+	}
+	if !CheckWritable(w, h.folderRepo, companyRec.ID, newPath) {
+		return
+	}
+	oldKey := filemeta.FolderKeyPrefix(companyRec.CompanySlug, f.Path)
+
+	// The target must be unused so the move cannot overwrite anything.
+	targetFolders, err := h.folderRepo.ListTree(companyRec.ID, newPath)
+	if err != nil {
+		http.Error(w, "failed to look up folders", http.StatusInternalServerError)
+		return
+	}
+	targetFiles, err := h.fileMetaRepo.ListFiles(companyRec.ID, newKey)
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+		return
+	}
+	if len(targetFolders) > 0 || len(targetFiles) > 0 {
+		http.Error(w, "path is already in use", http.StatusConflict)
+		return
+	}
+
+	files, err := h.fileMetaRepo.ListFiles(companyRec.ID, oldKey)
+	if err != nil {
+		http.Error(w, "failed to look up file meta", http.StatusInternalServerError)
+		return
+	}
+	blocked, err := h.writeRetentionBlock(w, companyRec, files)
+	if err != nil {
+		http.Error(w, "failed to check retention rules", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		return
+	}
+	// Archived objects cannot be copied; refuse before anything is locked.
+	for _, file := range files {
+		info, err := h.s3Service.HeadObject(ctx, companyRec, file.FileKey)
+		if err != nil {
+			http.Error(w, "failed to read object stats", http.StatusInternalServerError)
+			return
+		}
+		if info != nil && !info.Readable() {
+			http.Error(w, "file "+file.FileKey+" is archived and must be restored first", http.StatusConflict)
+			return
+		}
+	}
+
+	rename := &folder.Rename{
+		ID:          utils.GenerateID(),
+		CompanyID:   companyRec.ID,
+		FolderID:    f.ID,
+		FromPath:    f.Path,
+		ToPath:      newPath,
+		FileTxnMeta: req.FileTxnMeta,
+		Status:      folder.RenameCopying,
+	}
+	created, err := h.folderRepo.CreateRename(rename)
+	if err != nil {
+		http.Error(w, "failed to start rename", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		http.Error(w, "folder is being renamed", http.StatusConflict)
+		return
+	}
+	h.renamer.Start(rename)
+
+	writeFolderRename(w, http.StatusAccepted, rename)
+}
+
+// companyRename loads the rename of the id URL param, answering 404 if it
+// does not belong to companyRec. It returns nil if a response was written.
+func (h *Handler) companyRename(w http.ResponseWriter, r *http.Request, companyRec *company.Company) *folder.Rename {
+	rename, err := h.folderRepo.GetRename(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "failed to look up rename", http.StatusInternalServerError)
+		return nil
+	}
+	if rename == nil || rename.CompanyID != companyRec.ID {
+		http.Error(w, "rename not found", http.StatusNotFound)
+		return nil
+	}
+	return rename
+}
+
+// GetFolderRename godoc
+// @Summary      Get the progress of a folder rename
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "Rename ID"
+// @Success      200        {object}  FolderRenameResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "rename not found"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/renames/{id} [get]
+func (h *Handler) GetFolderRename(w http.ResponseWriter, r *http.Request) {
+	rename := h.companyRename(w, r, company.FromContext(r.Context()))
+	if rename == nil {
+		return
+	}
+	writeFolderRename(w, http.StatusOK, rename)
+}
+
+// ResumeFolderRename godoc
+// @Summary      Resume an interrupted or failed folder rename
+// @Tags         folders
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "Rename ID"
+// @Success      202        {object}  FolderRenameResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "rename not found"
+// @Failure      409        {string}  string "rename is running or completed"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/folders/renames/{id}/resume [post]
+func (h *Handler) ResumeFolderRename(w http.ResponseWriter, r *http.Request) {
+	rename := h.companyRename(w, r, company.FromContext(r.Context()))
+	if rename == nil {
+		return
+	}
+	if rename.Finished() {
+		http.Error(w, "rename is completed", http.StatusConflict)
+		return
+	}
+	if !h.renamer.Start(rename) {
+		http.Error(w, "rename is already running", http.StatusConflict)
+		return
+	}
+
+	writeFolderRename(w, http.StatusAccepted, rename)
+}
@@ -2,15 +2,50 @@ package uploader
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
 )
 
 type Repository interface {
 	Create(cfg *UploaderConfig) error
-	FindActiveConfig() (*UploaderConfig, error)
+	GetByID(id string) (*UploaderConfig, error)
+	GetByName(name string) (*UploaderConfig, error)
+	List() ([]UploaderConfig, error)
+	ListByStatus(status string) ([]UploaderConfig, error)
+	SetStatus(id, status string) error
+	SetMaxUploadURLExpiry(id string, seconds *int) error
+	// Usage returns the usage of every pool that has companies, keyed by pool ID.
+	Usage() (map[string]PoolUsage, error)
+	// AdoptLegacyConfigs fills pool name and status of configs created before
+	// pools existed, and links companies to the pool of their bucket.
+	AdoptLegacyConfigs() error
+	// PrepareUniqueNames makes existing pool names unique and drops their
+	// old non-unique index. It must run before AutoMigrate adds the unique
+	// index.
+	PrepareUniqueNames() error
+	// RotateCredentials replaces the pool's sealed credentials and those of
+	// every company and migration job using them in one transaction, keeping
+	// the old ones as previous until overlapUntil. It returns the number of
+	// companies updated.
+	RotateCredentials(cfg *UploaderConfig, accessKey, secretKey string, overlapUntil time.Time) (int64, error)
+
+	// Transaction runs fn with stores bound to a single transaction.
+	Transaction(fn func(tx Stores) error) error
+}
+
+// Stores are the repositories a file delete is recorded with.
+type Stores struct {
+	Companies company.Repository
+	FileMetas filemeta.Repository
 }
 
+// errConcurrentRotation is returned when the pool's credentials changed
+// while a rotation was in progress.
+var errConcurrentRotation = errors.New("credentials were rotated concurrently")
+
 type repository struct {
 	db *gorm.DB
 }
@@ -23,13 +58,196 @@ func (r *repository) Create(cfg *UploaderConfig) error {
 	return r.db.Create(cfg).Error
 }
 
-func (r *repository) FindActiveConfig() (*UploaderConfig, error) {
-	var activeUploaderConfig UploaderConfig
-	if err := r.db.Where("is_active = ?", true).First(&activeUploaderConfig).Error; err != nil {
+func (r *repository) GetByID(id string) (*UploaderConfig, error) {
+	var cfg UploaderConfig
+	if err := r.db.Where("id = ?", id).First(&cfg).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &activeUploaderConfig, nil
+	return &cfg, nil
+}
+
+func (r *repository) GetByName(name string) (*UploaderConfig, error) {
+	var cfg UploaderConfig
+	if err := r.db.Where("name = ?", name).First(&cfg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (r *repository) List() ([]UploaderConfig, error) {
+	var cfgs []UploaderConfig
+	if err := r.db.Order("created_at").Find(&cfgs).Error; err != nil {
+		return nil, err
+	}
+	return cfgs, nil
+}
+
+func (r *repository) ListByStatus(status string) ([]UploaderConfig, error) {
+	var cfgs []UploaderConfig
+	if err := r.db.Where("status = ?", status).Order("created_at").Find(&cfgs).Error; err != nil {
+		return nil, err
+	}
+	return cfgs, nil
+}
+
+func (r *repository) SetMaxUploadURLExpiry(id string, seconds *int) error {
+	return r.db.Model(&UploaderConfig{}).
+		Where("id = ?", id).
+		Update("max_upload_url_expiry", seconds).Error
+}
+
+func (r *repository) SetStatus(id, status string) error {
+	isActive := 0
+	if status == PoolActive {
+		isActive = 1
+	}
+	return r.db.Model(&UploaderConfig{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "is_active": isActive}).Error
+}
+
+func (r *repository) Usage() (map[string]PoolUsage, error) {
+	var rows []PoolUsage
+	if err := r.db.Model(&company.Company{}).
+		Select("uploader_config_id, COUNT(*) AS companies, COALESCE(SUM(total_usage_quota), 0) AS allocated_quota, COALESCE(SUM(used_quota), 0) AS used_quota").
+		Where("uploader_config_id IS NOT NULL").
+		Group("uploader_config_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]PoolUsage, len(rows))
+	for _, row := range rows {
+		usage[row.UploaderConfigID] = row
+	}
+	return usage, nil
+}
+
+func (r *repository) AdoptLegacyConfigs() error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := uniquePoolNames(tx); err != nil {
+			return err
+		}
+		if err := tx.Model(&UploaderConfig{}).
+			Where("is_active = 1 AND status = ?", PoolInactive).
+			Update("status", PoolActive).Error; err != nil {
+			return err
+		}
+		return tx.Model(&company.Company{}).
+			Where("uploader_config_id IS NULL").
+			Update("uploader_config_id", gorm.Expr(
+				"(SELECT u.id FROM uploader_config u WHERE u.aws_bucket_name = companies.aws_bucket_name ORDER BY u.created_at LIMIT 1)",
+			)).Error
+	})
+}
+
+// legacyNameIndex is the non-unique index pool names had before.
+const legacyNameIndex = "idx_uploader_config_name"
+
+func (r *repository) PrepareUniqueNames() error {
+	migrator := r.db.Migrator()
+	if !migrator.HasTable(&UploaderConfig{}) || !migrator.HasColumn(&UploaderConfig{}, "name") {
+		return nil
+	}
+	if err := r.db.Transaction(uniquePoolNames); err != nil {
+		return err
+	}
+	if migrator.HasIndex(&UploaderConfig{}, legacyNameIndex) {
+		return migrator.DropIndex(&UploaderConfig{}, legacyNameIndex)
+	}
+	return nil
+}
+
+// uniquePoolNames names unnamed pools after their bucket and appends the
+// pool ID to names already taken by an older pool.
+func uniquePoolNames(tx *gorm.DB) error {
+	var cfgs []UploaderConfig
+	if err := tx.Select("id", "name", "aws_bucket_name").Order("created_at, id").Find(&cfgs).Error; err != nil {
+		return err
+	}
+	taken := make(map[string]bool, len(cfgs))
+	for _, cfg := range cfgs {
+		name := cfg.Name
+		if name == "" {
+			name = cfg.AwsBucketName
+		}
+		if taken[name] {
+			// Fits the 64 characters of the column with a 40 character ID.
+			name = name[:min(len(name), 23)] + "-" + cfg.ID
+		}
+		taken[name] = true
+		if name != cfg.Name {
+			if err := tx.Model(&UploaderConfig{}).Where("id = ?", cfg.ID).Update("name", name).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *repository) RotateCredentials(cfg *UploaderConfig, accessKey, secretKey string, overlapUntil time.Time) (int64, error) {
+	var companies int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UploaderConfig{}).
+			Where("id = ? AND credentials_version = ?", cfg.ID, cfg.CredentialsVersion).
+			Updates(map[string]interface{}{
+				"aws_access_key":          accessKey,
+				"aws_secret_key":          secretKey,
+				"previous_access_key":     cfg.AwsAccessKey,
+				"previous_secret_key":     cfg.AwsSecretKey,
+				"previous_key_expires_at": overlapUntil,
+				"credentials_version":     gorm.Expr("credentials_version + 1"),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errConcurrentRotation
+		}
+
+		// Companies with keys of their own keep them.
+		res = tx.Model(&company.Company{}).
+			Where("uploader_config_id = ? AND aws_iam_user IS NULL", cfg.ID).
+			Updates(map[string]interface{}{
+				"aws_access_key": accessKey,
+				"aws_secret_key": secretKey,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		companies = res.RowsAffected
+
+		// Migration jobs keep a copy of both sides' credentials. A target
+		// without a config of its own uses the source's keys.
+		if err := tx.Table("storage_migrations").
+			Where("source_config_id = ?", cfg.ID).
+			Updates(map[string]interface{}{
+				"source_access_key": accessKey,
+				"source_secret_key": secretKey,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Table("storage_migrations").
+			Where("target_config_id = ? OR (target_config_id IS NULL AND source_config_id = ?)", cfg.ID, cfg.ID).
+			Updates(map[string]interface{}{
+				"target_access_key": accessKey,
+				"target_secret_key": secretKey,
+			}).Error
+	})
+	return companies, err
+}
+
+func (r *repository) Transaction(fn func(tx Stores) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(Stores{
+			Companies: company.NewRepository(tx),
+			FileMetas: filemeta.NewRepository(tx),
+		})
+	})
 }
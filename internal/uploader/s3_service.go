@@ -1,27 +1,49 @@
 package uploader
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"path/filepath"
-	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/config"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/retention"
+	"shreshtasmg.in/jupyter/internal/secrets"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 type S3Service interface {
+	// GeneratePresignedUploadURL presigns a PUT of objectKey valid for
+	// expires. The client must send opts.Headers() along.
 	GeneratePresignedUploadURL(
 		ctx context.Context,
 		company *company.Company,
 		objectKey string,
 		fileSize int64,
+		expires time.Duration,
+		opts UploadOptions,
+	) (string, error)
+
+	GeneratePresignedDownloadURL(
+		ctx context.Context,
+		company *company.Company,
+		objectKey string,
+		expires time.Duration,
 	) (string, error)
 
 	DeleteObject(
@@ -36,17 +58,224 @@ type S3Service interface {
 		prefix string,
 	) (deletedCount int, deletedBytes int64, err error)
 
-	ListPrefixes(ctx context.Context, companyRec *company.Company, fullPrefix string, limit int, nextToken string) ([]string, *string, error)
-	ListFilesInFolder(ctx context.Context, companyRec *company.Company, folderPrefix string, limit int, nextToken string) ([]string, *string, error)
+	ListObjects(ctx context.Context, companyRec *company.Company, prefix string) ([]ObjectInfo, error)
+	PrefixInUse(ctx context.Context, companyRec *company.Company, prefix string) (bool, error)
+
+	// HeadObject returns the object's stats, or nil if it does not exist.
+	HeadObject(ctx context.Context, companyRec *company.Company, objectKey string) (*ObjectInfo, error)
+
+	// GetObject opens objectKey for reading, limited to byteRange (an HTTP
+	// Range header value) if it is not empty. It returns nil if the object
+	// does not exist and ErrInvalidRange if the range is unsatisfiable. The
+	// caller must close the body.
+	GetObject(ctx context.Context, companyRec *company.Company, objectKey, byteRange string) (*ObjectReader, error)
+
+	// CopyObject copies objectKey from the source company storage to the
+	// target one, server side when both share credentials. Objects larger
+	// than a single request allows are copied in parts.
+	CopyObject(ctx context.Context, source, target *company.Company, objectKey string) error
+
+	// CopyObjectWithin copies sourceKey to targetKey in the company's bucket,
+	// stored in storageClass (empty for STANDARD).
+	CopyObjectWithin(ctx context.Context, companyRec *company.Company, sourceKey, targetKey, storageClass string) error
+
+	// PutFolderPlaceholder writes the empty object that marks folderKey
+	// (ending in '/') as an existing folder.
+	PutFolderPlaceholder(ctx context.Context, companyRec *company.Company, folderKey string) error
+
+	// ProvisionBucket creates the company's bucket with the pool's keys,
+	// with versioning, default encryption, blocked public access and CORS
+	// for browser uploads. It then creates an IAM user limited to the bucket
+	// and stores its sealed keys and name in companyRec. On failure nothing
+	// is left behind.
+	ProvisionBucket(ctx context.Context, companyRec *company.Company, pool *UploaderConfig) error
+	// DeprovisionBucket deletes the empty bucket and IAM user created by
+	// ProvisionBucket, with the pool's keys.
+	DeprovisionBucket(ctx context.Context, companyRec *company.Company, pool *UploaderConfig) error
+
+	// Multipart uploads back resumable uploads. Parts are numbered from 1 and
+	// CompleteMultipartUpload assembles all uploaded parts in order; it
+	// returns ErrNoSuchUpload if the upload was completed or aborted already.
+	CreateMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey string, opts UploadOptions) (string, error)
+	UploadPart(ctx context.Context, companyRec *company.Company, objectKey, uploadID string, partNumber int32, data []byte) error
+	CompleteMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey, uploadID string) (etag string, err error)
+	AbortMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey, uploadID string) error
+
+	// ObjectLockEnabled reports whether the company bucket has Object Lock
+	// enabled.
+	ObjectLockEnabled(ctx context.Context, companyRec *company.Company) (bool, error)
+	// SetLegalHold turns the legal hold of objectKey on or off.
+	SetLegalHold(ctx context.Context, companyRec *company.Company, objectKey string, on bool) error
+
+	// RestoreObject requests a temporary copy of an archived object, readable
+	// for days once the restore finished. tier is Standard, Bulk or
+	// Expedited. It returns ErrRestoreInProgress if a restore is running.
+	RestoreObject(ctx context.Context, companyRec *company.Company, objectKey string, days int32, tier string) error
+	// PutTransitionRules replaces the company's rules in the lifecycle
+	// configuration of its bucket with the transition rules among rules,
+	// keeping the rules of other companies and operators.
+	PutTransitionRules(ctx context.Context, companyRec *company.Company, rules []retention.Rule) error
+
+	// SealCredential encrypts a cloud credential for storage in the database.
+	SealCredential(value string) (string, error)
+
+	// CheckCredentials verifies that the plaintext credentials can access bucket.
+	CheckCredentials(ctx context.Context, region, bucket, accessKey, secretKey string) error
+
+	// InvalidateClients drops all cached S3 clients, e.g. after credentials
+	// were rotated.
+	InvalidateClients()
+}
+
+// clientCacheTTL bounds how long a cached client outlives a credential
+// rotation on instances that did not perform it. Rotation overlap windows
+// must be at least this long.
+const clientCacheTTL = 5 * time.Minute
+
+type cachedClient struct {
+	client  *s3.Client
+	expires time.Time
+}
+
+// ErrRestoreInProgress is returned when a restore of the object is already
+// running.
+var ErrRestoreInProgress = errors.New("restore already in progress")
+
+// ErrInvalidRange is returned for a range outside the object.
+var ErrInvalidRange = errors.New("requested range not satisfiable")
+
+// ErrNoSuchUpload is returned for a multipart upload that was completed or
+// aborted already.
+var ErrNoSuchUpload = errors.New("multipart upload does not exist")
+
+// ObjectReader streams an object or a range of it.
+type ObjectReader struct {
+	Body          io.ReadCloser
+	ContentLength int64
+	// ContentRange is set when a range was returned, e.g. "bytes 0-99/1000".
+	ContentRange string
+	ContentType  string
 }
 
-type s3Service struct{}
+// ObjectInfo describes a stored object as reported by S3.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	// Only set by HeadObject.
+	ContentType  string
+	StorageClass string        // empty for STANDARD
+	Restore      *RestoreState // nil if no restore was requested
+}
 
-func NewS3Service() S3Service {
-	return &s3Service{}
+// RestoreState is the state of the restore of an archived object.
+type RestoreState struct {
+	Ongoing bool
+	// ExpiresAt is when the restored copy is removed again, once the restore
+	// finished.
+	ExpiresAt *time.Time
+}
+
+// Readable reports whether the object's data can be downloaded.
+func (o ObjectInfo) Readable() bool {
+	if !filemeta.Archived(o.StorageClass) {
+		return true
+	}
+	return o.Restore != nil && !o.Restore.Ongoing
 }
 
-func buildS3Client(ctx context.Context, companyRec *company.Company) (*s3.Client, error) {
+// UploadOptions are the settings an object is written with. Presigned
+// uploads sign the headers that carry them.
+type UploadOptions struct {
+	Lock               *ObjectLock
+	StorageClass       string // empty for STANDARD
+	ContentType        string
+	CacheControl       string
+	ContentDisposition string
+}
+
+// Headers returns the headers a client must send with a presigned upload,
+// besides Content-MD5 for locked objects.
+func (o UploadOptions) Headers() map[string]string {
+	headers := o.Lock.Headers()
+	for name, value := range map[string]string{
+		"x-amz-storage-class": o.StorageClass,
+		"Content-Type":        o.ContentType,
+		"Cache-Control":       o.CacheControl,
+		"Content-Disposition": o.ContentDisposition,
+	} {
+		if value == "" {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[name] = value
+	}
+	return headers
+}
+
+// optionalHeader returns nil for an unset header value.
+func optionalHeader(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
+
+type s3Service struct {
+	corsOrigins []string
+	keyring     *secrets.Keyring
+
+	mu      sync.Mutex
+	clients map[string]cachedClient // keyed by region and access key
+
+	lifecycleMu sync.Mutex // held while a bucket lifecycle is read and written
+}
+
+func NewS3Service(cfg *config.Config, keyring *secrets.Keyring) S3Service {
+	return &s3Service{
+		corsOrigins: cfg.StorageCORSOrigins,
+		keyring:     keyring,
+		clients:     make(map[string]cachedClient),
+	}
+}
+
+func (s *s3Service) InvalidateClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.clients)
+}
+
+func (s *s3Service) CheckCredentials(ctx context.Context, region, bucket, accessKey, secretKey string) error {
+	s3Client, err := newS3Client(ctx, region, accessKey, secretKey)
+	if err != nil {
+		return err
+	}
+	_, err = s3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	return err
+}
+
+func (s *s3Service) SealCredential(value string) (string, error) {
+	return s.keyring.Seal(value)
+}
+
+// credentials decrypts the company's stored access key and secret. This is
+// the only place stored credentials are decrypted.
+func (s *s3Service) credentials(companyRec *company.Company) (string, string, error) {
+	accessKey, err := s.keyring.Open(*companyRec.AwsAccessKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt access key: %w", err)
+	}
+	secretKey, err := s.keyring.Open(*companyRec.AwsSecretKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt secret key: %w", err)
+	}
+	return accessKey, secretKey, nil
+}
+
+func (s *s3Service) buildS3Client(ctx context.Context, companyRec *company.Company) (*s3.Client, error) {
 	if companyRec.AwsBucketName == nil ||
 		companyRec.AwsBucketRegion == nil ||
 		companyRec.AwsAccessKey == nil ||
@@ -54,13 +283,33 @@ func buildS3Client(ctx context.Context, companyRec *company.Company) (*s3.Client
 		return nil, fmt.Errorf("company AWS configuration is incomplete")
 	}
 
+	accessKey, secretKey, err := s.credentials(companyRec)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := *companyRec.AwsBucketRegion + "/" + accessKey
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.clients[cacheKey]; ok && time.Now().Before(cached.expires) {
+		return cached.client, nil
+	}
+	s3Client, err := newS3Client(ctx, *companyRec.AwsBucketRegion, accessKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	s.clients[cacheKey] = cachedClient{client: s3Client, expires: time.Now().Add(clientCacheTTL)}
+	return s3Client, nil
+}
+
+func newS3Client(ctx context.Context, region, accessKey, secretKey string) (*s3.Client, error) {
 	awsCfg, err := awsconfig.LoadDefaultConfig(
 		ctx,
-		awsconfig.WithRegion(*companyRec.AwsBucketRegion),
+		awsconfig.WithRegion(region),
 		awsconfig.WithCredentialsProvider(
 			credentials.NewStaticCredentialsProvider(
-				*companyRec.AwsAccessKey,
-				*companyRec.AwsSecretKey,
+				accessKey,
+				secretKey,
 				"",
 			),
 		),
@@ -77,18 +326,30 @@ func (s *s3Service) GeneratePresignedUploadURL(
 	companyRec *company.Company,
 	objectKey string,
 	fileSize int64,
+	expires time.Duration,
+	opts UploadOptions,
 ) (string, error) {
-	s3Client, err := buildS3Client(ctx, companyRec)
+	s3Client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
 		return "", err
 	}
 
+	input := &s3.PutObjectInput{
+		Bucket:             aws.String(*companyRec.AwsBucketName),
+		Key:                aws.String(objectKey),
+		ContentLength:      aws.Int64(fileSize),
+		StorageClass:       types.StorageClass(opts.StorageClass),
+		ContentType:        optionalHeader(opts.ContentType),
+		CacheControl:       optionalHeader(opts.CacheControl),
+		ContentDisposition: optionalHeader(opts.ContentDisposition),
+	}
+	if lock := opts.Lock; lock != nil {
+		input.ObjectLockMode = types.ObjectLockMode(lock.Mode)
+		input.ObjectLockRetainUntilDate = aws.Time(lock.RetainUntil)
+	}
+
 	presigner := s3.NewPresignClient(s3Client)
-	out, err := presigner.PresignPutObject(ctx, &s3.PutObjectInput{
-		Bucket:        aws.String(*companyRec.AwsBucketName),
-		Key:           aws.String(objectKey),
-		ContentLength: aws.Int64(fileSize),
-	}, s3.WithPresignExpires(15*time.Minute))
+	out, err := presigner.PresignPutObject(ctx, input, s3.WithPresignExpires(expires))
 	if err != nil {
 		return "", fmt.Errorf("failed to presign put object: %w", err)
 	}
@@ -96,12 +357,36 @@ func (s *s3Service) GeneratePresignedUploadURL(
 	return out.URL, nil
 }
 
+func (s *s3Service) GeneratePresignedDownloadURL(
+	ctx context.Context,
+	companyRec *company.Company,
+	objectKey string,
+	expires time.Duration,
+) (string, error) {
+	s3Client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return "", err
+	}
+
+	presigner := s3.NewPresignClient(s3Client)
+	out, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(*companyRec.AwsBucketName),
+		Key:                        aws.String(objectKey),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", filepath.Base(objectKey))),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign get object: %w", err)
+	}
+
+	return out.URL, nil
+}
+
 func (s *s3Service) DeleteObject(
 	ctx context.Context,
 	companyRec *company.Company,
 	objectKey string,
 ) error {
-	client, err := buildS3Client(ctx, companyRec)
+	client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
 		return err
 	}
@@ -117,141 +402,625 @@ func (s *s3Service) DeleteObject(
 	return nil
 }
 
-// DeletePrefix deletes all objects under the given prefix.
-// NOTE: this is a simple, single-page implementation. For very large folders,
-// you should paginate ListObjectsV2.
+// DeletePrefix deletes all objects under the given prefix. Folder
+// placeholders are deleted too but not counted.
 func (s *s3Service) DeletePrefix(
 	ctx context.Context,
 	companyRec *company.Company,
 	prefix string,
 ) (int, int64, error) {
-	client, err := buildS3Client(ctx, companyRec)
+	client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	listOut, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
-		Bucket: aws.String(*companyRec.AwsBucketName),
+	var (
+		deletedCount int
+		deletedBytes int64
+		token        *string
+	)
+	for {
+		listOut, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(*companyRec.AwsBucketName),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: token,
+		})
+		if err != nil {
+			return deletedCount, deletedBytes, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if len(listOut.Contents) == 0 {
+			break
+		}
+
+		// A page holds at most 1000 keys, which is also the DeleteObjects limit.
+		objects := make([]types.ObjectIdentifier, 0, len(listOut.Contents))
+		var pageCount int
+		var pageBytes int64
+		for _, obj := range listOut.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
+			if obj.Key != nil && strings.HasSuffix(*obj.Key, "/") {
+				continue
+			}
+			pageCount++
+			if obj.Size != nil {
+				pageBytes += *obj.Size
+			}
+		}
+		_, err = client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(*companyRec.AwsBucketName),
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return deletedCount, deletedBytes, fmt.Errorf("failed to delete objects: %w", err)
+		}
+		deletedCount += pageCount
+		deletedBytes += pageBytes
+
+		if listOut.IsTruncated == nil || !*listOut.IsTruncated {
+			break
+		}
+		token = listOut.NextContinuationToken
+	}
+	return deletedCount, deletedBytes, nil
+}
+
+// ListObjects returns every object under prefix, following continuation tokens.
+func (s *s3Service) ListObjects(ctx context.Context, companyRec *company.Company, prefix string) ([]ObjectInfo, error) {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: companyRec.AwsBucketName,
 		Prefix: aws.String(prefix),
 	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			if obj.Key == nil {
+				continue
+			}
+			info := ObjectInfo{
+				Key:  *obj.Key,
+				ETag: strings.Trim(aws.ToString(obj.ETag), `"`),
+			}
+			if obj.Size != nil {
+				info.Size = *obj.Size
+			}
+			if obj.LastModified != nil {
+				info.LastModified = *obj.LastModified
+			}
+			objects = append(objects, info)
+		}
+	}
+
+	return objects, nil
+}
+
+// PrefixInUse reports whether any object exists under prefix.
+func (s *s3Service) PrefixInUse(ctx context.Context, companyRec *company.Company, prefix string) (bool, error) {
+	client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to list objects: %w", err)
+		return false, err
 	}
 
-	if len(listOut.Contents) == 0 {
-		return 0, 0, nil
+	out, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  companyRec.AwsBucketName,
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list objects: %w", err)
 	}
+	return len(out.Contents) > 0, nil
+}
 
-	mandatory_delete := len(listOut.Contents) >= 10
+// withPoolCredentials returns a copy of companyRec holding the pool's keys.
+func withPoolCredentials(companyRec *company.Company, pool *UploaderConfig) *company.Company {
+	admin := *companyRec
+	admin.AwsAccessKey = &pool.AwsAccessKey
+	admin.AwsSecretKey = &pool.AwsSecretKey
+	return &admin
+}
 
-	objects := make([]types.ObjectIdentifier, 0, len(listOut.Contents))
-	var totalBytes int64
+func (s *s3Service) ProvisionBucket(ctx context.Context, companyRec *company.Company, pool *UploaderConfig) (err error) {
+	admin := withPoolCredentials(companyRec, pool)
+	client, err := s.buildS3Client(ctx, admin)
+	if err != nil {
+		return err
+	}
+	bucket := companyRec.AwsBucketName
 
-	for _, obj := range listOut.Contents {
-		objects = append(objects, types.ObjectIdentifier{
-			Key: obj.Key,
-		})
-		if obj.Size != nil {
-			totalBytes += int64(*obj.Size)
+	input := &s3.CreateBucketInput{Bucket: bucket}
+	// us-east-1 is the default location and must not be sent as a constraint.
+	if region := *companyRec.AwsBucketRegion; region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
 		}
 	}
-	if mandatory_delete {
-		_, err = client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
-			Bucket: aws.String(*companyRec.AwsBucketName),
-			Delete: &types.Delete{
-				Objects: objects,
-				Quiet:   aws.Bool(true),
+	if _, err := client.CreateBucket(ctx, input); err != nil {
+		return fmt.Errorf("failed to create bucket: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if cleanupErr := s.DeprovisionBucket(ctx, admin, pool); cleanupErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to clean up: %w", cleanupErr))
+		}
+	}()
+
+	if _, err := client.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+		Bucket: bucket,
+		PublicAccessBlockConfiguration: &types.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			RestrictPublicBuckets: aws.Bool(true),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to block public access: %w", err)
+	}
+
+	if _, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: bucket,
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to enable versioning: %w", err)
+	}
+
+	if _, err := client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: bucket,
+		ServerSideEncryptionConfiguration: &types.ServerSideEncryptionConfiguration{
+			Rules: []types.ServerSideEncryptionRule{{
+				ApplyServerSideEncryptionByDefault: &types.ServerSideEncryptionByDefault{
+					SSEAlgorithm: types.ServerSideEncryptionAes256,
+				},
+			}},
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to enable default encryption: %w", err)
+	}
+
+	if len(s.corsOrigins) > 0 {
+		if _, err := client.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+			Bucket: bucket,
+			CORSConfiguration: &types.CORSConfiguration{
+				CORSRules: []types.CORSRule{{
+					AllowedMethods: []string{"GET", "PUT", "HEAD"},
+					AllowedOrigins: s.corsOrigins,
+					AllowedHeaders: []string{"*"},
+					ExposeHeaders:  []string{"ETag"},
+					MaxAgeSeconds:  aws.Int32(3600),
+				}},
 			},
-		})
-		if err != nil {
-			return 0, 0, fmt.Errorf("failed to delete objects: %w", err)
+		}); err != nil {
+			return fmt.Errorf("failed to configure CORS: %w", err)
 		}
 	}
-	return len(objects), totalBytes, nil
+
+	return s.createBucketUser(ctx, companyRec, admin)
 }
 
-func (s *s3Service) ListPrefixes(ctx context.Context, companyRec *company.Company, fullPrefix string, limit int, nextToken string) ([]string, *string, error) {
-	s3Client, err := buildS3Client(ctx, companyRec)
+func (s *s3Service) HeadObject(ctx context.Context, companyRec *company.Company, objectKey string) (*ObjectInfo, error) {
+	client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	input := &s3.ListObjectsV2Input{
-		Bucket:    companyRec.AwsBucketName,
-		Prefix:    aws.String(fullPrefix),
-		Delimiter: aws.String("/"), // Assuming you applied the fix from the previous advice
-		MaxKeys:   aws.Int32(int32(limit)),
+
+	out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: companyRec.AwsBucketName,
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to head object: %w", err)
 	}
 
-	// Only set ContinuationToken if the input token is provided and non-empty
-	if nextToken != "" {
-		input.ContinuationToken = aws.String(nextToken)
+	info := &ObjectInfo{
+		Key:          objectKey,
+		Size:         aws.ToInt64(out.ContentLength),
+		ETag:         strings.Trim(aws.ToString(out.ETag), `"`),
+		ContentType:  aws.ToString(out.ContentType),
+		StorageClass: string(out.StorageClass),
+		Restore:      parseRestore(aws.ToString(out.Restore)),
 	}
+	if out.LastModified != nil {
+		info.LastModified = *out.LastModified
+	}
+	return info, nil
+}
 
-	listOut, err := s3Client.ListObjectsV2(ctx, input)
+// parseRestore parses the x-amz-restore header, e.g.
+// `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`.
+func parseRestore(header string) *RestoreState {
+	if header == "" {
+		return nil
+	}
+	state := &RestoreState{Ongoing: strings.Contains(header, `ongoing-request="true"`)}
+	if _, rest, ok := strings.Cut(header, `expiry-date="`); ok {
+		if value, _, ok := strings.Cut(rest, `"`); ok {
+			if expiresAt, err := http.ParseTime(value); err == nil {
+				state.ExpiresAt = &expiresAt
+			}
+		}
+	}
+	return state
+}
+
+func (s *s3Service) GetObject(ctx context.Context, companyRec *company.Company, objectKey, byteRange string) (*ObjectReader, error) {
+	client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
-		fmt.Printf("DEBUG S3 Error: %v\n", err)
-		return nil, nil, fmt.Errorf("failed to list prefixes: %w", err)
+		return nil, err
 	}
 
-	var prefixes []string
-	for _, obj := range listOut.Contents {
-		if obj.Key != nil {
-			prefix := filepath.Dir(*obj.Key)
-			if !contains(prefixes, prefix) {
-				prefixes = append(prefixes, prefix)
-			}
+	input := &s3.GetObjectInput{
+		Bucket: companyRec.AwsBucketName,
+		Key:    aws.String(objectKey),
+	}
+	if byteRange != "" {
+		input.Range = aws.String(byteRange)
+	}
+	out, err := client.GetObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, nil
+		}
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidRange" {
+			return nil, ErrInvalidRange
 		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return &ObjectReader{
+		Body:          out.Body,
+		ContentLength: aws.ToInt64(out.ContentLength),
+		ContentRange:  aws.ToString(out.ContentRange),
+		ContentType:   aws.ToString(out.ContentType),
+	}, nil
+}
+
+func (s *s3Service) CopyObject(ctx context.Context, source, target *company.Company, objectKey string) error {
+	targetClient, err := s.buildS3Client(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	sourceAccessKey, _, err := s.credentials(source)
+	if err != nil {
+		return err
+	}
+	targetAccessKey, _, err := s.credentials(target)
+	if err != nil {
+		return err
 	}
 
-	return prefixes, listOut.NextContinuationToken, nil
+	if sourceAccessKey == targetAccessKey {
+		return copyWithin(ctx, targetClient, *target.AwsBucketName, objectKey, *source.AwsBucketName, objectKey, "")
+	}
+
+	// Different accounts: stream the object through this process.
+	sourceClient, err := s.buildS3Client(ctx, source)
+	if err != nil {
+		return err
+	}
+	obj, err := sourceClient.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: source.AwsBucketName,
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read source object: %w", err)
+	}
+	defer obj.Body.Close()
+
+	return streamObject(ctx, targetClient, *target.AwsBucketName, objectKey, obj.Body, aws.ToInt64(obj.ContentLength), obj.ContentType)
 }
 
-func (s *s3Service) ListFilesInFolder(ctx context.Context, companyRec *company.Company, folderPrefix string, limit int, nextToken string) ([]string, *string, error) {
-	client, err := buildS3Client(ctx, companyRec)
+func (s *s3Service) CopyObjectWithin(ctx context.Context, companyRec *company.Company, sourceKey, targetKey, storageClass string) error {
+	client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
+	return copyWithin(ctx, client, *companyRec.AwsBucketName, targetKey, *companyRec.AwsBucketName, sourceKey, storageClass)
+}
 
-	// Ensure prefix ends with '/' so we list objects inside the folder
-	if folderPrefix != "" && folderPrefix[len(folderPrefix)-1] != '/' {
-		folderPrefix = folderPrefix + "/"
+func (s *s3Service) PutFolderPlaceholder(ctx context.Context, companyRec *company.Company, folderKey string) error {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return err
 	}
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        companyRec.AwsBucketName,
+		Key:           aws.String(folderKey),
+		Body:          strings.NewReader(""),
+		ContentLength: aws.Int64(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create folder placeholder: %w", err)
+	}
+	return nil
+}
 
-	input := &s3.ListObjectsV2Input{
+func (s *s3Service) ObjectLockEnabled(ctx context.Context, companyRec *company.Company) (bool, error) {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return false, err
+	}
+	out, err := client.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{
+		Bucket: companyRec.AwsBucketName,
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ObjectLockConfigurationNotFoundError" {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get object lock configuration: %w", err)
+	}
+	return out.ObjectLockConfiguration != nil &&
+		out.ObjectLockConfiguration.ObjectLockEnabled == types.ObjectLockEnabledEnabled, nil
+}
+
+func (s *s3Service) SetLegalHold(ctx context.Context, companyRec *company.Company, objectKey string, on bool) error {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return err
+	}
+	status := types.ObjectLockLegalHoldStatusOff
+	if on {
+		status = types.ObjectLockLegalHoldStatusOn
+	}
+	_, err = client.PutObjectLegalHold(ctx, &s3.PutObjectLegalHoldInput{
 		Bucket:    companyRec.AwsBucketName,
-		Prefix:    aws.String(folderPrefix),
-		Delimiter: aws.String("/"), // ensures subfolders appear in CommonPrefixes, contents are files directly under prefix
-		MaxKeys:   aws.Int32(int32(limit)),
+		Key:       aws.String(objectKey),
+		LegalHold: &types.ObjectLockLegalHold{Status: status},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set legal hold: %w", err)
 	}
+	return nil
+}
 
-	if nextToken != "" {
-		input.ContinuationToken = aws.String(nextToken)
+func (s *s3Service) RestoreObject(ctx context.Context, companyRec *company.Company, objectKey string, days int32, tier string) error {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return err
 	}
+	_, err = client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: companyRec.AwsBucketName,
+		Key:    aws.String(objectKey),
+		RestoreRequest: &types.RestoreRequest{
+			Days:                 aws.Int32(days),
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: types.Tier(tier)},
+		},
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress" {
+			return ErrRestoreInProgress
+		}
+		return fmt.Errorf("failed to restore object: %w", err)
+	}
+	return nil
+}
+
+// transitionRulePrefix starts the IDs of the lifecycle rules written for
+// transition rules.
+const transitionRulePrefix = "transition-"
 
-	out, err := client.ListObjectsV2(ctx, input)
+// PutTransitionRules moves current and noncurrent versions under the prefix
+// of every transition rule to its storage class after its days. The rules
+// of the bucket lifecycle configuration outside the company's key prefix,
+// or not written here, are kept; the configuration is removed when no rules
+// remain.
+func (s *s3Service) PutTransitionRules(ctx context.Context, companyRec *company.Company, rules []retention.Rule) error {
+	client, err := s.buildS3Client(ctx, companyRec)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to list files: %w", err)
+		return err
 	}
 
-	var files []string
-	for _, obj := range out.Contents {
-		if obj.Key == nil {
+	var lifecycleRules []types.LifecycleRule
+	for _, rule := range rules {
+		if rule.RuleType != retention.RuleTransition || rule.StorageClass == nil {
 			continue
 		}
-		k := *obj.Key
-		// skip folder placeholder objects and the folder key itself
-		if k == folderPrefix {
-			continue
+		class := *rule.StorageClass
+		lifecycleRules = append(lifecycleRules, types.LifecycleRule{
+			ID:     aws.String(transitionRulePrefix + rule.ID),
+			Status: types.ExpirationStatusEnabled,
+			Filter: &types.LifecycleRuleFilter{
+				Prefix: aws.String(filemeta.FolderKeyPrefix(companyRec.CompanySlug, rule.Prefix)),
+			},
+			Transitions: []types.Transition{{
+				Days:         aws.Int32(int32(rule.Days)),
+				StorageClass: types.TransitionStorageClass(class),
+			}},
+			NoncurrentVersionTransitions: []types.NoncurrentVersionTransition{{
+				NoncurrentDays: aws.Int32(int32(rule.Days)),
+				StorageClass:   types.TransitionStorageClass(class),
+			}},
+		})
+	}
+
+	// S3 only replaces the whole configuration. Companies sharing a bucket
+	// are serialized within this process.
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	current, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: companyRec.AwsBucketName,
+	})
+	var existing []types.LifecycleRule
+	var minObjectSize types.TransitionDefaultMinimumObjectSize
+	if err != nil {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("failed to get bucket lifecycle: %w", err)
 		}
-		if len(k) > 0 && k[len(k)-1] == '/' {
+	} else {
+		existing = current.Rules
+		minObjectSize = current.TransitionDefaultMinimumObjectSize
+	}
+	merged := mergeTransitionRules(existing, companyRec.CompanySlug+"/", lifecycleRules)
+
+	if len(merged) == 0 {
+		if len(existing) == 0 {
+			return nil
+		}
+		if _, err := client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: companyRec.AwsBucketName,
+		}); err != nil {
+			return fmt.Errorf("failed to delete bucket lifecycle: %w", err)
+		}
+		return nil
+	}
+	if _, err := client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                             companyRec.AwsBucketName,
+		LifecycleConfiguration:             &types.BucketLifecycleConfiguration{Rules: merged},
+		TransitionDefaultMinimumObjectSize: minObjectSize,
+	}); err != nil {
+		return fmt.Errorf("failed to put bucket lifecycle: %w", err)
+	}
+	return nil
+}
+
+// mergeTransitionRules returns the existing lifecycle rules with the
+// transition rules under keyPrefix replaced by rules.
+func mergeTransitionRules(existing []types.LifecycleRule, keyPrefix string, rules []types.LifecycleRule) []types.LifecycleRule {
+	var merged []types.LifecycleRule
+	for _, rule := range existing {
+		if strings.HasPrefix(aws.ToString(rule.ID), transitionRulePrefix) &&
+			rule.Filter != nil && strings.HasPrefix(aws.ToString(rule.Filter.Prefix), keyPrefix) {
 			continue
 		}
-		files = append(files, k)
+		merged = append(merged, rule)
+	}
+	return append(merged, rules...)
+}
+
+// copySource URL-encodes "bucket/key" for CopyObject, keeping the slashes.
+func copySource(bucket, objectKey string) string {
+	segments := strings.Split(bucket+"/"+objectKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (s *s3Service) CreateMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey string, opts UploadOptions) (string, error) {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return "", err
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:             companyRec.AwsBucketName,
+		Key:                aws.String(objectKey),
+		StorageClass:       types.StorageClass(opts.StorageClass),
+		ContentType:        optionalHeader(opts.ContentType),
+		CacheControl:       optionalHeader(opts.CacheControl),
+		ContentDisposition: optionalHeader(opts.ContentDisposition),
+	}
+	if lock := opts.Lock; lock != nil {
+		input.ObjectLockMode = types.ObjectLockMode(lock.Mode)
+		input.ObjectLockRetainUntilDate = aws.Time(lock.RetainUntil)
+	}
+	out, err := client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+func (s *s3Service) UploadPart(ctx context.Context, companyRec *company.Company, objectKey, uploadID string, partNumber int32, data []byte) error {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        companyRec.AwsBucketName,
+		Key:           aws.String(objectKey),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	return nil
+}
+
+func (s *s3Service) CompleteMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey, uploadID string) (string, error) {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []types.CompletedPart
+	paginator := s3.NewListPartsPaginator(client, &s3.ListPartsInput{
+		Bucket:   companyRec.AwsBucketName,
+		Key:      aws.String(objectKey),
+		UploadId: aws.String(uploadID),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list parts: %w", err)
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, types.CompletedPart{ETag: part.ETag, PartNumber: part.PartNumber})
+		}
 	}
 
-	return files, out.NextContinuationToken, nil
+	out, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          companyRec.AwsBucketName,
+		Key:             aws.String(objectKey),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		var noSuchUpload *types.NoSuchUpload
+		if errors.As(err, &noSuchUpload) {
+			return "", ErrNoSuchUpload
+		}
+		return "", fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return strings.Trim(aws.ToString(out.ETag), `"`), nil
 }
 
-func contains(slice []string, item string) bool {
-	return slices.Contains(slice, item)
+func (s *s3Service) AbortMultipartUpload(ctx context.Context, companyRec *company.Company, objectKey, uploadID string) error {
+	client, err := s.buildS3Client(ctx, companyRec)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   companyRec.AwsBucketName,
+		Key:      aws.String(objectKey),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		var noSuchUpload *types.NoSuchUpload
+		if errors.As(err, &noSuchUpload) {
+			return nil
+		}
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
 }
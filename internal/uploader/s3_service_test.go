@@ -0,0 +1,61 @@
+package uploader
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestMergeTransitionRules(t *testing.T) {
+	rule := func(id, prefix string) types.LifecycleRule {
+		return types.LifecycleRule{ID: aws.String(id), Filter: &types.LifecycleRuleFilter{Prefix: aws.String(prefix)}}
+	}
+
+	tests := []struct {
+		name     string
+		existing []types.LifecycleRule
+		rules    []types.LifecycleRule
+		want     []string // rule IDs
+	}{
+		{
+			name:  "empty bucket",
+			rules: []types.LifecycleRule{rule("transition-2", "acme/a/")},
+			want:  []string{"transition-2"},
+		},
+		{
+			name:     "company rules are replaced",
+			existing: []types.LifecycleRule{rule("transition-1", "acme/a/"), rule("transition-2", "acme/b/")},
+			rules:    []types.LifecycleRule{rule("transition-2", "acme/b/")},
+			want:     []string{"transition-2"},
+		},
+		{
+			name:     "other companies keep their rules",
+			existing: []types.LifecycleRule{rule("transition-1", "acme-2/a/"), rule("transition-3", "globex/a/")},
+			rules:    []types.LifecycleRule{rule("transition-2", "acme/a/")},
+			want:     []string{"transition-1", "transition-3", "transition-2"},
+		},
+		{
+			name:     "operator rules under the company prefix are kept",
+			existing: []types.LifecycleRule{rule("abort-multipart", "acme/"), {ID: aws.String("expire-logs"), Prefix: aws.String("acme/logs/")}},
+			want:     []string{"abort-multipart", "expire-logs"},
+		},
+		{
+			name:     "removing the last company rule",
+			existing: []types.LifecycleRule{rule("transition-1", "acme/a/")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range mergeTransitionRules(tt.existing, "acme/", tt.rules) {
+				got = append(got, aws.ToString(r.ID))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package uploader
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+type FileSearchItem struct {
+	ID          string   `json:"id"`
+	CreatedAt   string   `json:"created_at"`
+	FileName    *string  `json:"file_name,omitempty"`
+	FileSize    int64    `json:"file_size"`
+	FileKey     string   `json:"file_key"`
+	LocTag      *string  `json:"loc_tag,omitempty"`
+	FileTxnType int16    `json:"file_txn_type"`
+	FileTxnMeta *string  `json:"file_txn_meta,omitempty"`
+	Tags        []string `json:"tags"`
+}
+
+type SearchFilesResponse struct {
+	Items []FileSearchItem `json:"items"`
+	// NextCursor fetches the next page; empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// parseSearchQuery reads the search filters from the URL query.
+func parseSearchQuery(r *http.Request) (filemeta.SearchQuery, string) {
+	v := r.URL.Query()
+	q := filemeta.SearchQuery{
+		NameContains: v.Get("q"),
+		NamePrefix:   v.Get("name_prefix"),
+		Recursive:    v.Get("recursive") == "true",
+		Sort:         v.Get("sort"),
+		Descending:   v.Get("order") == "desc",
+		Limit:        defaultSearchLimit,
+	}
+	if o := v.Get("order"); o != "" && o != "asc" && o != "desc" {
+		return q, "order must be asc or desc"
+	}
+	if q.Sort == "" {
+		q.Sort = filemeta.SortCreatedAt
+	}
+
+	if s := v.Get("txn_type"); s != "" {
+		txnType, err := strconv.ParseInt(s, 10, 16)
+		if err != nil {
+			return q, "txn_type must be a number"
+		}
+		q.TxnType = int16(txnType)
+	}
+	for name, target := range map[string]**int64{"min_size": &q.MinSize, "max_size": &q.MaxSize} {
+		if s := v.Get(name); s != "" {
+			size, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || size < 0 {
+				return q, name + " must be a non-negative number"
+			}
+			*target = &size
+		}
+	}
+	for name, target := range map[string]**time.Time{"created_from": &q.CreatedFrom, "created_to": &q.CreatedTo} {
+		if s := v.Get(name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return q, name + " must be an RFC3339 timestamp"
+			}
+			*target = &t
+		}
+	}
+	if s := v.Get("tags"); s != "" {
+		tags, err := filemeta.NormalizeTags(strings.Split(s, ","))
+		if err != nil {
+			return q, err.Error()
+		}
+		q.Tags = tags
+	}
+	if s := v.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			return q, "limit must be between 1 and " + strconv.Itoa(maxSearchLimit)
+		}
+		q.Limit = limit
+	}
+	if s := v.Get("cursor"); s != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(s)
+		var cursor filemeta.SearchCursor
+		if err != nil || json.Unmarshal(raw, &cursor) != nil {
+			return q, "invalid cursor"
+		}
+		q.After = &cursor
+	}
+	return q, ""
+}
+
+// SearchFiles godoc
+// @Summary      Search the calling company's files
+// @Description  Searches the current files (deleted and replaced uploads and files in the trash are excluded). Pass next_cursor back as cursor with the same filters to get the next page.
+// @Tags         uploader
+// @Produce      json
+// @Param        X-API-Key     header  string  true   "Company API key"
+// @Param        q             query   string  false  "File name contains"
+// @Param        name_prefix   query   string  false  "File name starts with"
+// @Param        loc_tag       query   string  false  "Folder"
+// @Param        recursive     query   bool    false  "Include sub folders of loc_tag"
+// @Param        txn_type      query   int     false  "File transaction type"
+// @Param        min_size      query   int     false  "Minimum size in bytes"
+// @Param        max_size      query   int     false  "Maximum size in bytes"
+// @Param        created_from  query   string  false  "Created at or after (RFC3339)"
+// @Param        created_to    query   string  false  "Created before (RFC3339)"
+// @Param        tags          query   string  false  "Comma separated tags, all must match"
+// @Param        sort          query   string  false  "created_at (default), file_name or file_size"
+// @Param        order         query   string  false  "asc (default) or desc"
+// @Param        limit         query   int     false  "Page size (default 50, max 200)"
+// @Param        cursor        query   string  false  "next_cursor of the previous page"
+// @Success      200           {object}  SearchFilesResponse
+// @Failure      400           {string}  string "invalid request"
+// @Failure      401           {string}  string "unauthorized"
+// @Failure      403           {string}  string "company suspended or outside its subscription"
+// @Failure      500           {string}  string "internal error"
+// @Router       /uploader/files/search [get]
+func (h *Handler) SearchFiles(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+
+	q, msg := parseSearchQuery(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	var ok bool
+	if q.LocTag, ok = h.locationFilter(w, companyRec.ID, "loc_tag", r.URL.Query().Get("loc_tag")); !ok {
+		return
+	}
+	pageSize := q.Limit
+	q.Limit++ // one extra row tells whether there is a next page
+
+	files, err := h.fileMetaRepo.Search(companyRec.ID, q)
+	if err != nil {
+		http.Error(w, "failed to search files", http.StatusInternalServerError)
+		return
+	}
+
+	var resp SearchFilesResponse
+	if len(files) > pageSize {
+		files = files[:pageSize]
+		raw, _ := json.Marshal(filemeta.CursorFor(files[pageSize-1], q.Sort))
+		resp.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		ids = append(ids, f.FileMetaID)
+	}
+	tags, err := h.fileMetaRepo.TagsByFileIDs(ids)
+	if err != nil {
+		http.Error(w, "failed to load tags", http.StatusInternalServerError)
+		return
+	}
+
+	resp.Items = make([]FileSearchItem, 0, len(files))
+	for _, f := range files {
+		itemTags := tags[f.FileMetaID]
+		if itemTags == nil {
+			itemTags = []string{}
+		}
+		resp.Items = append(resp.Items, FileSearchItem{
+			ID:          f.FileMetaID,
+			CreatedAt:   f.CreatedAt.Format(time.RFC3339Nano),
+			FileName:    f.FileName,
+			FileSize:    f.FileSize,
+			FileKey:     f.FileKey,
+			LocTag:      f.LocTag,
+			FileTxnType: f.FileTxnType,
+			FileTxnMeta: f.FileTxnMeta,
+			Tags:        itemTags,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,215 @@
+package uploader
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+)
+
+const (
+	defaultRestoreDays = 7
+	maxRestoreDays     = 30
+
+	restoreTierStandard  = "Standard"
+	restoreTierBulk      = "Bulk"
+	restoreTierExpedited = "Expedited"
+)
+
+// ParseUploadStorageClass returns the storage class of an upload, empty for
+// STANDARD, and whether s names one uploads may choose.
+func ParseUploadStorageClass(s string) (string, bool) {
+	if s == "" {
+		return "", true
+	}
+	class, ok := filemeta.ParseStorageClass(s)
+	if !ok {
+		return "", false
+	}
+	if class == filemeta.StorageClassStandard {
+		return "", true
+	}
+	return class, true
+}
+
+// RestoreFileRequest asks for a temporary readable copy of an archived file.
+type RestoreFileRequest struct {
+	Days int    `json:"days,omitempty"` // 1 to 30, default 7
+	Tier string `json:"tier,omitempty"` // Standard (default), Bulk or Expedited
+}
+
+type RestoreStatusResponse struct {
+	FileID       string `json:"file_id"`
+	StorageClass string `json:"storage_class"`
+	Archived     bool   `json:"archived"`
+	// RestoreRequired is set while the file cannot be downloaded.
+	RestoreRequired   bool    `json:"restore_required"`
+	RestoreInProgress bool    `json:"restore_in_progress"`
+	RestoredUntil     *string `json:"restored_until,omitempty"`
+}
+
+func storageClassName(class string) string {
+	if class == "" {
+		return filemeta.StorageClassStandard
+	}
+	return class
+}
+
+func toRestoreStatus(fileID string, info ObjectInfo) RestoreStatusResponse {
+	resp := RestoreStatusResponse{
+		FileID:          fileID,
+		StorageClass:    storageClassName(info.StorageClass),
+		Archived:        filemeta.Archived(info.StorageClass),
+		RestoreRequired: !info.Readable(),
+	}
+	if info.Restore != nil {
+		resp.RestoreInProgress = info.Restore.Ongoing
+		if info.Restore.ExpiresAt != nil {
+			restoredUntil := info.Restore.ExpiresAt.Format(time.RFC3339)
+			resp.RestoredUntil = &restoredUntil
+		}
+	}
+	return resp
+}
+
+// WriteArchived answers 409 for a download of an archived object that has
+// no restored copy.
+func WriteArchived(w http.ResponseWriter, objectKey string, info ObjectInfo) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":               "archived",
+		"file_key":            objectKey,
+		"storage_class":       info.StorageClass,
+		"restore_required":    true,
+		"restore_in_progress": info.Restore != nil && info.Restore.Ongoing,
+	})
+}
+
+// storedObject returns the live stats of file's object. It answers 410 if
+// the object is missing and returns nil if a response was written.
+func (h *Handler) storedObject(w http.ResponseWriter, r *http.Request, companyRec *company.Company, file *filemeta.File) *ObjectInfo {
+	info, err := h.s3Service.HeadObject(r.Context(), companyRec, file.FileKey)
+	if err != nil {
+		http.Error(w, "failed to read object stats", http.StatusInternalServerError)
+		return nil
+	}
+	if info == nil {
+		http.Error(w, "object is missing from storage", http.StatusGone)
+		return nil
+	}
+	return info
+}
+
+// RestoreFile godoc
+// @Summary      Restore an archived file
+// @Description  Requests a temporary readable copy of a file in GLACIER or DEEP_ARCHIVE, kept for days once the restore finished.
+// @Description  Restores take minutes (Expedited) to 48 hours (DEEP_ARCHIVE Bulk); poll the restore status. Repeating a finished restore extends it.
+// @Tags         uploader
+// @Accept       json
+// @Produce      json
+// @Param        X-API-Key  header    string              true   "Company API key"
+// @Param        id         path      string              true   "File ID"
+// @Param        body       body      RestoreFileRequest  false  "Restore options"
+// @Success      202        {object}  RestoreStatusResponse
+// @Failure      400        {string}  string "invalid request"
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found"
+// @Failure      409        {string}  string "file is not archived"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/{id}/restore [post]
+func (h *Handler) RestoreFile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	companyRec := company.FromContext(ctx)
+	file := h.currentFile(w, r, companyRec)
+	if file == nil {
+		return
+	}
+
+	var req RestoreFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Days == 0 {
+		req.Days = defaultRestoreDays
+	}
+	if req.Days < 1 || req.Days > maxRestoreDays {
+		http.Error(w, "days must be between 1 and 30", http.StatusBadRequest)
+		return
+	}
+	var tier string
+	switch strings.ToLower(req.Tier) {
+	case "", "standard":
+		tier = restoreTierStandard
+	case "bulk":
+		tier = restoreTierBulk
+	case "expedited":
+		tier = restoreTierExpedited
+	default:
+		http.Error(w, "tier must be Standard, Bulk or Expedited", http.StatusBadRequest)
+		return
+	}
+
+	info := h.storedObject(w, r, companyRec, file)
+	if info == nil {
+		return
+	}
+	if !filemeta.Archived(info.StorageClass) {
+		http.Error(w, "file is not archived", http.StatusConflict)
+		return
+	}
+	if info.StorageClass == filemeta.StorageClassDeepArchive && tier == restoreTierExpedited {
+		http.Error(w, "DEEP_ARCHIVE files cannot be restored with the Expedited tier", http.StatusBadRequest)
+		return
+	}
+
+	err := h.s3Service.RestoreObject(ctx, companyRec, file.FileKey, int32(req.Days), tier)
+	if err != nil && !errors.Is(err, ErrRestoreInProgress) {
+		http.Error(w, "failed to request restore", http.StatusInternalServerError)
+		return
+	}
+	if info = h.storedObject(w, r, companyRec, file); info == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(toRestoreStatus(file.FileMetaID, *info))
+}
+
+// GetRestoreStatus godoc
+// @Summary      Get the restore status of a file
+// @Description  Reports the current storage class of the file and whether it must be restored before it can be downloaded.
+// @Tags         uploader
+// @Produce      json
+// @Param        X-API-Key  header    string  true  "Company API key"
+// @Param        id         path      string  true  "File ID"
+// @Success      200        {object}  RestoreStatusResponse
+// @Failure      401        {string}  string "unauthorized"
+// @Failure      403        {string}  string "company suspended or outside its subscription"
+// @Failure      404        {string}  string "file not found"
+// @Failure      410        {string}  string "file was deleted or replaced"
+// @Failure      500        {string}  string "internal error"
+// @Router       /uploader/files/{id}/restore [get]
+func (h *Handler) GetRestoreStatus(w http.ResponseWriter, r *http.Request) {
+	companyRec := company.FromContext(r.Context())
+	file := h.currentFile(w, r, companyRec)
+	if file == nil {
+		return
+	}
+	info := h.storedObject(w, r, companyRec, file)
+	if info == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(toRestoreStatus(file.FileMetaID, *info))
+}
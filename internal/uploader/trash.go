@@ -0,0 +1,157 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"shreshtasmg.in/jupyter/internal/company"
+	"shreshtasmg.in/jupyter/internal/filemeta"
+	"shreshtasmg.in/jupyter/internal/folder"
+	"shreshtasmg.in/jupyter/internal/retention"
+	"shreshtasmg.in/jupyter/internal/utils"
+)
+
+// TrashRetention is how long folders stay in the trash before the trash
+// purger deletes them.
+const TrashRetention = 30 * 24 * time.Hour
+
+// purgeBatch is the number of trashed folders loaded at a time.
+const purgeBatch = 500
+
+// deleteFolderObjects deletes every object under locTag, records the
+// folder delete in files_meta and refunds the deleted bytes.
+func deleteFolderObjects(ctx context.Context, storage S3Service, fileMetaRepo filemeta.Repository, companyRepo company.Repository, companyRec *company.Company, locTag string, txnMeta *string) (int, int64, error) {
+	deletedCount, deletedBytes, err := storage.DeletePrefix(ctx, companyRec, filemeta.FolderKeyPrefix(companyRec.CompanySlug, locTag))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete files from storage: %w", err)
+	}
+
+	// Record a single files_meta entry, representing this bulk delete (file_txn_type=3)
+	// file_key stores the folder prefix, file_size = deletedBytes
+	meta := &filemeta.FileMeta{
+		ID:          utils.GenerateID(),
+		FileName:    nil,
+		FileSize:    deletedBytes,
+		FileKey:     locTag,
+		FileTxnType: filemeta.TxnFolderDelete,
+		FileTxnMeta: txnMeta,
+		CompanyID:   &companyRec.ID,
+	}
+	if err := fileMetaRepo.Create(meta); err != nil {
+		return 0, 0, fmt.Errorf("failed to create folder delete meta: %w", err)
+	}
+
+	if err := companyRepo.DecrementUsedQuota(companyRec.ID, deletedBytes); err != nil {
+		return 0, 0, fmt.Errorf("failed to update quota: %w", err)
+	}
+	return deletedCount, deletedBytes, nil
+}
+
+type PurgeResult struct {
+	Purged       int
+	DeletedCount int
+	DeletedBytes int64
+	Kept         int // protected by Object Lock or a retention rule
+	Failed       int
+}
+
+// TrashPurger permanently deletes the folders that have been in the trash
+// for TrashRetention, with their files, and refunds the quota.
+type TrashPurger struct {
+	folderRepo    folder.Repository
+	companyRepo   company.Repository
+	fileMetaRepo  filemeta.Repository
+	retentionRepo retention.Repository
+	storage       S3Service
+}
+
+func NewTrashPurger(folderRepo folder.Repository, companyRepo company.Repository, fileMetaRepo filemeta.Repository, retentionRepo retention.Repository, storage S3Service) *TrashPurger {
+	return &TrashPurger{folderRepo: folderRepo, companyRepo: companyRepo, fileMetaRepo: fileMetaRepo, retentionRepo: retentionRepo, storage: storage}
+}
+
+func (p *TrashPurger) Purge(ctx context.Context, now time.Time) (PurgeResult, error) {
+	var result PurgeResult
+	companies := make(map[string]*company.Company)
+	var afterCompanyID, afterPath string
+	// handled holds the folders of the current company purged or kept so
+	// far; their sub folders go with them.
+	var handled []string
+	for {
+		folders, err := p.folderRepo.ListTrashedBefore(now.Add(-TrashRetention), afterCompanyID, afterPath, purgeBatch)
+		if err != nil {
+			return result, fmt.Errorf("failed to list trashed folders: %w", err)
+		}
+		for _, f := range folders {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			if f.CompanyID != afterCompanyID {
+				handled = nil
+			}
+			afterCompanyID, afterPath = f.CompanyID, f.Path
+			if folder.WithinAny(f.Path, handled) {
+				continue
+			}
+			handled = append(handled, f.Path)
+
+			companyRec, ok := companies[f.CompanyID]
+			if !ok {
+				companyRec, err = p.companyRepo.GetByID(f.CompanyID)
+				if err != nil {
+					return result, err
+				}
+				companies[f.CompanyID] = companyRec
+			}
+			if companyRec == nil {
+				continue
+			}
+			if err := p.purge(ctx, companyRec, f, now, &result); err != nil {
+				log.Printf("trash purge of folder %s failed: %v", f.ID, err)
+				result.Failed++
+			}
+		}
+		if len(folders) < purgeBatch {
+			return result, nil
+		}
+	}
+}
+
+func (p *TrashPurger) purge(ctx context.Context, companyRec *company.Company, f folder.Folder, now time.Time, result *PurgeResult) error {
+	files, err := p.fileMetaRepo.ListFiles(companyRec.ID, filemeta.FolderKeyPrefix(companyRec.CompanySlug, f.Path))
+	if err != nil {
+		return err
+	}
+	block, err := retentionBlock(p.retentionRepo, companyRec, files, now)
+	if err != nil {
+		return err
+	}
+	if block != nil {
+		// Tried again on a later purge.
+		result.Kept++
+		return nil
+	}
+
+	// The folder may have been restored since it was listed.
+	current, err := p.folderRepo.GetByID(f.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.TrashedAt == nil || !current.TrashedAt.Equal(*f.TrashedAt) {
+		return nil
+	}
+
+	txnMeta := "trash purge"
+	deletedCount, deletedBytes, err := deleteFolderObjects(ctx, p.storage, p.fileMetaRepo, p.companyRepo, companyRec, f.Path, &txnMeta)
+	if err != nil {
+		return err
+	}
+	if err := p.folderRepo.DeleteTree(companyRec.ID, f.Path); err != nil {
+		return fmt.Errorf("failed to delete folders: %w", err)
+	}
+	result.Purged++
+	result.DeletedCount += deletedCount
+	result.DeletedBytes += deletedBytes
+	return nil
+}
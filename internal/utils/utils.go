@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"strings"
 	"time"
@@ -42,6 +43,15 @@ func GenerateID() string {
 	return hex.EncodeToString(bytes)
 }
 
+// GenerateToken returns an opaque URL-safe token with n random bytes.
+func GenerateToken(n int) (string, error) {
+	randomBytes := make([]byte, n)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
 func GetShortDate(currTime time.Time) string {
 	shortDateLayout := "Mon 02-Jan-06 2006"
 	return currTime.Format(shortDateLayout)